@@ -42,13 +42,32 @@ func run() int {
 	scrapeCtx, cancelScrapes := context.WithCancel(context.Background())
 	defer cancelScrapes()
 
-	// prometheus set up
-	tdarrCollector, err := collector.NewTdarrCollector(scrapeCtx, userConfig)
+	// prometheus set up: one collector per Tdarr instance (the primary url plus
+	// any config file targets), held by the reloader so SIGHUP can swap them.
+	// Each carries its own tdarr_instance const label, so they share the
+	// registry without colliding, as do the HTTP client metrics each collector
+	// registers. Exporter-level series (build info, promhttp counters, reload
+	// gauges) are labeled with the first instance at startup: the primary url,
+	// or the first target without one.
+	exporterInstance := userConfig.TargetConfigs()[0].InstanceName
+	registry := buildRegistry(exporterInstance)
+	var collectorOpts []collector.TdarrCollectorOption
+	if userConfig.StateFile != "" {
+		store, err := collector.OpenPieCacheStore(userConfig.StateFile)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to open state file")
+			return 1
+		}
+		collectorOpts = append(collectorOpts, collector.WithStateStore(store))
+	}
+	reload, err := newReloader(scrapeCtx, userConfig, func() (config.Config, error) {
+		return config.Load(os.Args[1:], os.Getenv)
+	}, collector.NewTdarrCollector, registry, collectorOpts...)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to create Tdarr collector")
 		return 1
 	}
-	registry := buildRegistry(userConfig.InstanceName, tdarrCollector)
+	instanceRegisterer(registry, exporterInstance).MustRegister(reload.metrics()...)
 
 	// http server
 	stopHttpChan := make(chan bool)
@@ -58,23 +77,44 @@ func run() int {
 	errHttpChan := make(chan error, 2)
 	httpWg := &sync.WaitGroup{}
 	httpServerConfig := server.HttpServerConfig{
-		TdarrInstance:   userConfig.InstanceName,
+		TdarrInstance:   exporterInstance,
 		PrometheusPort:  userConfig.PrometheusPort,
 		PrometheusPath:  userConfig.PrometheusPath,
 		ListenAddress:   userConfig.ListenAddress,
 		GracefulTimeout: 30 * time.Second,
+		// The Tdarr collectors are gathered per request rather than from the
+		// registry, so each scrape runs under the deadline Prometheus sends.
+		Scrape:              reload.targets,
+		ScrapeTimeoutOffset: userConfig.ScrapeTimeoutOffset,
+	}
+	if userConfig.ProbeEnabled {
+		reload.probes = collector.NewTdarrProbePool(scrapeCtx, userConfig)
+		httpServerConfig.Probes = reload.probes
+	}
+	if userConfig.ReloadEnabled {
+		httpServerConfig.Reload = reload.Reload
+	}
+	if userConfig.DebugCacheEnabled {
+		httpServerConfig.DebugCache = func() any { return reload.targets.CacheViews() }
 	}
 	httpWg.Add(1)
 	go server.ServeHttp(httpWg, registry, httpServerConfig, stopHttpChan, errHttpChan)
 
+	// SIGHUP reloads the configuration (the error is already logged and
+	// reflected in the reload gauge, so it is dropped here).
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			_ = reload.Reload()
+		}
+	}()
+
 	// graceful shutdown
 	quitServer := make(chan os.Signal, 1)
-	// SIGHUP has nothing to reload here (no config file to re-read), so it is
-	// treated the same as the other signals below: a graceful shutdown, exit 0.
 	signal.Notify(
 		quitServer,
 		os.Interrupt,
-		syscall.SIGHUP,
 		syscall.SIGINT,
 		syscall.SIGQUIT,
 		syscall.SIGTERM,
@@ -95,22 +135,27 @@ func run() int {
 	return exitCode
 }
 
-// buildRegistry assembles the Prometheus registry: the Tdarr collector, the
-// standard Go runtime + process collectors, and the build-info metric registered
-// through an instance-labeled registerer so tdarr_exporter_build_info carries
-// tdarr_instance like the exporter's own metrics.
-func buildRegistry(instanceName string, tdarrCollector prometheus.Collector) *prometheus.Registry {
+// buildRegistry assembles the Prometheus registry: the standard Go runtime +
+// process collectors, and the build-info metric plus any exporterCollectors
+// registered through an instance-labeled registerer so they carry
+// tdarr_instance like the exporter's own metrics. The Tdarr collectors are not
+// in it: the metrics handler gathers them with each scrape's deadline.
+func buildRegistry(instanceName string, exporterCollectors ...prometheus.Collector) *prometheus.Registry {
 	registry := prometheus.NewRegistry()
-	registry.MustRegister(tdarrCollector)
 	registry.MustRegister(collectors.NewGoCollector())
 	registry.MustRegister(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
-	prometheus.WrapRegistererWith(
-		prometheus.Labels{"tdarr_instance": instanceName},
-		registry,
-	).MustRegister(versioncollector.NewCollector("tdarr_exporter"))
+	reg := instanceRegisterer(registry, instanceName)
+	reg.MustRegister(versioncollector.NewCollector("tdarr_exporter"))
+	reg.MustRegister(exporterCollectors...)
 	return registry
 }
 
+// instanceRegisterer wraps registry so the collectors registered through it
+// carry tdarr_instance.
+func instanceRegisterer(registry *prometheus.Registry, instanceName string) prometheus.Registerer {
+	return prometheus.WrapRegistererWith(prometheus.Labels{"tdarr_instance": instanceName}, registry)
+}
+
 // forcedExitCode maps a signal to the conventional 128+signum exit code that
 // shells, Docker, and Kubernetes report for a signal-terminated process (SIGINT
 // -> 130, SIGTERM -> 143). This keeps a force-quit distinguishable from
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/homeylab/tdarr-exporter/internal/collector"
+	"github.com/homeylab/tdarr-exporter/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+// targetSet is one generation of per-target collectors, index-aligned with the
+// configs they were built from.
+type targetSet struct {
+	configs    []config.Config
+	collectors []*collector.TdarrCollector
+	// stop ends the background polls of the set's collectors (see
+	// TdarrCollector.Run) once a reload has replaced it.
+	stop context.CancelFunc
+}
+
+// targetCollectors is the registry's stable handle on the Tdarr collectors. A
+// reload swaps the whole set with one atomic store, so a scrape sees either the
+// old set or the new one and never an empty registry (which unregistering and
+// re-registering would briefly produce).
+//
+// It is an unchecked collector (Describe sends nothing): a reload can add or
+// remove targets, which changes the tdarr_instance const labels and so the desc
+// set, and a registered Describe cannot change after registration. Each
+// TdarrCollector's own Describe is still covered by its tests.
+type targetCollectors struct {
+	current atomic.Pointer[targetSet]
+}
+
+func (t *targetCollectors) Describe(chan<- *prometheus.Desc) {}
+
+// Collect is CollectContext without a deadline.
+func (t *targetCollectors) Collect(ch chan<- prometheus.Metric) {
+	t.CollectContext(context.Background(), ch)
+}
+
+// CollectContext scrapes every target concurrently within ctx, as the registry
+// would if each collector were registered on its own, so one slow Tdarr
+// instance does not add its latency to the others'.
+func (t *targetCollectors) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	set := t.current.Load()
+	var wg sync.WaitGroup
+	for _, c := range set.collectors {
+		wg.Go(func() { c.CollectContext(ctx, ch) })
+	}
+	wg.Wait()
+}
+
+// CacheViews returns the pie cache state of every target, for /debug/cache.
+func (t *targetCollectors) CacheViews() []collector.PieCacheView {
+	set := t.current.Load()
+	views := make([]collector.PieCacheView, 0, len(set.collectors))
+	for _, c := range set.collectors {
+		views = append(views, c.CacheView())
+	}
+	return views
+}
+
+// reloader re-reads the configuration on SIGHUP or POST /-/reload and swaps in
+// freshly built collectors (and so fresh client.RequestClients). The load is
+// all-or-nothing: a validation or construction error leaves the running config
+// and collectors untouched and only flips the last-reload gauge to 0.
+type reloader struct {
+	ctx          context.Context
+	load         func() (config.Config, error)
+	newCollector func(ctx context.Context, runConfig config.Config, opts ...collector.TdarrCollectorOption) (*collector.TdarrCollector, error)
+	// collectorOpts are passed to every newCollector call.
+	collectorOpts []collector.TdarrCollectorOption
+	// registerer, when not nil, is where every collector registers its HTTP
+	// client metrics; the reloader unregisters those of targets it drops.
+	registerer prometheus.Registerer
+	now        func() time.Time
+
+	// mu serializes reloads; SIGHUP and the HTTP endpoint can race.
+	mu      sync.Mutex
+	running config.Config
+	targets *targetCollectors
+	// probes is reset with the new config on reload; nil when probing is off.
+	probes *collector.TdarrProbePool
+
+	lastReloadSuccessful       prometheus.Gauge
+	lastReloadSuccessTimestamp prometheus.Gauge
+}
+
+// newReloader builds the initial collectors for initial, and those of every
+// reload, with collectorOpts and their HTTP client metrics registered with
+// registerer (nil leaves the clients uninstrumented), and returns a reloader
+// whose gauges already report the startup load as a successful one.
+func newReloader(ctx context.Context, initial config.Config, load func() (config.Config, error), newCollector func(context.Context, config.Config, ...collector.TdarrCollectorOption) (*collector.TdarrCollector, error), registerer prometheus.Registerer, collectorOpts ...collector.TdarrCollectorOption) (*reloader, error) {
+	if registerer != nil {
+		collectorOpts = append(collectorOpts, collector.WithRegisterer(registerer))
+	}
+	r := &reloader{
+		ctx:           ctx,
+		load:          load,
+		newCollector:  newCollector,
+		collectorOpts: collectorOpts,
+		registerer:    registerer,
+		now:           time.Now,
+		running:       initial,
+		targets:       &targetCollectors{},
+		lastReloadSuccessful: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tdarr_exporter_config_last_reload_successful",
+			Help: "Whether the last configuration reload attempt was successful (1) or was rejected and the previous configuration kept (0).",
+		}),
+		lastReloadSuccessTimestamp: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tdarr_exporter_config_last_reload_success_timestamp_seconds",
+			Help: "Unix timestamp of the last successful configuration load, including the one at startup.",
+		}),
+	}
+	set, err := r.buildTargets(initial, nil)
+	if err != nil {
+		return nil, err
+	}
+	r.targets.current.Store(set)
+	r.markSuccess()
+	return r, nil
+}
+
+// metrics returns the reloader's own gauges for registration.
+func (r *reloader) metrics() []prometheus.Collector {
+	return []prometheus.Collector{r.lastReloadSuccessful, r.lastReloadSuccessTimestamp}
+}
+
+func (r *reloader) markSuccess() {
+	r.lastReloadSuccessful.Set(1)
+	r.lastReloadSuccessTimestamp.Set(float64(r.now().UnixNano()) / 1e9)
+}
+
+// Reload loads the configuration again and, if it is valid, swaps it in. It is
+// safe to call concurrently and returns the reason a reload was rejected.
+func (r *reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := r.load()
+	if err != nil {
+		return r.reject(err)
+	}
+	next = pinStartupSettings(next, r.running)
+	set, err := r.buildTargets(next, r.targets.current.Load())
+	if err != nil {
+		return r.reject(err)
+	}
+
+	prev := r.targets.current.Swap(set)
+	prev.stop()
+	r.unregisterDropped(prev.configs, set.configs)
+	if r.probes != nil {
+		r.probes.Reset(next)
+	}
+	next.ApplyLogLevel()
+	r.running = next
+	r.markSuccess()
+	log.Info().Int("targets", len(set.collectors)).Str("logLevel", next.LogLevel).Msg("Reloaded configuration")
+	return nil
+}
+
+func (r *reloader) reject(err error) error {
+	r.lastReloadSuccessful.Set(0)
+	log.Error().Err(err).Msg("Configuration reload failed, keeping the previous configuration")
+	return fmt.Errorf("reload configuration: %w", err)
+}
+
+// buildTargets constructs one collector per target of cfg and starts their
+// background polls, if any. A collector whose target (instance name and url)
+// also exists in prev inherits its state, so a reload does not drop the pie
+// cache or reset counters for unchanged targets.
+func (r *reloader) buildTargets(cfg config.Config, prev *targetSet) (*targetSet, error) {
+	configs := cfg.TargetConfigs()
+	set := &targetSet{configs: configs, collectors: make([]*collector.TdarrCollector, 0, len(configs))}
+	for i, targetConfig := range configs {
+		c, err := r.newCollector(r.ctx, targetConfig, r.collectorOpts...)
+		if err != nil {
+			// The client metrics of the targets built so far, and of the one
+			// that failed part way, must not outlive the rejected set.
+			var running []config.Config
+			if prev != nil {
+				running = prev.configs
+			}
+			r.unregisterDropped(configs[:i+1], running)
+			return nil, fmt.Errorf("create collector for %s: %w", targetConfig.InstanceName, err)
+		}
+		if prev != nil {
+			for i, prevConfig := range prev.configs {
+				if sameTarget(prevConfig, targetConfig) {
+					c.InheritState(prev.collectors[i])
+					break
+				}
+			}
+		}
+		set.collectors = append(set.collectors, c)
+	}
+	if len(set.collectors) == 0 {
+		return nil, errors.New("configuration has no targets")
+	}
+	// Polls run under r.ctx, so shutdown stops them as it aborts live scrapes.
+	var ctx context.Context
+	ctx, set.stop = context.WithCancel(r.ctx)
+	for _, c := range set.collectors {
+		go c.Run(ctx)
+	}
+	return set, nil
+}
+
+// unregisterDropped removes the HTTP client metrics of every target in from
+// whose tdarr_instance label is not also in to. A target keeping its label
+// shares its metrics with its successor (see client.NewMetrics), so those stay.
+func (r *reloader) unregisterDropped(from, to []config.Config) {
+	if r.registerer == nil {
+		return
+	}
+	kept := make(map[string]bool, len(to))
+	for _, c := range to {
+		kept[c.InstanceName] = true
+	}
+	for _, c := range from {
+		if !kept[c.InstanceName] {
+			collector.UnregisterClientMetrics(r.registerer, c.InstanceName)
+		}
+	}
+}
+
+// sameTarget reports whether a and b scrape the same Tdarr instance under the
+// same label, i.e. whether cached data from one is valid for the other.
+func sameTarget(a, b config.Config) bool {
+	return a.InstanceName == b.InstanceName &&
+		a.UrlParsed.String() == b.UrlParsed.String() &&
+		a.TdarrStatsPath == b.TdarrStatsPath &&
+		a.TdarrPieStatsPath == b.TdarrPieStatsPath
+}
+
+// pinStartupSettings keeps the settings the HTTP server and registry were built
+// from at startup (listener, routes, exporter-level instance label), warning for
+// each one the reloaded config tried to change: those need a restart.
+func pinStartupSettings(next, running config.Config) config.Config {
+	pins := []struct {
+		name          string
+		changed       bool
+		restoreToNext func()
+	}{
+		{"prometheus_port", next.PrometheusPort != running.PrometheusPort, func() { next.PrometheusPort = running.PrometheusPort }},
+		{"prometheus_path", next.PrometheusPath != running.PrometheusPath, func() { next.PrometheusPath = running.PrometheusPath }},
+		{"listen_address", next.ListenAddress != running.ListenAddress, func() { next.ListenAddress = running.ListenAddress }},
+		{"probe_enabled", next.ProbeEnabled != running.ProbeEnabled, func() { next.ProbeEnabled = running.ProbeEnabled }},
+		{"reload_enabled", next.ReloadEnabled != running.ReloadEnabled, func() { next.ReloadEnabled = running.ReloadEnabled }},
+		{"debug_cache_enabled", next.DebugCacheEnabled != running.DebugCacheEnabled, func() { next.DebugCacheEnabled = running.DebugCacheEnabled }},
+		{"scrape_timeout_offset", next.ScrapeTimeoutOffset != running.ScrapeTimeoutOffset, func() { next.ScrapeTimeoutOffset = running.ScrapeTimeoutOffset }},
+		{"state_file", next.StateFile != running.StateFile, func() { next.StateFile = running.StateFile }},
+	}
+	for _, pin := range pins {
+		if pin.changed {
+			log.Warn().Str("setting", pin.name).Msg("Setting cannot change on reload, restart the exporter to apply it")
+			pin.restoreToNext()
+		}
+	}
+	return next
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/homeylab/tdarr-exporter/internal/collector"
+	"github.com/homeylab/tdarr-exporter/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+)
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("url.Parse(%q): %v", raw, err)
+	}
+	return u
+}
+
+// reloadTestConfig builds a config scraping http://<name> for each instance:
+// the first as the primary url, the rest as config file targets. LogLevel is
+// the current global level so a successful Reload leaves it unchanged.
+func reloadTestConfig(t *testing.T, instances ...string) config.Config {
+	t.Helper()
+	cfg := config.Config{
+		UrlParsed:          mustParseURL(t, "http://"+instances[0]),
+		InstanceName:       instances[0],
+		HttpTimeoutSeconds: 1,
+		HttpMaxConcurrency: 1,
+		Collectors:         config.DefaultCollectors(),
+		LogLevel:           zerolog.GlobalLevel().String(),
+		PrometheusPort:     "9090",
+	}
+	for _, name := range instances[1:] {
+		cfg.Targets = append(cfg.Targets, config.Target{UrlParsed: mustParseURL(t, "http://"+name), InstanceName: name})
+	}
+	return cfg
+}
+
+// cancelledCtx makes collector scrapes fail immediately instead of dialing the
+// fake hosts, so Gather reports tdarr_up=0 per target without network access.
+func cancelledCtx() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+// upInstances gathers reg and returns the tdarr_instance label of every tdarr_up
+// series.
+func upInstances(t *testing.T, reg prometheus.Gatherer) []string {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	var instances []string
+	for _, fam := range families {
+		if fam.GetName() != "tdarr_up" {
+			continue
+		}
+		for _, m := range fam.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if lp.GetName() == "tdarr_instance" {
+					instances = append(instances, lp.GetValue())
+				}
+			}
+		}
+	}
+	return instances
+}
+
+// TestBuildRegistryMultipleTargets verifies collectors for several Tdarr
+// instances are served alongside the registry, as the metrics handler gathers
+// them: their series differ only by the tdarr_instance const label, and every
+// target is scraped.
+func TestBuildRegistryMultipleTargets(t *testing.T) {
+	t.Parallel()
+
+	cfg := reloadTestConfig(t, "tdarr-a.lan", "tdarr-b.lan")
+	r, err := newReloader(cancelledCtx(), cfg, nil, collector.NewTdarrCollector, nil)
+	if err != nil {
+		t.Fatalf("newReloader: %v", err)
+	}
+	targets := prometheus.NewRegistry()
+	targets.MustRegister(r.targets)
+
+	got := upInstances(t, prometheus.Gatherers{buildRegistry("tdarr-a.lan", r.metrics()...), targets})
+	if len(got) != 2 || got[0] == got[1] {
+		t.Errorf("tdarr_up instances = %v, want one series each for tdarr-a.lan and tdarr-b.lan", got)
+	}
+}
+
+// TestReloaderSwapsTargets verifies a successful reload swaps in the new target
+// set and records the success in both gauges.
+func TestReloaderSwapsTargets(t *testing.T) {
+	initial := reloadTestConfig(t, "tdarr-a.lan")
+	next := reloadTestConfig(t, "tdarr-a.lan", "tdarr-b.lan")
+	r, err := newReloader(cancelledCtx(), initial, func() (config.Config, error) { return next, nil }, collector.NewTdarrCollector, nil)
+	if err != nil {
+		t.Fatalf("newReloader: %v", err)
+	}
+	if got := testutil.ToFloat64(r.lastReloadSuccessful); got != 1 {
+		t.Errorf("last_reload_successful at startup = %v, want 1", got)
+	}
+	reloadedAt := time.Unix(1_700_000_000, 0)
+	r.now = func() time.Time { return reloadedAt }
+
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(r.targets)
+	if got := upInstances(t, reg); len(got) != 2 {
+		t.Errorf("tdarr_up instances after reload = %v, want 2 targets", got)
+	}
+	if got := testutil.ToFloat64(r.lastReloadSuccessful); got != 1 {
+		t.Errorf("last_reload_successful = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(r.lastReloadSuccessTimestamp); got != float64(reloadedAt.Unix()) {
+		t.Errorf("last_reload_success_timestamp_seconds = %v, want %v", got, reloadedAt.Unix())
+	}
+}
+
+// TestReloaderKeepsPreviousConfigOnFailure verifies both failure modes (the
+// config does not validate, a collector cannot be built) leave the running
+// target set and success timestamp untouched and flip the success gauge to 0.
+func TestReloaderKeepsPreviousConfigOnFailure(t *testing.T) {
+	t.Parallel()
+
+	errBadConfig := errors.New("prometheus_port must be an integer between 1 and 65535")
+	errBadClient := errors.New("bad client")
+	tests := []struct {
+		name         string
+		load         func() (config.Config, error)
+		failInstance string
+		wantErr      error
+	}{
+		{
+			name:    "invalid config",
+			load:    func() (config.Config, error) { return config.Config{}, errBadConfig },
+			wantErr: errBadConfig,
+		},
+		{
+			name:         "collector construction error",
+			load:         func() (config.Config, error) { return reloadTestConfig(t, "tdarr-a.lan", "tdarr-b.lan"), nil },
+			failInstance: "tdarr-b.lan",
+			wantErr:      errBadClient,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			newCollector := func(ctx context.Context, cfg config.Config, _ ...collector.TdarrCollectorOption) (*collector.TdarrCollector, error) {
+				if cfg.InstanceName == tc.failInstance {
+					return nil, errBadClient
+				}
+				return collector.NewTdarrCollector(ctx, cfg)
+			}
+			r, err := newReloader(cancelledCtx(), reloadTestConfig(t, "tdarr-a.lan"), tc.load, newCollector, nil)
+			if err != nil {
+				t.Fatalf("newReloader: %v", err)
+			}
+			before := r.targets.current.Load()
+			startedAt := testutil.ToFloat64(r.lastReloadSuccessTimestamp)
+
+			if err := r.Reload(); !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Reload error = %v, want %v", err, tc.wantErr)
+			}
+			if r.targets.current.Load() != before {
+				t.Errorf("target set was swapped despite the failed reload")
+			}
+			if got := testutil.ToFloat64(r.lastReloadSuccessful); got != 0 {
+				t.Errorf("last_reload_successful = %v, want 0", got)
+			}
+			if got := testutil.ToFloat64(r.lastReloadSuccessTimestamp); got != startedAt {
+				t.Errorf("last_reload_success_timestamp_seconds = %v, want unchanged %v", got, startedAt)
+			}
+		})
+	}
+}
+
+// httpInstances gathers reg and returns the tdarr_instance labels that carry
+// HTTP client series.
+func httpInstances(t *testing.T, reg prometheus.Gatherer) map[string]bool {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	instances := map[string]bool{}
+	for _, fam := range families {
+		if !strings.HasPrefix(fam.GetName(), "tdarr_exporter_http_") {
+			continue
+		}
+		for _, m := range fam.GetMetric() {
+			for _, lp := range m.GetLabel() {
+				if lp.GetName() == "tdarr_instance" {
+					instances[lp.GetValue()] = true
+				}
+			}
+		}
+	}
+	return instances
+}
+
+// TestReloaderUnregistersDroppedClientMetrics verifies the HTTP client series
+// of a target a reload removes leave the registry, the kept target's stay, and
+// a reload rejected part way leaves none behind for the targets it built.
+func TestReloaderUnregistersDroppedClientMetrics(t *testing.T) {
+	t.Parallel()
+
+	// Every target answers 404 at once, so each scrape records its requests
+	// without retries.
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	local := func(cfg config.Config) config.Config {
+		cfg.UrlParsed = mustParseURL(t, srv.URL)
+		for i := range cfg.Targets {
+			cfg.Targets[i].UrlParsed = cfg.UrlParsed
+		}
+		return cfg
+	}
+
+	registry := prometheus.NewRegistry()
+	next := local(reloadTestConfig(t, "tdarr-a.lan"))
+	r, err := newReloader(context.Background(), local(reloadTestConfig(t, "tdarr-a.lan", "tdarr-b.lan")), func() (config.Config, error) { return next, nil }, collector.NewTdarrCollector, registry)
+	if err != nil {
+		t.Fatalf("newReloader: %v", err)
+	}
+	targets := prometheus.NewRegistry()
+	targets.MustRegister(r.targets)
+	// Scrape once so each target's client has recorded its requests.
+	if _, err := targets.Gather(); err != nil {
+		t.Fatalf("Gather targets: %v", err)
+	}
+	if got := httpInstances(t, registry); !got["tdarr-a.lan"] || !got["tdarr-b.lan"] {
+		t.Fatalf("client series instances at startup = %v, want tdarr-a.lan and tdarr-b.lan", got)
+	}
+
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if got := httpInstances(t, registry); !got["tdarr-a.lan"] || got["tdarr-b.lan"] {
+		t.Errorf("client series instances after removing tdarr-b.lan = %v, want only tdarr-a.lan", got)
+	}
+
+	// tdarr-c.lan is built, and scrapes once, before tdarr-d.lan fails.
+	next = local(reloadTestConfig(t, "tdarr-a.lan", "tdarr-c.lan", "tdarr-d.lan"))
+	r.newCollector = func(ctx context.Context, cfg config.Config, opts ...collector.TdarrCollectorOption) (*collector.TdarrCollector, error) {
+		if cfg.InstanceName == "tdarr-d.lan" {
+			return nil, errors.New("bad client")
+		}
+		c, err := collector.NewTdarrCollector(ctx, cfg, opts...)
+		if err == nil {
+			_ = testutil.CollectAndCount(c)
+		}
+		return c, err
+	}
+	if err := r.Reload(); err == nil {
+		t.Fatal("Reload: want an error from the failing target")
+	}
+	if got := httpInstances(t, registry); !got["tdarr-a.lan"] || got["tdarr-c.lan"] {
+		t.Errorf("client series instances after a rejected reload = %v, want only tdarr-a.lan", got)
+	}
+}
+
+// TestReloaderPinsStartupSettings verifies settings baked into the HTTP server
+// at startup keep their running values across a reload that changes them.
+func TestReloaderPinsStartupSettings(t *testing.T) {
+	initial := reloadTestConfig(t, "tdarr-a.lan")
+	next := reloadTestConfig(t, "tdarr-a.lan")
+	next.PrometheusPort = "9100"
+	next.ProbeEnabled = true
+	next.DebugCacheEnabled = true
+	next.HttpMaxConcurrency = 7
+	r, err := newReloader(cancelledCtx(), initial, func() (config.Config, error) { return next, nil }, collector.NewTdarrCollector, nil)
+	if err != nil {
+		t.Fatalf("newReloader: %v", err)
+	}
+
+	if err := r.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if r.running.PrometheusPort != "9090" || r.running.ProbeEnabled || r.running.DebugCacheEnabled {
+		t.Errorf("running port/probe_enabled/debug_cache_enabled = %q/%v/%v, want startup values 9090/false/false", r.running.PrometheusPort, r.running.ProbeEnabled, r.running.DebugCacheEnabled)
+	}
+	if r.running.HttpMaxConcurrency != 7 {
+		t.Errorf("running HttpMaxConcurrency = %d, want reloaded value 7", r.running.HttpMaxConcurrency)
+	}
+}
+
+func TestSameTarget(t *testing.T) {
+	t.Parallel()
+
+	base := reloadTestConfig(t, "tdarr-a.lan")
+	renamed := base
+	renamed.InstanceName = "renamed"
+	moved := base
+	moved.UrlParsed = mustParseURL(t, "http://tdarr-a.lan:8266")
+	rekeyed := base
+	rekeyed.ApiKey = "new-key"
+
+	tests := []struct {
+		name string
+		b    config.Config
+		want bool
+	}{
+		{"identical", base, true},
+		{"new api key keeps state", rekeyed, true},
+		{"renamed instance", renamed, false},
+		{"different url", moved, false},
+	}
+	for _, tc := range tests {
+		if got := sameTarget(base, tc.b); got != tc.want {
+			t.Errorf("%s: sameTarget = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
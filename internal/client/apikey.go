@@ -0,0 +1,141 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// APIKeySource supplies the api key sent as x-api-key. RequestClient asks for it
+// on every request, so an implementation can rotate the key at runtime; an
+// empty key sends no header.
+type APIKeySource interface {
+	APIKey() string
+}
+
+// StaticAPIKey is a key fixed at startup (TDARR_API_KEY / -api_key).
+type StaticAPIKey string
+
+func (k StaticAPIKey) APIKey() string { return string(k) }
+
+// FileAPIKeyOption is a functional option for NewFileAPIKey.
+type FileAPIKeyOption func(*FileAPIKey)
+
+// WithKeyCheckInterval sets how often the key file is re-read. Default: 10s.
+func WithKeyCheckInterval(interval time.Duration) FileAPIKeyOption {
+	return func(k *FileAPIKey) {
+		k.interval = interval
+	}
+}
+
+// WithKeyClock injects the clock used to rate-limit re-reads, replacing
+// time.Now. Intended for tests.
+func WithKeyClock(now func() time.Time) FileAPIKeyOption {
+	return func(k *FileAPIKey) {
+		k.now = now
+	}
+}
+
+// WithKeyLogger injects the logger for rotation and read-failure messages.
+// Defaults to log.Logger.
+func WithKeyLogger(logger zerolog.Logger) FileAPIKeyOption {
+	return func(k *FileAPIKey) {
+		k.logger = logger
+	}
+}
+
+// FileAPIKey reads the api key from a file (TDARR_API_KEY_FILE / -api_key_file)
+// and picks up a rotated key without a restart, e.g. a Kubernetes Secret volume
+// the kubelet rewrites in place.
+//
+// The file is re-read lazily: the first APIKey call after the check interval
+// has elapsed reads it again, so the key is checked at most once per interval
+// and only while the exporter is actually making requests. There is no watcher
+// goroutine, so a FileAPIKey needs no shutdown and is safe to discard with its
+// collector on a config reload. A failed or empty re-read keeps the last good
+// key, logs a warning and counts a read failure (see ReadFailures).
+type FileAPIKey struct {
+	path     string
+	interval time.Duration
+	now      func() time.Time
+	logger   zerolog.Logger
+
+	mu        sync.Mutex
+	key       string
+	checkedAt time.Time
+	failures  atomic.Uint64
+}
+
+// NewFileAPIKey reads path once and returns the source. Unlike a later re-read,
+// an unreadable or empty file here is an error: there is no last good key yet.
+func NewFileAPIKey(path string, opts ...FileAPIKeyOption) (*FileAPIKey, error) {
+	k := &FileAPIKey{
+		path:     path,
+		interval: 10 * time.Second,
+		now:      time.Now,
+		logger:   log.Logger,
+	}
+	for _, opt := range opts {
+		opt(k)
+	}
+	key, err := readAPIKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+	k.key = key
+	k.checkedAt = k.now()
+	return k, nil
+}
+
+// APIKey returns the current key, re-reading the file first if the check
+// interval has elapsed. Safe for concurrent use; concurrent callers wait on a
+// re-read in progress rather than racing it.
+func (k *FileAPIKey) APIKey() string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	now := k.now()
+	if now.Sub(k.checkedAt) < k.interval {
+		return k.key
+	}
+	k.checkedAt = now
+	key, err := readAPIKeyFile(k.path)
+	if err != nil {
+		k.failures.Add(1)
+		k.logger.Warn().Err(err).Str("path", k.path).Msg("Failed to re-read api key file, keeping the last good key")
+		return k.key
+	}
+	if key != k.key {
+		k.logger.Info().Str("path", k.path).Msg("Api key file changed, using the rotated key")
+		k.key = key
+	}
+	return k.key
+}
+
+// ReadFailures is the number of failed re-reads since the source was created.
+func (k *FileAPIKey) ReadFailures() uint64 {
+	return k.failures.Load()
+}
+
+// readAPIKeyFile returns the trimmed file content. Surrounding whitespace is
+// dropped because secrets written with echo or an editor end in a newline, which
+// Tdarr would reject as part of the key.
+func readAPIKeyFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read api key file: %w", err)
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", fmt.Errorf("read api key file %s: %w", path, errEmptyAPIKeyFile)
+	}
+	return key, nil
+}
+
+var errEmptyAPIKeyFile = errors.New("file is empty")
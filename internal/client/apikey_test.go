@@ -0,0 +1,169 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// fakeClock is a settable clock for WithKeyClock.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func writeKeyFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write key file: %v", err)
+	}
+}
+
+// newTestFileAPIKey returns a FileAPIKey over a temp file holding "key-1\n",
+// re-read every minute of fake time, plus the path and clock driving it.
+func newTestFileAPIKey(t *testing.T) (*FileAPIKey, string, *fakeClock) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "api-key")
+	writeKeyFile(t, path, "key-1\n")
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	k, err := NewFileAPIKey(path, WithKeyCheckInterval(time.Minute), WithKeyClock(clock.Now), WithKeyLogger(zerolog.Nop()))
+	if err != nil {
+		t.Fatalf("NewFileAPIKey: %v", err)
+	}
+	return k, path, clock
+}
+
+func TestNewFileAPIKey_InitialReadErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if _, err := NewFileAPIKey(filepath.Join(dir, "missing")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing file: error = %v, want fs.ErrNotExist", err)
+	}
+	empty := filepath.Join(dir, "empty")
+	writeKeyFile(t, empty, " \n")
+	if _, err := NewFileAPIKey(empty); !errors.Is(err, errEmptyAPIKeyFile) {
+		t.Errorf("empty file: error = %v, want errEmptyAPIKeyFile", err)
+	}
+}
+
+// TestFileAPIKey_Rotation verifies the key is trimmed, not re-read inside the
+// check interval, and replaced by the rotated content once it elapses.
+func TestFileAPIKey_Rotation(t *testing.T) {
+	t.Parallel()
+	k, path, clock := newTestFileAPIKey(t)
+
+	if got := k.APIKey(); got != "key-1" {
+		t.Fatalf("initial key = %q, want trimmed key-1", got)
+	}
+	writeKeyFile(t, path, "key-2\n")
+	clock.Advance(30 * time.Second)
+	if got := k.APIKey(); got != "key-1" {
+		t.Errorf("key inside the check interval = %q, want cached key-1", got)
+	}
+	clock.Advance(30 * time.Second)
+	if got := k.APIKey(); got != "key-2" {
+		t.Errorf("key after the check interval = %q, want rotated key-2", got)
+	}
+	if got := k.ReadFailures(); got != 0 {
+		t.Errorf("ReadFailures = %d, want 0", got)
+	}
+}
+
+// TestFileAPIKey_FailedReReadKeepsLastGoodKey verifies a missing or emptied
+// file keeps serving the last good key and counts each failed re-read, and that
+// the source recovers once the file is valid again.
+func TestFileAPIKey_FailedReReadKeepsLastGoodKey(t *testing.T) {
+	t.Parallel()
+	k, path, clock := newTestFileAPIKey(t)
+
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("remove key file: %v", err)
+	}
+	clock.Advance(time.Minute)
+	if got := k.APIKey(); got != "key-1" {
+		t.Errorf("key after the file vanished = %q, want last good key-1", got)
+	}
+	writeKeyFile(t, path, "")
+	clock.Advance(time.Minute)
+	if got := k.APIKey(); got != "key-1" {
+		t.Errorf("key after the file was emptied = %q, want last good key-1", got)
+	}
+	if got := k.ReadFailures(); got != 2 {
+		t.Errorf("ReadFailures = %d, want 2", got)
+	}
+
+	writeKeyFile(t, path, "key-3")
+	clock.Advance(time.Minute)
+	if got := k.APIKey(); got != "key-3" {
+		t.Errorf("key after recovery = %q, want key-3", got)
+	}
+	if got := k.ReadFailures(); got != 2 {
+		t.Errorf("ReadFailures after recovery = %d, want unchanged 2", got)
+	}
+}
+
+// TestRequestClient_SendsCurrentAPIKey verifies the x-api-key header follows
+// the key source across requests rather than being fixed at construction.
+func TestRequestClient_SendsCurrentAPIKey(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var gotKeys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		gotKeys = append(gotKeys, r.Header.Get("x-api-key"))
+		mu.Unlock()
+		_, _ = io.WriteString(w, `{}`)
+	}))
+	defer srv.Close()
+
+	k, path, clock := newTestFileAPIKey(t)
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("url.Parse: %v", err)
+	}
+	c, err := NewRequestClient(u, true, 5, "ignored-static-key", WithAPIKeySource(k))
+	if err != nil {
+		t.Fatalf("NewRequestClient: %v", err)
+	}
+
+	var target payloadTarget
+	if err := c.DoRequest(context.Background(), "/api/v2/status", &target); err != nil {
+		t.Fatalf("DoRequest: %v", err)
+	}
+	writeKeyFile(t, path, "key-2")
+	clock.Advance(time.Minute)
+	if err := c.DoPostRequest(context.Background(), "/api/v2/cruddb", &target, []byte(`{}`)); err != nil {
+		t.Fatalf("DoPostRequest: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(gotKeys) != 2 || gotKeys[0] != "key-1" || gotKeys[1] != "key-2" {
+		t.Errorf("x-api-key per request = %q, want [key-1 key-2]", gotKeys)
+	}
+}
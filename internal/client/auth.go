@@ -0,0 +1,258 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authenticator adds credentials to an outgoing request. ClientTransport calls
+// it on every attempt, retries included, with a copy of the request, so an
+// implementation may set headers freely and a credential refreshed between
+// attempts replaces the old one instead of stacking on it. An error aborts the
+// attempt like a transport error would.
+type Authenticator interface {
+	Authenticate(req *http.Request) error
+}
+
+// Invalidator is implemented by authenticators that cache a credential the
+// server can reject before it is due to expire, e.g. a revoked OAuth2 token.
+// On a 401 ClientTransport calls Invalidate and, if it returns true, retries
+// the request once with a freshly fetched credential.
+type Invalidator interface {
+	Invalidate() bool
+}
+
+// Authenticators applies each authenticator in order, e.g. the Tdarr api key
+// plus the credential of an auth proxy in front of it.
+type Authenticators []Authenticator
+
+func (a Authenticators) Authenticate(req *http.Request) error {
+	for _, auth := range a {
+		if err := auth.Authenticate(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Invalidate invalidates every member that caches a credential and reports
+// whether any did.
+func (a Authenticators) Invalidate() bool {
+	invalidated := false
+	for _, auth := range a {
+		if inv, ok := auth.(Invalidator); ok && inv.Invalidate() {
+			invalidated = true
+		}
+	}
+	return invalidated
+}
+
+// APIKeyAuth sends the key from Source as Tdarr's x-api-key header, or nothing
+// while the key is empty.
+type APIKeyAuth struct {
+	Source APIKeySource
+}
+
+func (a APIKeyAuth) Authenticate(req *http.Request) error {
+	if key := a.Source.APIKey(); key != "" {
+		req.Header.Set("x-api-key", key)
+	}
+	return nil
+}
+
+// BasicAuth sends HTTP basic credentials, e.g. for a reverse proxy with an
+// htpasswd file.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+func (a BasicAuth) Authenticate(req *http.Request) error {
+	req.SetBasicAuth(a.Username, a.Password)
+	return nil
+}
+
+// BearerToken sends a static "Authorization: Bearer" token.
+type BearerToken string
+
+func (t BearerToken) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+string(t))
+	return nil
+}
+
+// StaticHeaders sets each header to its value, replacing any existing value.
+type StaticHeaders map[string]string
+
+func (h StaticHeaders) Authenticate(req *http.Request) error {
+	for name, value := range h {
+		req.Header.Set(name, value)
+	}
+	return nil
+}
+
+// OAuth2Config is the OAuth2 client-credentials grant (RFC 6749 section 4.4)
+// used to get a bearer token from an auth proxy's token endpoint.
+type OAuth2Config struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// InsecureSkipVerify skips verifying the token endpoint's certificate, like
+	// verify_ssl=false does for Tdarr.
+	InsecureSkipVerify bool
+}
+
+// OAuth2Option is a functional option for NewOAuth2ClientCredentials.
+type OAuth2Option func(*OAuth2ClientCredentials)
+
+// WithOAuth2HTTPClient sets the client used for token requests. The default is
+// a client with a 15s timeout over a clone of http.DefaultTransport, verifying
+// against the system roots unless cfg.InsecureSkipVerify is set: the token
+// endpoint is a different server from Tdarr, so the Tdarr TLS settings
+// (tls_ca_file, tls_server_name, client certificate) do not apply to it.
+func WithOAuth2HTTPClient(httpClient *http.Client) OAuth2Option {
+	return func(o *OAuth2ClientCredentials) {
+		o.httpClient = httpClient
+	}
+}
+
+// WithOAuth2Clock injects the clock used for token expiry, replacing time.Now.
+// Intended for tests.
+func WithOAuth2Clock(now func() time.Time) OAuth2Option {
+	return func(o *OAuth2ClientCredentials) {
+		o.now = now
+	}
+}
+
+// oauth2ExpiryDelta is how long before its stated expiry a token is replaced,
+// so a token is not sent in its last seconds and rejected in flight. Matches
+// golang.org/x/oauth2's defaultExpiryDelta.
+const oauth2ExpiryDelta = 10 * time.Second
+
+// OAuth2ClientCredentials sends a bearer token obtained with the client
+// credentials grant. The token is cached until shortly before its expires_in
+// (indefinitely when the endpoint gives none) and fetched again after a 401
+// through Invalidate. Fetches run on the request's context, so a scrape
+// timeout bounds them too, and concurrent requests share one fetch.
+type OAuth2ClientCredentials struct {
+	cfg        OAuth2Config
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// NewOAuth2ClientCredentials returns an authenticator for cfg. No token is
+// fetched until the first request.
+func NewOAuth2ClientCredentials(cfg OAuth2Config, opts ...OAuth2Option) *OAuth2ClientCredentials {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	o := &OAuth2ClientCredentials{
+		cfg:        cfg,
+		httpClient: &http.Client{Transport: transport, Timeout: 15 * time.Second},
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func (o *OAuth2ClientCredentials) Authenticate(req *http.Request) error {
+	token, err := o.currentToken(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Invalidate drops the cached token so the next request fetches a new one.
+func (o *OAuth2ClientCredentials) Invalidate() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.token = ""
+	return true
+}
+
+func (o *OAuth2ClientCredentials) currentToken(ctx context.Context) (string, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.token != "" && (o.expiry.IsZero() || o.now().Before(o.expiry)) {
+		return o.token, nil
+	}
+	token, expiresIn, err := o.fetchToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	o.token, o.expiry = token, time.Time{}
+	if expiresIn > 0 {
+		// A token shorter-lived than twice the delta is kept for half its life.
+		o.expiry = o.now().Add(max(expiresIn-oauth2ExpiryDelta, expiresIn/2))
+	}
+	return o.token, nil
+}
+
+// ErrOAuth2Token is returned (wrapped) when the token endpoint cannot be
+// reached or does not issue a token.
+var ErrOAuth2Token = errors.New("oauth2 token request failed")
+
+// oauth2TokenResponse is the token endpoint's success and error body (RFC 6749
+// sections 5.1 and 5.2).
+type oauth2TokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (o *OAuth2ClientCredentials) fetchToken(ctx context.Context) (string, time.Duration, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(o.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(o.cfg.Scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, fmt.Errorf("%w: %w", ErrOAuth2Token, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// Section 2.3.1: the client id and secret are form-encoded before being
+	// used as the basic auth pair.
+	req.SetBasicAuth(url.QueryEscape(o.cfg.ClientID), url.QueryEscape(o.cfg.ClientSecret))
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("%w: %w", ErrOAuth2Token, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	var body oauth2TokenResponse
+	// A non-JSON body (e.g. a proxy error page) leaves body empty, which is
+	// reported through the status code below.
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body)
+	if resp.StatusCode != http.StatusOK {
+		if body.Error != "" {
+			return "", 0, fmt.Errorf("%w: status %d: %s %s", ErrOAuth2Token, resp.StatusCode, body.Error, body.ErrorDescription)
+		}
+		return "", 0, fmt.Errorf("%w: status %d", ErrOAuth2Token, resp.StatusCode)
+	}
+	if body.AccessToken == "" {
+		return "", 0, fmt.Errorf("%w: response has no access_token", ErrOAuth2Token)
+	}
+	if body.TokenType != "" && !strings.EqualFold(body.TokenType, "bearer") {
+		return "", 0, fmt.Errorf("%w: unsupported token_type %q", ErrOAuth2Token, body.TokenType)
+	}
+	return body.AccessToken, time.Duration(body.ExpiresIn) * time.Second, nil
+}
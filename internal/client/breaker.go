@@ -0,0 +1,198 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned, without contacting Tdarr, for a request made
+// while the circuit breaker is open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets every request through.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen fails every request with ErrCircuitOpen until the cooldown
+	// has passed.
+	CircuitOpen
+	// CircuitHalfOpen lets a single probe request through; its outcome closes
+	// or re-opens the circuit.
+	CircuitHalfOpen
+)
+
+// CircuitStates lists every state, in the order metrics emit them.
+var CircuitStates = []CircuitState{CircuitClosed, CircuitOpen, CircuitHalfOpen}
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half_open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// CircuitBreaker stops requests to a Tdarr that keeps failing, so a scrape
+// fails in milliseconds instead of waiting out every endpoint's retries. After
+// threshold consecutive failed requests it opens; once cooldown has passed it
+// lets one probe request through, which closes it on success and re-opens it
+// on failure. A failure is a request that ended in an error once its retries
+// were used up, other than a 4xx: that shows Tdarr is reachable and counts as
+// a success. Safe for concurrent use.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	// probing is set while the half-open probe request is in flight.
+	probing     bool
+	transitions map[CircuitState]uint64
+}
+
+// NewCircuitBreaker returns a closed breaker that opens after threshold
+// consecutive failures and probes again after cooldown.
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold:   max(threshold, 1),
+		cooldown:    cooldown,
+		now:         time.Now,
+		transitions: make(map[CircuitState]uint64),
+	}
+}
+
+// State returns the current state. An open breaker whose cooldown has passed
+// still reports CircuitOpen until the next request moves it to half-open.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Transitions returns how many times the breaker has entered each state.
+func (b *CircuitBreaker) Transitions() map[CircuitState]uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make(map[CircuitState]uint64, len(b.transitions))
+	for state, n := range b.transitions {
+		out[state] = n
+	}
+	return out
+}
+
+// setState moves to state and counts the transition. Callers hold b.mu.
+func (b *CircuitBreaker) setState(state CircuitState) {
+	if b.state == state {
+		return
+	}
+	b.state = state
+	b.transitions[state]++
+	if state == CircuitOpen {
+		b.openedAt = b.now()
+	}
+}
+
+// allow reports whether a request may go out, returning ErrCircuitOpen if not.
+// A nil error commits the caller to reporting the outcome through done.
+func (b *CircuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case CircuitOpen:
+		if remaining := b.cooldown - b.now().Sub(b.openedAt); remaining > 0 {
+			return fmt.Errorf("%w: retrying Tdarr in %s", ErrCircuitOpen, remaining.Round(time.Millisecond))
+		}
+		b.setState(CircuitHalfOpen)
+		b.probing = true
+		return nil
+	case CircuitHalfOpen:
+		if b.probing {
+			return fmt.Errorf("%w: waiting for the probe request to Tdarr", ErrCircuitOpen)
+		}
+		b.probing = true
+		return nil
+	}
+	return nil
+}
+
+// done records the outcome of a request allow let through, made on behalf of
+// caller: the context the request's own timeout was derived from.
+func (b *CircuitBreaker) done(caller context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	halfOpen := b.state == CircuitHalfOpen
+	if halfOpen {
+		b.probing = false
+	}
+	switch {
+	case caller.Err() != nil || errors.Is(err, context.Canceled):
+		// The caller gave up (e.g. shutdown, or a Prometheus scrape timing
+		// out), which says nothing about Tdarr. Only the request's own
+		// timeout expiring counts against it.
+	case isFailure(err):
+		b.failures++
+		if halfOpen || b.failures >= b.threshold {
+			b.setState(CircuitOpen)
+		}
+	default:
+		b.failures = 0
+		b.setState(CircuitClosed)
+	}
+}
+
+type callerKey struct{}
+
+// contextWithCaller records ctx as the caller of the request made with the
+// context derived from it, so the breaker can tell the caller's deadline from
+// the request's own timeout.
+func contextWithCaller(ctx context.Context) context.Context {
+	return context.WithValue(ctx, callerKey{}, ctx)
+}
+
+// callerFromContext returns the caller recorded by contextWithCaller, or a
+// context that is never done for a request sent without one.
+func callerFromContext(ctx context.Context) context.Context {
+	if caller, ok := ctx.Value(callerKey{}).(context.Context); ok {
+		return caller
+	}
+	return context.Background()
+}
+
+// isFailure reports whether err from ClientTransport means Tdarr could not
+// be reached or could not answer: a connection or DNS error, a timeout, a
+// connection closed mid-response, or a 5xx or 429 once the retries are used
+// up. Anything else, such as a redirect or a request that could not be sent
+// as built (no host, an unsupported scheme, failed authentication), is the
+// fault of the request and says nothing about Tdarr.
+func isFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError || statusErr.StatusCode == http.StatusTooManyRequests
+	}
+	var (
+		opErr  *net.OpError
+		dnsErr *net.DNSError
+		netErr net.Error
+	)
+	return errors.As(err, &opErr) || errors.As(err, &dnsErr) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
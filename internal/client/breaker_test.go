@@ -0,0 +1,248 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// errConnRefused is the error of a dial to a port nothing listens on.
+var errConnRefused = &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+
+func newTestBreaker(threshold int) (*CircuitBreaker, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)}
+	b := NewCircuitBreaker(threshold, 30*time.Second)
+	b.now = clock.Now
+	return b, clock
+}
+
+// TestCircuitBreaker_States walks the breaker through closed, open, a failed
+// half-open probe and a successful one.
+func TestCircuitBreaker_States(t *testing.T) {
+	t.Parallel()
+
+	b, clock := newTestBreaker(2)
+	failure := errConnRefused
+
+	for i := range 2 {
+		if err := b.allow(); err != nil {
+			t.Fatalf("attempt %d: allow = %v, want the closed breaker to let it through", i+1, err)
+		}
+		b.done(context.Background(), failure)
+	}
+	if got := b.State(); got != CircuitOpen {
+		t.Fatalf("state after 2 failures = %v, want open", got)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow while open = %v, want ErrCircuitOpen", err)
+	}
+
+	clock.Advance(30 * time.Second)
+	if err := b.allow(); err != nil {
+		t.Fatalf("allow after the cooldown = %v, want the probe let through", err)
+	}
+	if got := b.State(); got != CircuitHalfOpen {
+		t.Fatalf("state during the probe = %v, want half_open", got)
+	}
+	if err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second allow during the probe = %v, want ErrCircuitOpen", err)
+	}
+	b.done(context.Background(), &StatusError{StatusCode: http.StatusBadGateway})
+	if got := b.State(); got != CircuitOpen {
+		t.Fatalf("state after a failed probe = %v, want open", got)
+	}
+
+	clock.Advance(30 * time.Second)
+	if err := b.allow(); err != nil {
+		t.Fatalf("allow after the second cooldown = %v", err)
+	}
+	b.done(context.Background(), nil)
+	if got := b.State(); got != CircuitClosed {
+		t.Fatalf("state after a successful probe = %v, want closed", got)
+	}
+
+	want := map[CircuitState]uint64{CircuitOpen: 2, CircuitHalfOpen: 2, CircuitClosed: 1}
+	got := b.Transitions()
+	for _, state := range CircuitStates {
+		if got[state] != want[state] {
+			t.Errorf("transitions to %v = %d, want %d", state, got[state], want[state])
+		}
+	}
+}
+
+// TestCircuitBreaker_Outcomes verifies which results count towards opening:
+// a 4xx shows Tdarr answering and resets the count, a cancelled request is
+// ignored, and so is any error once the caller's own context is done.
+func TestCircuitBreaker_Outcomes(t *testing.T) {
+	t.Parallel()
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Unix(0, 0))
+	defer cancel()
+
+	tests := []struct {
+		name     string
+		caller   context.Context
+		second   error
+		wantOpen bool
+	}{
+		{name: "transport error", second: fmt.Errorf("error sending HTTP Request: %w", errConnRefused), wantOpen: true},
+		{name: "dns error", second: &net.DNSError{Err: "no such host", Name: "tdarr.lan", IsNotFound: true}, wantOpen: true},
+		{name: "connection closed", second: io.ErrUnexpectedEOF, wantOpen: true},
+		{name: "5xx", second: &StatusError{StatusCode: http.StatusServiceUnavailable}, wantOpen: true},
+		{name: "429", second: &StatusError{StatusCode: http.StatusTooManyRequests}, wantOpen: true},
+		{name: "deadline", second: context.DeadlineExceeded, wantOpen: true},
+		{name: "4xx", second: &StatusError{StatusCode: http.StatusNotFound}},
+		{name: "redirect", second: errors.New("received Redirect Status Code: 302, ")},
+		{name: "authentication", second: errors.New("failed to authenticate request: no token")},
+		{name: "cancelled", second: context.Canceled},
+		{name: "caller deadline", caller: expired, second: context.DeadlineExceeded},
+		{name: "transport error after the caller gave up", caller: expired, second: errConnRefused},
+	}
+	for _, tt := range tests {
+		caller := tt.caller
+		if caller == nil {
+			caller = context.Background()
+		}
+		b, _ := newTestBreaker(2)
+		b.done(context.Background(), errConnRefused)
+		b.done(caller, tt.second)
+		if got := b.State() == CircuitOpen; got != tt.wantOpen {
+			t.Errorf("%s: open = %v, want %v", tt.name, got, tt.wantOpen)
+		}
+	}
+
+	// A cancelled probe frees the half-open slot without deciding the state.
+	b, clock := newTestBreaker(1)
+	b.done(context.Background(), errConnRefused)
+	clock.Advance(30 * time.Second)
+	if err := b.allow(); err != nil {
+		t.Fatalf("allow after the cooldown = %v", err)
+	}
+	b.done(context.Background(), context.Canceled)
+	if got := b.State(); got != CircuitHalfOpen {
+		t.Errorf("state after a cancelled probe = %v, want half_open", got)
+	}
+	if err := b.allow(); err != nil {
+		t.Errorf("allow after a cancelled probe = %v, want a new probe let through", err)
+	}
+}
+
+// TestClientTransport_CircuitBreaker verifies an open breaker fails requests
+// without sending them or waiting out the retries.
+func TestClientTransport_CircuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	var (
+		sleeps []time.Duration
+		calls  int
+	)
+	inner := roundTripFunc(func(*http.Request) (*http.Response, error) {
+		calls++
+		return nil, errConnRefused
+	})
+	b, _ := newTestBreaker(2)
+	tr := NewClientTransport(inner, WithAfter(collectingAfter(&sleeps)), WithBreaker(b))
+
+	for range 2 {
+		if _, err := tr.RoundTrip(newRequest(t, http.MethodGet, "http://example.com/api/v2/status", "")); err == nil {
+			t.Fatal("RoundTrip: want an error from the failing transport")
+		}
+	}
+	if calls != 6 {
+		t.Fatalf("calls = %d, want 6 (2 requests with 2 retries each)", calls)
+	}
+
+	_, err := tr.RoundTrip(newRequest(t, http.MethodPost, "http://example.com/api/v2/cruddb", `{}`))
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("RoundTrip error = %v, want ErrCircuitOpen", err)
+	}
+	if calls != 6 || len(sleeps) != 4 {
+		t.Errorf("calls = %d, sleeps = %d; want no attempt or wait once open", calls, len(sleeps))
+	}
+}
+
+// TestClientTransport_CircuitBreakerRequestFaults verifies a redirect, or a
+// request whose URL cannot be sent, fails without opening the breaker: the
+// fault is the request's, not Tdarr's.
+func TestClientTransport_CircuitBreakerRequestFaults(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{name: "3xx", url: srv.URL + "/api/v2/status"},
+		{name: "unsupported scheme", url: "gopher://tdarr.lan/api/v2/status"},
+		{name: "no host", url: "http:///api/v2/status"},
+	}
+	for _, tt := range tests {
+		var sleeps []time.Duration
+		b, _ := newTestBreaker(1)
+		tr := NewClientTransport(http.DefaultTransport, WithAfter(collectingAfter(&sleeps)), WithBreaker(b))
+		if _, err := tr.RoundTrip(newRequest(t, http.MethodGet, tt.url, "")); err == nil {
+			t.Fatalf("%s: RoundTrip: want an error", tt.name)
+		}
+		if got := b.State(); got != CircuitClosed {
+			t.Errorf("%s: breaker state = %v, want closed", tt.name, got)
+		}
+	}
+}
+
+// TestRequestClient_CircuitBreakerCallerDeadline verifies a request cut off by
+// the caller's deadline (e.g. a Prometheus scrape timeout) leaves the breaker
+// closed, while the request's own timeout expiring counts as a failure.
+func TestRequestClient_CircuitBreakerCallerDeadline(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("url.Parse: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		callerTimeout  time.Duration
+		requestTimeout time.Duration
+		wantState      CircuitState
+	}{
+		{name: "caller deadline", callerTimeout: 20 * time.Millisecond, requestTimeout: 5 * time.Second, wantState: CircuitClosed},
+		{name: "request timeout", callerTimeout: 5 * time.Second, requestTimeout: 20 * time.Millisecond, wantState: CircuitOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			b := NewCircuitBreaker(1, 30*time.Second)
+			policies := map[string]RequestPolicy{"/api/v2/status": {Timeout: tt.requestTimeout}}
+			c, err := NewRequestClient(u, false, 5, "", WithPolicies(policies), WithCircuitBreaker(b))
+			if err != nil {
+				t.Fatalf("NewRequestClient: %v", err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), tt.callerTimeout)
+			defer cancel()
+
+			if err := c.DoRequest(ctx, "/api/v2/status", &payloadTarget{}); !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("DoRequest error = %v, want context.DeadlineExceeded", err)
+			}
+			if got := b.State(); got != tt.wantState {
+				t.Errorf("breaker state = %v, want %v", got, tt.wantState)
+			}
+		})
+	}
+}
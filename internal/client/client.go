@@ -18,8 +18,23 @@ import (
 // Client struct is an *Arr client.
 type RequestClient struct {
 	httpClient http.Client
-	apiKey     string
-	URL        url.URL
+	// apiKey is consulted on every request attempt (see APIKeySource).
+	apiKey APIKeySource
+	// timeout bounds each request, retries included, unless its path has a
+	// RequestPolicy with its own Timeout.
+	timeout time.Duration
+	// policies are the per-path request policies (see WithPolicies).
+	policies map[string]RequestPolicy
+	// tlsFiles, when set, supplies the transport's TLS config (see WithTLSFiles).
+	tlsFiles *TLSFiles
+	// auth are the authenticators applied after the api key (see WithAuth).
+	auth Authenticators
+	// breaker, when set, short-circuits requests to an unreachable Tdarr (see
+	// WithCircuitBreaker).
+	breaker *CircuitBreaker
+	// metrics, when set, records every request (see WithMetrics).
+	metrics *Metrics
+	URL     url.URL
 	// logger is the client's logger, defaulting to the package-global log.Logger.
 	// Injected (not read from the global at each call) so tests can silence or
 	// capture client logs deterministically.
@@ -28,31 +43,99 @@ type RequestClient struct {
 
 type QueryParams = url.Values
 
+// RequestClientOption is a functional option for NewRequestClient.
+type RequestClientOption func(*RequestClient)
+
+// WithAPIKeySource replaces the static apiKeyAuth passed to NewRequestClient
+// with a source consulted per request, e.g. a FileAPIKey.
+func WithAPIKeySource(source APIKeySource) RequestClientOption {
+	return func(c *RequestClient) {
+		c.apiKey = source
+	}
+}
+
+// WithAuth adds authenticators applied after the x-api-key header, in order,
+// e.g. the credential of an auth proxy in front of Tdarr.
+func WithAuth(authenticators ...Authenticator) RequestClientOption {
+	return func(c *RequestClient) {
+		c.auth = append(c.auth, authenticators...)
+	}
+}
+
+// WithTLSFiles replaces the verify_ssl-only TLS config with tlsFiles.Config:
+// a custom CA bundle, client certificate, server name or minimum version. The
+// verifySsl passed to NewRequestClient is then ignored in favour of the one
+// tlsFiles was built with.
+func WithTLSFiles(tlsFiles *TLSFiles) RequestClientOption {
+	return func(c *RequestClient) {
+		c.tlsFiles = tlsFiles
+	}
+}
+
+// WithPolicies sets the RequestPolicy for requests to each path, e.g.
+// "/api/v2/status". Requests to other paths keep the timeoutSeconds passed to
+// NewRequestClient and the transport's default backoff.
+func WithPolicies(policies map[string]RequestPolicy) RequestClientOption {
+	return func(c *RequestClient) {
+		c.policies = policies
+	}
+}
+
+// WithCircuitBreaker sends every request through breaker, see CircuitBreaker.
+func WithCircuitBreaker(breaker *CircuitBreaker) RequestClientOption {
+	return func(c *RequestClient) {
+		c.breaker = breaker
+	}
+}
+
+// WithMetrics records the latency, status code, retries and response size of
+// every request in m.
+func WithMetrics(m *Metrics) RequestClientOption {
+	return func(c *RequestClient) {
+		c.metrics = m
+	}
+}
+
 // NewRequestClient constructs an HTTP client for Tdarr requests.
 //   - verifySsl: when true, TLS certificates are verified (InsecureSkipVerify=false).
-//   - timeoutSeconds: HTTP client timeout; use config.HttpTimeoutSeconds (default 15).
+//   - timeoutSeconds: per-request timeout, retries included; use config.HttpTimeoutSeconds (default 15).
+//   - apiKeyAuth: static api key; ignored when WithAPIKeySource is given.
 //
 // The global http.DefaultTransport is never mutated; a fresh clone is created per call.
-func NewRequestClient(parsedUrl *url.URL, verifySsl bool, timeoutSeconds int, apiKeyAuth string) (*RequestClient, error) {
+func NewRequestClient(parsedUrl *url.URL, verifySsl bool, timeoutSeconds int, apiKeyAuth string, opts ...RequestClientOption) (*RequestClient, error) {
+	c := &RequestClient{
+		URL:     *parsedUrl,
+		apiKey:  StaticAPIKey(apiKeyAuth),
+		timeout: time.Duration(timeoutSeconds) * time.Second,
+		logger:  log.Logger,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
 	baseTransport := http.DefaultTransport.(*http.Transport).Clone()
-	baseTransport.TLSClientConfig = &tls.Config{InsecureSkipVerify: !verifySsl}
-
-	return &RequestClient{
-		httpClient: http.Client{
-			// If CheckRedirect is nil, the Client uses its default policy,
-			// which is to stop after 10 consecutive requests.
-			// uncomment below to not follow redirects
-			// CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			// 	return http.ErrUseLastResponse
-			// },
-			// TdarrTransport implements `RoundTrip`
-			Transport: NewClientTransport(baseTransport),
-			Timeout:   time.Duration(timeoutSeconds) * time.Second,
-		},
-		URL:    *parsedUrl,
-		apiKey: apiKeyAuth,
-		logger: log.Logger,
-	}, nil
+	if c.tlsFiles != nil {
+		baseTransport.TLSClientConfig = c.tlsFiles.Config(parsedUrl.Hostname())
+	} else {
+		baseTransport.TLSClientConfig = &tls.Config{InsecureSkipVerify: !verifySsl}
+	}
+	c.httpClient = http.Client{
+		// If CheckRedirect is nil, the Client uses its default policy,
+		// which is to stop after 10 consecutive requests.
+		// uncomment below to not follow redirects
+		// CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		// 	return http.ErrUseLastResponse
+		// },
+		// TdarrTransport implements `RoundTrip`
+		// No http.Client Timeout: each request gets a context deadline from
+		// requestContext instead, so it can differ per path.
+		Transport: NewClientTransport(baseTransport,
+			WithAuthenticator(append(Authenticators{APIKeyAuth{Source: c.apiKey}}, c.auth...)),
+			WithBreaker(c.breaker),
+			WithTransportMetrics(c.metrics),
+		),
+	}
+	return c, nil
 }
 
 // maxBodyHeadBytes bounds how much of a failed response body is captured for the
@@ -105,6 +188,23 @@ func (c *RequestClient) unmarshalBody(body io.Reader, target any) error {
 	return nil
 }
 
+// requestContext derives the context for a request to path: its timeout and,
+// for a path with a RequestPolicy, the policy for ClientTransport to apply.
+// ctx is recorded as the caller, so its own deadline expiring is not taken for
+// Tdarr failing to answer within the timeout.
+func (c *RequestClient) requestContext(ctx context.Context, path string) (context.Context, context.CancelFunc) {
+	timeout := c.timeout
+	ctx = contextWithCaller(ctx)
+	policy, ok := c.policies[path]
+	if ok {
+		ctx = contextWithPolicy(ctx, policy)
+		if policy.Timeout > 0 {
+			timeout = policy.Timeout
+		}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
 // DoRequest - Take a HTTP Request and return Unmarshaled data. The ctx is
 // attached to the request so a cancelled/expired context aborts it in flight.
 func (c *RequestClient) DoRequest(ctx context.Context, path string, target any, queryParams ...QueryParams) error {
@@ -120,16 +220,14 @@ func (c *RequestClient) DoRequest(ctx context.Context, path string, target any,
 	url := c.URL.JoinPath(path)
 	url.RawQuery = values.Encode()
 
+	ctx, cancel := c.requestContext(ctx, path)
+	defer cancel()
 	c.logger.Debug().Str("url", url.String()).Msg("Sending HTTP request")
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url.String(), nil)
 	if err != nil {
 		c.logger.Error().Err(err).Str("url", url.String()).Msg("Failed to create HTTP Request")
 		return fmt.Errorf("failed to create HTTP Request(%s): %w", url, err)
 	}
-	if c.apiKey != "" {
-		c.logger.Debug().Str("authHeaderField", "x-api-key").Msg("Setting Authorization header - api token is set")
-		req.Header.Set("x-api-key", c.apiKey)
-	}
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to execute HTTP Request(%s): %w", url, err)
@@ -147,6 +245,8 @@ func (c *RequestClient) DoRequest(ctx context.Context, path string, target any,
 // attached to the request so a cancelled/expired context aborts it in flight.
 func (c *RequestClient) DoPostRequest(ctx context.Context, path string, target any, payload []byte) error {
 	url := c.URL.JoinPath(path)
+	ctx, cancel := c.requestContext(ctx, path)
+	defer cancel()
 	c.logger.Debug().Str("url", url.String()).Msg("Sending HTTP POST request")
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url.String(), bytes.NewReader(payload))
 	if err != nil {
@@ -155,10 +255,6 @@ func (c *RequestClient) DoPostRequest(ctx context.Context, path string, target a
 
 	// json content
 	req.Header.Set("Content-Type", "application/json")
-	if c.apiKey != "" {
-		c.logger.Debug().Str("authHeaderField", "x-api-key").Msg("Setting Authorization header - api token is set")
-		req.Header.Set("x-api-key", c.apiKey)
-	}
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to execute HTTP Request(%s): %w", url, err)
@@ -0,0 +1,166 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// requestDurationBuckets reach past the default 15s http_timeout_seconds: a
+// slow get-pies sweep on a large library is what the histogram is for.
+var requestDurationBuckets = []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 15, 30}
+
+// Metrics records each request attempt a ClientTransport sends to Tdarr,
+// labeled by endpoint (see endpointLabel). A nil *Metrics records nothing.
+type Metrics struct {
+	duration      *prometheus.HistogramVec
+	requests      *prometheus.CounterVec
+	retries       *prometheus.CounterVec
+	responseBytes *prometheus.CounterVec
+}
+
+// NewMetrics registers the request metrics with reg, typically wrapped with
+// the target's tdarr_instance label. Metrics reg already has, e.g. from the
+// client of the same target before a configuration reload, are reused so
+// their counts carry on.
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+	m := newMetrics()
+	var err error
+	if m.duration, err = register(reg, m.duration); err != nil {
+		return nil, err
+	}
+	if m.requests, err = register(reg, m.requests); err != nil {
+		return nil, err
+	}
+	if m.retries, err = register(reg, m.retries); err != nil {
+		return nil, err
+	}
+	if m.responseBytes, err = register(reg, m.responseBytes); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// UnregisterMetrics removes the request metrics NewMetrics registered with
+// reg, wrapped the same way, so a target dropped by a configuration reload
+// stops being served. A registry matches collectors by their descriptors, so
+// this needs none of the target's own *Metrics.
+func UnregisterMetrics(reg prometheus.Registerer) {
+	m := newMetrics()
+	for _, c := range []prometheus.Collector{m.duration, m.requests, m.retries, m.responseBytes} {
+		reg.Unregister(c)
+	}
+}
+
+// newMetrics builds the request metrics without registering them.
+func newMetrics() *Metrics {
+	return &Metrics{
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tdarr_exporter_http_request_duration_seconds",
+			Help:    "Duration of HTTP requests to Tdarr, one observation per attempt, from sending the request until its response body was read. Transport errors are included.",
+			Buckets: requestDurationBuckets,
+		}, []string{"endpoint"}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tdarr_exporter_http_requests_total",
+			Help: "HTTP requests sent to Tdarr, one per attempt, by response status code, or \"error\" for a transport error such as a timeout.",
+		}, []string{"endpoint", "code"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tdarr_exporter_http_retries_total",
+			Help: "HTTP requests to Tdarr sent again after a failed attempt.",
+		}, []string{"endpoint"}),
+		responseBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tdarr_exporter_http_response_bytes_total",
+			Help: "Bytes of HTTP response bodies read from Tdarr.",
+		}, []string{"endpoint"}),
+	}
+}
+
+// register registers c with reg, or returns the collector reg already has in
+// its place.
+func register[T prometheus.Collector](reg prometheus.Registerer, c T) (T, error) {
+	err := reg.Register(c)
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		if existing, ok := are.ExistingCollector.(T); ok {
+			return existing, nil
+		}
+	}
+	return c, err
+}
+
+// endpointLabel names the Tdarr endpoint of a request: the last element of
+// its path (status, get-pies, get-nodes), and for cruddb the collection the
+// body queries too, e.g. cruddb/StatisticsJSONDB, as that is what tells a
+// large statistics document from a short library list.
+func endpointLabel(urlPath string, body []byte) string {
+	endpoint := path.Base(urlPath)
+	if len(body) == 0 {
+		return endpoint
+	}
+	var payload struct {
+		Data struct {
+			Collection string `json:"collection"`
+		} `json:"data"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.Data.Collection != "" {
+		endpoint += "/" + payload.Data.Collection
+	}
+	return endpoint
+}
+
+// instrument counts one attempt to endpoint started at start. A response's
+// duration and size are recorded once its body is closed, so they cover
+// reading it; resp is returned with its body wrapped for that.
+func (m *Metrics) instrument(endpoint string, start time.Time, resp *http.Response, err error) *http.Response {
+	if m == nil {
+		return resp
+	}
+	if err != nil {
+		m.requests.WithLabelValues(endpoint, "error").Inc()
+		m.duration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+		return resp
+	}
+	m.requests.WithLabelValues(endpoint, strconv.Itoa(resp.StatusCode)).Inc()
+	resp.Body = &countingBody{ReadCloser: resp.Body, done: func(n int64) {
+		m.duration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+		m.responseBytes.WithLabelValues(endpoint).Add(float64(n))
+	}}
+	return resp
+}
+
+// retry counts a request to endpoint sent again.
+func (m *Metrics) retry(endpoint string) {
+	if m == nil {
+		return
+	}
+	m.retries.WithLabelValues(endpoint).Inc()
+}
+
+// countingBody counts the bytes read from a response body and reports them
+// to done when it is first closed.
+type countingBody struct {
+	io.ReadCloser
+	n      int64
+	done   func(n int64)
+	closed bool
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	if !b.closed {
+		b.closed = true
+		b.done(b.n)
+	}
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,162 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestEndpointLabel(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		path string
+		body string
+		want string
+	}{
+		{"get", "/api/v2/status", "", "status"},
+		{"post without collection", "/api/v2/stats/get-pies", `{"data":{"libraryId":"lib1"}}`, "get-pies"},
+		{"cruddb collection", "/api/v2/cruddb", `{"data":{"collection":"StatisticsJSONDB","mode":"getById"}}`, "cruddb/StatisticsJSONDB"},
+		{"body not json", "/api/v2/cruddb", "not json", "cruddb"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if got := endpointLabel(tc.path, []byte(tc.body)); got != tc.want {
+				t.Errorf("endpointLabel(%q) = %q, want %q", tc.path, got, tc.want)
+			}
+		})
+	}
+}
+
+// TestClientTransport_Metrics verifies each attempt is counted by status code
+// and timed, the retry counted, and the body of the returned response sized
+// once it is closed.
+func TestClientTransport_Metrics(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	reg := prometheus.NewRegistry()
+	m, err := NewMetrics(reg)
+	if err != nil {
+		t.Fatalf("NewMetrics: %v", err)
+	}
+	transport := NewClientTransport(http.DefaultTransport,
+		WithBackoff([]time.Duration{time.Millisecond}),
+		WithAfter(immediateAfter),
+		WithTransportMetrics(m),
+	)
+	req := newRequest(t, http.MethodPost, srv.URL+"/api/v2/cruddb", `{"data":{"collection":"StatisticsJSONDB"}}`)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip: %v", err)
+	}
+	drainClose(resp)
+
+	const endpoint = "cruddb/StatisticsJSONDB"
+	if got := testutil.ToFloat64(m.requests.WithLabelValues(endpoint, "502")); got != 1 {
+		t.Errorf("requests{code=502} = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.requests.WithLabelValues(endpoint, "200")); got != 1 {
+		t.Errorf("requests{code=200} = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.retries.WithLabelValues(endpoint)); got != 1 {
+		t.Errorf("retries = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.responseBytes.WithLabelValues(endpoint)); got != float64(len(`{"ok":true}`)) {
+		t.Errorf("response bytes = %v, want %d", got, len(`{"ok":true}`))
+	}
+	var hist dto.Metric
+	if err := m.duration.WithLabelValues(endpoint).(prometheus.Histogram).Write(&hist); err != nil {
+		t.Fatalf("write histogram: %v", err)
+	}
+	if got := hist.GetHistogram().GetSampleCount(); got != 2 {
+		t.Errorf("duration observations = %d, want 2 (one per attempt)", got)
+	}
+
+	// A transport error is counted as code "error".
+	failing := NewClientTransport(&fakeRoundTripper{responses: []fakeResponse{{err: errors.New("dial refused")}}},
+		WithBackoff(nil), WithTransportMetrics(m))
+	if _, err := failing.RoundTrip(newRequest(t, http.MethodGet, srv.URL+"/api/v2/status", "")); err == nil {
+		t.Fatal("RoundTrip with a failing transport: want an error")
+	}
+	if got := testutil.ToFloat64(m.requests.WithLabelValues("status", "error")); got != 1 {
+		t.Errorf("requests{endpoint=status,code=error} = %v, want 1", got)
+	}
+}
+
+// TestNewMetrics_ReusesRegistered verifies a second NewMetrics on the same
+// registerer, as a reload's new collector does, shares the first one's
+// counters instead of failing.
+func TestNewMetrics_ReusesRegistered(t *testing.T) {
+	t.Parallel()
+
+	reg := prometheus.WrapRegistererWith(prometheus.Labels{"tdarr_instance": "tdarr.lan"}, prometheus.NewRegistry())
+	first, err := NewMetrics(reg)
+	if err != nil {
+		t.Fatalf("first NewMetrics: %v", err)
+	}
+	first.retry("status")
+	second, err := NewMetrics(reg)
+	if err != nil {
+		t.Fatalf("second NewMetrics: %v", err)
+	}
+	second.retry("status")
+	if got := testutil.ToFloat64(first.retries.WithLabelValues("status")); got != 2 {
+		t.Errorf("retries = %v, want 2 from both", got)
+	}
+}
+
+// TestUnregisterMetrics verifies UnregisterMetrics removes one target's series
+// and leaves another target's, registered in the same registry, alone.
+func TestUnregisterMetrics(t *testing.T) {
+	t.Parallel()
+
+	registry := prometheus.NewRegistry()
+	regA := prometheus.WrapRegistererWith(prometheus.Labels{"tdarr_instance": "a.lan"}, registry)
+	regB := prometheus.WrapRegistererWith(prometheus.Labels{"tdarr_instance": "b.lan"}, registry)
+	for _, reg := range []prometheus.Registerer{regA, regB} {
+		m, err := NewMetrics(reg)
+		if err != nil {
+			t.Fatalf("NewMetrics: %v", err)
+		}
+		m.retry("status")
+	}
+
+	UnregisterMetrics(regA)
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	var instances []string
+	for _, mf := range families {
+		for _, metric := range mf.GetMetric() {
+			for _, lp := range metric.GetLabel() {
+				if lp.GetName() == "tdarr_instance" {
+					instances = append(instances, mf.GetName()+"/"+lp.GetValue())
+				}
+			}
+		}
+	}
+	if len(instances) != 1 || instances[0] != "tdarr_exporter_http_retries_total/b.lan" {
+		t.Errorf("series after unregistering a.lan = %v, want only b.lan's retries", instances)
+	}
+}
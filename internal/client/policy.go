@@ -0,0 +1,87 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// RequestPolicy is the timeout and retry behavior for requests to one Tdarr
+// endpoint, so a cheap probe like /api/v2/status can fail fast while the slow
+// per-library get-pies calls get a longer budget.
+type RequestPolicy struct {
+	// Timeout bounds the request, retries and their waits included. 0 uses the
+	// client's timeout.
+	Timeout time.Duration
+	// Retries is the number of attempts after the first.
+	Retries int
+	// Backoff is the wait before the first retry, doubled for each further
+	// retry up to MaxBackoff (no cap when 0).
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Jitter spreads each wait by up to this fraction either way, 0 to 1, so
+	// exporters scraping one Tdarr do not retry in lockstep.
+	Jitter float64
+	// RetryStatuses are the response codes retried. nil retries 429 and every
+	// 5xx. A transport error is always retried.
+	RetryStatuses []int
+}
+
+type policyKey struct{}
+
+// contextWithPolicy attaches p to ctx for ClientTransport to apply in place of
+// its default backoff.
+func contextWithPolicy(ctx context.Context, p RequestPolicy) context.Context {
+	return context.WithValue(ctx, policyKey{}, p)
+}
+
+func policyFromContext(ctx context.Context) (RequestPolicy, bool) {
+	p, ok := ctx.Value(policyKey{}).(RequestPolicy)
+	return p, ok
+}
+
+// retryableStatus reports whether p retries a response with code.
+func (p RequestPolicy) retryableStatus(code int) bool {
+	if p.RetryStatuses == nil {
+		return code == http.StatusTooManyRequests || code >= 500
+	}
+	return slices.Contains(p.RetryStatuses, code)
+}
+
+// backoff returns the wait before retry i (0-based), with jitter applied from
+// random, a value in [0, 1).
+func (p RequestPolicy) backoff(i int, random float64) time.Duration {
+	wait := p.Backoff
+	for range i {
+		if p.MaxBackoff > 0 && wait >= p.MaxBackoff {
+			break
+		}
+		wait *= 2
+	}
+	if p.MaxBackoff > 0 {
+		wait = min(wait, p.MaxBackoff)
+	}
+	return time.Duration(float64(wait) * (1 + p.Jitter*(2*random-1)))
+}
+
+// retryAfter returns the wait a 429 or 503 response asks for in its
+// Retry-After header, as delay-seconds or an HTTP date. ok is false for other
+// responses and for a missing or malformed header.
+func retryAfter(resp *http.Response, now time.Time) (wait time.Duration, ok bool) {
+	if resp == nil || (resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable) {
+		return 0, false
+	}
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(date.Sub(now), 0), true
+	}
+	return 0, false
+}
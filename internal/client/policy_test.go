@@ -0,0 +1,235 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestPolicy_Backoff(t *testing.T) {
+	t.Parallel()
+
+	p := RequestPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	for i, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if got := p.backoff(i, 0.5); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", i, got, want)
+		}
+	}
+
+	p.Jitter = 0.5
+	if got := p.backoff(0, 0); got != 500*time.Millisecond {
+		t.Errorf("backoff with the lowest jitter = %v, want 500ms", got)
+	}
+	if got := p.backoff(0, 0.999); got <= 1400*time.Millisecond || got >= 1500*time.Millisecond {
+		t.Errorf("backoff with the highest jitter = %v, want just under 1.5s", got)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		status int
+		header string
+		want   time.Duration
+		wantOk bool
+	}{
+		{name: "seconds on 429", status: 429, header: "7", want: 7 * time.Second, wantOk: true},
+		{name: "date on 503", status: 503, header: now.Add(90 * time.Second).Format(http.TimeFormat), want: 90 * time.Second, wantOk: true},
+		{name: "past date", status: 503, header: now.Add(-time.Minute).Format(http.TimeFormat), want: 0, wantOk: true},
+		{name: "ignored on 500", status: 500, header: "7"},
+		{name: "missing", status: 429},
+		{name: "malformed", status: 429, header: "soon"},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+		if tt.header != "" {
+			resp.Header.Set("Retry-After", tt.header)
+		}
+		got, ok := retryAfter(resp, now)
+		if got != tt.want || ok != tt.wantOk {
+			t.Errorf("%s: retryAfter = %v, %v; want %v, %v", tt.name, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+// statusSequence returns a round tripper answering with each status in turn,
+// setting Retry-After where given, and counting the attempts in *calls.
+func statusSequence(calls *int, statuses []int, retryAfter map[int]string) http.RoundTripper {
+	return roundTripFunc(func(*http.Request) (*http.Response, error) {
+		status := statuses[min(*calls, len(statuses)-1)]
+		resp := &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}
+		if v, ok := retryAfter[*calls]; ok {
+			resp.Header.Set("Retry-After", v)
+		}
+		*calls++
+		return resp, nil
+	})
+}
+
+// TestClientTransport_RequestPolicy verifies a policy in the request context
+// replaces the default backoff: its retry count, exponential backoff, retryable
+// statuses and Retry-After.
+func TestClientTransport_RequestPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy := RequestPolicy{Retries: 3, Backoff: 10 * time.Millisecond, MaxBackoff: 25 * time.Millisecond}
+	tests := []struct {
+		name       string
+		policy     RequestPolicy
+		statuses   []int
+		retryAfter map[int]string
+		wantCalls  int
+		wantSleeps []time.Duration
+		wantStatus int // 0: want a *StatusError with the last status
+	}{
+		{
+			name:       "exponential backoff capped",
+			policy:     policy,
+			statuses:   []int{500, 502, 504, 200},
+			wantCalls:  4,
+			wantSleeps: []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 25 * time.Millisecond},
+			wantStatus: 200,
+		},
+		{
+			name:       "429 retried honoring Retry-After",
+			policy:     policy,
+			statuses:   []int{429, 503, 200},
+			retryAfter: map[int]string{0: "0", 1: "2"},
+			wantCalls:  3,
+			wantSleeps: []time.Duration{0, 2 * time.Second},
+			wantStatus: 200,
+		},
+		{
+			name:       "status outside the list not retried",
+			policy:     RequestPolicy{Retries: 3, RetryStatuses: []int{503}},
+			statuses:   []int{500},
+			wantCalls:  1,
+			wantSleeps: nil,
+		},
+		{
+			name:       "no retries",
+			policy:     RequestPolicy{},
+			statuses:   []int{503},
+			wantCalls:  1,
+			wantSleeps: nil,
+		},
+		{
+			name:       "retries exhausted",
+			policy:     RequestPolicy{Retries: 1},
+			statuses:   []int{429},
+			wantCalls:  2,
+			wantSleeps: []time.Duration{0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var (
+				sleeps []time.Duration
+				calls  int
+			)
+			tr := NewClientTransport(statusSequence(&calls, tt.statuses, tt.retryAfter), WithAfter(collectingAfter(&sleeps)))
+			req := newRequest(t, http.MethodGet, "http://example.com/api/v2/status", "")
+			req = req.WithContext(contextWithPolicy(req.Context(), tt.policy))
+
+			resp, err := tr.RoundTrip(req)
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if len(sleeps) != len(tt.wantSleeps) {
+				t.Errorf("sleeps = %v, want %v", sleeps, tt.wantSleeps)
+			} else {
+				for i := range sleeps {
+					if sleeps[i] != tt.wantSleeps[i] {
+						t.Errorf("sleeps = %v, want %v", sleeps, tt.wantSleeps)
+						break
+					}
+				}
+			}
+			if tt.wantStatus != 0 {
+				if err != nil {
+					t.Fatalf("RoundTrip: %v", err)
+				}
+				_ = resp.Body.Close()
+				if resp.StatusCode != tt.wantStatus {
+					t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+				}
+				return
+			}
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.statuses[len(tt.statuses)-1] {
+				t.Errorf("RoundTrip error = %v, want a *StatusError for %d", err, tt.statuses[len(tt.statuses)-1])
+			}
+		})
+	}
+}
+
+// TestClientTransport_RetryAfterBeyondDeadline verifies a Retry-After longer
+// than the time left before the request's deadline ends the retries at once.
+func TestClientTransport_RetryAfterBeyondDeadline(t *testing.T) {
+	t.Parallel()
+
+	var (
+		sleeps []time.Duration
+		calls  int
+	)
+	tr := NewClientTransport(statusSequence(&calls, []int{503}, map[int]string{0: "3600"}), WithAfter(collectingAfter(&sleeps)))
+	ctx, cancel := context.WithTimeout(contextWithPolicy(context.Background(), RequestPolicy{Retries: 2}), time.Minute)
+	defer cancel()
+	req := newRequest(t, http.MethodGet, "http://example.com/api/v2/status", "").WithContext(ctx)
+
+	_, err := tr.RoundTrip(req)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("RoundTrip error = %v, want a *StatusError for 503", err)
+	}
+	if calls != 1 || len(sleeps) != 0 {
+		t.Errorf("calls = %d, sleeps = %v; want 1 call and no wait", calls, sleeps)
+	}
+}
+
+// TestRequestClient_WithPolicies verifies each path gets its own timeout: a
+// slow status probe fails fast while a slow get-pies has the budget to finish.
+func TestRequestClient_WithPolicies(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-r.Context().Done():
+			return
+		}
+		_, _ = io.WriteString(w, `{"name":"slow"}`)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	c, err := NewRequestClient(u, true, 5, "", WithPolicies(map[string]RequestPolicy{
+		"/api/v2/status":         {Timeout: 20 * time.Millisecond},
+		"/api/v2/stats/get-pies": {Timeout: 5 * time.Second},
+	}))
+	if err != nil {
+		t.Fatalf("NewRequestClient: %v", err)
+	}
+
+	var target payloadTarget
+	start := time.Now()
+	err = c.DoRequest(context.Background(), "/api/v2/status", &target)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("status request error = %v, want a deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("status request took %v, want it cut off at its 20ms timeout", elapsed)
+	}
+	if err := c.DoPostRequest(context.Background(), "/api/v2/stats/get-pies", &target, []byte(`{}`)); err != nil {
+		t.Errorf("get-pies request: %v", err)
+	}
+}
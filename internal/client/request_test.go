@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -167,6 +168,27 @@ func TestDoRequest_UnmarshalError(t *testing.T) {
 	}
 }
 
+// TestDoPostRequest_StatusError verifies a 4xx reaches the caller as a
+// *StatusError carrying the code, through the http.Client and request wrapping.
+func TestDoPostRequest_StatusError(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	c := newTestClient(t, srv.URL, "secret-key")
+
+	var target payloadTarget
+	err := c.DoPostRequest(context.Background(), "/api/v2/stats/get-pies", &target, []byte(`{}`))
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("DoPostRequest error = %v, want a *StatusError", err)
+	}
+	if statusErr.StatusCode != http.StatusNotFound {
+		t.Errorf("StatusCode = %d, want 404", statusErr.StatusCode)
+	}
+}
+
 // TestDoPostRequest_HappyPath verifies a POST: method POST, JSON content type,
 // the x-api-key header, the request body equals the payload, and the response
 // unmarshals into target.
@@ -0,0 +1,271 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// TLSOptions are the TLS settings for the Tdarr client beyond verify_ssl. Zero
+// values keep the Go defaults (system roots, no client certificate, SNI from the
+// url host, TLS 1.2 minimum).
+type TLSOptions struct {
+	// CAFile is a PEM bundle that replaces the system roots for verifying Tdarr.
+	CAFile string
+	// CertFile and KeyFile are a PEM client certificate and key for mTLS; both or
+	// neither.
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the name sent as SNI and verified against the server
+	// certificate, e.g. when the url is an IP or an internal alias.
+	ServerName string
+	// MinVersion is a crypto/tls version constant, 0 for the default.
+	MinVersion uint16
+}
+
+// TLSFilesOption is a functional option for NewTLSFiles.
+type TLSFilesOption func(*TLSFiles)
+
+// WithTLSCheckInterval sets how often the certificate files are checked for
+// changes. Default: 10s.
+func WithTLSCheckInterval(interval time.Duration) TLSFilesOption {
+	return func(f *TLSFiles) {
+		f.interval = interval
+	}
+}
+
+// WithTLSClock injects the clock used to rate-limit file checks, replacing
+// time.Now. Intended for tests.
+func WithTLSClock(now func() time.Time) TLSFilesOption {
+	return func(f *TLSFiles) {
+		f.now = now
+	}
+}
+
+// WithTLSLogger injects the logger for reload messages. Defaults to log.Logger.
+func WithTLSLogger(logger zerolog.Logger) TLSFilesOption {
+	return func(f *TLSFiles) {
+		f.logger = logger
+	}
+}
+
+// TLSFiles builds the client's *tls.Config from TLSOptions and keeps the CA
+// bundle and client certificate current as the files change on disk (e.g. a
+// cert-manager renewal). Like FileAPIKey, files are re-checked lazily, at most
+// once per interval, from the handshake callbacks: GetClientCertificate serves
+// the current certificate and, when a CA file is set, VerifyConnection verifies
+// against the current pool. A file that fails to load keeps the previous
+// material in use and logs a warning. Only new connections see a reload;
+// established keep-alive connections finish with what they negotiated.
+type TLSFiles struct {
+	opts      TLSOptions
+	verifySsl bool
+	interval  time.Duration
+	now       func() time.Time
+	logger    zerolog.Logger
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	caStamp   fileStamp
+	certStamp [2]fileStamp
+	roots     *x509.CertPool
+	cert      *tls.Certificate
+}
+
+// fileStamp identifies one version of a file for change detection. Stat
+// follows symlinks, so the atomic symlink swap Kubernetes uses for Secret and
+// ConfigMap volumes shows up as a change.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFile(path string) (fileStamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// NewTLSFiles loads the configured CA bundle and client certificate once.
+// Unlike a later reload, a load failure here is an error.
+func NewTLSFiles(opts TLSOptions, verifySsl bool, fileOpts ...TLSFilesOption) (*TLSFiles, error) {
+	if (opts.CertFile == "") != (opts.KeyFile == "") {
+		return nil, errors.New("tls client certificate and key must be set together")
+	}
+	f := &TLSFiles{
+		opts:      opts,
+		verifySsl: verifySsl,
+		interval:  10 * time.Second,
+		now:       time.Now,
+		logger:    log.Logger,
+	}
+	for _, opt := range fileOpts {
+		opt(f)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.loadCALocked(); err != nil {
+		return nil, err
+	}
+	if err := f.loadCertLocked(); err != nil {
+		return nil, err
+	}
+	f.checkedAt = f.now()
+	return f, nil
+}
+
+// Config returns the *tls.Config for a client of host (the url hostname). It
+// holds callbacks into f rather than copies of the material, so reloads apply
+// to it. host is the name the server certificate is verified against when a CA
+// file is set and no ServerName is: VerifyConnection only sees the SNI, which
+// is empty for an IP address, and must not skip the hostname check then. A
+// redirect to another host therefore fails verification rather than passing
+// unchecked.
+func (f *TLSFiles) Config(host string) *tls.Config {
+	cfg := &tls.Config{
+		InsecureSkipVerify: !f.verifySsl,
+		ServerName:         f.opts.ServerName,
+		MinVersion:         f.opts.MinVersion,
+	}
+	if f.opts.CertFile != "" {
+		cfg.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			f.maybeReloadLocked()
+			return f.cert, nil
+		}
+	}
+	if f.opts.CAFile != "" && f.verifySsl {
+		// The stdlib verifier reads RootCAs once per tls.Config; to verify
+		// against a pool that can change, the built-in verification is turned
+		// off and VerifyConnection performs the same chain and hostname check
+		// with the current pool. This is the pattern the crypto/tls docs give
+		// for custom verification.
+		cfg.InsecureSkipVerify = true
+		name := f.opts.ServerName
+		if name == "" {
+			name = host
+		}
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			return f.verifyConnection(cs, name)
+		}
+	}
+	return cfg
+}
+
+func (f *TLSFiles) verifyConnection(cs tls.ConnectionState, name string) error {
+	if name == "" {
+		return errors.New("tls: no server name to verify the certificate against")
+	}
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("tls: server presented no certificate")
+	}
+	f.mu.Lock()
+	f.maybeReloadLocked()
+	roots := f.roots
+	f.mu.Unlock()
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		DNSName:       name,
+	})
+	return err
+}
+
+// ClientCertificateNotAfter returns the expiry of the current client
+// certificate, checking the files for a renewal first. ok is false when no
+// client certificate is configured.
+func (f *TLSFiles) ClientCertificateNotAfter() (notAfter time.Time, ok bool) {
+	if f.opts.CertFile == "" {
+		return time.Time{}, false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.maybeReloadLocked()
+	return f.cert.Leaf.NotAfter, true
+}
+
+// maybeReloadLocked reloads any file whose stamp changed, at most once per
+// interval. Callers must hold f.mu.
+func (f *TLSFiles) maybeReloadLocked() {
+	now := f.now()
+	if now.Sub(f.checkedAt) < f.interval {
+		return
+	}
+	f.checkedAt = now
+	if f.opts.CAFile != "" {
+		if stamp, err := statFile(f.opts.CAFile); err != nil || stamp != f.caStamp {
+			if err := f.loadCALocked(); err != nil {
+				f.logger.Warn().Err(err).Str("path", f.opts.CAFile).Msg("Failed to reload tls ca file, keeping the previous bundle")
+			} else {
+				f.logger.Info().Str("path", f.opts.CAFile).Msg("Reloaded tls ca file")
+			}
+		}
+	}
+	if f.opts.CertFile != "" {
+		certStamp, certErr := statFile(f.opts.CertFile)
+		keyStamp, keyErr := statFile(f.opts.KeyFile)
+		if certErr != nil || keyErr != nil || [2]fileStamp{certStamp, keyStamp} != f.certStamp {
+			if err := f.loadCertLocked(); err != nil {
+				f.logger.Warn().Err(err).Str("path", f.opts.CertFile).Msg("Failed to reload tls client certificate, keeping the previous one")
+			} else {
+				f.logger.Info().Str("path", f.opts.CertFile).Time("notAfter", f.cert.Leaf.NotAfter).Msg("Reloaded tls client certificate")
+			}
+		}
+	}
+}
+
+func (f *TLSFiles) loadCALocked() error {
+	if f.opts.CAFile == "" {
+		return nil
+	}
+	stamp, err := statFile(f.opts.CAFile)
+	if err != nil {
+		return fmt.Errorf("read tls ca file: %w", err)
+	}
+	pem, err := os.ReadFile(f.opts.CAFile)
+	if err != nil {
+		return fmt.Errorf("read tls ca file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return fmt.Errorf("tls ca file %s: no PEM certificates found", f.opts.CAFile)
+	}
+	f.roots, f.caStamp = pool, stamp
+	return nil
+}
+
+func (f *TLSFiles) loadCertLocked() error {
+	if f.opts.CertFile == "" {
+		return nil
+	}
+	certStamp, err := statFile(f.opts.CertFile)
+	if err != nil {
+		return fmt.Errorf("read tls client certificate: %w", err)
+	}
+	keyStamp, err := statFile(f.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("read tls client key: %w", err)
+	}
+	// LoadX509KeyPair populates Leaf (Go 1.23+), which the expiry metric reads.
+	cert, err := tls.LoadX509KeyPair(f.opts.CertFile, f.opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("load tls client certificate: %w", err)
+	}
+	f.cert, f.certStamp = &cert, [2]fileStamp{certStamp, keyStamp}
+	return nil
+}
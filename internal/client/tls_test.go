@@ -0,0 +1,332 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/fs"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// testCA is a throwaway certificate authority for the TLS tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ca key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create ca cert: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue signs a leaf for commonName (also its DNS SAN) expiring at notAfter and
+// returns the certificate and key PEM.
+func (ca *testCA) issue(t *testing.T, commonName string, notAfter time.Time, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate leaf key: %v", err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("create leaf cert: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal leaf key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeTLSFile writes content to path and moves its mtime forward by bump, so
+// a rewrite within the filesystem's timestamp granularity still reads as a
+// change.
+func writeTLSFile(t *testing.T, path string, content []byte, bump time.Duration) {
+	t.Helper()
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+	mtime := time.Now().Add(bump)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatalf("chtimes %s: %v", path, err)
+	}
+}
+
+// newMTLSServer starts a TLS server for "tdarr.internal" signed by ca that
+// requires a client certificate signed by ca and answers with the client's
+// common name.
+func newMTLSServer(t *testing.T, ca *testCA) *httptest.Server {
+	t.Helper()
+	certPEM, keyPEM := ca.issue(t, "tdarr.internal", time.Now().Add(time.Hour), x509.ExtKeyUsageServerAuth)
+	serverCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("server key pair: %v", err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// tlsFixture is a CA file plus client certificate pair on disk for ca.
+type tlsFixture struct {
+	caFile, certFile, keyFile string
+}
+
+func newTLSFixture(t *testing.T, ca *testCA, clientName string, notAfter time.Time) tlsFixture {
+	t.Helper()
+	dir := t.TempDir()
+	fx := tlsFixture{
+		caFile:   filepath.Join(dir, "ca.pem"),
+		certFile: filepath.Join(dir, "client.pem"),
+		keyFile:  filepath.Join(dir, "client-key.pem"),
+	}
+	certPEM, keyPEM := ca.issue(t, clientName, notAfter, x509.ExtKeyUsageClientAuth)
+	writeTLSFile(t, fx.caFile, ca.pem, 0)
+	writeTLSFile(t, fx.certFile, certPEM, 0)
+	writeTLSFile(t, fx.keyFile, keyPEM, 0)
+	return fx
+}
+
+func (fx tlsFixture) options() TLSOptions {
+	return TLSOptions{CAFile: fx.caFile, CertFile: fx.certFile, KeyFile: fx.keyFile, ServerName: "tdarr.internal"}
+}
+
+// getCommonName makes a request on a fresh connection with cfg and returns the
+// body (the client certificate's common name as seen by newMTLSServer).
+func getCommonName(t *testing.T, srv *httptest.Server, cfg *tls.Config) (string, error) {
+	t.Helper()
+	transport := &http.Transport{TLSClientConfig: cfg, DisableKeepAlives: true}
+	defer transport.CloseIdleConnections()
+	resp, err := (&http.Client{Transport: transport, Timeout: 5 * time.Second}).Get(srv.URL)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	buf := make([]byte, 64)
+	n, _ := resp.Body.Read(buf)
+	return string(buf[:n]), nil
+}
+
+func TestNewTLSFiles_InitialLoadErrors(t *testing.T) {
+	t.Parallel()
+	ca := newTestCA(t)
+	fx := newTLSFixture(t, ca, "exporter", time.Now().Add(time.Hour))
+	dir := t.TempDir()
+
+	if _, err := NewTLSFiles(TLSOptions{CAFile: filepath.Join(dir, "missing")}, true); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("missing ca file: error = %v, want fs.ErrNotExist", err)
+	}
+	notPEM := filepath.Join(dir, "not-pem")
+	writeTLSFile(t, notPEM, []byte("hello"), 0)
+	if _, err := NewTLSFiles(TLSOptions{CAFile: notPEM}, true); err == nil {
+		t.Errorf("ca file without certificates: want error")
+	}
+	if _, err := NewTLSFiles(TLSOptions{CertFile: fx.certFile}, true); err == nil {
+		t.Errorf("cert without key: want error")
+	}
+	if _, err := NewTLSFiles(TLSOptions{CertFile: fx.certFile, KeyFile: fx.caFile}, true); err == nil {
+		t.Errorf("mismatched cert and key: want error")
+	}
+}
+
+// TestTLSFiles_MutualTLS verifies the client verifies the server against the
+// CA file under the overridden server name and presents its certificate.
+func TestTLSFiles_MutualTLS(t *testing.T) {
+	t.Parallel()
+	ca := newTestCA(t)
+	srv := newMTLSServer(t, ca)
+	fx := newTLSFixture(t, ca, "exporter", time.Now().Add(time.Hour))
+
+	f, err := NewTLSFiles(fx.options(), true)
+	if err != nil {
+		t.Fatalf("NewTLSFiles: %v", err)
+	}
+	if got, err := getCommonName(t, srv, f.Config("127.0.0.1")); err != nil || got != "exporter" {
+		t.Fatalf("mTLS request = %q, %v; want exporter", got, err)
+	}
+
+	// Without the server name override the server certificate (issued for
+	// tdarr.internal) does not match the 127.0.0.1 url host.
+	opts := fx.options()
+	opts.ServerName = ""
+	f, err = NewTLSFiles(opts, true)
+	if err != nil {
+		t.Fatalf("NewTLSFiles: %v", err)
+	}
+	if _, err := getCommonName(t, srv, f.Config("127.0.0.1")); err == nil {
+		t.Errorf("request without server name override: want verification error")
+	}
+
+	// A CA that did not sign the server certificate is rejected, unless
+	// verification is turned off.
+	other := newTLSFixture(t, newTestCA(t), "exporter", time.Now().Add(time.Hour))
+	opts = fx.options()
+	opts.CAFile = other.caFile
+	f, err = NewTLSFiles(opts, true)
+	if err != nil {
+		t.Fatalf("NewTLSFiles: %v", err)
+	}
+	if _, err := getCommonName(t, srv, f.Config("127.0.0.1")); err == nil {
+		t.Errorf("request with an unrelated CA: want verification error")
+	}
+	f, err = NewTLSFiles(opts, false)
+	if err != nil {
+		t.Fatalf("NewTLSFiles: %v", err)
+	}
+	if _, err := getCommonName(t, srv, f.Config("127.0.0.1")); err != nil {
+		t.Errorf("request with verify_ssl off: %v", err)
+	}
+}
+
+// TestTLSFiles_Reload verifies a renewed client certificate and a replaced CA
+// bundle are picked up on new connections once the check interval elapses, and
+// that a broken rewrite keeps the previous material.
+func TestTLSFiles_Reload(t *testing.T) {
+	t.Parallel()
+	ca := newTestCA(t)
+	srv := newMTLSServer(t, ca)
+	firstExpiry := time.Now().Add(time.Hour).Truncate(time.Second)
+	fx := newTLSFixture(t, ca, "exporter-1", firstExpiry)
+	// Start from a CA file that cannot verify the server.
+	writeTLSFile(t, fx.caFile, newTestCA(t).pem, 0)
+
+	clock := &fakeClock{now: time.Unix(1_700_000_000, 0)}
+	f, err := NewTLSFiles(fx.options(), true, WithTLSCheckInterval(time.Minute), WithTLSClock(clock.Now), WithTLSLogger(zerolog.Nop()))
+	if err != nil {
+		t.Fatalf("NewTLSFiles: %v", err)
+	}
+	cfg := f.Config("127.0.0.1")
+	if _, err := getCommonName(t, srv, cfg); err == nil {
+		t.Fatalf("request with the wrong CA: want verification error")
+	}
+
+	secondExpiry := time.Now().Add(2 * time.Hour).Truncate(time.Second)
+	certPEM, keyPEM := ca.issue(t, "exporter-2", secondExpiry, x509.ExtKeyUsageClientAuth)
+	writeTLSFile(t, fx.caFile, ca.pem, time.Second)
+	writeTLSFile(t, fx.certFile, certPEM, time.Second)
+	writeTLSFile(t, fx.keyFile, keyPEM, time.Second)
+
+	// Inside the interval nothing is re-read.
+	if notAfter, _ := f.ClientCertificateNotAfter(); !notAfter.Equal(firstExpiry) {
+		t.Errorf("expiry inside the interval = %v, want %v", notAfter, firstExpiry)
+	}
+	clock.Advance(time.Minute)
+	if got, err := getCommonName(t, srv, cfg); err != nil || got != "exporter-2" {
+		t.Fatalf("request after reload = %q, %v; want exporter-2", got, err)
+	}
+	if notAfter, ok := f.ClientCertificateNotAfter(); !ok || !notAfter.Equal(secondExpiry) {
+		t.Errorf("expiry after reload = %v, %v; want %v", notAfter, ok, secondExpiry)
+	}
+
+	writeTLSFile(t, fx.certFile, []byte("truncated"), 2*time.Second)
+	writeTLSFile(t, fx.caFile, []byte("truncated"), 2*time.Second)
+	clock.Advance(time.Minute)
+	if got, err := getCommonName(t, srv, cfg); err != nil || got != "exporter-2" {
+		t.Errorf("request after a broken rewrite = %q, %v; want exporter-2 kept", got, err)
+	}
+	if notAfter, _ := f.ClientCertificateNotAfter(); !notAfter.Equal(secondExpiry) {
+		t.Errorf("expiry after a broken rewrite = %v, want %v kept", notAfter, secondExpiry)
+	}
+}
+
+func TestTLSFiles_MinVersion(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	f, err := NewTLSFiles(TLSOptions{MinVersion: tls.VersionTLS13}, false)
+	if err != nil {
+		t.Fatalf("NewTLSFiles: %v", err)
+	}
+	if _, err := getCommonName(t, srv, f.Config("127.0.0.1")); err == nil {
+		t.Errorf("TLS 1.2 server with a 1.3 minimum: want handshake error")
+	}
+	if _, ok := f.ClientCertificateNotAfter(); ok {
+		t.Errorf("ClientCertificateNotAfter without a client certificate: want ok=false")
+	}
+}
+
+// TestNewRequestClient_WithTLSFiles verifies the option reaches the transport
+// NewRequestClient builds.
+func TestNewRequestClient_WithTLSFiles(t *testing.T) {
+	t.Parallel()
+	ca := newTestCA(t)
+	srv := newMTLSServer(t, ca)
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"cn":"` + r.TLS.PeerCertificates[0].Subject.CommonName + `"}`))
+	})
+	fx := newTLSFixture(t, ca, "exporter", time.Now().Add(time.Hour))
+	f, err := NewTLSFiles(fx.options(), true)
+	if err != nil {
+		t.Fatalf("NewTLSFiles: %v", err)
+	}
+	u, _ := url.Parse(srv.URL)
+	c, err := NewRequestClient(u, true, 5, "", WithTLSFiles(f))
+	if err != nil {
+		t.Fatalf("NewRequestClient: %v", err)
+	}
+	var got struct{ CN string }
+	if err := c.DoRequest(context.Background(), "/", &got); err != nil {
+		t.Fatalf("DoRequest: %v", err)
+	}
+	if got.CN != "exporter" {
+		t.Errorf("client certificate seen by the server = %q, want exporter", got.CN)
+	}
+}
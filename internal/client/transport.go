@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math/rand/v2"
 	"net/http"
 	"time"
 
@@ -14,8 +15,9 @@ import (
 // ClientTransportOption is a functional option for NewClientTransport.
 type ClientTransportOption func(*ClientTransport)
 
-// WithBackoff sets the retry backoff durations. The number of retries equals
-// len(durations). Default: [1s, 3s] (2 retries).
+// WithBackoff sets the retry backoff durations for requests without a
+// RequestPolicy. The number of retries equals len(durations). Default: [1s, 3s]
+// (2 retries).
 func WithBackoff(durations []time.Duration) ClientTransportOption {
 	return func(t *ClientTransport) {
 		t.backoff = durations
@@ -30,6 +32,14 @@ func WithAfter(fn func(time.Duration) <-chan time.Time) ClientTransportOption {
 	}
 }
 
+// WithRandom injects the source of RequestPolicy jitter, returning values in
+// [0, 1), replacing math/rand. Intended for tests.
+func WithRandom(fn func() float64) ClientTransportOption {
+	return func(t *ClientTransport) {
+		t.random = fn
+	}
+}
+
 // WithLogger injects the logger the transport uses. Defaults to log.Logger.
 func WithLogger(logger zerolog.Logger) ClientTransportOption {
 	return func(t *ClientTransport) {
@@ -37,12 +47,55 @@ func WithLogger(logger zerolog.Logger) ClientTransportOption {
 	}
 }
 
-// Set up as http.RoundTripper that can retry, add auth in future, etc.
+// WithAuthenticator sets the authenticator applied to every attempt. Default:
+// none, requests are sent as given.
+func WithAuthenticator(auth Authenticator) ClientTransportOption {
+	return func(t *ClientTransport) {
+		t.auth = auth
+	}
+}
+
+// WithBreaker routes every request through breaker, failing it with
+// ErrCircuitOpen instead of sending it while the breaker is open. Default: no
+// breaker.
+func WithBreaker(breaker *CircuitBreaker) ClientTransportOption {
+	return func(t *ClientTransport) {
+		t.breaker = breaker
+	}
+}
+
+// WithTransportMetrics records every attempt, retry and response body in m.
+// Default: none.
+func WithTransportMetrics(m *Metrics) ClientTransportOption {
+	return func(t *ClientTransport) {
+		t.metrics = m
+	}
+}
+
+// StatusError is returned for a response the transport does not pass on: a
+// 4xx, or a 5xx once the retries are used up. Callers errors.As it to branch on
+// the code, e.g. a 404 from an endpoint an older Tdarr does not have.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	if e.StatusCode >= 500 {
+		return fmt.Sprintf("received Server Error Status Code: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("received 40x Status Code: %d", e.StatusCode)
+}
+
+// Set up as http.RoundTripper that can retry, authenticate, etc.
 type ClientTransport struct {
 	inner   http.RoundTripper
 	backoff []time.Duration
 	after   func(time.Duration) <-chan time.Time
+	random  func() float64
 	logger  zerolog.Logger
+	auth    Authenticator
+	breaker *CircuitBreaker
+	metrics *Metrics
 }
 
 // NewClientTransport constructs a ClientTransport wrapping inner.
@@ -53,6 +106,7 @@ func NewClientTransport(inner http.RoundTripper, opts ...ClientTransportOption)
 		inner:   inner,
 		backoff: []time.Duration{1 * time.Second, 3 * time.Second},
 		after:   time.After,
+		random:  rand.Float64,
 		logger:  log.Logger,
 	}
 	for _, opt := range opts {
@@ -74,8 +128,77 @@ func drainClose(resp *http.Response) {
 	_ = resp.Body.Close()
 }
 
-// middleware for http to handle retries
+// send makes one attempt, recorded under endpoint. With an authenticator it
+// sends an authenticated copy of req, so each attempt carries the credential
+// current at that moment and the caller's request is left untouched.
+func (t *ClientTransport) send(req *http.Request, endpoint string) (*http.Response, error) {
+	out := req
+	if t.auth != nil {
+		out = req.Clone(req.Context())
+		if err := t.auth.Authenticate(out); err != nil {
+			return nil, fmt.Errorf("failed to authenticate request: %w", err)
+		}
+	}
+	start := time.Now()
+	resp, err := t.inner.RoundTrip(out)
+	return t.metrics.instrument(endpoint, start, resp, err), err
+}
+
+// retryPlan is the retry behavior for one request: the RequestPolicy attached
+// to its context (see RequestClient.WithPolicies), or else the transport's
+// backoff list retrying transport errors and every 5xx.
+type retryPlan struct {
+	t         *ClientTransport
+	policy    RequestPolicy
+	hasPolicy bool
+}
+
+func (p retryPlan) retries() int {
+	if p.hasPolicy {
+		return p.policy.Retries
+	}
+	return len(p.t.backoff)
+}
+
+func (p retryPlan) shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	if p.hasPolicy {
+		return p.policy.retryableStatus(resp.StatusCode)
+	}
+	return resp.StatusCode >= 500
+}
+
+// wait returns how long to wait before retry i after resp: what a 429 or 503
+// asks for in Retry-After, else the backoff.
+func (p retryPlan) wait(i int, resp *http.Response) time.Duration {
+	if wait, ok := retryAfter(resp, time.Now()); ok {
+		return wait
+	}
+	if p.hasPolicy {
+		return p.policy.backoff(i, p.t.random())
+	}
+	return p.t.backoff[i]
+}
+
+// RoundTrip sends req through the circuit breaker, when one is set, and the
+// retries.
 func (t *ClientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.breaker == nil {
+		return t.roundTrip(req)
+	}
+	if err := t.breaker.allow(); err != nil {
+		t.logger.Debug().Str("url", req.URL.String()).Msg("Circuit breaker open, not sending HTTP Request")
+		return nil, err
+	}
+	resp, err := t.roundTrip(req)
+	t.breaker.done(callerFromContext(req.Context()), err)
+	return resp, err
+}
+
+// middleware for http to handle retries
+func (t *ClientTransport) roundTrip(req *http.Request) (*http.Response, error) {
 	// read it first since once sent on first try it will be already streamed
 	// for request with body, we need to ensure we can re-use the body after it is read/cleared from the buffer on first request
 	// so copy it first to a buffer
@@ -87,12 +210,27 @@ func (t *ClientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 		// `req.Body` is then set to the copied data held in the buffer created with the `bodyBytes` slice.
 		req.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 	}
+	policy, hasPolicy := policyFromContext(req.Context())
+	plan := retryPlan{t: t, policy: policy, hasPolicy: hasPolicy}
+	var endpoint string
+	if t.metrics != nil {
+		endpoint = endpointLabel(req.URL.Path, bodyBytes)
+	}
 	// `req.Body` is streamed when used in `RoundTrip()`, so we need to re-create the `req.Body` with the copied data when retrying
-	resp, err := t.inner.RoundTrip(req)
-	if err != nil || resp.StatusCode >= 500 {
-		// retries = len(backoff); index i is always in range — no panic possible.
+	resp, err := t.send(req, endpoint)
+	if plan.shouldRetry(resp, err) {
+		// without a policy, retries = len(backoff); index i is always in range — no panic possible.
 		recovered := false
-		for i, backoffDur := range t.backoff {
+		for i := range plan.retries() {
+			backoffDur := plan.wait(i, resp)
+			// A wait that outlives the request's deadline (e.g. a long Retry-After)
+			// cannot end in a retry: give up now with the last result.
+			if deadline, ok := req.Context().Deadline(); ok && time.Until(deadline) < backoffDur {
+				t.logger.Debug().Interface("backoff_seconds", backoffDur).
+					Str("url", req.URL.String()).
+					Msg("Not retrying HTTP Request, backoff exceeds the request deadline")
+				break
+			}
 			t.logger.Debug().Int("retry_count", i+1).
 				Interface("backoff_seconds", backoffDur).
 				Str("url", req.URL.String()).
@@ -114,9 +252,10 @@ func (t *ClientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 			if req.Body != nil {
 				req.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 			}
-			resp, err = t.inner.RoundTrip(req)
-			if err == nil && resp.StatusCode < 500 {
-				// Got a non-5xx response. Don't short-circuit to success here:
+			t.metrics.retry(endpoint)
+			resp, err = t.send(req, endpoint)
+			if !plan.shouldRetry(resp, err) {
+				// Got a non-retryable response. Don't short-circuit to success here:
 				// a 4xx/3xx still has to go through the same classification below
 				// as a first-attempt response would. Break and fall through.
 				recovered = true
@@ -124,19 +263,42 @@ func (t *ClientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 			}
 		}
 		if !recovered {
-			// every attempt errored or returned 5xx
+			// every attempt errored or returned a retryable status
 			if err != nil {
 				return nil, fmt.Errorf("error sending HTTP Request: %w", err)
 			}
 			drainClose(resp)
-			return nil, fmt.Errorf("received Server Error Status Code: %d", resp.StatusCode)
+			return nil, &StatusError{StatusCode: resp.StatusCode}
 		}
-		// fall through: resp is now a <500 response, classify it like any other
+		// fall through: resp is now a non-retryable response, classify it like any other
+	}
+	// A 401 can mean a cached credential (e.g. an OAuth2 token) was revoked
+	// before its expiry: drop it and try once more with a fresh one. A second
+	// 401 is final and classified below like any other 4xx.
+	if inv, ok := t.auth.(Invalidator); ok && resp.StatusCode == http.StatusUnauthorized && inv.Invalidate() {
+		t.logger.Debug().Str("url", req.URL.String()).Msg("Received 401, retrying once with refreshed credentials")
+		drainClose(resp)
+		if req.Body != nil {
+			req.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+		}
+		t.metrics.retry(endpoint)
+		if resp, err = t.send(req, endpoint); err != nil {
+			return nil, fmt.Errorf("error sending HTTP Request: %w", err)
+		}
+		if resp.StatusCode >= 500 {
+			drainClose(resp)
+			return nil, &StatusError{StatusCode: resp.StatusCode}
+		}
+	}
+	// A 5xx reaches here only when a RequestPolicy does not retry its code.
+	if resp.StatusCode >= 500 {
+		drainClose(resp)
+		return nil, &StatusError{StatusCode: resp.StatusCode}
 	}
 	if resp.StatusCode >= 400 && resp.StatusCode <= 499 {
 		t.logger.Error().Int("status_code", resp.StatusCode).Str("url", req.URL.String()).Msgf("Received 40X Status Code: %d", resp.StatusCode)
 		drainClose(resp)
-		return nil, fmt.Errorf("received 40x Status Code: %d", resp.StatusCode)
+		return nil, &StatusError{StatusCode: resp.StatusCode}
 	}
 	if resp.StatusCode >= 300 && resp.StatusCode <= 399 {
 		t.logger.Debug().Int("status_code", resp.StatusCode).Str("url", req.URL.String()).Msgf("Received 30X Status Code: %d", resp.StatusCode)
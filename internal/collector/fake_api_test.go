@@ -11,12 +11,13 @@ import (
 
 // fakeKey identifies a logical Tdarr request so the fake can route to a fixture
 // and/or inject an error. For cruddb POSTs the discriminator is the payload's
-// collection field; for get-pies POSTs it is the libraryId; for GET requests it
-// is the path itself.
+// collection field; for get-pies POSTs it is the libraryId; for status-table
+// POSTs it is the table and page start; for GET requests it is the path itself.
 type fakeKey struct {
 	path string
 	// disc is the secondary discriminator: the cruddb collection name
-	// (StatisticsJSONDB / LibrarySettingsJSONDB) or the get-pies libraryId.
+	// (StatisticsJSONDB / LibrarySettingsJSONDB), the get-pies libraryId or
+	// "table@start" for a status-table page (see filesPageKey).
 	// Empty for plain GET requests keyed only on path.
 	disc string
 }
@@ -95,6 +96,16 @@ func keyForPost(path string, payload []byte) (fakeKey, error) {
 	if err := json.Unmarshal(payload, &cruddb); err == nil && cruddb.Data.Collection != "" {
 		return fakeKey{path: path, disc: cruddb.Data.Collection}, nil
 	}
+	// Status-table pages (has data.opts.table).
+	var files TdarrFilesRequest
+	if err := json.Unmarshal(payload, &files); err == nil && files.Data.Opts.Table != "" {
+		return filesPageKey(path, files.Data.Opts.Table, files.Data.Start), nil
+	}
+	// Plugin searches (has data.pluginType).
+	var plugins TdarrPluginsRequest
+	if err := json.Unmarshal(payload, &plugins); err == nil && plugins.Data.PluginType != "" {
+		return fakeKey{path: path, disc: plugins.Data.PluginType}, nil
+	}
 	// Fall back to get-pies shape (has data.libraryId).
 	var pie TdarrPieDataRequest
 	if err := json.Unmarshal(payload, &pie); err == nil {
@@ -103,6 +114,11 @@ func keyForPost(path string, payload []byte) (fakeKey, error) {
 	return fakeKey{}, fmt.Errorf("fakeTdarrAPI: could not derive key for POST %s payload %s", path, payload)
 }
 
+// filesPageKey is the key of the status-table page of table starting at start.
+func filesPageKey(path, table string, start int) fakeKey {
+	return fakeKey{path: path, disc: fmt.Sprintf("%s@%d", table, start)}
+}
+
 func (f *fakeTdarrAPI) DoPostRequest(ctx context.Context, path string, target any, payload []byte) error {
 	// Honor a cancelled/expired context like the real client does, so cancellation
 	// propagation through the collector is testable without a network.
@@ -0,0 +1,43 @@
+package collector
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/homeylab/tdarr-exporter/internal/config"
+)
+
+// hashedLabelLen is the hex digits kept of a hashed label value: 64 bits, so
+// two files colliding within one Tdarr is not a practical concern.
+const hashedLabelLen = 16
+
+// applyLabelPolicy rewrites a label value by p (see config.LabelMode). An
+// empty value, e.g. the file of an idle worker, stays empty in every mode.
+func applyLabelPolicy(p config.LabelPolicy, value string) string {
+	if value == "" {
+		return ""
+	}
+	switch p.Mode {
+	case config.LabelBasename:
+		// Tdarr nodes on Windows report paths with backslashes.
+		return value[strings.LastIndexAny(value, `/\`)+1:]
+	case config.LabelStripPrefix:
+		return strings.TrimPrefix(value, p.Prefix)
+	case config.LabelRegex:
+		for _, rule := range p.Rules {
+			if rule.Match.MatchString(value) {
+				return rule.Match.ReplaceAllString(value, rule.Replace)
+			}
+		}
+		return value
+	case config.LabelHash:
+		mac := hmac.New(sha256.New, []byte(p.HashKey))
+		mac.Write([]byte(value))
+		return hex.EncodeToString(mac.Sum(nil))[:hashedLabelLen]
+	case config.LabelDrop:
+		return ""
+	}
+	return value
+}
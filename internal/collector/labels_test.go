@@ -0,0 +1,97 @@
+package collector
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/homeylab/tdarr-exporter/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestApplyLabelPolicy(t *testing.T) {
+	t.Parallel()
+
+	rules := []config.LabelRewrite{
+		{Match: regexp.MustCompile(`^/media/(movies|tv)/.*$`), Replace: "$1"},
+		{Match: regexp.MustCompile(`^/media/.*$`), Replace: "other"},
+	}
+	tests := []struct {
+		name   string
+		policy config.LabelPolicy
+		value  string
+		want   string
+	}{
+		{name: "zero policy keeps", value: "/media/tv/a.mkv", want: "/media/tv/a.mkv"},
+		{name: "keep", policy: config.LabelPolicy{Mode: config.LabelKeep}, value: "/media/tv/a.mkv", want: "/media/tv/a.mkv"},
+		{name: "basename", policy: config.LabelPolicy{Mode: config.LabelBasename}, value: "/media/tv/a.mkv", want: "a.mkv"},
+		{name: "basename windows path", policy: config.LabelPolicy{Mode: config.LabelBasename}, value: `D:\media\tv\a.mkv`, want: "a.mkv"},
+		{name: "basename no directory", policy: config.LabelPolicy{Mode: config.LabelBasename}, value: "a.mkv", want: "a.mkv"},
+		{
+			name:   "strip_prefix",
+			policy: config.LabelPolicy{Mode: config.LabelStripPrefix, Prefix: "/media/"},
+			value:  "/media/tv/a.mkv", want: "tv/a.mkv",
+		},
+		{
+			name:   "strip_prefix not matching",
+			policy: config.LabelPolicy{Mode: config.LabelStripPrefix, Prefix: "/mnt/"},
+			value:  "/media/tv/a.mkv", want: "/media/tv/a.mkv",
+		},
+		{name: "regex first rule", policy: config.LabelPolicy{Mode: config.LabelRegex, Rules: rules}, value: "/media/tv/a.mkv", want: "tv"},
+		{name: "regex later rule", policy: config.LabelPolicy{Mode: config.LabelRegex, Rules: rules}, value: "/media/music/a.flac", want: "other"},
+		{name: "regex no rule", policy: config.LabelPolicy{Mode: config.LabelRegex, Rules: rules}, value: "/mnt/a.mkv", want: "/mnt/a.mkv"},
+		{
+			// HMAC-SHA256("k", "/media/tv/a.mkv"), first 16 hex digits.
+			name:   "hash",
+			policy: config.LabelPolicy{Mode: config.LabelHash, HashKey: "k"},
+			value:  "/media/tv/a.mkv", want: "1dba35521dbad799",
+		},
+		{name: "drop", policy: config.LabelPolicy{Mode: config.LabelDrop}, value: "/media/tv/a.mkv", want: ""},
+		{name: "empty stays empty", policy: config.LabelPolicy{Mode: config.LabelHash, HashKey: "k"}, value: "", want: ""},
+	}
+	for _, tt := range tests {
+		if got := applyLabelPolicy(tt.policy, tt.value); got != tt.want {
+			t.Errorf("%s: applyLabelPolicy(%q) = %q, want %q", tt.name, tt.value, got, tt.want)
+		}
+	}
+
+	// The hash is keyed: another key gives an unrelated value.
+	a := applyLabelPolicy(config.LabelPolicy{Mode: config.LabelHash, HashKey: "k"}, "/media/tv/a.mkv")
+	b := applyLabelPolicy(config.LabelPolicy{Mode: config.LabelHash, HashKey: "other"}, "/media/tv/a.mkv")
+	if a == b {
+		t.Errorf("hash with different keys = %q for both, want them to differ", a)
+	}
+}
+
+// TestEmit_LabelPolicies verifies the policies reach worker_file on
+// tdarr_node_worker_info and library_name on tdarr_library_info.
+func TestEmit_LabelPolicies(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	cfg.LabelPolicies = config.LabelPolicies{
+		WorkerFile:  config.LabelPolicy{Mode: config.LabelBasename},
+		LibraryName: config.LabelPolicy{Mode: config.LabelDrop},
+	}
+	c := newTdarrCollectorWithAPI(cfg, newSuccessFakeAPI(cfg))
+
+	node := TdarrNode{
+		Id:   "node-1",
+		Name: "Node1",
+		Workers: map[string]TdarrNodeWorkers{
+			"w1": {Id: "w1", WorkerType: "transcodecpu", File: "/media/tv/show/episode.mkv"},
+		},
+	}
+	pie := &TdarrPieStats{libraryName: "Private Library", libraryId: "lib-1"}
+	samples := collectSamples(t, func(ch chan<- prometheus.Metric) {
+		c.emitWorkers(ch, node)
+		c.emitPieMetrics(ch, []*TdarrPieStats{pie})
+	})
+
+	wi := findOne(t, samples, "tdarr_node_worker_info", map[string]string{"worker_id": "w1"})
+	if got := wi.labels["worker_file"]; got != "episode.mkv" {
+		t.Errorf("worker_file = %q, want episode.mkv", got)
+	}
+	li := findOne(t, samples, "tdarr_library_info", map[string]string{"library_id": "lib-1"})
+	if got := li.labels["library_name"]; got != "" {
+		t.Errorf("library_name = %q, want it dropped", got)
+	}
+}
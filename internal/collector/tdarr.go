@@ -5,12 +5,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/homeylab/tdarr-exporter/internal/client"
 	"github.com/homeylab/tdarr-exporter/internal/config"
@@ -38,6 +40,54 @@ var (
 	// ErrParse marks a failure interpreting an otherwise-successful response:
 	// payload marshalling or a numeric field that could not be converted.
 	ErrParse = errors.New("tdarr response parse failed")
+	// ErrDeadline marks an optional phase skipped because the time left before
+	// the scrape deadline could not cover it (see CollectContext).
+	ErrDeadline = errors.New("scrape deadline too close")
+)
+
+// Causes reported by tdarr_exporter_scrape_error for a scrape with tdarr_up 0.
+const (
+	causeDeadlineExceeded = "deadline_exceeded"
+	causeUpstream         = "upstream"
+	causeParse            = "parse"
+	causePartial          = "partial"
+	causePanic            = "panic"
+	causeUnknown          = "unknown"
+)
+
+// Phases of a scrape timed by tdarr_exporter_scrape_phase_duration_seconds.
+const (
+	phaseStatus    = "status"    // GET /api/v2/status
+	phaseGeneral   = "general"   // the statistics document
+	phaseLibraries = "libraries" // the library list, for the settings, the pie cache and the staged zeros
+	phasePies      = "pies"      // the get-pies sweep, on a cache miss
+	phaseNodes     = "nodes"     // get-nodes
+	phaseFiles     = "files"     // the status tables walk, when the totals moved
+	phaseFlows     = "flows"     // the flows document
+	phasePlugins   = "plugins"   // the plugin catalogue and library list, when the cached ones expired
+	phaseStaged    = "staged"    // the staged files
+)
+
+// Collectors reported by tdarr_scrape_collector_success, one per
+// collectors.* toggle; nodes covers the workers too, as both come from get-nodes.
+const (
+	subsystemServer  = "server"
+	subsystemGeneral = "general"
+	subsystemLibrary = "library"
+	subsystemNodes   = "nodes"
+	subsystemFiles   = "files"
+	subsystemStreams = "streams"
+	subsystemFlows   = "flows"
+	subsystemPlugins = "plugins"
+	subsystemStaged  = "staged"
+)
+
+// Reasons the pie cache could not serve a scrape, reported by
+// tdarr_exporter_pie_cache_misses_total (see refetchReason).
+const (
+	missEmpty              = "empty"
+	missTotalsChanged      = "totals_changed"
+	missFingerprintChanged = "fingerprint_changed"
 )
 
 // buildDesc builds a *prometheus.Desc with the METRIC_PREFIX-prefixed fqName and the
@@ -69,6 +119,18 @@ func newCounter(name, help string, varLabels []string, instance prometheus.Label
 	return typedDesc{desc: buildDesc(name, help, varLabels, instance), valueType: prometheus.CounterValue}
 }
 
+// newHistogram builds a typedDesc for a const histogram, emitted with
+// mustNewConstHistogram rather than mustNewConstMetric.
+func newHistogram(name, help string, varLabels []string, instance prometheus.Labels) typedDesc {
+	return typedDesc{desc: buildDesc(name, help, varLabels, instance)}
+}
+
+// mustNewConstHistogram emits a const histogram for this desc; buckets are
+// cumulative counts keyed by upper bound.
+func (d typedDesc) mustNewConstHistogram(count uint64, sum float64, buckets map[float64]uint64, labelValues ...string) prometheus.Metric {
+	return prometheus.MustNewConstHistogram(d.desc, count, sum, buckets, labelValues...)
+}
+
 // tdarrAPI is the HTTP-client seam used by the collectors. *client.RequestClient
 // satisfies it directly; tests inject an in-memory fake instead of a real client
 // plus httptest server.
@@ -86,51 +148,153 @@ type unknownStatusKey struct {
 type TdarrCollector struct {
 	// Only the config values read at collect time are stored, not the whole
 	// config.Config bag — the URL/SSL/timeout/api-key and instance label are
-	// consumed once in the constructor (client + descs) and never needed again.
+	// consumed once in the constructor (client + descs) and never needed again,
+	// bar the instance label naming the target on /debug/cache.
+	instanceName   string
 	statsPath      string
 	pieStatsPath   string
 	statusPath     string
 	maxConcurrency int
-	api            tdarrAPI // shared HTTP client, built once in the constructor
+	// collectors are the enabled metric groups; a disabled group's requests are
+	// skipped and its descs left out of descsList.
+	collectors config.Collectors
+	// labelPolicies rewrite worker_file, library_name and the library folders
+	// before they are emitted.
+	labelPolicies config.LabelPolicies
+	api           tdarrAPI // shared HTTP client, built once in the constructor
 	// baseCtx is the parent context for every scrape's HTTP requests. main wires in
 	// a context cancelled on shutdown so in-flight scrapes abort promptly; tests and
 	// the WithAPI constructor default it to context.Background().
 	baseCtx context.Context
 	// logger defaults to the package-global log.Logger and is shared with the node
 	// collector at construction. Injected so tests can silence or capture logs.
-	logger                zerolog.Logger
-	statsCache            *TdarrLibStatsCache
-	unknownStatusMu       sync.Mutex
-	unknownStatusCounts   map[unknownStatusKey]float64 // monotonic counter for enum drift detection
-	totalFilesMetric      typedDesc
-	totalTranscodeCount   typedDesc
-	totalHealthCheckCount typedDesc
-	sizeDiff              typedDesc
-	tdarrScore            typedDesc
-	healthCheckScore      typedDesc
-	avgNumStreams         typedDesc
-	streamStatsDuration   typedDesc
-	streamStatsBitRate    typedDesc
-	streamStatsNumFrames  typedDesc
-	pieNumFiles           typedDesc
-	pieNumTranscodes      typedDesc
-	pieNumHealthChecks    typedDesc
-	pieSizeDiff           typedDesc
-	pieTranscodes         typedDesc
-	pieHealthChecks       typedDesc
-	pieVideoCodecs        typedDesc
-	pieVideoContainers    typedDesc
-	pieVideoResolutions   typedDesc
-	pieAudioCodecs        typedDesc
-	pieAudioContainers    typedDesc
-	pieLibraryInfo        typedDesc           // library_id → library_name mapping (value always 1)
-	unknownStatusTotal    typedDesc           // counter for status values not in known enum
-	nodeCollector         *TdarrNodeCollector // node data
-	upMetric              typedDesc
-	serverUptime          typedDesc
-	serverInfo            typedDesc
-	serverStatus          typedDesc
-	serverHealthy         typedDesc
+	logger     zerolog.Logger
+	statsCache *TdarrLibStatsCache
+	// stateStore saves statsCache across restarts when a state file is
+	// configured, nil otherwise; the cache is kept there under instanceName
+	// and stateUrl.
+	stateStore *PieCacheStore
+	stateUrl   string
+	// filesCache holds the last walk of the files collector; fileSizeBuckets,
+	// filesPageSize and filesPath are its settings.
+	filesCache      *fileStatsCache
+	fileSizeBuckets []float64
+	filesPageSize   int
+	filesPath       string
+	// pluginsCache holds the last fetch of the plugins collector, fetched
+	// from pluginsPath again once pluginsRefresh has passed.
+	pluginsCache   *pluginsCache
+	pluginsPath    string
+	pluginsRefresh time.Duration
+	// codecResolutionMaxSeries caps the videoCodecResolutionBytes series per
+	// library; 0 leaves them out.
+	codecResolutionMaxSeries int
+	compat                   *apiCompat // per-server API shape, see tdarr_compat.go
+	apiCapability            typedDesc
+	unknownStatusMu          sync.Mutex
+	unknownStatusCounts      map[unknownStatusKey]float64 // monotonic counter for enum drift detection
+	totalFilesMetric         typedDesc
+	totalTranscodeCount      typedDesc
+	totalHealthCheckCount    typedDesc
+	sizeDiff                 typedDesc
+	tdarrScore               typedDesc
+	healthCheckScore         typedDesc
+	avgNumStreams            typedDesc
+	streamStatsDuration      typedDesc
+	streamStatsBitRate       typedDesc
+	streamStatsNumFrames     typedDesc
+	pieNumFiles              typedDesc
+	pieNumTranscodes         typedDesc
+	pieNumHealthChecks       typedDesc
+	pieSizeDiff              typedDesc
+	pieTranscodes            typedDesc
+	pieHealthChecks          typedDesc
+	pieVideoCodecs           typedDesc
+	pieVideoContainers       typedDesc
+	pieVideoResolutions      typedDesc
+	pieAudioCodecs           typedDesc
+	pieAudioContainers       typedDesc
+	pieLibraryInfo           typedDesc // library_id → library_name mapping (value always 1)
+	// The settings of each library, from the library list.
+	librarySettingsInfo       typedDesc
+	libraryFolderWatchEnabled typedDesc
+	libraryScanInterval       typedDesc
+	libraryProcessingEnabled  typedDesc
+	libraryTranscodeEnabled   typedDesc
+	libraryHealthCheckEnabled typedDesc
+	libraryPriority           typedDesc
+	libraryScheduleHours      typedDesc
+	libraryScheduleActive     typedDesc
+	libraryScanInProgress     typedDesc
+	libraryScanFilesFound     typedDesc
+	fileSizeHistogram         typedDesc
+	librarySizeBytes          typedDesc
+	// Bytes by the video properties of tdarr_library_video_codecs & co.
+	videoCodecBytes           typedDesc
+	videoContainerBytes       typedDesc
+	videoResolutionBytes      typedDesc
+	videoCodecResolutionBytes typedDesc
+	// Counts by stream property, for the streams collector.
+	videoDynamicRanges  typedDesc
+	videoBitDepths      typedDesc
+	videoFrameRates     typedDesc
+	audioChannelLayouts typedDesc
+	audioLanguages      typedDesc
+	subtitleLanguages   typedDesc
+	// The flow inventory and the flow step of each flow worker, for the
+	// flows collector.
+	flowInfo           typedDesc
+	flowPlugins        typedDesc
+	workerFlowStepInfo typedDesc
+	// The plugin catalogue and the classic plugin stack of each library, for
+	// the plugins collector.
+	pluginInfo         typedDesc
+	libraryPluginStack typedDesc
+	// The files awaiting approval in staging, for the staged collector.
+	stagedFiles         typedDesc
+	stagedBytes         typedDesc
+	stagedOldestAge     typedDesc
+	unknownStatusTotal  typedDesc           // counter for status values not in known enum
+	nodeCollector       *TdarrNodeCollector // node data
+	upMetric            typedDesc
+	scrapeError         typedDesc // cause of a scrape with tdarr_up 0
+	scrapePhaseDuration typedDesc
+	collectorSuccess    typedDesc
+	collectorDuration   typedDesc
+	pieCacheHits        typedDesc
+	pieCacheMisses      typedDesc
+	pieCacheAge         typedDesc
+	serverUptime        typedDesc
+	serverInfo          typedDesc
+	serverStatus        typedDesc
+	serverHealthy       typedDesc
+	// apiKeyFile is the rotating key source when runConfig.ApiKeyFile is set,
+	// nil otherwise; its read failures are exported as apiKeyFileReadFailures.
+	apiKeyFile             *client.FileAPIKey
+	apiKeyFileReadFailures typedDesc
+	// apiKeyFileFailuresCarried holds read failures counted by the collectors this
+	// one replaced on reload (see InheritState), keeping the counter monotonic.
+	apiKeyFileFailuresCarried float64
+	// tlsFiles holds the reloading CA bundle and client certificate when any TLS
+	// option is configured, nil otherwise; the certificate's expiry is exported
+	// as clientCertExpiry.
+	tlsFiles         *client.TLSFiles
+	clientCertExpiry typedDesc
+	// breaker is the client's circuit breaker, nil when disabled; exported as
+	// circuitState and circuitTransitions.
+	breaker            *client.CircuitBreaker
+	circuitState       typedDesc
+	circuitTransitions typedDesc
+	// circuitTransitionsCarried holds the transitions counted by the breakers of
+	// the collectors this one replaced on reload (see InheritState).
+	circuitTransitionsCarried map[client.CircuitState]float64
+	// pollInterval, when above 0, makes Collect serve snapshot, refreshed by Run,
+	// instead of scraping; see tdarr_poll.go.
+	pollInterval    time.Duration
+	snapshot        atomic.Pointer[pollSnapshot]
+	now             func() time.Time
+	snapshotAge     typedDesc
+	lastPollSuccess typedDesc
 	// descsList is the collector's own descs in Describe order, assembled once in the
 	// constructor. Describe ranges over this plus the node collector's descs(), so a
 	// metric is registered for Describe in exactly one place (no field-by-field hand-list).
@@ -148,9 +312,14 @@ type libStatsSnapshot struct {
 	stats []*TdarrPieStats
 	// fingerprint is a sorted-by-LibraryId snapshot of the library list at the
 	// time stats was cached, used alongside totals to decide whether a refetch
-	// is needed (see shouldRefetch). Sorted so two fetches of the same library
+	// is needed (see refetchReason). Sorted so two fetches of the same library
 	// set compare equal regardless of the order Tdarr returns them in.
 	fingerprint []TdarrLibraryInfo
+	// sweepDuration is how long the sweep that fetched stats took, the estimate
+	// for the next one when a scrape deadline is close (see libraryPies).
+	sweepDuration time.Duration
+	// written is when stats was cached.
+	written time.Time
 }
 
 // Cache to store library stats and reduce excessive API calls
@@ -159,10 +328,33 @@ type libStatsSnapshot struct {
 type TdarrLibStatsCache struct {
 	mu   sync.RWMutex
 	snap libStatsSnapshot
+	// hits and misses (by reason) count the lookups of libraryPies. They live
+	// with the cache so they carry across a reload along with it.
+	hits   float64
+	misses map[string]float64
 }
 
 func NewTdarrLibStatsCache() *TdarrLibStatsCache {
-	return &TdarrLibStatsCache{}
+	return &TdarrLibStatsCache{misses: make(map[string]float64)}
+}
+
+// recordLookup counts a lookup served from the cache (reason "") or missed
+// for reason.
+func (c *TdarrLibStatsCache) recordLookup(reason string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if reason == "" {
+		c.hits++
+		return
+	}
+	c.misses[reason]++
+}
+
+// lookups returns the hit count and a copy of the miss counts by reason.
+func (c *TdarrLibStatsCache) lookups() (hits float64, misses map[string]float64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.hits, maps.Clone(c.misses)
 }
 
 // Read returns the cached snapshot under a single lock. snap.stats is nil until
@@ -181,26 +373,168 @@ func (c *TdarrLibStatsCache) Write(snap libStatsSnapshot) {
 	c.snap = snap
 }
 
+// TdarrCollectorOption is a functional option for NewTdarrCollector.
+type TdarrCollectorOption func(*tdarrCollectorOptions)
+
+type tdarrCollectorOptions struct {
+	registerer prometheus.Registerer
+	stateStore *PieCacheStore
+}
+
+// WithRegisterer registers the metrics of the collector's HTTP client (see
+// client.Metrics) with reg, labeled with the target's tdarr_instance.
+// Default: the client is not instrumented.
+func WithRegisterer(reg prometheus.Registerer) TdarrCollectorOption {
+	return func(o *tdarrCollectorOptions) {
+		o.registerer = reg
+	}
+}
+
+// UnregisterClientMetrics removes the HTTP client metrics a collector for
+// instanceName registered with reg through WithRegisterer, for a target a
+// configuration reload has dropped or renamed.
+func UnregisterClientMetrics(reg prometheus.Registerer, instanceName string) {
+	client.UnregisterMetrics(instanceRegisterer(reg, instanceName))
+}
+
+// instanceRegisterer wraps reg with the tdarr_instance label of the client
+// metrics registered for instanceName.
+func instanceRegisterer(reg prometheus.Registerer, instanceName string) prometheus.Registerer {
+	return prometheus.WrapRegistererWith(prometheus.Labels{"tdarr_instance": instanceName}, reg)
+}
+
+// WithStateStore restores the collector's pie cache from store, and saves it
+// there after every sweep. Default: the cache starts empty and is not saved.
+func WithStateStore(store *PieCacheStore) TdarrCollectorOption {
+	return func(o *tdarrCollectorOptions) {
+		o.stateStore = store
+	}
+}
+
 // collector
 //
 // NewTdarrCollector builds the shared HTTP client once from runConfig and wires it
 // into both the top-level collector and the embedded node collector. The client is
 // surfaced as a tdarrAPI; the error from client.NewRequestClient is propagated so the
 // composition root (main) can fail fast on a bad URL.
-func NewTdarrCollector(ctx context.Context, runConfig config.Config) (*TdarrCollector, error) {
-	api, err := client.NewRequestClient(runConfig.UrlParsed, runConfig.VerifySsl, runConfig.HttpTimeoutSeconds, runConfig.ApiKey)
+func NewTdarrCollector(ctx context.Context, runConfig config.Config, collectorOpts ...TdarrCollectorOption) (*TdarrCollector, error) {
+	var (
+		options    tdarrCollectorOptions
+		opts       []client.RequestClientOption
+		apiKeyFile *client.FileAPIKey
+		tlsFiles   *client.TLSFiles
+		breaker    *client.CircuitBreaker
+	)
+	for _, opt := range collectorOpts {
+		opt(&options)
+	}
+	if options.registerer != nil {
+		metrics, err := client.NewMetrics(instanceRegisterer(options.registerer, runConfig.InstanceName))
+		if err != nil {
+			return nil, fmt.Errorf("register http client metrics: %w", err)
+		}
+		opts = append(opts, client.WithMetrics(metrics))
+	}
+	if runConfig.ApiKeyFile != "" {
+		var err error
+		if apiKeyFile, err = client.NewFileAPIKey(runConfig.ApiKeyFile); err != nil {
+			return nil, err
+		}
+		opts = append(opts, client.WithAPIKeySource(apiKeyFile))
+	}
+	if auth := proxyAuthenticators(runConfig); len(auth) > 0 {
+		opts = append(opts, client.WithAuth(auth...))
+	}
+	if len(runConfig.RequestPolicies) > 0 {
+		opts = append(opts, client.WithPolicies(requestPolicies(runConfig)))
+	}
+	if runConfig.CircuitBreakerThreshold > 0 {
+		breaker = client.NewCircuitBreaker(runConfig.CircuitBreakerThreshold, time.Duration(runConfig.CircuitBreakerCooldownSeconds)*time.Second)
+		opts = append(opts, client.WithCircuitBreaker(breaker))
+	}
+	if runConfig.HasTLSOptions() {
+		var err error
+		tlsFiles, err = client.NewTLSFiles(client.TLSOptions{
+			CAFile:     runConfig.TlsCaFile,
+			CertFile:   runConfig.TlsCertFile,
+			KeyFile:    runConfig.TlsKeyFile,
+			ServerName: runConfig.TlsServerName,
+			MinVersion: runConfig.TLSMinVersionValue(),
+		}, runConfig.VerifySsl)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, client.WithTLSFiles(tlsFiles))
+	}
+	api, err := client.NewRequestClient(runConfig.UrlParsed, runConfig.VerifySsl, runConfig.HttpTimeoutSeconds, runConfig.ApiKey, opts...)
 	if err != nil {
 		log.Error().
 			Err(err).Msg("Failed to create http request client for Tdarr, ensure proper URL is provided")
 		return nil, err
 	}
 	c := newTdarrCollectorWithAPI(runConfig, api)
+	c.apiKeyFile = apiKeyFile
+	c.tlsFiles = tlsFiles
+	c.breaker = breaker
 	// Wire the shutdown-cancellable context from the composition root so a scrape
 	// in flight when the process is terminating aborts instead of running to completion.
 	c.baseCtx = ctx
+	if options.stateStore != nil {
+		c.useStateStore(options.stateStore, runConfig.UrlParsed.String())
+	}
 	return c, nil
 }
 
+// proxyAuthenticators builds the authenticators for the credentials of a proxy
+// in front of Tdarr, sent in addition to the api key. The extra headers go
+// first so a configured Authorization scheme wins over an Authorization header.
+func proxyAuthenticators(runConfig config.Config) []client.Authenticator {
+	var auth []client.Authenticator
+	if len(runConfig.HttpHeaders) > 0 {
+		auth = append(auth, client.StaticHeaders(runConfig.HttpHeaders))
+	}
+	switch {
+	case runConfig.BasicAuth != config.BasicAuth{}:
+		auth = append(auth, client.BasicAuth{Username: runConfig.BasicAuth.Username, Password: runConfig.BasicAuth.Password})
+	case runConfig.BearerToken != "":
+		auth = append(auth, client.BearerToken(runConfig.BearerToken))
+	case runConfig.Oauth2.TokenUrl != "":
+		auth = append(auth, client.NewOAuth2ClientCredentials(client.OAuth2Config{
+			TokenURL:           runConfig.Oauth2.TokenUrl,
+			ClientID:           runConfig.Oauth2.ClientId,
+			ClientSecret:       runConfig.Oauth2.ClientSecret,
+			Scopes:             runConfig.Oauth2.Scopes,
+			InsecureSkipVerify: !runConfig.VerifySsl,
+		}))
+	}
+	return auth
+}
+
+// requestPolicies keys the configured per-endpoint request policies by the
+// request path the client sees.
+func requestPolicies(runConfig config.Config) map[string]client.RequestPolicy {
+	paths := map[string]string{
+		"stats":     runConfig.TdarrStatsPath,
+		"pie_stats": runConfig.TdarrPieStatsPath,
+		"nodes":     runConfig.TdarrNodePath,
+		"status":    runConfig.TdarrStatusPath,
+		"files":     runConfig.TdarrFilesPath,
+		"plugins":   runConfig.TdarrPluginsPath,
+	}
+	policies := make(map[string]client.RequestPolicy, len(runConfig.RequestPolicies))
+	for endpoint, p := range runConfig.RequestPolicies {
+		policies[paths[endpoint]] = client.RequestPolicy{
+			Timeout:       p.Timeout,
+			Retries:       p.Retries,
+			Backoff:       p.Backoff,
+			MaxBackoff:    p.MaxBackoff,
+			Jitter:        p.Jitter,
+			RetryStatuses: p.RetryStatuses,
+		}
+	}
+	return policies
+}
+
 // newTdarrCollectorWithAPI is the test-injection seam: it builds a fully wired
 // TdarrCollector around an already-constructed tdarrAPI. The same api is shared with
 // the node collector since both hit the same base URL.
@@ -208,15 +542,30 @@ func newTdarrCollectorWithAPI(runConfig config.Config, api tdarrAPI) *TdarrColle
 	instance := prometheus.Labels{"tdarr_instance": runConfig.InstanceName}
 
 	c := &TdarrCollector{
-		statsPath:           runConfig.TdarrStatsPath,
-		pieStatsPath:        runConfig.TdarrPieStatsPath,
-		statusPath:          runConfig.TdarrStatusPath,
-		maxConcurrency:      runConfig.HttpMaxConcurrency,
-		api:                 api,
-		baseCtx:             context.Background(),
-		logger:              log.Logger,
-		statsCache:          NewTdarrLibStatsCache(),
-		unknownStatusCounts: make(map[unknownStatusKey]float64),
+		instanceName:              runConfig.InstanceName,
+		statsPath:                 runConfig.TdarrStatsPath,
+		pieStatsPath:              runConfig.TdarrPieStatsPath,
+		statusPath:                runConfig.TdarrStatusPath,
+		maxConcurrency:            runConfig.HttpMaxConcurrency,
+		collectors:                runConfig.Collectors,
+		pollInterval:              runConfig.PollInterval,
+		now:                       time.Now,
+		labelPolicies:             runConfig.LabelPolicies,
+		api:                       api,
+		baseCtx:                   context.Background(),
+		logger:                    log.Logger,
+		statsCache:                NewTdarrLibStatsCache(),
+		filesCache:                &fileStatsCache{},
+		fileSizeBuckets:           runConfig.FileSizeBuckets,
+		filesPageSize:             runConfig.FilesPageSize,
+		filesPath:                 runConfig.TdarrFilesPath,
+		pluginsCache:              &pluginsCache{},
+		pluginsPath:               runConfig.TdarrPluginsPath,
+		pluginsRefresh:            runConfig.PluginsRefreshInterval,
+		codecResolutionMaxSeries:  runConfig.CodecResolutionMaxSeries,
+		compat:                    &apiCompat{},
+		unknownStatusCounts:       make(map[unknownStatusKey]float64),
+		circuitTransitionsCarried: make(map[client.CircuitState]float64),
 		totalFilesMetric: newGauge(
 			"files",
 			"Tdarr total file count - includes files in ignore lists within each library",
@@ -327,17 +676,142 @@ func newTdarrCollectorWithAPI(runConfig config.Config, api tdarrAPI) *TdarrColle
 			"Tdarr library metadata (value always 1); maps the stable library_id to its current library_name. Join other tdarr_library_* metrics on library_id to recover the name.",
 			[]string{"library_id", "library_name"}, instance,
 		),
+		librarySettingsInfo: newGauge(
+			"library_settings_info",
+			"Tdarr library settings (value always 1): mode (flows or classic plugin stack), flow_id (empty in classic mode), cache_folder and output_folder (empty without folder to folder conversion).",
+			[]string{"library_id", "mode", "flow_id", "cache_folder", "output_folder"}, instance,
+		),
+		libraryFolderWatchEnabled: newGauge(
+			"library_folder_watch_enabled",
+			"1 if the library watches its source folder for new files, 0 otherwise.",
+			[]string{"library_id"}, instance,
+		),
+		libraryScanInterval: newGauge(
+			"library_scan_interval_seconds",
+			"Seconds between the scans of the library's folder watcher.",
+			[]string{"library_id"}, instance,
+		),
+		libraryProcessingEnabled: newGauge(
+			"library_processing_enabled",
+			"1 if the library processes files, 0 if it is paused. Alert with tdarr_library_processing_enabled == 0 on a library left paused.",
+			[]string{"library_id"}, instance,
+		),
+		libraryTranscodeEnabled: newGauge(
+			"library_transcode_enabled",
+			"1 if the library queues files for transcoding, 0 otherwise.",
+			[]string{"library_id"}, instance,
+		),
+		libraryHealthCheckEnabled: newGauge(
+			"library_health_check_enabled",
+			"1 if the library queues files for health checks, 0 otherwise.",
+			[]string{"library_id"}, instance,
+		),
+		libraryPriority: newGauge(
+			"library_priority",
+			"Priority of the library's files in the queues; higher goes first.",
+			[]string{"library_id"}, instance,
+		),
+		libraryScheduleHours: newGauge(
+			"library_schedule_enabled_hours",
+			"Hours of the week, out of 168, the library's schedule lets it process files.",
+			[]string{"library_id"}, instance,
+		),
+		libraryScheduleActive: newGauge(
+			"library_schedule_active",
+			"1 if the library's schedule lets it process files in the current hour, in the exporter's time zone, 0 otherwise.",
+			[]string{"library_id"}, instance,
+		),
+		libraryScanInProgress: newGauge(
+			"library_scan_in_progress",
+			"1 while the library document's scanButtons is false, as Tdarr sets it for the length of a scan, 0 otherwise.",
+			[]string{"library_id"}, instance,
+		),
+		libraryScanFilesFound: newGauge(
+			"library_scan_files_found",
+			"Files the running or last scan of the library found, from the library document's scanFound.",
+			[]string{"library_id"}, instance,
+		),
+		videoDynamicRanges: newGauge(
+			"library_video_dynamic_ranges",
+			"Files in the library by the dynamic range of their video: hdr (PQ or HLG transfer) or sdr. Only emitted by the opt-in streams collector.",
+			[]string{"library_id", "dynamic_range"}, instance,
+		),
+		videoBitDepths: newGauge(
+			"library_video_bit_depths",
+			"Files in the library by the bit depth of their video, e.g. 8 or 10. Only emitted by the opt-in streams collector.",
+			[]string{"library_id", "bit_depth"}, instance,
+		),
+		videoFrameRates: newGauge(
+			"library_video_frame_rates",
+			"Files in the library by the frame rate of their video, the nearest standard rate (e.g. 23.976, 25, 59.94) or other. Only emitted by the opt-in streams collector.",
+			[]string{"library_id", "frame_rate"}, instance,
+		),
+		audioChannelLayouts: newGauge(
+			"library_audio_channel_layouts",
+			"Audio streams in the library by channel layout, e.g. stereo or 5.1. Only emitted by the opt-in streams collector.",
+			[]string{"library_id", "layout"}, instance,
+		),
+		audioLanguages: newGauge(
+			"library_audio_languages",
+			"Audio streams in the library by language tag, und when untagged. Only emitted by the opt-in streams collector.",
+			[]string{"library_id", "language"}, instance,
+		),
+		subtitleLanguages: newGauge(
+			"library_subtitle_languages",
+			"Subtitle streams in the library by language tag, und when untagged. Only emitted by the opt-in streams collector.",
+			[]string{"library_id", "language"}, instance,
+		),
+		flowInfo: newGauge(
+			"flow_info",
+			"Tdarr flow metadata (value always 1); maps the stable flow_id to its current flow_name. Join tdarr_library_settings_info on flow_id for the libraries using the flow. Only emitted by the opt-in flows collector.",
+			[]string{"flow_id", "flow_name"}, instance,
+		),
+		flowPlugins: newGauge(
+			"flow_plugins",
+			"Plugins in the flow, the steps a file can go through. Only emitted by the opt-in flows collector.",
+			[]string{"flow_id"}, instance,
+		),
+		workerFlowStepInfo: newGauge(
+			"node_worker_flow_step_info",
+			"Flow and step each busy flow worker is on (value always 1); flow_name and step_name are empty when the flow or step is not in the flows document. Only emitted by the opt-in flows collector with the workers collector enabled.",
+			[]string{"node_id", "node_name", "worker_id", "flow_id", "flow_name", "step_id", "step_name"}, instance,
+		),
+		pluginInfo: newGauge(
+			"plugin_info",
+			"Tdarr plugin metadata (value always 1); maps the plugin_id of tdarr_library_plugin_stack and the worker_plugin_id of tdarr_node_worker_plugin to its plugin_name, source (community or local) and version. Only emitted by the opt-in plugins collector.",
+			[]string{"plugin_id", "plugin_name", "source", "version"}, instance,
+		),
+		libraryPluginStack: newGauge(
+			"library_plugin_stack",
+			"Plugins of the library's classic plugin stack (value always 1): position is the 1-based run order, enabled whether the plugin is checked. Only emitted by the opt-in plugins collector.",
+			[]string{"library_id", "plugin_id", "position", "enabled"}, instance,
+		),
+		stagedFiles: newGauge(
+			"library_staged_files",
+			"Files of the library waiting in staging to be accepted or rejected. Libraries without staged files are absent. Only emitted by the opt-in staged collector.",
+			[]string{"library_id"}, instance,
+		),
+		stagedBytes: newGauge(
+			"library_staged_bytes",
+			"Bytes of the files of the library waiting in staging. Only emitted by the opt-in staged collector.",
+			[]string{"library_id"}, instance,
+		),
+		stagedOldestAge: newGauge(
+			"library_staged_oldest_age_seconds",
+			"Seconds since the oldest file of the library waiting in staging was staged; absent when no staged file records when it was staged. Only emitted by the opt-in staged collector.",
+			[]string{"library_id"}, instance,
+		),
 		unknownStatusTotal: newCounter(
 			"unknown_status_total",
-			"Count of pie status values not in the known enum, by job_kind (transcode|healthcheck) and status label. "+
+			"Count of pie status and stream property values not in the known enum, by job_kind (transcode|healthcheck, or the stream property of the streams collector) and status label. "+
 				"A non-zero value indicates Tdarr emitted a status that the exporter does not pre-emit zeros for. "+
 				"Use increase(tdarr_unknown_status_total[24h]) > 0 to alert on API drift.",
 			[]string{"job_kind", "status"}, instance,
 		),
 		upMetric: newGauge(
 			"up",
-			"1 if the last collection cycle succeeded, 0 otherwise (Tdarr API error, response parse error, or partial pie-stats fetch). "+
-				"Distinct from prometheus built-in 'up' which indicates exporter process reachability.",
+			"1 if every enabled collector succeeded in the last collection cycle, 0 otherwise (Tdarr API error, response parse error, or partial pie-stats fetch); "+
+				"tdarr_scrape_collector_success tells which failed. Distinct from prometheus built-in 'up' which indicates exporter process reachability.",
 			nil, instance,
 		),
 		serverUptime: newGauge(
@@ -356,6 +830,108 @@ func newTdarrCollectorWithAPI(runConfig config.Config, api tdarrAPI) *TdarrColle
 				"Alert with tdarr_server_status_info{status!=\"good\"} == 1.",
 			[]string{"status"}, instance,
 		),
+		apiKeyFileReadFailures: newCounter(
+			"api_key_file_read_failures_total",
+			"Count of failed re-reads of the api key file (missing, unreadable or empty); the last good key stays in use. Only emitted when api_key_file is set.",
+			nil, instance,
+		),
+		clientCertExpiry: newGauge(
+			"client_certificate_expiry_timestamp_seconds",
+			"Unix time at which the tls client certificate presented to Tdarr expires, re-read when the file changes. Only emitted when tls_cert_file is set.",
+			nil, instance,
+		),
+		apiCapability: newGauge(
+			"exporter_api_capability",
+			"1 if the exporter uses this Tdarr API feature for the instance, 0 otherwise, as decided by the last scrape from the server version and the endpoints it has. "+
+				"server_status gates the tdarr_server_* series; library_pies and legacy_library_pies say where the tdarr_library_* series come from (get-pies, or the statistics document of Tdarr before 2.24.01).",
+			[]string{"feature"}, instance,
+		),
+		circuitState: newGauge(
+			"exporter_circuit_state",
+			"1 for the state the circuit breaker in front of Tdarr is in (closed, open or half_open), 0 for the others. "+
+				"While open, scrapes fail with tdarr_up=0 without contacting Tdarr. Only emitted when circuit_breaker_threshold is above 0.",
+			[]string{"state"}, instance,
+		),
+		circuitTransitions: newCounter(
+			"exporter_circuit_transitions_total",
+			"Count of circuit breaker transitions into each state. Only emitted when circuit_breaker_threshold is above 0.",
+			[]string{"state"}, instance,
+		),
+		scrapeError: newGauge(
+			"exporter_scrape_error",
+			"1 with the cause of the last scrape's failure when tdarr_up is 0: deadline_exceeded (the Prometheus scrape timeout was reached or too close to fetch library stats), upstream, parse, partial (some library stats missing) or panic.",
+			[]string{"cause"}, instance,
+		),
+		scrapePhaseDuration: newGauge(
+			"exporter_scrape_phase_duration_seconds",
+			"Seconds each phase of the last scrape took: status, general (the statistics document), libraries (the library list), pies (the per-library stats, only when not served from the cache), nodes, files (the file walk, only when not served from the cache), flows (the flows document), plugins (the plugin catalogue, only when the cached one expired) and staged (the staged files). A phase that did not run is absent.",
+			[]string{"phase"}, instance,
+		),
+		fileSizeHistogram: newHistogram(
+			"library_file_size_bytes",
+			"Sizes of the files in the library, per file_size_buckets. Only emitted by the opt-in files collector.",
+			[]string{"library_id"}, instance,
+		),
+		librarySizeBytes: newGauge(
+			"library_size_bytes",
+			"Total size of the files in the library. Only emitted by the opt-in files collector.",
+			[]string{"library_id"}, instance,
+		),
+		videoCodecBytes: newGauge(
+			"library_video_codec_bytes",
+			"Total size of the files in the library by video codec, the bytes behind tdarr_library_video_codecs. Only emitted by the opt-in files collector.",
+			[]string{"library_id", "codec"}, instance,
+		),
+		videoContainerBytes: newGauge(
+			"library_video_container_bytes",
+			"Total size of the files in the library by container, the bytes behind tdarr_library_video_containers. Only emitted by the opt-in files collector.",
+			[]string{"library_id", "container_type"}, instance,
+		),
+		videoResolutionBytes: newGauge(
+			"library_video_resolution_bytes",
+			"Total size of the files in the library by video resolution, the bytes behind tdarr_library_video_resolutions. Only emitted by the opt-in files collector.",
+			[]string{"library_id", "resolution"}, instance,
+		),
+		videoCodecResolutionBytes: newGauge(
+			"library_video_codec_resolution_bytes",
+			"Total size of the files in the library by video codec and resolution together, at most codec_resolution_max_series series per library: the smallest pairs beyond it are summed as codec and resolution \"overflow\". Only emitted by the opt-in files collector with codec_resolution_max_series set.",
+			[]string{"library_id", "codec", "resolution"}, instance,
+		),
+		collectorSuccess: newGauge(
+			"scrape_collector_success",
+			"1 if the collector succeeded in the last scrape, 0 if it failed: server, general, library (0 too when some library stats are missing or were served from the cache past the scrape deadline), nodes (nodes and workers), files, streams, flows, plugins or staged. Only enabled collectors are reported; each emits its own series whether or not the others fail.",
+			[]string{"collector"}, instance,
+		),
+		collectorDuration: newGauge(
+			"scrape_collector_duration_seconds",
+			"Seconds each enabled collector took in the last scrape, including the requests it needed. general and library both include the statistics document they share.",
+			[]string{"collector"}, instance,
+		),
+		pieCacheHits: newCounter(
+			"exporter_pie_cache_hits_total",
+			"Scrapes whose per-library stats were served from the cache because the statistics totals and the library list were unchanged.",
+			nil, instance,
+		),
+		pieCacheMisses: newCounter(
+			"exporter_pie_cache_misses_total",
+			"Scrapes that had to fetch the per-library stats, by reason: empty (nothing cached yet), totals_changed (a statistics total changed) or fingerprint_changed (a library was added, removed or renamed).",
+			[]string{"reason"}, instance,
+		),
+		pieCacheAge: newGauge(
+			"exporter_pie_cache_age_seconds",
+			"Seconds since the cached per-library stats were fetched. Absent until a fetch has succeeded.",
+			nil, instance,
+		),
+		snapshotAge: newGauge(
+			"exporter_snapshot_age_seconds",
+			"Seconds since the background poll that produced the served metrics finished. Only emitted when poll_interval is set.",
+			nil, instance,
+		),
+		lastPollSuccess: newGauge(
+			"exporter_last_poll_success_timestamp_seconds",
+			"Unix timestamp of the last background poll in which every request to Tdarr succeeded. Only emitted when poll_interval is set, once a poll has succeeded.",
+			nil, instance,
+		),
 		serverHealthy: newGauge(
 			"server_healthy",
 			"1 if Tdarr server self-reported status is healthy (\"good\"/\"ok\"/\"healthy\", case-insensitive), 0 otherwise. Raw status string is on tdarr_server_status_info.",
@@ -364,43 +940,164 @@ func newTdarrCollectorWithAPI(runConfig config.Config, api tdarrAPI) *TdarrColle
 		nodeCollector: NewTdarrNodeCollector(runConfig, api, log.Logger),
 	}
 
-	// Assemble the collector's own descs once, in Describe order. Describe ranges over
-	// this list plus the node collector's descs() — adding a metric means appending here
-	// in exactly one place. The order matches the historical hand-written Describe list.
+	// Assemble the collector's own descs once, in Describe order: the always-on exporter
+	// descs, then the group of each enabled collector.* toggle. Describe ranges over this
+	// list plus the node collector's descs() — adding a metric means appending to its
+	// group here in exactly one place.
+	collectors := runConfig.Collectors
 	c.descsList = []typedDesc{
-		c.totalFilesMetric,
-		c.totalTranscodeCount,
-		c.totalHealthCheckCount,
-		c.sizeDiff,
-		c.tdarrScore,
-		c.healthCheckScore,
-		c.pieNumFiles,
-		c.pieNumTranscodes,
-		c.pieNumHealthChecks,
-		c.pieSizeDiff,
-		c.pieTranscodes,
-		c.pieHealthChecks,
-		c.avgNumStreams,
-		c.streamStatsDuration,
-		c.streamStatsBitRate,
-		c.streamStatsNumFrames,
-		c.pieVideoCodecs,
-		c.pieVideoContainers,
-		c.pieVideoResolutions,
-		c.pieAudioCodecs,
-		c.pieAudioContainers,
-		c.pieLibraryInfo,
-		c.unknownStatusTotal,
 		c.upMetric,
-		c.serverUptime,
-		c.serverInfo,
-		c.serverStatus,
-		c.serverHealthy,
+		c.scrapeError,
+		c.scrapePhaseDuration,
+		c.collectorSuccess,
+		c.collectorDuration,
+		c.apiKeyFileReadFailures,
+		c.clientCertExpiry,
+		c.apiCapability,
+		c.circuitState,
+		c.circuitTransitions,
+		c.snapshotAge,
+		c.lastPollSuccess,
+	}
+	if collectors.Server {
+		c.descsList = append(c.descsList,
+			c.serverUptime,
+			c.serverInfo,
+			c.serverStatus,
+			c.serverHealthy,
+		)
+	}
+	if collectors.General {
+		c.descsList = append(c.descsList,
+			c.totalFilesMetric,
+			c.totalTranscodeCount,
+			c.totalHealthCheckCount,
+			c.sizeDiff,
+			c.tdarrScore,
+			c.healthCheckScore,
+			c.avgNumStreams,
+			c.streamStatsDuration,
+			c.streamStatsBitRate,
+			c.streamStatsNumFrames,
+		)
+	}
+	if collectors.Library {
+		c.descsList = append(c.descsList,
+			c.pieNumFiles,
+			c.pieNumTranscodes,
+			c.pieNumHealthChecks,
+			c.pieSizeDiff,
+			c.pieTranscodes,
+			c.pieHealthChecks,
+			c.pieVideoCodecs,
+			c.pieVideoContainers,
+			c.pieVideoResolutions,
+			c.pieAudioCodecs,
+			c.pieAudioContainers,
+			c.pieLibraryInfo,
+			c.librarySettingsInfo,
+			c.libraryFolderWatchEnabled,
+			c.libraryScanInterval,
+			c.libraryProcessingEnabled,
+			c.libraryTranscodeEnabled,
+			c.libraryHealthCheckEnabled,
+			c.libraryPriority,
+			c.libraryScheduleHours,
+			c.libraryScheduleActive,
+			c.libraryScanInProgress,
+			c.libraryScanFilesFound,
+			c.unknownStatusTotal,
+			c.pieCacheHits,
+			c.pieCacheMisses,
+			c.pieCacheAge,
+		)
+	}
+	if collectors.Files {
+		c.descsList = append(c.descsList,
+			c.fileSizeHistogram,
+			c.librarySizeBytes,
+			c.videoCodecBytes,
+			c.videoContainerBytes,
+			c.videoResolutionBytes,
+		)
+		if c.codecResolutionMaxSeries > 0 {
+			c.descsList = append(c.descsList, c.videoCodecResolutionBytes)
+		}
+	}
+	if collectors.Streams {
+		c.descsList = append(c.descsList,
+			c.videoDynamicRanges,
+			c.videoBitDepths,
+			c.videoFrameRates,
+			c.audioChannelLayouts,
+			c.audioLanguages,
+			c.subtitleLanguages,
+		)
+		if !collectors.Library {
+			c.descsList = append(c.descsList, c.unknownStatusTotal)
+		}
+	}
+	if collectors.Flows {
+		c.descsList = append(c.descsList,
+			c.flowInfo,
+			c.flowPlugins,
+		)
+		if collectors.Workers {
+			c.descsList = append(c.descsList, c.workerFlowStepInfo)
+		}
+	}
+	if collectors.Plugins {
+		c.descsList = append(c.descsList,
+			c.pluginInfo,
+			c.libraryPluginStack,
+		)
+	}
+	if collectors.Staged {
+		c.descsList = append(c.descsList,
+			c.stagedFiles,
+			c.stagedBytes,
+			c.stagedOldestAge,
+		)
 	}
 
 	return c
 }
 
+// InheritState carries the scrape-spanning state of prev over to c when a config
+// reload replaces a collector for the same Tdarr instance: the pie-stats and file
+// caches and API compatibility state (shared, so a reload does not force a full
+// per-library refetch, a file walk, a plugin catalogue fetch or repeat the
+// get-pies probe) and the unknown-status, api-key-file
+// failure and circuit transition counts (copied, so the counters stay
+// monotonic instead of resetting). With a poll interval, c serves prev's last
+// snapshot until its own first poll finishes. The caller decides prev is the same
+// instance; c must not have been scraped yet.
+func (c *TdarrCollector) InheritState(prev *TdarrCollector) {
+	c.statsCache = prev.statsCache
+	c.filesCache = prev.filesCache
+	c.pluginsCache = prev.pluginsCache
+	c.compat = prev.compat
+	c.snapshot.Store(prev.snapshot.Load())
+	if prev.apiKeyFile != nil {
+		c.apiKeyFileFailuresCarried = prev.apiKeyFileFailuresCarried + float64(prev.apiKeyFile.ReadFailures())
+	}
+	for state, n := range prev.circuitTransitionsCarried {
+		c.circuitTransitionsCarried[state] += n
+	}
+	if prev.breaker != nil {
+		for state, n := range prev.breaker.Transitions() {
+			c.circuitTransitionsCarried[state] += float64(n)
+		}
+	}
+	prev.unknownStatusMu.Lock()
+	defer prev.unknownStatusMu.Unlock()
+	c.unknownStatusMu.Lock()
+	defer c.unknownStatusMu.Unlock()
+	for key, count := range prev.unknownStatusCounts {
+		c.unknownStatusCounts[key] = count
+	}
+}
+
 // Describe emits every registered desc by ranging over a single ordered slice: the
 // collector's own descs (assembled in the constructor) followed by the node collector's
 // descs(). There is no field-by-field hand-list and no reach-in to nodeCollector.metrics.*,
@@ -444,7 +1141,7 @@ func (c *TdarrCollector) bumpUnknownStatus(kind, status string) {
 }
 
 // support concurrency
-func (c *TdarrCollector) getLibStats(ctx context.Context, wg *sync.WaitGroup, inChan <-chan TdarrPieDataRequest, outChan chan<- *TdarrPieStats, partial *atomic.Bool) {
+func (c *TdarrCollector) getLibStats(ctx context.Context, wg *sync.WaitGroup, inChan <-chan TdarrPieDataRequest, outChan chan<- *TdarrPieStats, partial, missing *atomic.Bool) {
 	defer wg.Done()
 	for piePayload := range inChan {
 		pieMetric := &TdarrPieStats{}
@@ -452,6 +1149,9 @@ func (c *TdarrCollector) getLibStats(ctx context.Context, wg *sync.WaitGroup, in
 		err := c.httpReqHelper(ctx, c.pieStatsPath, piePayload, pieMetric)
 		if err != nil {
 			c.logger.Error().Interface("payload", piePayload).Err(err).Msg("Failed to get Lib stats pie data")
+			if isNotFound(err) {
+				missing.Store(true)
+			}
 			// Signal partial failure so Collect() sets tdarr_up=0 and collect()
 			// skips the cache write (partial results are never cached; the next
 			// scrape re-fetches). This scrape emits only the libraries that succeeded.
@@ -468,30 +1168,40 @@ func (c *TdarrCollector) getLibStats(ctx context.Context, wg *sync.WaitGroup, in
 		// response schema), so log loudly if this fires and flag the scrape
 		// partial so the dropped series surfaces as tdarr_up=0 instead of
 		// silently vanishing.
-		if pieMetric.libraryId == "" || pieMetric.libraryName == "" {
-			c.logger.Warn().
-				Str("libraryId", pieMetric.libraryId).
-				Str("libraryName", pieMetric.libraryName).
-				Msg("Tdarr returned library with empty id or name; dropping series")
+		if !c.preparePie(pieMetric) {
 			partial.Store(true)
 			continue
 		}
-		// Normalize status slices to cleaned-label maps covering the full known enum.
-		// This ensures zero values are emitted for all known statuses even when Tdarr
-		// omits them from the response (Tdarr only returns non-zero counts).
-		normalizePieStatuses(pieMetric, c.bumpUnknownStatus)
 		outChan <- pieMetric
 	}
 }
 
+// preparePie readies a fetched library for emission and reports whether it may be
+// emitted. A library with an empty id or name is logged and dropped; the caller
+// flags the scrape partial.
+func (c *TdarrCollector) preparePie(pie *TdarrPieStats) bool {
+	if pie.libraryId == "" || pie.libraryName == "" {
+		c.logger.Warn().
+			Str("libraryId", pie.libraryId).
+			Str("libraryName", pie.libraryName).
+			Msg("Tdarr returned library with empty id or name; dropping series")
+		return false
+	}
+	// Normalize status slices to cleaned-label maps covering the full known enum.
+	// This ensures zero values are emitted for all known statuses even when Tdarr
+	// omits them from the response (Tdarr only returns non-zero counts).
+	normalizePieStatuses(pie, c.bumpUnknownStatus)
+	return true
+}
+
 // fetchPies fans the per-library pie requests across HttpMaxConcurrency workers and
 // fans the results back in. Per-library fetch failures are handled inside getLibStats
 // (it stores true into the per-scrape partial flag and skips that library), so this returns
 // the gathered pie stats alongside whether any library failed — the same set collect()
-// previously assembled inline before caching.
-func (c *TdarrCollector) fetchPies(ctx context.Context, allLibs []TdarrLibraryInfo) ([]*TdarrPieStats, bool) {
-	var pieData []*TdarrPieStats
-	var partial atomic.Bool
+// previously assembled inline before caching — and whether any failed with a 404,
+// i.e. the server has no get-pies endpoint.
+func (c *TdarrCollector) fetchPies(ctx context.Context, allLibs []TdarrLibraryInfo) (pieData []*TdarrPieStats, partialFail, missing bool) {
+	var partial, notFound atomic.Bool
 
 	dataWg := &sync.WaitGroup{}
 	inChan := make(chan TdarrPieDataRequest, len(allLibs))
@@ -499,7 +1209,7 @@ func (c *TdarrCollector) fetchPies(ctx context.Context, allLibs []TdarrLibraryIn
 	// start workers
 	for i := 0; i < c.maxConcurrency; i++ {
 		dataWg.Add(1)
-		go c.getLibStats(ctx, dataWg, inChan, outChan, &partial)
+		go c.getLibStats(ctx, dataWg, inChan, outChan, &partial, &notFound)
 	}
 
 	// send data to workers
@@ -536,14 +1246,52 @@ func (c *TdarrCollector) fetchPies(ctx context.Context, allLibs []TdarrLibraryIn
 
 	// wait for results to be collected
 	resultWg.Wait()
-	return pieData, partial.Load()
+	return pieData, partial.Load(), notFound.Load()
 }
 
+// ScrapeCollector collects metrics within a context, so a scrape can stop once
+// the scraper has stopped waiting for it. TdarrCollector implements it.
+type ScrapeCollector interface {
+	CollectContext(ctx context.Context, ch chan<- prometheus.Metric)
+}
+
+// Collect is CollectContext without a deadline.
 func (c *TdarrCollector) Collect(ch chan<- prometheus.Metric) {
-	// Derive a per-scrape context from baseCtx (cancelled on shutdown). The defer
-	// releases the context tree when the scrape returns; if baseCtx is cancelled
+	c.CollectContext(context.Background(), ch)
+}
+
+// CollectContext scrapes Tdarr live, or with a poll interval serves the latest
+// snapshot taken by Run. A live scrape stops when ctx is done, e.g. when the
+// Prometheus scrape being served times out, and skips the library stats
+// sweep when ctx's deadline is too close for it. Either way it reports
+// tdarr_up 0 with cause deadline_exceeded.
+func (c *TdarrCollector) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	if c.pollInterval > 0 {
+		c.collectSnapshot(ch)
+		return
+	}
+	// The scrape stays under baseCtx, so shutdown still aborts it; ctx's
+	// deadline and cancellation are layered on top. The cause is carried over
+	// so scrape can tell a missed deadline from a cancellation.
+	parent, cancel := context.WithCancelCause(c.baseCtx)
+	defer cancel(nil)
+	stop := context.AfterFunc(ctx, func() { cancel(context.Cause(ctx)) })
+	defer stop()
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancelDeadline context.CancelFunc
+		parent, cancelDeadline = context.WithDeadline(parent, deadline)
+		defer cancelDeadline()
+	}
+	c.scrape(parent, ch)
+}
+
+// scrape runs one collection against Tdarr, emitting every metric including
+// tdarr_up, and reports whether it fully succeeded (tdarr_up 1).
+func (c *TdarrCollector) scrape(parent context.Context, ch chan<- prometheus.Metric) (up bool) {
+	// Derive a per-scrape context from parent (baseCtx, cancelled on shutdown). The
+	// defer releases the context tree when the scrape returns; if parent is cancelled
 	// mid-scrape, the in-flight HTTP requests abort.
-	ctx, cancel := context.WithCancel(c.baseCtx)
+	ctx, cancel := context.WithCancel(parent)
 	defer cancel()
 	// Recover from any panic in the scrape path so a single bad scrape degrades to
 	// tdarr_up=0 instead of crashing the process (client_golang's collectWorker has
@@ -551,18 +1299,68 @@ func (c *TdarrCollector) Collect(ch chan<- prometheus.Metric) {
 	defer func() {
 		if r := recover(); r != nil {
 			c.logger.Error().Interface("panic", r).Msg("Panic during collection; emitting tdarr_up=0")
+			ch <- c.scrapeError.mustNewConstMetric(1, causePanic)
 			ch <- c.upMetric.mustNewConstMetric(0.0)
+			up = false
 		}
 	}()
+	// Emitted before collect so a scrape failing on a rejected (stale) key still
+	// reports the read failures that explain it.
+	if c.apiKeyFile != nil {
+		ch <- c.apiKeyFileReadFailures.mustNewConstMetric(c.apiKeyFileFailuresCarried + float64(c.apiKeyFile.ReadFailures()))
+	}
+	if c.tlsFiles != nil {
+		if notAfter, ok := c.tlsFiles.ClientCertificateNotAfter(); ok {
+			ch <- c.clientCertExpiry.mustNewConstMetric(float64(notAfter.Unix()))
+		}
+	}
 	partial, err := c.collect(ctx, ch)
 	if err != nil {
 		c.logger.Error().Err(err).Msg("Collection cycle failed")
 	}
-	v := 1.0
-	if err != nil || partial {
-		v = 0.0
+	c.emitCircuit(ch)
+	if err == nil && !partial {
+		ch <- c.upMetric.mustNewConstMetric(1.0)
+		return true
+	}
+	ch <- c.scrapeError.mustNewConstMetric(1, scrapeErrorCause(ctx, err))
+	ch <- c.upMetric.mustNewConstMetric(0.0)
+	return false
+}
+
+// scrapeErrorCause classifies a failed scrape for tdarr_exporter_scrape_error.
+// A scrape whose deadline passed reports deadline_exceeded whatever request
+// it broke, since the deadline is the cause; a nil err means a partial scrape.
+func scrapeErrorCause(ctx context.Context, err error) string {
+	switch {
+	case errors.Is(err, ErrDeadline), errors.Is(context.Cause(ctx), context.DeadlineExceeded):
+		return causeDeadlineExceeded
+	case err == nil:
+		return causePartial
+	case errors.Is(err, ErrParse):
+		return causeParse
+	case errors.Is(err, ErrUpstream):
+		return causeUpstream
+	}
+	return causeUnknown
+}
+
+// emitCircuit emits the circuit breaker's state, as left by this scrape, and
+// its transitions, plus those of the collectors replaced on reload.
+func (c *TdarrCollector) emitCircuit(ch chan<- prometheus.Metric) {
+	if c.breaker == nil {
+		return
+	}
+	current := c.breaker.State()
+	transitions := c.breaker.Transitions()
+	for _, state := range client.CircuitStates {
+		v := 0.0
+		if state == current {
+			v = 1.0
+		}
+		ch <- c.circuitState.mustNewConstMetric(v, state.String())
+		ch <- c.circuitTransitions.mustNewConstMetric(c.circuitTransitionsCarried[state]+float64(transitions[state]), state.String())
 	}
-	ch <- c.upMetric.mustNewConstMetric(v)
 }
 
 // totalsFromMetric builds the cache-totals snapshot from a general-stats metric.
@@ -593,11 +1391,13 @@ func libraryFingerprint(libs []TdarrLibraryInfo) []TdarrLibraryInfo {
 	return fp
 }
 
-// shouldRefetch decides whether library pie stats must be re-fetched. It returns
-// true when the cache is empty (cached.stats nil), any of the 10 cached totals
-// differs from the current metric, or the cached library-list fingerprint differs
-// from the current one. tdarrCacheTotals is all-int and thus comparable, so the
-// struct != comparison is equivalent to the prior field-by-field OR chain.
+// refetchReason decides whether library pie stats must be re-fetched. It returns
+// why, missEmpty, missTotalsChanged or missFingerprintChanged in that order of
+// precedence, when the cache is empty (cached.stats nil), any of the 10 cached
+// totals differs from the current metric, or the cached library-list
+// fingerprint differs from the current one, and "" when the cache is current.
+// tdarrCacheTotals is all-int and thus comparable, so the struct != comparison
+// is equivalent to the prior field-by-field OR chain.
 //
 // Invalidation is deliberately GLOBAL (all-or-nothing across every library), not
 // per-library, and this is forced by the Tdarr API's shape — do not "optimize" it
@@ -617,31 +1417,160 @@ func libraryFingerprint(libs []TdarrLibraryInfo) []TdarrLibraryInfo {
 // added zero-file library (neither moves any of the 10 counts); the fingerprint
 // catches both, since it changes whenever a library's id/name set changes. Either
 // signal changing triggers a refetch — they cover different drift.
-func shouldRefetch(cached libStatsSnapshot, metric *TdarrMetric, currentFingerprint []TdarrLibraryInfo) bool {
-	return cached.stats == nil || cached.totals != totalsFromMetric(metric) || !slices.Equal(cached.fingerprint, currentFingerprint)
+func refetchReason(cached libStatsSnapshot, metric *TdarrMetric, currentFingerprint []TdarrLibraryInfo) string {
+	switch {
+	case cached.stats == nil:
+		return missEmpty
+	case cached.totals != totalsFromMetric(metric):
+		return missTotalsChanged
+	case !slices.Equal(cached.fingerprint, currentFingerprint):
+		return missFingerprintChanged
+	}
+	return ""
+}
+
+// emitPhase emits how long phase took, from start until now.
+func (c *TdarrCollector) emitPhase(ch chan<- prometheus.Metric, phase string, start time.Time) {
+	ch <- c.scrapePhaseDuration.mustNewConstMetric(time.Since(start).Seconds(), phase)
 }
 
-func (c *TdarrCollector) collect(ctx context.Context, ch chan<- prometheus.Metric) (bool, error) {
-	partialFail := false
-	// Fetch server status (/api/v2/status) first. Cheapest call and a liveness/version
-	// probe, so fail fast here before the heavier stats/pie/node work. Required: a failure
-	// returns an error like every other upstream fetch, flipping tdarr_up=0 via Collect().
+// emitCollector emits whether collector succeeded and how long it took.
+func (c *TdarrCollector) emitCollector(ch chan<- prometheus.Metric, collector string, elapsed time.Duration, ok bool) {
+	v := 0.0
+	if ok {
+		v = 1
+	}
+	ch <- c.collectorSuccess.mustNewConstMetric(v, collector)
+	ch <- c.collectorDuration.mustNewConstMetric(elapsed.Seconds(), collector)
+}
+
+// collect runs the enabled collectors, each on its own: one failing still
+// lets the others emit their series, and every one reports its outcome on
+// tdarr_scrape_collector_success. err joins the errors of the collectors that
+// failed; partial reports library stats missing from an otherwise successful
+// scrape. Either makes scrape report tdarr_up 0.
+func (c *TdarrCollector) collect(ctx context.Context, ch chan<- prometheus.Metric) (partial bool, err error) {
+	// Fetch server status (/api/v2/status) first: a 404 is not a failure, Tdarr
+	// releases without the endpoint are scraped with no tdarr_server_* series
+	// and an unknown version (see apiCompat). With the server collector
+	// disabled, or failing, the version is unknown too.
 	serverStatus := &TdarrServerStatus{}
-	if err := c.api.DoRequest(ctx, c.statusPath, serverStatus); err != nil {
-		return false, fmt.Errorf("get server status: %w: %w", ErrUpstream, err)
+	hasStatus := false
+	var serverErr error
+	if c.collectors.Server {
+		start := time.Now()
+		serverErr = c.api.DoRequest(ctx, c.statusPath, serverStatus)
+		c.emitPhase(ch, phaseStatus, start)
+		switch {
+		case serverErr == nil:
+			hasStatus = true
+			if !isHealthyServerStatus(serverStatus.Status) {
+				c.logger.Warn().Str("status", serverStatus.Status).
+					Msg("Tdarr server reported non-healthy status")
+			}
+			c.emitServerMetrics(ch, serverStatus)
+		case isNotFound(serverErr):
+			c.logger.Debug().Str("path", c.statusPath).Msg("Tdarr has no status endpoint; skipping server metrics")
+			serverErr = nil
+		default:
+			serverErr = fmt.Errorf("get server status: %w: %w", ErrUpstream, serverErr)
+			serverStatus = &TdarrServerStatus{}
+		}
+		c.emitCollector(ch, subsystemServer, time.Since(start), serverErr == nil)
+	}
+
+	// The library and files collectors need the statistics document too: its
+	// totals decide whether their caches are stale, and older Tdarr embeds the
+	// pies in it.
+	// The library and staged collectors share the library list.
+	source := pieSourceNone
+	libs := &libraryList{}
+	var generalErr, libraryErr, walkErr error
+	if c.collectors.General || c.collectors.Library || c.collectors.Files || c.collectors.Streams {
+		source, partial, generalErr, libraryErr, walkErr = c.collectStats(ctx, ch, serverStatus.Version, libs)
+	}
+	// The capabilities are only known once the status and library stats
+	// requests have answered; cached stats served past the deadline count.
+	if serverErr == nil && (libraryErr == nil || errors.Is(libraryErr, ErrDeadline)) {
+		c.emitCapabilities(ch, hasStatus, source)
+	}
+
+	// The flows go before the nodes, so the flow workers can be named.
+	var (
+		flows    map[string]TdarrFlow
+		flowsErr error
+	)
+	if c.collectors.Flows {
+		start := time.Now()
+		flows, flowsErr = c.collectFlows(ctx, ch)
+		c.emitCollector(ch, subsystemFlows, time.Since(start), flowsErr == nil)
 	}
-	if !isHealthyServerStatus(serverStatus.Status) {
-		c.logger.Warn().Str("status", serverStatus.Status).
-			Msg("Tdarr server reported non-healthy status")
+
+	var pluginsErr error
+	if c.collectors.Plugins {
+		start := time.Now()
+		pluginsErr = c.collectPlugins(ctx, ch)
+		c.emitCollector(ch, subsystemPlugins, time.Since(start), pluginsErr == nil)
 	}
-	c.emitServerMetrics(ch, serverStatus)
 
+	var stagedErr error
+	if c.collectors.Staged {
+		start := time.Now()
+		stagedErr = c.collectStaged(ctx, ch, libs)
+		c.emitCollector(ch, subsystemStaged, time.Since(start), stagedErr == nil)
+	}
+
+	var nodesErr error
+	if c.collectors.Nodes || c.collectors.Workers {
+		start := time.Now()
+		var nodeData map[string]TdarrNode
+		nodeData, nodesErr = c.nodeCollector.GetNodeData(ctx)
+		c.emitPhase(ch, phaseNodes, start)
+		if nodesErr == nil {
+			// get worker data for each node
+			c.emitNodeMetrics(ch, nodeData)
+			if c.collectors.Workers && flows != nil {
+				c.emitWorkerFlowSteps(ch, nodeData, flows)
+			}
+		}
+		c.emitCollector(ch, subsystemNodes, time.Since(start), nodesErr == nil)
+	}
+	return partial, errors.Join(serverErr, generalErr, libraryErr, walkErr, flowsErr, pluginsErr, stagedErr, nodesErr)
+}
+
+// collectStats fetches the statistics document and runs the general, library,
+// files and streams collectors of those enabled, returning the error of each;
+// walkErr is the file walk's, shared by files and streams. version is the
+// server's reported version, "" if unknown. source is where the library stats
+// came from, pieSourceNone with the library collector disabled or failed. A
+// libraryErr or walkErr of ErrDeadline comes with the series emitted, from
+// the cache. libs is the scrape's library list.
+func (c *TdarrCollector) collectStats(ctx context.Context, ch chan<- prometheus.Metric, version string, libs *libraryList) (source pieSource, partialFail bool, generalErr, libraryErr, walkErr error) {
 	// get server metrics
 	metricReqBody := getGeneralReqPayload("")
 	metric := &TdarrMetric{}
+	start := time.Now()
 	err := c.httpReqHelper(ctx, c.statsPath, metricReqBody, &metric)
+	c.emitPhase(ch, phaseGeneral, start)
+	fetched := time.Since(start)
 	if err != nil {
-		return false, err
+		if c.collectors.General {
+			c.emitCollector(ch, subsystemGeneral, fetched, false)
+			generalErr = err
+		}
+		if c.collectors.Library {
+			c.emitCollector(ch, subsystemLibrary, fetched, false)
+			libraryErr = err
+		}
+		if c.collectors.Files {
+			c.emitCollector(ch, subsystemFiles, fetched, false)
+			walkErr = err
+		}
+		if c.collectors.Streams {
+			c.emitCollector(ch, subsystemStreams, fetched, false)
+			walkErr = err
+		}
+		return pieSourceNone, false, generalErr, libraryErr, walkErr
 	}
 
 	c.logger.Debug().Int("totalFiles", metric.TotalFileCount).
@@ -649,36 +1578,134 @@ func (c *TdarrCollector) collect(ctx context.Context, ch chan<- prometheus.Metri
 		Int("totalHealthChecks", metric.TotalHealthCheckCount).
 		Msg("General stats totals")
 
-	// get metrics data
+	if c.collectors.General {
+		generalErr = c.collectGeneral(ch, metric)
+		c.emitCollector(ch, subsystemGeneral, time.Since(start), generalErr == nil)
+	}
+	if c.collectors.Library {
+		libStart := time.Now()
+		source, partialFail, libraryErr = c.collectLibraries(ctx, ch, metric, version, libs)
+		c.emitCollector(ch, subsystemLibrary, fetched+time.Since(libStart), libraryErr == nil && !partialFail)
+	}
+	if c.collectors.Files || c.collectors.Streams {
+		walkStart := time.Now()
+		var libraries map[string]*libraryFiles
+		libraries, walkErr = c.walkedFiles(ctx, ch, metric)
+		elapsed := fetched + time.Since(walkStart)
+		if c.collectors.Files {
+			c.emitFileMetrics(ch, libraries)
+			c.emitCollector(ch, subsystemFiles, elapsed, walkErr == nil)
+		}
+		if c.collectors.Streams {
+			c.emitStreamMetrics(ch, libraries)
+			c.emitCollector(ch, subsystemStreams, elapsed, walkErr == nil)
+		}
+	}
+	if c.collectors.Library || c.collectors.Streams {
+		c.emitUnknownStatuses(ch)
+	}
+	return source, partialFail, generalErr, libraryErr, walkErr
+}
+
+// collectGeneral emits the general series from the statistics document.
+func (c *TdarrCollector) collectGeneral(ch chan<- prometheus.Metric, metric *TdarrMetric) error {
+	score, err := strconv.ParseFloat(metric.TdarrScore, 64)
+	if err != nil {
+		return fmt.Errorf("parse tdarr score %q: %w: %w", metric.TdarrScore, ErrParse, err)
+	}
+	healthScore, err := strconv.ParseFloat(metric.HealthCheckScore, 64)
+	if err != nil {
+		return fmt.Errorf("parse health score %q: %w: %w", metric.HealthCheckScore, ErrParse, err)
+	}
+	c.emitGeneralMetrics(ch, metric, score, healthScore)
+	return nil
+}
+
+// collectLibraries emits the library series: the settings from the library
+// list, and the stats from get-pies or, on Tdarr without it, from the
+// statistics document. An ErrDeadline err comes with everything emitted, the
+// library stats from the cache.
+func (c *TdarrCollector) collectLibraries(ctx context.Context, ch chan<- prometheus.Metric, metric *TdarrMetric, version string, libs *libraryList) (source pieSource, partialFail bool, err error) {
 	var (
-		pieData         []*TdarrPieStats
-		score           float64
-		healthScore     float64
-		floatConvertErr error
+		pieData []*TdarrPieStats
+		// skipped is the ErrDeadline of a skipped sweep: the collector carries
+		// on with the cached stats and fails once everything is emitted.
+		skipped error
 	)
+	// The library list is a cruddb call every Tdarr answers, so it is fetched
+	// whatever the pie source. A failure hard-fails the library collector like
+	// the general-stats fetch in collectStats: there is no fallback, since a
+	// stale or partial list would make both the settings series and the cache
+	// decision below unreliable.
+	settings, err := libs.get(ctx, c, ch)
+	if err != nil {
+		return pieSourceNone, false, err
+	}
+	// The library list is fresh on every scrape, so its settings are too,
+	// whether or not the pie stats come from the cache.
+	c.emitLibrarySettings(ch, settings)
+
+	source = c.compat.pieSourceFor(version)
+	if source == pieSourceGetPies {
+		var missing bool
+		pieData, partialFail, missing, err = c.libraryPies(ctx, ch, metric, libraryInfos(settings))
+		if errors.Is(err, ErrDeadline) {
+			skipped = err
+		} else if err != nil {
+			return pieSourceNone, false, err
+		}
+		if missing && c.compat.markGetPiesMissing(version) {
+			c.logger.Info().Str("path", c.pieStatsPath).Msg("Tdarr has no get-pies endpoint; reading library stats from the statistics document")
+			source = pieSourceLegacy
+		}
+	}
+	if source == pieSourceLegacy {
+		if pieData, partialFail, err = c.legacyLibraryPies(metric); err != nil {
+			return pieSourceNone, false, err
+		}
+	}
+	c.emitPieMetrics(ch, pieData)
+	if source == pieSourceGetPies {
+		c.emitPieCache(ch)
+	}
 
-	score, floatConvertErr = strconv.ParseFloat(metric.TdarrScore, 64)
-	if floatConvertErr != nil {
-		return false, fmt.Errorf("parse tdarr score %q: %w: %w", metric.TdarrScore, ErrParse, floatConvertErr)
+	return source, partialFail, skipped
+}
+
+// emitUnknownStatuses emits the unknown-status counters (monotonically
+// increasing across scrapes), of the library statuses and the stream
+// properties. A non-zero value means Tdarr returned a label the exporter did
+// not pre-init zeros for.
+func (c *TdarrCollector) emitUnknownStatuses(ch chan<- prometheus.Metric) {
+	c.unknownStatusMu.Lock()
+	defer c.unknownStatusMu.Unlock()
+	for key, count := range c.unknownStatusCounts {
+		ch <- c.unknownStatusTotal.mustNewConstMetric(count,
+			key.kind, key.status)
 	}
-	healthScore, floatConvertErr = strconv.ParseFloat(metric.HealthCheckScore, 64)
-	if floatConvertErr != nil {
-		return false, fmt.Errorf("parse health score %q: %w: %w", metric.HealthCheckScore, ErrParse, floatConvertErr)
+}
+
+// emitPieCache emits the pie cache's lookup counts and, once it holds stats,
+// their age.
+func (c *TdarrCollector) emitPieCache(ch chan<- prometheus.Metric) {
+	hits, misses := c.statsCache.lookups()
+	ch <- c.pieCacheHits.mustNewConstMetric(hits)
+	for _, reason := range []string{missEmpty, missTotalsChanged, missFingerprintChanged} {
+		ch <- c.pieCacheMisses.mustNewConstMetric(misses[reason], reason)
 	}
+	if cached := c.statsCache.Read(); cached.stats != nil {
+		ch <- c.pieCacheAge.mustNewConstMetric(c.now().Sub(cached.written).Seconds())
+	}
+}
 
-	// supports only api versions: v2.24.01+
+// libraryPies returns the per-library stats from get-pies (Tdarr 2.24.01+), from
+// the cache when nothing changed since the last full sweep. allLibs is the
+// library list of this scrape: fetched on every scrape, not just on cache
+// misses, it is the invalidation signal (see refetchReason) as well as the
+// libraries to sweep. missing reports a 404 from get-pies. The phases that ran
+// are timed on ch.
+func (c *TdarrCollector) libraryPies(ctx context.Context, ch chan<- prometheus.Metric, metric *TdarrMetric, allLibs []TdarrLibraryInfo) (pieData []*TdarrPieStats, partialFail, missing bool, err error) {
 	c.logger.Debug().Str("path", c.pieStatsPath).Msg("Fetching library pie stats")
-	// Fetch the current library list on EVERY scrape, not just cache misses. This is
-	// a single cheap cruddb call (~ms in latency) used purely as an invalidation
-	// signal (see shouldRefetch); a failure here hard-fails the scrape exactly like
-	// the general-stats fetch above — there is no fallback, since a stale/partial
-	// library list would make both the cache decision and any resulting pie fetch
-	// unreliable.
-	getLibsPayload := getGeneralReqPayload("library")
-	allLibs := []TdarrLibraryInfo{}
-	if err := c.httpReqHelper(ctx, c.statsPath, getLibsPayload, &allLibs); err != nil {
-		return false, fmt.Errorf("get library details: %w", err)
-	}
 	fingerprint := libraryFingerprint(allLibs)
 
 	// already have total file count from general stats (`metric.TotalFileCount`)
@@ -694,57 +1721,86 @@ func (c *TdarrCollector) collect(ctx context.Context, ch chan<- prometheus.Metri
 	// decode to 0, so 0==0 never triggers a spurious refetch. The fingerprint (sorted
 	// library id/name pairs) catches a library rename or a new zero-file library —
 	// drift the 10 totals alone cannot see, since neither moves any of them.
-	shouldCollect := shouldRefetch(cached, metric, fingerprint)
-	if shouldCollect {
-		c.logger.Debug().Msg("Stats totals or library fingerprint mismatch - re-fetching library pie stats")
-	}
+	reason := refetchReason(cached, metric, fingerprint)
+	c.statsCache.recordLookup(reason)
 	// if counts and fingerprint are unchanged, use cache
-	if !shouldCollect {
+	if reason == "" {
 		c.logger.Debug().Msg("Using cached library stats - api totals and library fingerprint match cached values")
-		pieData = cached.stats
-	} else { // fetch new data and update cache; reuse the library list already fetched above
-		pieData, partialFail = c.fetchPies(ctx, allLibs)
-		// Cache invariant: only a fully successful pie sweep may be cached.
-		// Caching a partial result would let the next scrape serve incomplete
-		// data from cache with tdarr_up=1 (partial failure is scoped to this
-		// scrape only), silently dropping the failed library's series until the
-		// totals or fingerprint next change. Skipping the write keeps the cache
-		// stale/nil, so shouldRefetch() triggers a full refetch on the next scrape.
-		if partialFail {
-			c.logger.Warn().Msg("Partial library pie fetch failure - cache not updated, will re-fetch next scrape")
-		} else {
-			c.logger.Debug().Msg("All library stats gathered - setting cache")
-			// Store the whole snapshot in one Write so a concurrent scrape never
-			// reads a torn combination of totals/stats/fingerprint.
-			c.statsCache.Write(libStatsSnapshot{
-				totals:      totalsFromMetric(metric),
-				stats:       pieData,
-				fingerprint: fingerprint,
-			})
+		return cached.stats, false, false, nil
+	}
+	c.logger.Debug().Str("reason", reason).Msg("Stats totals or library fingerprint mismatch - re-fetching library pie stats")
+	// The sweep is the one optional phase: skip it when the last one took
+	// longer than the time left before the scrape deadline, serving the cached
+	// stats (stale, but better than series vanishing) and failing the scrape.
+	if deadline, ok := ctx.Deadline(); ok && cached.stats != nil {
+		if left := time.Until(deadline); left < cached.sweepDuration {
+			return cached.stats, false, false, fmt.Errorf("skip library pie stats, %s left before the scrape deadline and the last sweep took %s: %w",
+				left.Round(time.Millisecond), cached.sweepDuration.Round(time.Millisecond), ErrDeadline)
 		}
 	}
+	// fetch new data and update cache; reuse the library list already fetched above
+	sweepStart := time.Now()
+	pieData, partialFail, missing = c.fetchPies(ctx, allLibs)
+	c.emitPhase(ch, phasePies, sweepStart)
+	// Cache invariant: only a fully successful pie sweep may be cached.
+	// Caching a partial result would let the next scrape serve incomplete
+	// data from cache with tdarr_up=1 (partial failure is scoped to this
+	// scrape only), silently dropping the failed library's series until the
+	// totals or fingerprint next change. Skipping the write keeps the cache
+	// stale/nil, so refetchReason() triggers a full refetch on the next scrape.
+	if partialFail {
+		c.logger.Warn().Msg("Partial library pie fetch failure - cache not updated, will re-fetch next scrape")
+	} else {
+		c.logger.Debug().Msg("All library stats gathered - setting cache")
+		// Store the whole snapshot in one Write so a concurrent scrape never
+		// reads a torn combination of totals/stats/fingerprint.
+		snap := libStatsSnapshot{
+			totals:        totalsFromMetric(metric),
+			stats:         pieData,
+			fingerprint:   fingerprint,
+			sweepDuration: time.Since(sweepStart),
+			written:       c.now(),
+		}
+		c.statsCache.Write(snap)
+		c.saveState(snap)
+	}
 
-	// add metrics to collector
-	c.emitGeneralMetrics(ch, metric, score, healthScore)
-	c.emitPieMetrics(ch, pieData)
+	return pieData, partialFail, missing, nil
+}
 
-	// Emit unknown-status counters (monotonically increasing across scrapes).
-	// A non-zero value means Tdarr returned a status label the exporter did not pre-init zeros for.
-	c.unknownStatusMu.Lock()
-	for key, count := range c.unknownStatusCounts {
-		ch <- c.unknownStatusTotal.mustNewConstMetric(count,
-			key.kind, key.status)
+// legacyLibraryPies returns the per-library stats of Tdarr before 2.24.01 from the
+// statistics document already fetched, so it costs no request and is not cached.
+// A library dropped by preparePie flags the scrape partial like a get-pies failure.
+func (c *TdarrCollector) legacyLibraryPies(metric *TdarrMetric) (pieData []*TdarrPieStats, partialFail bool, err error) {
+	all, err := legacyPieStats(metric.Pies)
+	if err != nil {
+		return nil, false, fmt.Errorf("decode library pies: %w: %w", ErrParse, err)
 	}
-	c.unknownStatusMu.Unlock()
+	for _, pie := range all {
+		if !c.preparePie(pie) {
+			partialFail = true
+			continue
+		}
+		pieData = append(pieData, pie)
+	}
+	return pieData, partialFail, nil
+}
 
-	// get all node metrics
-	nodeData, err := c.nodeCollector.GetNodeData(ctx)
-	if err != nil {
-		return false, err
+// emitCapabilities emits tdarr_exporter_api_capability for every feature: whether
+// /api/v2/status answered and which source the library stats came from.
+func (c *TdarrCollector) emitCapabilities(ch chan<- prometheus.Metric, hasStatus bool, source pieSource) {
+	enabled := map[string]bool{
+		featureServerStatus:      hasStatus,
+		featureLibraryPies:       source == pieSourceGetPies,
+		featureLegacyLibraryPies: source == pieSourceLegacy,
+	}
+	for _, feature := range apiFeatures {
+		v := 0.0
+		if enabled[feature] {
+			v = 1
+		}
+		ch <- c.apiCapability.mustNewConstMetric(v, feature)
 	}
-	// get worker data for each node
-	c.emitNodeMetrics(ch, nodeData)
-	return partialFail, nil
 }
 
 // emitServerMetrics emits the /api/v2/status-derived series: uptime gauge plus the
@@ -796,7 +1852,7 @@ func (c *TdarrCollector) emitPieMetrics(ch chan<- prometheus.Metric, pieData []*
 	for _, pie := range pieData {
 		// library_name lives only on the info metric; every other series keys on library_id so a
 		// library rename doesn't churn them. Dashboards join back on library_id to recover the name.
-		ch <- c.pieLibraryInfo.mustNewConstMetric(1, pie.libraryId, pie.libraryName)
+		ch <- c.pieLibraryInfo.mustNewConstMetric(1, pie.libraryId, applyLabelPolicy(c.labelPolicies.LibraryName, pie.libraryName))
 		ch <- c.pieNumFiles.mustNewConstMetric(float64(pie.PieStats.TotalFiles), pie.libraryId)
 		ch <- c.pieNumTranscodes.mustNewConstMetric(float64(pie.PieStats.TotalTranscodeCount), pie.libraryId)
 		ch <- c.pieNumHealthChecks.mustNewConstMetric(float64(pie.PieStats.TotalHealthCheckCount), pie.libraryId)
@@ -836,125 +1892,140 @@ func (c *TdarrCollector) emitParsedFloat(ch chan<- prometheus.Metric, desc typed
 // Pure: reads nodeData, writes to ch. Resource-stat parse failures are silently skipped
 // (see emitParsedFloat); ETA parse failures skip only the eta_seconds gauge.
 func (c *TdarrCollector) emitNodeMetrics(ch chan<- prometheus.Metric, nodeData map[string]TdarrNode) {
+	m := c.nodeCollector.metrics
 	for _, node := range nodeData {
-		m := c.nodeCollector.metrics
+		if m.nodes {
+			c.emitNode(ch, node)
+		}
+		if m.workers {
+			c.emitWorkers(ch, node)
+		}
+	}
+}
+
+// emitNode emits the node-level series of the nodes collector.
+func (c *TdarrCollector) emitNode(ch chan<- prometheus.Metric, node TdarrNode) {
+	m := c.nodeCollector.metrics
 
-		// node identity info
-		ch <- m.nodeInfo.mustNewConstMetric(1,
-			node.Id, node.Name, node.GpuSelect,
-			strconv.Itoa(node.Config.Pid), strconv.Itoa(node.Priority),
-			strconv.FormatBool(node.AllowGpuDoCpu),
+	// node identity info
+	ch <- m.nodeInfo.mustNewConstMetric(1,
+		node.Id, node.Name, node.GpuSelect,
+		strconv.Itoa(node.Config.Pid), strconv.Itoa(node.Priority),
+		strconv.FormatBool(node.AllowGpuDoCpu),
+	)
+
+	// node uptime
+	ch <- m.nodeUptime.mustNewConstMetric(
+		float64(node.ResourceStats.Process.Uptime), node.Id, node.Name)
+
+	// convert resource stats to float from string; skip on parse failure
+	c.emitParsedFloat(ch, m.nodeHeapUsedBytes, node.ResourceStats.Process.HeapUsedMb, bytesPerMB, node.Id, node.Name)
+	c.emitParsedFloat(ch, m.nodeHeapTotalBytes, node.ResourceStats.Process.HeapTotalMb, bytesPerMB, node.Id, node.Name)
+	c.emitParsedFloat(ch, m.nodeHostCpuRatio, node.ResourceStats.Os.CpuPercent, percentToRatio, node.Id, node.Name)
+	c.emitParsedFloat(ch, m.nodeHostMemUsedBytes, node.ResourceStats.Os.MemUsedGb, bytesPerGB, node.Id, node.Name)
+	c.emitParsedFloat(ch, m.nodeHostMemTotalBytes, node.ResourceStats.Os.MemTotalGb, bytesPerGB, node.Id, node.Name)
+
+	// node state gauges
+	pausedVal := 0.0
+	if node.Paused {
+		pausedVal = 1.0
+	}
+	ch <- m.nodePaused.mustNewConstMetric(pausedVal, node.Id, node.Name)
+	ch <- m.nodeMaxGpuWorkers.mustNewConstMetric(float64(node.MaxGpuWorkers), node.Id, node.Name)
+	schedVal := 0.0
+	if node.ScheduleEnabled {
+		schedVal = 1.0
+	}
+	ch <- m.nodeScheduleEnabled.mustNewConstMetric(schedVal, node.Id, node.Name)
+
+	// per-type gauges — always emit all four types so zero-value series appear
+	emitPerType(ch, m.nodeWorkerLimit, node.Id, node.Name, node.WorkerLimits)
+	emitPerType(ch, m.nodeQueueLength, node.Id, node.Name, node.QueueLengths)
+
+	// worker count by type — count from active workers map.
+	// Always emit zeros for the four known dims; emit unknown buckets only when non-zero
+	// (raw API string preserved as worker_type, "unknown" as compute_type).
+	workerCounts := countWorkersByType(node.Workers)
+	for _, d := range knownWorkerTypeDims {
+		ch <- m.nodeWorkerCount.mustNewConstMetric(
+			float64(workerCounts.known[d]), node.Id, node.Name, d.workerType, d.computeType)
+	}
+	for rawType, count := range workerCounts.unknown {
+		if count == 0 {
+			continue
+		}
+		c.logger.Warn().Str("workerType", rawType).Int("count", count).
+			Msg("Unknown worker type encountered; bucketing under 'unknown'")
+		ch <- m.nodeWorkerCount.mustNewConstMetric(
+			float64(count), node.Id, node.Name, rawType, computeTypeUnknown)
+	}
+}
+
+// emitWorkers emits the per-worker series of the workers collector.
+func (c *TdarrCollector) emitWorkers(ch chan<- prometheus.Metric, node TdarrNode) {
+	m := c.nodeCollector.metrics
+	for _, worker := range node.Workers {
+		c.logger.Debug().Interface("worker", worker).Msg("Worker data")
+
+		// unified worker info metric (all workers, flow or classic).
+		// Split Tdarr's compound workerType string into worker_type + compute_type labels.
+		wType, cType := parseWorkerType(worker.WorkerType)
+		ch <- m.nodeWorkerInfo.mustNewConstMetric(1,
+			node.Id, node.Name, worker.Id, wType, cType,
+			strconv.FormatBool(worker.FlowWorker),
+			applyLabelPolicy(c.labelPolicies.WorkerFile, worker.File), strconv.FormatBool(worker.Process.Connected),
 		)
 
-		// node uptime
-		ch <- m.nodeUptime.mustNewConstMetric(
-			float64(node.ResourceStats.Process.Uptime), node.Id, node.Name)
-
-		// convert resource stats to float from string; skip on parse failure
-		c.emitParsedFloat(ch, m.nodeHeapUsedBytes, node.ResourceStats.Process.HeapUsedMb, bytesPerMB, node.Id, node.Name)
-		c.emitParsedFloat(ch, m.nodeHeapTotalBytes, node.ResourceStats.Process.HeapTotalMb, bytesPerMB, node.Id, node.Name)
-		c.emitParsedFloat(ch, m.nodeHostCpuRatio, node.ResourceStats.Os.CpuPercent, percentToRatio, node.Id, node.Name)
-		c.emitParsedFloat(ch, m.nodeHostMemUsedBytes, node.ResourceStats.Os.MemUsedGb, bytesPerGB, node.Id, node.Name)
-		c.emitParsedFloat(ch, m.nodeHostMemTotalBytes, node.ResourceStats.Os.MemTotalGb, bytesPerGB, node.Id, node.Name)
-
-		// node state gauges
-		pausedVal := 0.0
-		if node.Paused {
-			pausedVal = 1.0
-		}
-		ch <- m.nodePaused.mustNewConstMetric(pausedVal, node.Id, node.Name)
-		ch <- m.nodeMaxGpuWorkers.mustNewConstMetric(float64(node.MaxGpuWorkers), node.Id, node.Name)
-		schedVal := 0.0
-		if node.ScheduleEnabled {
-			schedVal = 1.0
-		}
-		ch <- m.nodeScheduleEnabled.mustNewConstMetric(schedVal, node.Id, node.Name)
-
-		// per-type gauges — always emit all four types so zero-value series appear
-		emitPerType(ch, m.nodeWorkerLimit, node.Id, node.Name, node.WorkerLimits)
-		emitPerType(ch, m.nodeQueueLength, node.Id, node.Name, node.QueueLengths)
-
-		// worker count by type — count from active workers map.
-		// Always emit zeros for the four known dims; emit unknown buckets only when non-zero
-		// (raw API string preserved as worker_type, "unknown" as compute_type).
-		workerCounts := countWorkersByType(node.Workers)
-		for _, d := range knownWorkerTypeDims {
-			ch <- m.nodeWorkerCount.mustNewConstMetric(
-				float64(workerCounts.known[d]), node.Id, node.Name, d.workerType, d.computeType)
-		}
-		for rawType, count := range workerCounts.unknown {
-			if count == 0 {
-				continue
-			}
-			c.logger.Warn().Str("workerType", rawType).Int("count", count).
-				Msg("Unknown worker type encountered; bucketing under 'unknown'")
-			ch <- m.nodeWorkerCount.mustNewConstMetric(
-				float64(count), node.Id, node.Name, rawType, computeTypeUnknown)
-		}
-
-		// per-worker metrics
-		for _, worker := range node.Workers {
-			c.logger.Debug().Interface("worker", worker).Msg("Worker data")
-
-			// unified worker info metric (all workers, flow or classic).
-			// Split Tdarr's compound workerType string into worker_type + compute_type labels.
-			wType, cType := parseWorkerType(worker.WorkerType)
-			ch <- m.nodeWorkerInfo.mustNewConstMetric(1,
-				node.Id, node.Name, worker.Id, wType, cType,
-				strconv.FormatBool(worker.FlowWorker),
-				worker.File, strconv.FormatBool(worker.Process.Connected),
-			)
-
-			// worker status — free-form string, emitted for every worker (incl. "Scanning")
-			ch <- m.nodeWorkerStatus.mustNewConstMetric(1, node.Id, node.Name, worker.Id, worker.Status)
-
-			// plugin step — presence-gated: only classic transcode workers past the scan phase
-			// have plugin data. Gating on data presence (not worker type) is immune to the
-			// scan-phase isFlowWorker bug and naturally skips flow/health-check workers.
-			if worker.LastPluginDetails.Id != "" {
-				ch <- m.nodeWorkerPlugin.mustNewConstMetric(1,
-					node.Id, node.Name, worker.Id,
-					worker.LastPluginDetails.Id, worker.LastPluginDetails.PositionNumber)
-			}
+		// worker status — free-form string, emitted for every worker (incl. "Scanning")
+		ch <- m.nodeWorkerStatus.mustNewConstMetric(1, node.Id, node.Name, worker.Id, worker.Status)
 
-			// idle 0/1
-			idleVal := 0.0
-			if worker.Idle {
-				idleVal = 1.0
-			}
-			ch <- m.nodeWorkerIdle.mustNewConstMetric(idleVal, node.Id, node.Name, worker.Id)
-
-			// per-worker numeric gauges
-			ch <- m.nodeWorkerRatio.mustNewConstMetric(
-				worker.Percentage*percentToRatio, node.Id, node.Name, worker.Id)
-			ch <- m.nodeWorkerFps.mustNewConstMetric(
-				float64(worker.Fps), node.Id, node.Name, worker.Id)
-			ch <- m.nodeWorkerOriginalFileSizeBytes.mustNewConstMetric(
-				worker.OriginalfileSizeGb*bytesPerGB, node.Id, node.Name, worker.Id)
-			ch <- m.nodeWorkerOutputFileSizeBytes.mustNewConstMetric(
-				worker.OutputFileSizeGb*bytesPerGB, node.Id, node.Name, worker.Id)
-			ch <- m.nodeWorkerEstFileSizeBytes.mustNewConstMetric(
-				worker.EstSizeGb*bytesPerGB, node.Id, node.Name, worker.Id)
-			ch <- m.nodeWorkerJobStartTimestamp.mustNewConstMetric(
-				float64(worker.Job.StartTime), node.Id, node.Name, worker.Id)
-			ch <- m.nodeWorkerStepStartTimestamp.mustNewConstMetric(
-				float64(worker.StartTime), node.Id, node.Name, worker.Id)
-			ch <- m.nodeWorkerStatusTimestamp.mustNewConstMetric(
-				float64(worker.StatusTs), node.Id, node.Name, worker.Id)
-			// ETA: parse "H:MM:SS" string into seconds; skip on parse failure
-			if etaSecs, ok := parseEtaSeconds(worker.Eta); ok {
-				ch <- m.nodeWorkerEtaSeconds.mustNewConstMetric(
-					float64(etaSecs), node.Id, node.Name, worker.Id)
-			} else {
-				c.logger.Debug().Str("nodeId", node.Id).Str("workerId", worker.Id).
-					Str("eta", worker.Eta).Msg("Failed to parse worker ETA; skipping metric")
-			}
+		// plugin step — presence-gated: only classic transcode workers past the scan phase
+		// have plugin data. Gating on data presence (not worker type) is immune to the
+		// scan-phase isFlowWorker bug and naturally skips flow/health-check workers.
+		if worker.LastPluginDetails.Id != "" {
+			ch <- m.nodeWorkerPlugin.mustNewConstMetric(1,
+				node.Id, node.Name, worker.Id,
+				worker.LastPluginDetails.Id, worker.LastPluginDetails.PositionNumber)
+		}
+
+		// idle 0/1
+		idleVal := 0.0
+		if worker.Idle {
+			idleVal = 1.0
+		}
+		ch <- m.nodeWorkerIdle.mustNewConstMetric(idleVal, node.Id, node.Name, worker.Id)
+
+		// per-worker numeric gauges
+		ch <- m.nodeWorkerRatio.mustNewConstMetric(
+			worker.Percentage*percentToRatio, node.Id, node.Name, worker.Id)
+		ch <- m.nodeWorkerFps.mustNewConstMetric(
+			float64(worker.Fps), node.Id, node.Name, worker.Id)
+		ch <- m.nodeWorkerOriginalFileSizeBytes.mustNewConstMetric(
+			worker.OriginalfileSizeGb*bytesPerGB, node.Id, node.Name, worker.Id)
+		ch <- m.nodeWorkerOutputFileSizeBytes.mustNewConstMetric(
+			worker.OutputFileSizeGb*bytesPerGB, node.Id, node.Name, worker.Id)
+		ch <- m.nodeWorkerEstFileSizeBytes.mustNewConstMetric(
+			worker.EstSizeGb*bytesPerGB, node.Id, node.Name, worker.Id)
+		ch <- m.nodeWorkerJobStartTimestamp.mustNewConstMetric(
+			float64(worker.Job.StartTime), node.Id, node.Name, worker.Id)
+		ch <- m.nodeWorkerStepStartTimestamp.mustNewConstMetric(
+			float64(worker.StartTime), node.Id, node.Name, worker.Id)
+		ch <- m.nodeWorkerStatusTimestamp.mustNewConstMetric(
+			float64(worker.StatusTs), node.Id, node.Name, worker.Id)
+		// ETA: parse "H:MM:SS" string into seconds; skip on parse failure
+		if etaSecs, ok := parseEtaSeconds(worker.Eta); ok {
+			ch <- m.nodeWorkerEtaSeconds.mustNewConstMetric(
+				float64(etaSecs), node.Id, node.Name, worker.Id)
+		} else {
+			c.logger.Debug().Str("nodeId", node.Id).Str("workerId", worker.Id).
+				Str("eta", worker.Eta).Msg("Failed to parse worker ETA; skipping metric")
 		}
 	}
 }
 
 func getGeneralReqPayload(payloadRequestType string) TdarrMetricRequest {
-	if payloadRequestType == "library" {
+	switch payloadRequestType {
+	case "library":
 		return TdarrMetricRequest{
 			Data: TdarrDataRequest{
 				Collection: "LibrarySettingsJSONDB",
@@ -963,7 +2034,25 @@ func getGeneralReqPayload(payloadRequestType string) TdarrMetricRequest {
 				Obj:        map[string]any{},
 			},
 		}
-	} else {
+	case "flows":
+		return TdarrMetricRequest{
+			Data: TdarrDataRequest{
+				Collection: "FlowsJSONDB",
+				Mode:       "getAll",
+				DocId:      "",
+				Obj:        map[string]any{},
+			},
+		}
+	case "staged":
+		return TdarrMetricRequest{
+			Data: TdarrDataRequest{
+				Collection: "StagedJSONDB",
+				Mode:       "getAll",
+				DocId:      "",
+				Obj:        map[string]any{},
+			},
+		}
+	default:
 		return TdarrMetricRequest{
 			Data: TdarrDataRequest{
 				Collection: "StatisticsJSONDB",
@@ -0,0 +1,179 @@
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/homeylab/tdarr-exporter/internal/client"
+)
+
+// tdarrVersion is a parsed Tdarr server version, e.g. "2.24.01" from
+// /api/v2/status. Tdarr zero-pads the patch number, which parsing drops.
+type tdarrVersion struct {
+	major, minor, patch int
+}
+
+// parseTdarrVersion parses "major.minor[.patch]" with an optional leading "v"
+// and ignores a trailing pre-release or build suffix ("2.24.01-beta"). ok is
+// false for anything else, including an empty string.
+func parseTdarrVersion(s string) (v tdarrVersion, ok bool) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexAny(s, "-_+ "); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return tdarrVersion{}, false
+	}
+	nums := [3]int{}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return tdarrVersion{}, false
+		}
+		nums[i] = n
+	}
+	return tdarrVersion{major: nums[0], minor: nums[1], patch: nums[2]}, true
+}
+
+// atLeast reports whether v is the same as or newer than o.
+func (v tdarrVersion) atLeast(o tdarrVersion) bool {
+	if v.major != o.major {
+		return v.major > o.major
+	}
+	if v.minor != o.minor {
+		return v.minor > o.minor
+	}
+	return v.patch >= o.patch
+}
+
+// getPiesMinVersion is the first Tdarr release with /api/v2/stats/get-pies.
+// Older servers only have the per-library pies embedded in the statistics
+// document (see TdarrMetric.Pies).
+var getPiesMinVersion = tdarrVersion{major: 2, minor: 24, patch: 1}
+
+// Features reported on tdarr_exporter_api_capability, in emit order.
+const (
+	// featureServerStatus: /api/v2/status exists, so the tdarr_server_* series
+	// are emitted.
+	featureServerStatus = "server_status"
+	// featureLibraryPies: per-library stats come from get-pies, with the pie
+	// cache in front of it.
+	featureLibraryPies = "library_pies"
+	// featureLegacyLibraryPies: per-library stats come from the pies array of
+	// the cruddb statistics document, for servers without get-pies.
+	featureLegacyLibraryPies = "legacy_library_pies"
+)
+
+var apiFeatures = []string{featureServerStatus, featureLibraryPies, featureLegacyLibraryPies}
+
+// pieSource is where a scrape reads per-library stats from.
+type pieSource int
+
+const (
+	pieSourceGetPies pieSource = iota
+	pieSourceLegacy
+	// pieSourceNone: the library collector is disabled.
+	pieSourceNone
+)
+
+// apiCompat picks the request and response shapes for a Tdarr server. The
+// reported version decides when it is known; without one (a server too old to
+// have /api/v2/status) get-pies is tried and, once it returns 404, the legacy
+// source is used until the server reports a version. Shared across a config
+// reload like the pie cache, so a reload does not repeat the probe.
+type apiCompat struct {
+	mu sync.Mutex
+	// getPiesMissing records that get-pies returned 404 while the server
+	// reported no version.
+	getPiesMissing bool
+}
+
+// pieSourceFor returns the per-library stats source for a server reporting
+// version, "" when it reported none.
+func (a *apiCompat) pieSourceFor(version string) pieSource {
+	if v, ok := parseTdarrVersion(version); ok {
+		if v.atLeast(getPiesMinVersion) {
+			return pieSourceGetPies
+		}
+		return pieSourceLegacy
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.getPiesMissing {
+		return pieSourceLegacy
+	}
+	return pieSourceGetPies
+}
+
+// markGetPiesMissing records a 404 from get-pies for a server without a
+// version and reports whether it did. A reported version is trusted over a
+// 404, which then points at a wrong pie stats path rather than an old server,
+// so nothing is recorded for it.
+func (a *apiCompat) markGetPiesMissing(version string) bool {
+	if _, ok := parseTdarrVersion(version); ok {
+		return false
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.getPiesMissing = true
+	return true
+}
+
+// isNotFound reports whether err is a 404 from the Tdarr API, i.e. an endpoint
+// the server does not have.
+func isNotFound(err error) bool {
+	var statusErr *client.StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+// legacyPieStats converts the pies array of a pre-2.24 statistics document to
+// the get-pies shape. Each entry is a positional array:
+//
+//	[name, id, totalFiles, totalTranscodeCount, sizeDiff, totalHealthCheckCount,
+//	 transcodeStatus, healthCheckStatus, videoCodecs, videoContainers,
+//	 videoResolutions, audioCodecs, audioContainers]
+//
+// where the last seven are [{name, value}] slices; releases that predate a
+// trailing element omit it. The "all" aggregate entry is skipped like the
+// removed synthetic aggregate sentinel, and an entry that does not decode is
+// an error.
+func legacyPieStats(pies []json.RawMessage) ([]*TdarrPieStats, error) {
+	stats := make([]*TdarrPieStats, 0, len(pies))
+	for _, raw := range pies {
+		var fields []json.RawMessage
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return nil, err
+		}
+		pie := &TdarrPieStats{}
+		targets := []any{
+			&pie.libraryName,
+			&pie.libraryId,
+			&pie.PieStats.TotalFiles,
+			&pie.PieStats.TotalTranscodeCount,
+			&pie.PieStats.SizeDiff,
+			&pie.PieStats.TotalHealthCheckCount,
+			&pie.PieStats.Status.Transcode,
+			&pie.PieStats.Status.HealthCheck,
+			&pie.PieStats.Video.Codecs,
+			&pie.PieStats.Video.Containers,
+			&pie.PieStats.Video.Resolutions,
+			&pie.PieStats.Audio.Codecs,
+			&pie.PieStats.Audio.Containers,
+		}
+		for i, field := range fields[:min(len(fields), len(targets))] {
+			if err := json.Unmarshal(field, targets[i]); err != nil {
+				return nil, err
+			}
+		}
+		if strings.EqualFold(pie.libraryId, "all") {
+			continue
+		}
+		stats = append(stats, pie)
+	}
+	return stats, nil
+}
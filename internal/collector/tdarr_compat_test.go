@@ -0,0 +1,214 @@
+package collector
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/homeylab/tdarr-exporter/internal/client"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestParseTdarrVersion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in     string
+		want   tdarrVersion
+		wantOk bool
+	}{
+		{in: "2.24.01", want: tdarrVersion{2, 24, 1}, wantOk: true},
+		{in: "v2.17.01", want: tdarrVersion{2, 17, 1}, wantOk: true},
+		{in: "2.30", want: tdarrVersion{2, 30, 0}, wantOk: true},
+		{in: "2.26.01-beta", want: tdarrVersion{2, 26, 1}, wantOk: true},
+		{in: ""},
+		{in: "2"},
+		{in: "2.x.01"},
+		{in: "2.24.01.7"},
+	}
+	for _, tt := range tests {
+		got, ok := parseTdarrVersion(tt.in)
+		if ok != tt.wantOk || got != tt.want {
+			t.Errorf("parseTdarrVersion(%q) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func TestAPICompat_PieSourceFor(t *testing.T) {
+	t.Parallel()
+
+	a := &apiCompat{}
+	for version, want := range map[string]pieSource{
+		"2.24.01": pieSourceGetPies,
+		"2.77.01": pieSourceGetPies,
+		"2.23.01": pieSourceLegacy,
+		"":        pieSourceGetPies,
+	} {
+		if got := a.pieSourceFor(version); got != want {
+			t.Errorf("pieSourceFor(%q) = %v, want %v", version, got, want)
+		}
+	}
+
+	if a.markGetPiesMissing("2.77.01") {
+		t.Errorf("markGetPiesMissing recorded a 404 for a server reporting its version")
+	}
+	if got := a.pieSourceFor(""); got != pieSourceGetPies {
+		t.Errorf("pieSourceFor(\"\") after a versioned 404 = %v, want get-pies", got)
+	}
+	if !a.markGetPiesMissing("") {
+		t.Errorf("markGetPiesMissing did not record a 404 for a server without a version")
+	}
+	if got := a.pieSourceFor(""); got != pieSourceLegacy {
+		t.Errorf("pieSourceFor(\"\") after a 404 = %v, want legacy", got)
+	}
+	if got := a.pieSourceFor("2.24.01"); got != pieSourceGetPies {
+		t.Errorf("pieSourceFor(2.24.01) after a 404 = %v, want get-pies", got)
+	}
+}
+
+// legacyStatsBody is a pre-2.24 statistics document: the general stats plus the
+// pies array, led by the "all" aggregate, with one library per entry.
+func legacyStatsBody() []byte {
+	return []byte(`{
+		"totalFileCount": 10, "totalTranscodeCount": 2, "totalHealthCheckCount": 1,
+		"sizeDiff": 0, "tdarrScore": "50.00", "healthCheckScore": "10.00",
+		"pies": [
+			["All", "all", 10, 2, 1.5, 1, [], [], [], [], [], [], []],
+			["Movies", "lib-old", 10, 2, 1.5, 1,
+				[{"name": "Transcode success", "value": 2}],
+				[{"name": "Success", "value": 1}],
+				[{"name": "HEVC", "value": 10}],
+				[{"name": "mkv", "value": 10}],
+				[{"name": "1080p", "value": 10}]]
+		]
+	}`)
+}
+
+func TestLegacyPieStats(t *testing.T) {
+	t.Parallel()
+
+	var metric TdarrMetric
+	if err := json.Unmarshal(legacyStatsBody(), &metric); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	stats, err := legacyPieStats(metric.Pies)
+	if err != nil {
+		t.Fatalf("legacyPieStats: %v", err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("got %d libraries, want 1 with the aggregate skipped", len(stats))
+	}
+	pie := stats[0]
+	if pie.libraryId != "lib-old" || pie.libraryName != "Movies" {
+		t.Errorf("library = %q/%q, want lib-old/Movies", pie.libraryId, pie.libraryName)
+	}
+	if pie.PieStats.TotalFiles != 10 || pie.PieStats.TotalTranscodeCount != 2 || pie.PieStats.SizeDiff != 1.5 || pie.PieStats.TotalHealthCheckCount != 1 {
+		t.Errorf("totals = %+v", pie.PieStats)
+	}
+	if got := pie.PieStats.Video.Resolutions; len(got) != 1 || got[0] != (TdarrPieSlice{Name: "1080p", Value: 10}) {
+		t.Errorf("resolutions = %v", got)
+	}
+	if pie.PieStats.Audio.Codecs != nil {
+		t.Errorf("audio codecs = %v, want none from an entry without them", pie.PieStats.Audio.Codecs)
+	}
+
+	if _, err := legacyPieStats([]json.RawMessage{json.RawMessage(`["Movies", "lib-old", "ten"]`)}); err == nil {
+		t.Errorf("legacyPieStats: want an error for a non-numeric file count")
+	}
+}
+
+const capabilityHelp = `# HELP tdarr_exporter_api_capability 1 if the exporter uses this Tdarr API feature for the instance, 0 otherwise, as decided by the last scrape from the server version and the endpoints it has. server_status gates the tdarr_server_* series; library_pies and legacy_library_pies say where the tdarr_library_* series come from (get-pies, or the statistics document of Tdarr before 2.24.01).
+# TYPE tdarr_exporter_api_capability gauge
+`
+
+// TestCollect_LegacyServer verifies the fallbacks for Tdarr before 2.24.01: a
+// missing status endpoint is not a failure, and library stats come from the
+// statistics document when the version says so, or once get-pies returns 404.
+func TestCollect_LegacyServer(t *testing.T) {
+	t.Parallel()
+
+	notFound := &client.StatusError{StatusCode: 404}
+	tests := []struct {
+		name   string
+		status []byte
+		// wantGetPiesCalls is the get-pies requests over two scrapes.
+		wantGetPiesCalls int
+		wantCapability   string
+	}{
+		{
+			name:             "old version",
+			status:           []byte(`{"status":"good","os":"linux","version":"2.17.01","uptime":45}`),
+			wantGetPiesCalls: 0,
+			wantCapability: `tdarr_exporter_api_capability{feature="legacy_library_pies",tdarr_instance="test-instance"} 1
+tdarr_exporter_api_capability{feature="library_pies",tdarr_instance="test-instance"} 0
+tdarr_exporter_api_capability{feature="server_status",tdarr_instance="test-instance"} 1
+`,
+		},
+		{
+			name:             "no status endpoint",
+			wantGetPiesCalls: 1,
+			wantCapability: `tdarr_exporter_api_capability{feature="legacy_library_pies",tdarr_instance="test-instance"} 1
+tdarr_exporter_api_capability{feature="library_pies",tdarr_instance="test-instance"} 0
+tdarr_exporter_api_capability{feature="server_status",tdarr_instance="test-instance"} 0
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := newTestConfig(t)
+			api := newSuccessFakeAPI(cfg)
+			api.setResponse(fakeKey{path: cfg.TdarrStatsPath, disc: "StatisticsJSONDB"}, legacyStatsBody())
+			api.setError(fakeKey{path: cfg.TdarrPieStatsPath, disc: "lib1"}, notFound)
+			if tt.status != nil {
+				api.setResponse(fakeKey{path: cfg.TdarrStatusPath}, tt.status)
+			} else {
+				api.setError(fakeKey{path: cfg.TdarrStatusPath}, notFound)
+			}
+			c := newTdarrCollectorWithAPI(cfg, api)
+
+			for scrape := 1; scrape <= 2; scrape++ {
+				mfs := gatherMetricFamilies(t, c)
+				if up := upValueFromFamilies(mfs); up != 1 {
+					t.Errorf("scrape %d: tdarr_up = %v, want 1", scrape, up)
+				}
+				if got := hasMetricFamily(mfs, "tdarr_server_info"); got != (tt.status != nil) {
+					t.Errorf("scrape %d: tdarr_server_info present = %v, want %v", scrape, got, tt.status != nil)
+				}
+			}
+			if got := api.callCount(fakeKey{path: cfg.TdarrPieStatsPath, disc: "lib1"}); got != tt.wantGetPiesCalls {
+				t.Errorf("get-pies requests = %d, want %d", got, tt.wantGetPiesCalls)
+			}
+
+			expected := capabilityHelp + tt.wantCapability + `# HELP tdarr_library_files Tdarr total files in library
+# TYPE tdarr_library_files gauge
+tdarr_library_files{library_id="lib-old",tdarr_instance="test-instance"} 10
+`
+			if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "tdarr_exporter_api_capability", "tdarr_library_files"); err != nil {
+				t.Errorf("metric output mismatch:\n%v", err)
+			}
+		})
+	}
+}
+
+// TestCollect_GetPies404WithVersion verifies a 404 from get-pies on a server
+// reporting a get-pies version is a partial failure, not a fallback: the pie
+// stats path is wrong rather than the server old.
+func TestCollect_GetPies404WithVersion(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	api := newSuccessFakeAPI(cfg)
+	api.setError(fakeKey{path: cfg.TdarrPieStatsPath, disc: "lib1"}, &client.StatusError{StatusCode: 404})
+	c := newTdarrCollectorWithAPI(cfg, api)
+
+	if up := getUpValue(t, c); up != 0 {
+		t.Errorf("tdarr_up = %v, want 0", up)
+	}
+	expected := capabilityHelp + `tdarr_exporter_api_capability{feature="legacy_library_pies",tdarr_instance="test-instance"} 0
+tdarr_exporter_api_capability{feature="library_pies",tdarr_instance="test-instance"} 1
+tdarr_exporter_api_capability{feature="server_status",tdarr_instance="test-instance"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "tdarr_exporter_api_capability"); err != nil {
+		t.Errorf("metric output mismatch:\n%v", err)
+	}
+}
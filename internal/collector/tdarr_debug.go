@@ -0,0 +1,65 @@
+package collector
+
+import "time"
+
+// PieCacheView is the pie cache of one target as served on /debug/cache: the
+// totals and library list the next scrape compares against to decide whether
+// to refetch the per-library stats, and how the cache has fared so far.
+type PieCacheView struct {
+	Instance string `json:"instance"`
+	// Empty is true until a sweep has been cached; the fields up to Libraries
+	// are then left out.
+	Empty                bool               `json:"empty"`
+	WrittenAt            time.Time          `json:"written_at,omitzero"`
+	AgeSeconds           float64            `json:"age_seconds,omitempty"`
+	SweepDurationSeconds float64            `json:"sweep_duration_seconds,omitempty"`
+	Totals               map[string]int     `json:"totals,omitempty"`
+	Fingerprint          []TdarrLibraryInfo `json:"fingerprint,omitempty"`
+	Libraries            int                `json:"libraries,omitempty"`
+	Hits                 float64            `json:"hits"`
+	Misses               map[string]float64 `json:"misses"`
+}
+
+// CacheView returns the state of the collector's pie cache.
+func (c *TdarrCollector) CacheView() PieCacheView {
+	cached := c.statsCache.Read()
+	hits, misses := c.statsCache.lookups()
+	view := PieCacheView{
+		Instance: c.instanceName,
+		Empty:    cached.stats == nil,
+		Hits:     hits,
+		Misses:   misses,
+	}
+	if view.Empty {
+		return view
+	}
+	view.WrittenAt = cached.written
+	view.AgeSeconds = c.now().Sub(cached.written).Seconds()
+	view.SweepDurationSeconds = cached.sweepDuration.Seconds()
+	view.Totals = cached.totals.byName()
+	// The cached names are Tdarr's own: apply the library_name policy, as the
+	// metrics do, so the view cannot leak a name the policy hashes or drops.
+	view.Fingerprint = make([]TdarrLibraryInfo, len(cached.fingerprint))
+	for i, lib := range cached.fingerprint {
+		view.Fingerprint[i] = TdarrLibraryInfo{LibraryId: lib.LibraryId, Name: applyLabelPolicy(c.labelPolicies.LibraryName, lib.Name)}
+	}
+	view.Libraries = len(cached.stats)
+	return view
+}
+
+// byName returns the totals keyed by name, as PieCacheView and the state file
+// show them.
+func (t tdarrCacheTotals) byName() map[string]int {
+	return map[string]int{
+		"total_file_count":         t.totalFileCount,
+		"total_transcode_count":    t.totalTranscodeCount,
+		"total_health_check_count": t.totalHealthCheckCount,
+		"hold_queue":               t.holdQueue,
+		"transcode_queue":          t.transcodeQueue,
+		"transcode_success":        t.transcodeSuccess,
+		"transcode_failed":         t.transcodeFailed,
+		"health_check_queue":       t.healthCheckQueue,
+		"health_check_success":     t.healthCheckSuccess,
+		"health_check_failed":      t.healthCheckFailed,
+	}
+}
@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/homeylab/tdarr-exporter/internal/config"
+)
+
+// TestCacheView verifies the view reports an empty cache before the first
+// sweep, then the cached totals and library list with their age.
+func TestCacheView(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	c := newTdarrCollectorWithAPI(cfg, newSuccessFakeAPI(cfg))
+	now := time.Unix(1_700_000_000, 0)
+	c.now = func() time.Time { return now }
+
+	view := c.CacheView()
+	if view.Instance != cfg.InstanceName || !view.Empty || view.Totals != nil {
+		t.Errorf("view before the first scrape = %+v, want an empty cache for %s", view, cfg.InstanceName)
+	}
+
+	getUpValue(t, c)
+	getUpValue(t, c)
+	now = now.Add(30 * time.Second)
+	view = c.CacheView()
+	if view.Empty {
+		t.Fatal("view after a successful scrape reports an empty cache")
+	}
+	if view.AgeSeconds != 30 {
+		t.Errorf("AgeSeconds = %v, want 30", view.AgeSeconds)
+	}
+	if got := view.Totals["total_file_count"]; got != 10 {
+		t.Errorf("Totals[total_file_count] = %d, want 10", got)
+	}
+	if len(view.Fingerprint) != 1 || view.Fingerprint[0].LibraryId != "lib1" || view.Libraries != 1 {
+		t.Errorf("Fingerprint = %+v, Libraries = %d, want lib1 alone", view.Fingerprint, view.Libraries)
+	}
+	if view.Hits != 1 || view.Misses[missEmpty] != 1 {
+		t.Errorf("Hits = %v, Misses = %v, want 1 hit after 1 empty miss", view.Hits, view.Misses)
+	}
+	body, err := json.Marshal(view)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	for _, key := range []string{"instance", "written_at", "totals", "fingerprint", "hits", "misses"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("JSON view missing %q: %s", key, body)
+		}
+	}
+}
+
+// TestCacheView_LibraryNamePolicy verifies the fingerprint's library names go
+// through the library_name label policy, so a hashed name never reaches the
+// JSON view.
+func TestCacheView_LibraryNamePolicy(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	cfg.LabelPolicies.LibraryName = config.LabelPolicy{Mode: config.LabelHash, HashKey: "secret"}
+	c := newTdarrCollectorWithAPI(cfg, newSuccessFakeAPI(cfg))
+	getUpValue(t, c)
+
+	view := c.CacheView()
+	if len(view.Fingerprint) != 1 {
+		t.Fatalf("Fingerprint = %+v, want one library", view.Fingerprint)
+	}
+	raw := c.statsCache.Read().fingerprint[0].Name
+	if raw == "" {
+		t.Fatal("fixture library has no name to hash")
+	}
+	if want := applyLabelPolicy(cfg.LabelPolicies.LibraryName, raw); view.Fingerprint[0].Name != want {
+		t.Errorf("Fingerprint name = %q, want the hashed %q", view.Fingerprint[0].Name, want)
+	}
+	body, err := json.Marshal(view)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if strings.Contains(string(body), raw) {
+		t.Errorf("JSON view contains the raw library name %q: %s", raw, body)
+	}
+	if c.statsCache.Read().fingerprint[0].Name != raw {
+		t.Error("CacheView rewrote the cached fingerprint")
+	}
+}
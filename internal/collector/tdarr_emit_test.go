@@ -2,6 +2,7 @@ package collector
 
 import (
 	"sort"
+	"strconv"
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -33,17 +34,34 @@ func drain(t *testing.T, ch <-chan prometheus.Metric) []sample {
 		for _, lp := range pb.GetLabel() {
 			labels[lp.GetName()] = lp.GetValue()
 		}
+		fqName := fqNameFromDesc(m.Desc().String())
 		var val float64
 		switch {
 		case pb.GetGauge() != nil:
 			val = pb.GetGauge().GetValue()
 		case pb.GetCounter() != nil:
 			val = pb.GetCounter().GetValue()
+		case pb.GetHistogram() != nil:
+			// Flattened like the exposition format: a _bucket sample per
+			// bound (le, cumulative, no +Inf), then _sum and _count.
+			h := pb.GetHistogram()
+			for _, b := range h.GetBucket() {
+				out = append(out, sample{
+					fqName: fqName + "_bucket",
+					labels: withLabel(labels, "le", strconv.FormatFloat(b.GetUpperBound(), 'g', -1, 64)),
+					value:  float64(b.GetCumulativeCount()),
+				})
+			}
+			out = append(out,
+				sample{fqName: fqName + "_sum", labels: labels, value: h.GetSampleSum()},
+				sample{fqName: fqName + "_count", labels: labels, value: float64(h.GetSampleCount())},
+			)
+			continue
 		default:
 			t.Fatalf("unexpected metric type for %s", m.Desc().String())
 		}
 		out = append(out, sample{
-			fqName: fqNameFromDesc(m.Desc().String()),
+			fqName: fqName,
 			labels: labels,
 			value:  val,
 		})
@@ -51,6 +69,16 @@ func drain(t *testing.T, ch <-chan prometheus.Metric) []sample {
 	return out
 }
 
+// withLabel returns a copy of labels with name set to value.
+func withLabel(labels map[string]string, name, value string) map[string]string {
+	out := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		out[k] = v
+	}
+	out[name] = value
+	return out
+}
+
 // fqNameFromDesc extracts the fqName from a Desc.String() rendering, which looks like:
 //
 //	Desc{fqName: "tdarr_files", help: "...", ...}
@@ -129,9 +157,9 @@ func hasName(samples []sample, fqName string) bool {
 	return countByName(samples, fqName) > 0
 }
 
-// --- shouldRefetch / totalsFromMetric ----------------------------------------
+// --- refetchReason / totalsFromMetric ---------------------------------------
 
-func TestShouldRefetch(t *testing.T) {
+func TestRefetchReason(t *testing.T) {
 	t.Parallel()
 
 	// base metric whose totals exactly match the cached struct below.
@@ -166,7 +194,7 @@ func TestShouldRefetch(t *testing.T) {
 		return &cp
 	}
 
-	// populatedStats marks the cache as non-empty; shouldRefetch only checks
+	// populatedStats marks the cache as non-empty; refetchReason only checks
 	// stats for nil-ness, never its contents.
 	populatedStats := []*TdarrPieStats{{libraryId: "lib1"}}
 	// matchingSnap is the "nothing changed" cached snapshot most cases start from.
@@ -181,7 +209,7 @@ func TestShouldRefetch(t *testing.T) {
 		cached             libStatsSnapshot
 		metric             *TdarrMetric
 		currentFingerprint []TdarrLibraryInfo
-		want               bool
+		want               string
 	}{
 		{
 			name: "empty cache (nil stats) forces refetch even when totals and fingerprint match",
@@ -192,14 +220,14 @@ func TestShouldRefetch(t *testing.T) {
 			},
 			metric:             base,
 			currentFingerprint: matchingFingerprint,
-			want:               true,
+			want:               missEmpty,
 		},
 		{
 			name:               "totals and fingerprint match, cache populated -> no refetch",
 			cached:             matchingSnap,
 			metric:             base,
 			currentFingerprint: matchingFingerprint,
-			want:               false,
+			want:               "",
 		},
 		{
 			name: "zero totals vs zero metric, nil fingerprints -> no refetch (graceful degradation)",
@@ -210,71 +238,71 @@ func TestShouldRefetch(t *testing.T) {
 			},
 			metric:             &TdarrMetric{},
 			currentFingerprint: nil,
-			want:               false,
+			want:               "",
 		},
 		{
 			name:               "totalFileCount changed -> refetch",
 			cached:             matchingSnap,
 			metric:             mutate(func(m *TdarrMetric) { m.TotalFileCount = 101 }),
 			currentFingerprint: matchingFingerprint,
-			want:               true,
+			want:               missTotalsChanged,
 		},
 		{
 			name:               "totalTranscodeCount changed -> refetch",
 			cached:             matchingSnap,
 			metric:             mutate(func(m *TdarrMetric) { m.TotalTranscodeCount = 41 }),
 			currentFingerprint: matchingFingerprint,
-			want:               true,
+			want:               missTotalsChanged,
 		},
 		{
 			name:               "totalHealthCheckCount changed -> refetch",
 			cached:             matchingSnap,
 			metric:             mutate(func(m *TdarrMetric) { m.TotalHealthCheckCount = 31 }),
 			currentFingerprint: matchingFingerprint,
-			want:               true,
+			want:               missTotalsChanged,
 		},
 		{
 			name:               "table0Count (holdQueue) changed -> refetch",
 			cached:             matchingSnap,
 			metric:             mutate(func(m *TdarrMetric) { m.HoldQueue = 99 }),
 			currentFingerprint: matchingFingerprint,
-			want:               true,
+			want:               missTotalsChanged,
 		},
 		{
 			name:               "table1Count (transcodeQueue) changed -> refetch",
 			cached:             matchingSnap,
 			metric:             mutate(func(m *TdarrMetric) { m.TranscodeQueue = 99 }),
 			currentFingerprint: matchingFingerprint,
-			want:               true,
+			want:               missTotalsChanged,
 		},
 		{
 			name:               "table6Count (healthCheckFailed) changed -> refetch",
 			cached:             matchingSnap,
 			metric:             mutate(func(m *TdarrMetric) { m.HealthCheckFailed = 99 }),
 			currentFingerprint: matchingFingerprint,
-			want:               true,
+			want:               missTotalsChanged,
 		},
 		{
 			name:               "library renamed, totals unchanged -> refetch",
 			cached:             matchingSnap,
 			metric:             base,
 			currentFingerprint: renamedFingerprint,
-			want:               true,
+			want:               missFingerprintChanged,
 		},
 		{
 			name:               "new library appears, totals unchanged -> refetch",
 			cached:             matchingSnap,
 			metric:             base,
 			currentFingerprint: newLibraryFingerprint,
-			want:               true,
+			want:               missFingerprintChanged,
 		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
-			if got := shouldRefetch(tc.cached, tc.metric, tc.currentFingerprint); got != tc.want {
-				t.Errorf("shouldRefetch = %v, want %v", got, tc.want)
+			if got := refetchReason(tc.cached, tc.metric, tc.currentFingerprint); got != tc.want {
+				t.Errorf("refetchReason = %q, want %q", got, tc.want)
 			}
 		})
 	}
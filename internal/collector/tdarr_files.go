@@ -0,0 +1,257 @@
+package collector
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// fileTables are the status tables the files and streams collectors walk: hold, transcode
+// queue, transcode success/not required and transcode error/cancelled (see
+// TdarrMetric). Every file sits in exactly one transcode table, so together
+// they list each file once; the health check tables would list them again.
+var fileTables = []string{"table0", "table1", "table2", "table3"}
+
+// overflowLabel is the codec and resolution of the files beyond
+// codec_resolution_max_series, summed as one series.
+const overflowLabel = "overflow"
+
+// libraryFiles aggregates the files of one library.
+type libraryFiles struct {
+	count uint64
+	bytes float64
+	// buckets counts the files per bucket of the collector's fileSizeBuckets,
+	// not cumulative; files above the last bound are only in count.
+	buckets []uint64
+	// Bytes by video property, lowercased like the pie slices; files without
+	// a video stream are left out. codecResolution is always aggregated, so
+	// changing codec_resolution_max_series does not invalidate the walk.
+	codecs          map[string]float64
+	containers      map[string]float64
+	resolutions     map[string]float64
+	codecResolution map[codecResolution]float64
+	// streams is nil unless the walk counted the stream properties, for the
+	// streams collector.
+	streams streamCounts
+}
+
+type codecResolution struct {
+	codec, resolution string
+}
+
+// fileStatsSnapshot is one complete walk of the status tables. Like
+// libStatsSnapshot, it is read and written as one value.
+type fileStatsSnapshot struct {
+	totals tdarrCacheTotals
+	// libraries is nil until a walk has completed, keyed by library id.
+	libraries map[string]*libraryFiles
+	// buckets are the bucket bounds libraries was counted with; a reload
+	// changing file_size_buckets invalidates the walk.
+	buckets []float64
+	// streams is whether libraries counted the stream properties; enabling
+	// the streams collector invalidates a walk without them.
+	streams      bool
+	walkDuration time.Duration
+}
+
+// fileStatsCache holds the last walk, so the file tables, which grow with the
+// file count, are only walked again once the statistics totals move (see
+// refetchReason), like the per-library stats.
+type fileStatsCache struct {
+	mu   sync.RWMutex
+	snap fileStatsSnapshot
+}
+
+func (c *fileStatsCache) Read() fileStatsSnapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.snap
+}
+
+func (c *fileStatsCache) Write(snap fileStatsSnapshot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.snap = snap
+}
+
+// walkedFiles returns the files of each library, walking the status tables
+// when the statistics totals changed since the last walk. An ErrDeadline err
+// comes with the libraries of the last walk, any other err with none.
+func (c *TdarrCollector) walkedFiles(ctx context.Context, ch chan<- prometheus.Metric, metric *TdarrMetric) (map[string]*libraryFiles, error) {
+	totals := totalsFromMetric(metric)
+	cached := c.filesCache.Read()
+	if cached.libraries != nil && cached.totals == totals && slices.Equal(cached.buckets, c.fileSizeBuckets) &&
+		(cached.streams || !c.collectors.Streams) {
+		c.logger.Debug().Msg("Using cached file walk - api totals match cached values")
+		return cached.libraries, nil
+	}
+	// Like the pie sweep, the walk is skipped when the last one would not fit
+	// before the scrape deadline, serving the previous walk.
+	if deadline, ok := ctx.Deadline(); ok && cached.libraries != nil {
+		if left := time.Until(deadline); left < cached.walkDuration {
+			return cached.libraries, fmt.Errorf("skip file walk, %s left before the scrape deadline and the last walk took %s: %w",
+				left.Round(time.Millisecond), cached.walkDuration.Round(time.Millisecond), ErrDeadline)
+		}
+	}
+	start := time.Now()
+	libraries, err := c.walkFiles(ctx)
+	c.emitPhase(ch, phaseFiles, start)
+	if err != nil {
+		return nil, err
+	}
+	c.filesCache.Write(fileStatsSnapshot{
+		totals:       totals,
+		libraries:    libraries,
+		buckets:      c.fileSizeBuckets,
+		streams:      c.collectors.Streams,
+		walkDuration: time.Since(start),
+	})
+	return libraries, nil
+}
+
+// walkFiles pages through fileTables, filesPageSize files at a time, and
+// aggregates the files by library. A file seen twice, having moved to a later
+// table while the walk was under way, is counted once.
+func (c *TdarrCollector) walkFiles(ctx context.Context) (map[string]*libraryFiles, error) {
+	libraries := make(map[string]*libraryFiles)
+	seen := make(map[string]struct{})
+	for _, table := range fileTables {
+		for start := 0; ; {
+			payload := TdarrFilesRequest{Data: TdarrFilesRequestData{
+				Start:    start,
+				PageSize: c.filesPageSize,
+				Filters:  []any{},
+				Sorts:    []any{},
+				Opts:     TdarrFilesTable{Table: table},
+			}}
+			page := TdarrFilesPage{}
+			if err := c.httpReqHelper(ctx, c.filesPath, payload, &page); err != nil {
+				return nil, fmt.Errorf("get files of %s: %w", table, err)
+			}
+			for _, file := range page.Array {
+				if _, ok := seen[file.Id]; ok {
+					continue
+				}
+				seen[file.Id] = struct{}{}
+				c.addFile(libraries, file)
+			}
+			start += len(page.Array)
+			if len(page.Array) < c.filesPageSize || start >= page.TotalCount {
+				break
+			}
+		}
+	}
+	if c.collectors.Streams {
+		for libId, lib := range libraries {
+			normalizeStreamCounts(lib.streams, libId, c.bumpUnknownStatus)
+		}
+	}
+	return libraries, nil
+}
+
+// addFile counts file in its library.
+func (c *TdarrCollector) addFile(libraries map[string]*libraryFiles, file TdarrFile) {
+	lib, ok := libraries[file.LibraryId]
+	if !ok {
+		lib = &libraryFiles{
+			buckets:         make([]uint64, len(c.fileSizeBuckets)),
+			codecs:          make(map[string]float64),
+			containers:      make(map[string]float64),
+			resolutions:     make(map[string]float64),
+			codecResolution: make(map[codecResolution]float64),
+		}
+		libraries[file.LibraryId] = lib
+	}
+	size := file.sizeBytes()
+	lib.count++
+	lib.bytes += size
+	if i := sort.SearchFloat64s(c.fileSizeBuckets, size); i < len(lib.buckets) {
+		lib.buckets[i]++
+	}
+	codec, resolution := strings.ToLower(file.VideoCodecName), strings.ToLower(file.VideoResolution)
+	if codec != "" {
+		lib.codecs[codec] += size
+	}
+	if file.Container != "" {
+		lib.containers[strings.ToLower(file.Container)] += size
+	}
+	if resolution != "" {
+		lib.resolutions[resolution] += size
+	}
+	if codec != "" && resolution != "" {
+		lib.codecResolution[codecResolution{codec, resolution}] += size
+	}
+	if c.collectors.Streams {
+		if lib.streams == nil {
+			lib.streams = make(streamCounts)
+		}
+		lib.streams.addFile(file)
+	}
+}
+
+// emitFileMetrics emits the size histogram and total bytes of each library.
+func (c *TdarrCollector) emitFileMetrics(ch chan<- prometheus.Metric, libraries map[string]*libraryFiles) {
+	for libId, lib := range libraries {
+		cumulative := make(map[float64]uint64, len(c.fileSizeBuckets))
+		var n uint64
+		for i, bound := range c.fileSizeBuckets {
+			n += lib.buckets[i]
+			cumulative[bound] = n
+		}
+		ch <- c.fileSizeHistogram.mustNewConstHistogram(lib.count, lib.bytes, cumulative, libId)
+		ch <- c.librarySizeBytes.mustNewConstMetric(lib.bytes, libId)
+		for codec, bytes := range lib.codecs {
+			ch <- c.videoCodecBytes.mustNewConstMetric(bytes, libId, codec)
+		}
+		for container, bytes := range lib.containers {
+			ch <- c.videoContainerBytes.mustNewConstMetric(bytes, libId, container)
+		}
+		for resolution, bytes := range lib.resolutions {
+			ch <- c.videoResolutionBytes.mustNewConstMetric(bytes, libId, resolution)
+		}
+		if c.codecResolutionMaxSeries > 0 {
+			c.emitCodecResolution(ch, libId, lib.codecResolution)
+		}
+	}
+}
+
+// emitCodecResolution emits the codec by resolution bytes of a library, at
+// most codecResolutionMaxSeries series: past it, the largest pairs keep their
+// own series and the rest are summed under overflowLabel.
+func (c *TdarrCollector) emitCodecResolution(ch chan<- prometheus.Metric, libId string, pairs map[codecResolution]float64) {
+	keys := make([]codecResolution, 0, len(pairs))
+	for k := range pairs {
+		keys = append(keys, k)
+	}
+	var overflow float64
+	overflowed := len(keys) > c.codecResolutionMaxSeries
+	if overflowed {
+		// Largest first, ties by name so the same pairs overflow every scrape.
+		slices.SortFunc(keys, func(a, b codecResolution) int {
+			if n := cmp.Compare(pairs[b], pairs[a]); n != 0 {
+				return n
+			}
+			if n := cmp.Compare(a.codec, b.codec); n != 0 {
+				return n
+			}
+			return cmp.Compare(a.resolution, b.resolution)
+		})
+		for _, k := range keys[c.codecResolutionMaxSeries-1:] {
+			overflow += pairs[k]
+		}
+		keys = keys[:c.codecResolutionMaxSeries-1]
+	}
+	for _, k := range keys {
+		ch <- c.videoCodecResolutionBytes.mustNewConstMetric(pairs[k], libId, k.codec, k.resolution)
+	}
+	if overflowed {
+		ch <- c.videoCodecResolutionBytes.mustNewConstMetric(overflow, libId, overflowLabel, overflowLabel)
+	}
+}
@@ -0,0 +1,272 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/homeylab/tdarr-exporter/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// newFilesTestConfig enables the files collector with three buckets and a
+// page size of 2, so the fixtures of setFilesPages span several pages.
+func newFilesTestConfig(t *testing.T) config.Config {
+	t.Helper()
+	cfg := newTestConfig(t)
+	cfg.Collectors.Files = true
+	cfg.FileSizeBuckets = []float64{100e6, 1e9, 10e9}
+	cfg.FilesPageSize = 2
+	return cfg
+}
+
+func filesPageBody(total int, files ...TdarrFile) []byte {
+	b, _ := json.Marshal(TdarrFilesPage{Array: files, TotalCount: total})
+	return b
+}
+
+func testFile(id, libraryId string, sizeBytes int64) TdarrFile {
+	f := TdarrFile{Id: id, LibraryId: libraryId}
+	f.StatSync.Size = sizeBytes
+	return f
+}
+
+func withVideo(f TdarrFile, codec, container, resolution string) TdarrFile {
+	f.VideoCodecName, f.Container, f.VideoResolution = codec, container, resolution
+	return f
+}
+
+// setFilesPages registers the status tables: lib1 holds a (50 MB, h264 mkv
+// 1080p), b (300 MB, only as file_size, hevc mkv 1080p) and d (60 GB, above
+// the last bucket, hevc mp4 4KUHD); lib2 holds c (1.5 GB, H264 MKV 720p) and
+// e (10 GB, no video stream). b shows up again in table2, as a file moving
+// tables mid-walk would, and table2 takes two pages.
+func setFilesPages(cfg config.Config, api *fakeTdarrAPI) {
+	path := cfg.TdarrFilesPath
+	b := withVideo(TdarrFile{Id: "b", LibraryId: "lib1", FileSize: 300}, "hevc", "mkv", "1080p")
+	api.setResponse(filesPageKey(path, "table0", 0), filesPageBody(2,
+		withVideo(testFile("a", "lib1", 50e6), "h264", "mkv", "1080p"),
+		b,
+	))
+	api.setResponse(filesPageKey(path, "table1", 0), filesPageBody(1, withVideo(testFile("c", "lib2", 1.5e9), "H264", "MKV", "720p")))
+	api.setResponse(filesPageKey(path, "table2", 0), filesPageBody(3,
+		withVideo(testFile("d", "lib1", 60e9), "hevc", "mp4", "4KUHD"),
+		b,
+	))
+	api.setResponse(filesPageKey(path, "table2", 2), filesPageBody(3, testFile("e", "lib2", 10e9)))
+	api.setResponse(filesPageKey(path, "table3", 0), filesPageBody(0))
+}
+
+// filesWalkCalls counts the status-table requests of one walk of setFilesPages.
+func filesWalkCalls(cfg config.Config, api *fakeTdarrAPI) int {
+	n := 0
+	for _, key := range []fakeKey{
+		filesPageKey(cfg.TdarrFilesPath, "table0", 0),
+		filesPageKey(cfg.TdarrFilesPath, "table1", 0),
+		filesPageKey(cfg.TdarrFilesPath, "table2", 0),
+		filesPageKey(cfg.TdarrFilesPath, "table2", 2),
+		filesPageKey(cfg.TdarrFilesPath, "table3", 0),
+	} {
+		n += api.callCount(key)
+	}
+	return n
+}
+
+// TestCollectFiles_Histogram verifies the walk pages through every table,
+// counts a file seen twice once and buckets the sizes per library.
+func TestCollectFiles_Histogram(t *testing.T) {
+	t.Parallel()
+	cfg := newFilesTestConfig(t)
+	api := newSuccessFakeAPI(cfg)
+	setFilesPages(cfg, api)
+	c := newTdarrCollectorWithAPI(cfg, api)
+	samples := collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+
+	if got := filesWalkCalls(cfg, api); got != 5 {
+		t.Errorf("status-table requests = %d, want 5", got)
+	}
+	tests := []struct {
+		library string
+		le      string
+		want    float64
+	}{
+		{"lib1", "1e+08", 1},
+		{"lib1", "1e+09", 2},
+		{"lib1", "1e+10", 2},
+		{"lib2", "1e+08", 0},
+		{"lib2", "1e+09", 0},
+		{"lib2", "1e+10", 2},
+	}
+	for _, tt := range tests {
+		got := findOne(t, samples, "tdarr_library_file_size_bytes_bucket", map[string]string{"library_id": tt.library, "le": tt.le}).value
+		if got != tt.want {
+			t.Errorf("%s bucket le=%s = %v, want %v", tt.library, tt.le, got, tt.want)
+		}
+	}
+	lib1 := map[string]string{"library_id": "lib1"}
+	if got := findOne(t, samples, "tdarr_library_file_size_bytes_count", lib1).value; got != 3 {
+		t.Errorf("lib1 count = %v, want 3", got)
+	}
+	if got := findOne(t, samples, "tdarr_library_file_size_bytes_sum", lib1).value; got != 60.35e9 {
+		t.Errorf("lib1 sum = %v, want 60.35e9", got)
+	}
+	if got := findOne(t, samples, "tdarr_library_size_bytes", lib1).value; got != 60.35e9 {
+		t.Errorf("lib1 size = %v, want 60.35e9", got)
+	}
+	if got := findOne(t, samples, "tdarr_library_size_bytes", map[string]string{"library_id": "lib2"}).value; got != 11.5e9 {
+		t.Errorf("lib2 size = %v, want 11.5e9", got)
+	}
+	if got := findOne(t, samples, "tdarr_scrape_collector_success", map[string]string{"collector": subsystemFiles}).value; got != 1 {
+		t.Errorf("files collector success = %v, want 1", got)
+	}
+	findOne(t, samples, "tdarr_exporter_scrape_phase_duration_seconds", map[string]string{"phase": phaseFiles})
+}
+
+// TestCollectFiles_VideoBytes verifies the bytes by codec, container and
+// resolution, lowercased like the pie slices, leave out files without a video
+// stream, and the joint breakdown stays off by default.
+func TestCollectFiles_VideoBytes(t *testing.T) {
+	t.Parallel()
+	cfg := newFilesTestConfig(t)
+	api := newSuccessFakeAPI(cfg)
+	setFilesPages(cfg, api)
+	c := newTdarrCollectorWithAPI(cfg, api)
+	samples := collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   float64
+	}{
+		{"tdarr_library_video_codec_bytes", map[string]string{"library_id": "lib1", "codec": "h264"}, 50e6},
+		{"tdarr_library_video_codec_bytes", map[string]string{"library_id": "lib1", "codec": "hevc"}, 60.3e9},
+		{"tdarr_library_video_codec_bytes", map[string]string{"library_id": "lib2", "codec": "h264"}, 1.5e9},
+		{"tdarr_library_video_container_bytes", map[string]string{"library_id": "lib1", "container_type": "mkv"}, 350e6},
+		{"tdarr_library_video_container_bytes", map[string]string{"library_id": "lib1", "container_type": "mp4"}, 60e9},
+		{"tdarr_library_video_container_bytes", map[string]string{"library_id": "lib2", "container_type": "mkv"}, 1.5e9},
+		{"tdarr_library_video_resolution_bytes", map[string]string{"library_id": "lib1", "resolution": "1080p"}, 350e6},
+		{"tdarr_library_video_resolution_bytes", map[string]string{"library_id": "lib1", "resolution": "4kuhd"}, 60e9},
+		{"tdarr_library_video_resolution_bytes", map[string]string{"library_id": "lib2", "resolution": "720p"}, 1.5e9},
+	}
+	for _, tt := range tests {
+		if got := findOne(t, samples, tt.name, tt.labels).value; got != tt.want {
+			t.Errorf("%s%v = %v, want %v", tt.name, tt.labels, got, tt.want)
+		}
+	}
+	if got := countByName(samples, "tdarr_library_video_codec_bytes"); got != 3 {
+		t.Errorf("codec series = %d, want 3", got)
+	}
+	if hasName(samples, "tdarr_library_video_codec_resolution_bytes") {
+		t.Error("codec by resolution bytes emitted without codec_resolution_max_series")
+	}
+}
+
+// TestCollectFiles_CodecResolutionGuard verifies the joint breakdown keeps at
+// most codec_resolution_max_series series per library, summing the smallest
+// pairs past it as overflow.
+func TestCollectFiles_CodecResolutionGuard(t *testing.T) {
+	t.Parallel()
+	cfg := newFilesTestConfig(t)
+	cfg.CodecResolutionMaxSeries = 2
+	api := newSuccessFakeAPI(cfg)
+	setFilesPages(cfg, api)
+	c := newTdarrCollectorWithAPI(cfg, api)
+	samples := collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+
+	tests := []struct {
+		labels map[string]string
+		want   float64
+	}{
+		// lib1 has three pairs: the largest keeps its series.
+		{map[string]string{"library_id": "lib1", "codec": "hevc", "resolution": "4kuhd"}, 60e9},
+		{map[string]string{"library_id": "lib1", "codec": overflowLabel, "resolution": overflowLabel}, 350e6},
+		{map[string]string{"library_id": "lib2", "codec": "h264", "resolution": "720p"}, 1.5e9},
+	}
+	for _, tt := range tests {
+		if got := findOne(t, samples, "tdarr_library_video_codec_resolution_bytes", tt.labels).value; got != tt.want {
+			t.Errorf("%v = %v, want %v", tt.labels, got, tt.want)
+		}
+	}
+	if got := countByName(samples, "tdarr_library_video_codec_resolution_bytes"); got != 3 {
+		t.Errorf("codec by resolution series = %d, want 3", got)
+	}
+}
+
+// TestCollectFiles_Disabled verifies the default collectors never walk the
+// status tables.
+func TestCollectFiles_Disabled(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	api := newSuccessFakeAPI(cfg)
+	setFilesPages(cfg, api)
+	c := newTdarrCollectorWithAPI(cfg, api)
+	samples := collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+
+	if got := filesWalkCalls(cfg, api); got != 0 {
+		t.Errorf("status-table requests = %d, want 0", got)
+	}
+	if hasName(samples, "tdarr_library_size_bytes") {
+		t.Error("tdarr_library_size_bytes emitted with the files collector disabled")
+	}
+}
+
+// TestCollectFiles_Cache verifies the last walk is served while the
+// statistics totals are unchanged, and the tables walked again once they move.
+func TestCollectFiles_Cache(t *testing.T) {
+	t.Parallel()
+	cfg := newFilesTestConfig(t)
+	api := newSuccessFakeAPI(cfg)
+	setFilesPages(cfg, api)
+	c := newTdarrCollectorWithAPI(cfg, api)
+	scrape := func() []sample {
+		return collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+	}
+
+	scrape()
+	api.resetCalls()
+	samples := scrape()
+	if got := filesWalkCalls(cfg, api); got != 0 {
+		t.Errorf("status-table requests with unchanged totals = %d, want 0", got)
+	}
+	if got := findOne(t, samples, "tdarr_library_size_bytes", map[string]string{"library_id": "lib2"}).value; got != 11.5e9 {
+		t.Errorf("cached lib2 size = %v, want 11.5e9", got)
+	}
+
+	moved, _ := json.Marshal(TdarrMetric{TotalFileCount: 11, TdarrScore: "0", HealthCheckScore: "0"})
+	api.setResponse(fakeKey{path: cfg.TdarrStatsPath, disc: "StatisticsJSONDB"}, moved)
+	api.setResponse(filesPageKey(cfg.TdarrFilesPath, "table3", 0), filesPageBody(1, testFile("f", "lib2", 1e9)))
+	api.resetCalls()
+	samples = scrape()
+	if got := filesWalkCalls(cfg, api); got != 5 {
+		t.Errorf("status-table requests with changed totals = %d, want 5", got)
+	}
+	if got := findOne(t, samples, "tdarr_library_size_bytes", map[string]string{"library_id": "lib2"}).value; got != 12.5e9 {
+		t.Errorf("refetched lib2 size = %v, want 12.5e9", got)
+	}
+}
+
+// TestCollectFiles_WalkFails verifies a failed walk only fails the files
+// collector, without emitting a partial histogram.
+func TestCollectFiles_WalkFails(t *testing.T) {
+	t.Parallel()
+	cfg := newFilesTestConfig(t)
+	api := newSuccessFakeAPI(cfg)
+	setFilesPages(cfg, api)
+	api.setError(filesPageKey(cfg.TdarrFilesPath, "table2", 2), statErr{"page failed"})
+	c := newTdarrCollectorWithAPI(cfg, api)
+	samples := collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+
+	want := map[string]float64{subsystemServer: 1, subsystemGeneral: 1, subsystemLibrary: 1, subsystemNodes: 1, subsystemFiles: 0}
+	for collector, ok := range want {
+		if got := findOne(t, samples, "tdarr_scrape_collector_success", map[string]string{"collector": collector}).value; got != ok {
+			t.Errorf("%s collector success = %v, want %v", collector, got, ok)
+		}
+	}
+	if got := findOne(t, samples, "tdarr_up", nil).value; got != 0 {
+		t.Errorf("tdarr_up = %v, want 0", got)
+	}
+	if hasName(samples, "tdarr_library_size_bytes") {
+		t.Error("tdarr_library_size_bytes emitted after a failed walk")
+	}
+	findOne(t, samples, "tdarr_exporter_scrape_error", map[string]string{"cause": causeUpstream})
+}
@@ -0,0 +1,60 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectFlows fetches the flows document and emits the flow inventory. It
+// returns the flows by id, for naming the flow workers, or nil with the err.
+func (c *TdarrCollector) collectFlows(ctx context.Context, ch chan<- prometheus.Metric) (map[string]TdarrFlow, error) {
+	all := []TdarrFlow{}
+	start := time.Now()
+	err := c.httpReqHelper(ctx, c.statsPath, getGeneralReqPayload("flows"), &all)
+	c.emitPhase(ch, phaseFlows, start)
+	if err != nil {
+		return nil, fmt.Errorf("get flows: %w", err)
+	}
+	flows := make(map[string]TdarrFlow, len(all))
+	for _, flow := range all {
+		flows[flow.Id] = flow
+		ch <- c.flowInfo.mustNewConstMetric(1, flow.Id, flow.Name)
+		ch <- c.flowPlugins.mustNewConstMetric(float64(len(flow.FlowPlugins)), flow.Id)
+	}
+	return flows, nil
+}
+
+// stepName returns the name of a step of flow, its label in the flow editor
+// or else the plugin it runs, and "" for a step not in the flow.
+func (f TdarrFlow) stepName(stepId string) string {
+	for _, p := range f.FlowPlugins {
+		if p.Id != stepId {
+			continue
+		}
+		if p.Name != "" {
+			return p.Name
+		}
+		return p.PluginName
+	}
+	return ""
+}
+
+// emitWorkerFlowSteps emits the flow and step of each busy flow worker. A
+// flow missing from flows, e.g. one deleted while the worker runs it, keeps
+// its id with an empty name.
+func (c *TdarrCollector) emitWorkerFlowSteps(ch chan<- prometheus.Metric, nodeData map[string]TdarrNode, flows map[string]TdarrFlow) {
+	for _, node := range nodeData {
+		for _, worker := range node.Workers {
+			if !worker.FlowWorker || worker.Idle || worker.FlowId == "" {
+				continue
+			}
+			flow := flows[worker.FlowId]
+			stepId := worker.LastPluginDetails.Id
+			ch <- c.workerFlowStepInfo.mustNewConstMetric(1,
+				node.Id, node.Name, worker.Id, worker.FlowId, flow.Name, stepId, flow.stepName(stepId))
+		}
+	}
+}
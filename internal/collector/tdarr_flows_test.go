@@ -0,0 +1,118 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const flowsBody = `[
+  {
+    "_id": "flow-1", "name": "Convert to HEVC",
+    "flowPlugins": [
+      {"id": "step-in", "name": "Input File", "pluginName": "inputFile"},
+      {"id": "step-ff", "name": "", "pluginName": "ffmpegCommandStart"},
+      {"id": "step-out", "name": "Replace Original", "pluginName": "replaceOriginalFile"}
+    ]
+  },
+  {"_id": "flow-2", "name": "Empty", "flowPlugins": []}
+]`
+
+// flowNodesBody has a flow worker on a step of flow-1, one on a flow missing
+// from flowsBody, an idle flow worker and a classic worker.
+const flowNodesBody = `{
+  "node-1": {
+    "_id": "node-1", "nodeName": "Node1",
+    "workers": {
+      "w1": {"_id": "w1", "workerType": "transcodecpu", "isFlowWorker": true, "flowId": "flow-1", "lastPluginDetails": {"id": "step-ff"}},
+      "w2": {"_id": "w2", "workerType": "transcodegpu", "isFlowWorker": true, "flowId": "flow-gone", "lastPluginDetails": {"id": "step-x"}},
+      "w3": {"_id": "w3", "workerType": "transcodecpu", "isFlowWorker": true, "idle": true, "flowId": "flow-1"},
+      "w4": {"_id": "w4", "workerType": "healthcheckcpu", "lastPluginDetails": {"id": "Tdarr_Plugin_MC93_Migz1FFMPEG"}}
+    }
+  }
+}`
+
+// TestCollectFlows verifies the flow inventory and the flow step of each busy
+// flow worker.
+func TestCollectFlows(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	cfg.Collectors.Flows = true
+	api := newSuccessFakeAPI(cfg)
+	api.setResponse(fakeKey{path: cfg.TdarrStatsPath, disc: "FlowsJSONDB"}, []byte(flowsBody))
+	api.setResponse(fakeKey{path: cfg.TdarrNodePath}, []byte(flowNodesBody))
+	c := newTdarrCollectorWithAPI(cfg, api)
+	samples := collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+
+	findOne(t, samples, "tdarr_flow_info", map[string]string{"flow_id": "flow-1", "flow_name": "Convert to HEVC"})
+	findOne(t, samples, "tdarr_flow_info", map[string]string{"flow_id": "flow-2", "flow_name": "Empty"})
+	if got := findOne(t, samples, "tdarr_flow_plugins", map[string]string{"flow_id": "flow-1"}).value; got != 3 {
+		t.Errorf("flow-1 plugins = %v, want 3", got)
+	}
+	if got := findOne(t, samples, "tdarr_flow_plugins", map[string]string{"flow_id": "flow-2"}).value; got != 0 {
+		t.Errorf("flow-2 plugins = %v, want 0", got)
+	}
+
+	// The step without a label is named after its plugin.
+	findOne(t, samples, "tdarr_node_worker_flow_step_info", map[string]string{
+		"worker_id": "w1", "flow_id": "flow-1", "flow_name": "Convert to HEVC", "step_id": "step-ff", "step_name": "ffmpegCommandStart",
+	})
+	findOne(t, samples, "tdarr_node_worker_flow_step_info", map[string]string{
+		"worker_id": "w2", "flow_id": "flow-gone", "flow_name": "", "step_id": "step-x", "step_name": "",
+	})
+	if got := countByName(samples, "tdarr_node_worker_flow_step_info"); got != 2 {
+		t.Errorf("worker flow step series = %d, want 2", got)
+	}
+	if got := findOne(t, samples, "tdarr_scrape_collector_success", map[string]string{"collector": subsystemFlows}).value; got != 1 {
+		t.Errorf("flows collector success = %v, want 1", got)
+	}
+	findOne(t, samples, "tdarr_exporter_scrape_phase_duration_seconds", map[string]string{"phase": phaseFlows})
+}
+
+// TestCollectFlows_Fails verifies a failed flows request fails only the
+// flows collector, and the workers are still emitted without their flow.
+func TestCollectFlows_Fails(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	cfg.Collectors.Flows = true
+	api := newSuccessFakeAPI(cfg)
+	api.setError(fakeKey{path: cfg.TdarrStatsPath, disc: "FlowsJSONDB"}, statErr{"flows failed"})
+	api.setResponse(fakeKey{path: cfg.TdarrNodePath}, []byte(flowNodesBody))
+	c := newTdarrCollectorWithAPI(cfg, api)
+	samples := collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+
+	want := map[string]float64{subsystemLibrary: 1, subsystemNodes: 1, subsystemFlows: 0}
+	for collector, ok := range want {
+		if got := findOne(t, samples, "tdarr_scrape_collector_success", map[string]string{"collector": collector}).value; got != ok {
+			t.Errorf("%s collector success = %v, want %v", collector, got, ok)
+		}
+	}
+	if got := findOne(t, samples, "tdarr_up", nil).value; got != 0 {
+		t.Errorf("tdarr_up = %v, want 0", got)
+	}
+	findOne(t, samples, "tdarr_node_worker_info", map[string]string{"worker_id": "w1"})
+	if hasName(samples, "tdarr_node_worker_flow_step_info") || hasName(samples, "tdarr_flow_info") {
+		t.Error("flow series emitted after a failed flows request")
+	}
+}
+
+// TestDescribe_Flows verifies the worker flow step desc is only described
+// with the workers collector enabled: without it, Describe drops that desc
+// and the 13 worker descs.
+func TestDescribe_Flows(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	cfg.Collectors.Flows = true
+	describe := func() int {
+		ch := make(chan *prometheus.Desc, 256)
+		newTdarrCollectorWithAPI(cfg, newSuccessFakeAPI(cfg)).Describe(ch)
+		close(ch)
+		return len(ch)
+	}
+	withWorkers := describe()
+	cfg.Collectors.Workers = false
+	if got := describe(); got != withWorkers-1-13 {
+		t.Errorf("Describe without workers = %d descs, want %d", got, withWorkers-1-13)
+	}
+}
@@ -12,9 +12,22 @@ import (
 // collectorMetricNames is the exhaustive list of metric families emitted by
 // TdarrCollector.Collect. The comparison is scoped to this allowlist so that
 // handler-level series registered elsewhere (the promhttp_metric_handler_*
-// counters from internal/handlers/metrics.go) cannot influence it.
+// counters from internal/handlers/metrics.go) cannot influence it. The
+// timing series (tdarr_exporter_scrape_phase_duration_seconds,
+// tdarr_scrape_collector_duration_seconds and
+// tdarr_exporter_pie_cache_age_seconds) vary from run to run and are left out.
 var collectorMetricNames = []string{
+	"tdarr_api_key_file_read_failures_total",
 	"tdarr_avg_num_streams",
+	"tdarr_client_certificate_expiry_timestamp_seconds",
+	"tdarr_exporter_api_capability",
+	"tdarr_exporter_circuit_state",
+	"tdarr_exporter_circuit_transitions_total",
+	"tdarr_exporter_last_poll_success_timestamp_seconds",
+	"tdarr_exporter_pie_cache_hits_total",
+	"tdarr_exporter_pie_cache_misses_total",
+	"tdarr_exporter_scrape_error",
+	"tdarr_exporter_snapshot_age_seconds",
 	"tdarr_files",
 	"tdarr_health_check_score_ratio",
 	"tdarr_health_checks_completed",
@@ -57,6 +70,7 @@ var collectorMetricNames = []string{
 	"tdarr_node_worker_status_timestamp_seconds",
 	"tdarr_node_worker_step_start_timestamp_seconds",
 	"tdarr_score_ratio",
+	"tdarr_scrape_collector_success",
 	"tdarr_server_healthy",
 	"tdarr_server_info",
 	"tdarr_server_status_info",
@@ -101,6 +115,7 @@ func newGoldenTestConfig(t *testing.T) config.Config {
 		TdarrNodePath:      "/api/v2/get-nodes",
 		TdarrStatusPath:    "/api/v2/status",
 		HttpMaxConcurrency: 1,
+		Collectors:         config.DefaultCollectors(),
 	}
 }
 
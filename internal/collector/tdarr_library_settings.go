@@ -0,0 +1,172 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Modes of tdarr_library_settings_info: whether the library runs a flow or
+// the classic plugin stack.
+const (
+	libraryModeFlows   = "flows"
+	libraryModeClassic = "classic"
+)
+
+// scheduleDays are the day prefixes of the schedule slot ids, by weekday.
+var scheduleDays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseScheduleSlot returns the weekday and starting hour of a schedule slot
+// id, with or without a colon after the day.
+//
+// Examples:
+//
+//	"Sun:00-01" -> Sunday, 0
+//	"Mon13-14"  -> Monday, 13
+func parseScheduleSlot(id string) (time.Weekday, int, bool) {
+	if len(id) < 5 {
+		return 0, 0, false
+	}
+	day, ok := scheduleDays[strings.ToLower(id[:3])]
+	if !ok {
+		return 0, 0, false
+	}
+	rest := strings.TrimPrefix(id[3:], ":")
+	if len(rest) < 2 {
+		return 0, 0, false
+	}
+	hour, err := strconv.Atoi(rest[:2])
+	if err != nil || hour < 0 || hour > 23 {
+		return 0, 0, false
+	}
+	return day, hour, true
+}
+
+// boolGauge is 1 for true, 0 for false.
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// libraryList is the library list of one scrape, fetched once for the
+// library and staged collectors.
+type libraryList struct {
+	fetched bool
+	libs    []TdarrLibrarySettings
+	err     error
+}
+
+// get returns the library list, fetching it on the first call. A failed
+// fetch is not retried within the scrape.
+func (l *libraryList) get(ctx context.Context, c *TdarrCollector, ch chan<- prometheus.Metric) ([]TdarrLibrarySettings, error) {
+	if !l.fetched {
+		l.fetched = true
+		libs := []TdarrLibrarySettings{}
+		start := time.Now()
+		err := c.httpReqHelper(ctx, c.statsPath, getGeneralReqPayload("library"), &libs)
+		c.emitPhase(ch, phaseLibraries, start)
+		if err != nil {
+			l.err = fmt.Errorf("get library details: %w", err)
+		} else {
+			l.libs = libs
+		}
+	}
+	return l.libs, l.err
+}
+
+// libraryInfos returns the library id and name of each library's settings.
+func libraryInfos(libs []TdarrLibrarySettings) []TdarrLibraryInfo {
+	infos := make([]TdarrLibraryInfo, len(libs))
+	for i, lib := range libs {
+		infos[i] = lib.TdarrLibraryInfo
+	}
+	return infos
+}
+
+// emitLibrarySettings emits the settings of each library. A setting the
+// document lacks is left out rather than reported as off, so a library saved
+// by an older Tdarr does not look paused.
+func (c *TdarrCollector) emitLibrarySettings(ch chan<- prometheus.Metric, libs []TdarrLibrarySettings) {
+	now := c.now()
+	for _, lib := range libs {
+		id := lib.LibraryId
+		mode, flowId := libraryModeClassic, ""
+		if lib.DecisionMaker.SettingsFlows {
+			mode, flowId = libraryModeFlows, lib.FlowId
+		}
+		output := ""
+		if lib.FolderToFolderConversion {
+			output = lib.Output
+		}
+		ch <- c.librarySettingsInfo.mustNewConstMetric(1, id, mode, flowId,
+			applyLabelPolicy(c.labelPolicies.LibraryFolder, lib.Cache),
+			applyLabelPolicy(c.labelPolicies.LibraryFolder, output))
+		if lib.FolderWatching != nil {
+			ch <- c.libraryFolderWatchEnabled.mustNewConstMetric(boolGauge(*lib.FolderWatching), id)
+		}
+		if lib.FolderWatchScanInterval.set {
+			ch <- c.libraryScanInterval.mustNewConstMetric(lib.FolderWatchScanInterval.value, id)
+		}
+		if lib.ProcessLibrary != nil {
+			ch <- c.libraryProcessingEnabled.mustNewConstMetric(boolGauge(*lib.ProcessLibrary), id)
+		}
+		if lib.ProcessTranscodes != nil {
+			ch <- c.libraryTranscodeEnabled.mustNewConstMetric(boolGauge(*lib.ProcessTranscodes), id)
+		}
+		if lib.ProcessHealthChecks != nil {
+			ch <- c.libraryHealthCheckEnabled.mustNewConstMetric(boolGauge(*lib.ProcessHealthChecks), id)
+		}
+		if lib.Priority.set {
+			ch <- c.libraryPriority.mustNewConstMetric(lib.Priority.value, id)
+		}
+		c.emitLibrarySchedule(ch, id, lib.Schedule, now)
+		c.emitLibraryScan(ch, lib)
+	}
+}
+
+// emitLibraryScan emits the state of the library's scans, each series once
+// the document holds it.
+func (c *TdarrCollector) emitLibraryScan(ch chan<- prometheus.Metric, lib TdarrLibrarySettings) {
+	if lib.ScanButtons != nil {
+		ch <- c.libraryScanInProgress.mustNewConstMetric(boolGauge(!*lib.ScanButtons), lib.LibraryId)
+	}
+	if lib.ScanFound.set {
+		ch <- c.libraryScanFilesFound.mustNewConstMetric(lib.ScanFound.value, lib.LibraryId)
+	}
+}
+
+// emitLibrarySchedule emits how many hours of the week the schedule of a
+// library enables and whether it enables the current one, in the exporter's
+// time zone. A library without a schedule emits neither.
+func (c *TdarrCollector) emitLibrarySchedule(ch chan<- prometheus.Metric, libId string, schedule []TdarrScheduleSlot, now time.Time) {
+	if len(schedule) == 0 {
+		return
+	}
+	hours, active, found := 0, false, false
+	for _, slot := range schedule {
+		if slot.Checked {
+			hours++
+		}
+		if day, hour, ok := parseScheduleSlot(slot.Id); ok && day == now.Weekday() && hour == now.Hour() {
+			active, found = slot.Checked, true
+		}
+	}
+	ch <- c.libraryScheduleHours.mustNewConstMetric(float64(hours), libId)
+	if found {
+		ch <- c.libraryScheduleActive.mustNewConstMetric(boolGauge(active), libId)
+	}
+}
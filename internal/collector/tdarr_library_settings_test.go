@@ -0,0 +1,205 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/homeylab/tdarr-exporter/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestParseScheduleSlot(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		id       string
+		wantDay  time.Weekday
+		wantHour int
+		wantOk   bool
+	}{
+		{"Sun:00-01", time.Sunday, 0, true},
+		{"Mon13-14", time.Monday, 13, true},
+		{"sat:23-00", time.Saturday, 23, true},
+		{"Xyz:01-02", 0, 0, false},
+		{"Tue:24-25", 0, 0, false},
+		{"Wed", 0, 0, false},
+	}
+	for _, tt := range tests {
+		day, hour, ok := parseScheduleSlot(tt.id)
+		if ok != tt.wantOk || (ok && (day != tt.wantDay || hour != tt.wantHour)) {
+			t.Errorf("parseScheduleSlot(%q) = %v, %d, %v, want %v, %d, %v", tt.id, day, hour, ok, tt.wantDay, tt.wantHour, tt.wantOk)
+		}
+	}
+}
+
+func TestLooseTimestamp(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		in      string
+		want    float64
+		wantSet bool
+	}{
+		{`1760000000`, 1760000000, true},
+		{`1760000000500`, 1760000000.5, true},
+		{`"1760000000000"`, 1760000000, true},
+		{`"2025-10-09T09:00:00Z"`, 1760000400, true},
+		{`0`, 0, false},
+		{`null`, 0, false},
+		{`"yesterday"`, 0, false},
+		{`{}`, 0, false},
+	}
+	for _, tt := range tests {
+		var ts looseTimestamp
+		if err := json.Unmarshal([]byte(tt.in), &ts); err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.in, err)
+			continue
+		}
+		if ts.set != tt.wantSet || (ts.set && ts.value != tt.want) {
+			t.Errorf("%s = %v (set %v), want %v (set %v)", tt.in, ts.value, ts.set, tt.want, tt.wantSet)
+		}
+	}
+}
+
+func TestScanFound(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		in      string
+		want    float64
+		wantSet bool
+	}{
+		{`"Files found:120"`, 120, true},
+		{`"Files found: 0"`, 0, true},
+		{`"35"`, 35, true},
+		{`35`, 35, true},
+		{`"Files found:"`, 0, false},
+		{`"Scanning"`, 0, false},
+		{`null`, 0, false},
+	}
+	for _, tt := range tests {
+		var f scanFound
+		if err := json.Unmarshal([]byte(tt.in), &f); err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.in, err)
+			continue
+		}
+		if f.set != tt.wantSet || (f.set && f.value != tt.want) {
+			t.Errorf("%s = %v (set %v), want %v (set %v)", tt.in, f.value, f.set, tt.want, tt.wantSet)
+		}
+	}
+}
+
+// librarySettingsBody is a library list of a paused flow library with a
+// schedule and a scan running, saved with a string priority as older Tdarr does, and a classic
+// library saved before the processing toggles existed. The scan fields are
+// those of Tdarr's library defaults, set by its scanner while a scan runs.
+const librarySettingsBody = `[
+  {
+    "_id": "lib1", "name": "Shows",
+    "processLibrary": false, "processTranscodes": true, "processHealthChecks": false,
+    "folderWatching": true, "folderWatchScanInterval": 30, "priority": "2",
+    "cache": "/mnt/cache/shows", "output": "/mnt/out/shows", "folderToFolderConversion": true,
+    "decisionMaker": {"settingsFlows": true, "settingsPlugin": false},
+    "flowId": "flow-1",
+    "schedule": [
+      {"_id": "Mon:12-13", "checked": true},
+      {"_id": "Mon:13-14", "checked": false},
+      {"_id": "Tue:13-14", "checked": true}
+    ],
+    "scanButtons": false, "scanFound": "Files found:120"
+  },
+  {
+    "_id": "lib2", "name": "Music",
+    "cache": "/mnt/cache/music", "output": "/mnt/out/music",
+    "decisionMaker": {"settingsPlugin": true},
+    "flowId": "flow-1",
+    "priority": "high"
+  }
+]`
+
+// TestCollectLibrarySettings verifies the settings of each library are
+// emitted from the library list, settings it lacks are left out, and the
+// schedule is read in the exporter's time zone.
+func TestCollectLibrarySettings(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	cfg.LabelPolicies.LibraryFolder = config.LabelPolicy{Mode: config.LabelStripPrefix, Prefix: "/mnt/"}
+	api := newSuccessFakeAPI(cfg)
+	api.setResponse(fakeKey{path: cfg.TdarrStatsPath, disc: "LibrarySettingsJSONDB"}, []byte(librarySettingsBody))
+	api.setResponse(fakeKey{path: cfg.TdarrPieStatsPath, disc: "lib2"}, validPieBody())
+	c := newTdarrCollectorWithAPI(cfg, api)
+	// A Monday, 13:30.
+	c.now = func() time.Time { return time.Date(2026, time.October, 12, 13, 30, 0, 0, time.Local) }
+	samples := collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+
+	findOne(t, samples, "tdarr_library_settings_info", map[string]string{
+		"library_id": "lib1", "mode": libraryModeFlows, "flow_id": "flow-1", "cache_folder": "cache/shows", "output_folder": "out/shows",
+	})
+	findOne(t, samples, "tdarr_library_settings_info", map[string]string{
+		"library_id": "lib2", "mode": libraryModeClassic, "flow_id": "", "cache_folder": "cache/music", "output_folder": "",
+	})
+	lib1 := map[string]string{"library_id": "lib1"}
+	tests := []struct {
+		name string
+		want float64
+	}{
+		{"tdarr_library_processing_enabled", 0},
+		{"tdarr_library_transcode_enabled", 1},
+		{"tdarr_library_health_check_enabled", 0},
+		{"tdarr_library_folder_watch_enabled", 1},
+		{"tdarr_library_scan_interval_seconds", 30},
+		{"tdarr_library_priority", 2},
+		{"tdarr_library_schedule_enabled_hours", 2},
+		{"tdarr_library_schedule_active", 0},
+		{"tdarr_library_scan_in_progress", 1},
+		{"tdarr_library_scan_files_found", 120},
+	}
+	for _, tt := range tests {
+		if got := findOne(t, samples, tt.name, lib1).value; got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// lib2 has no toggles, scan interval, numeric priority, schedule or scan:
+	// lib1 alone has each series.
+	for _, name := range []string{
+		"tdarr_library_processing_enabled", "tdarr_library_folder_watch_enabled",
+		"tdarr_library_scan_interval_seconds", "tdarr_library_priority", "tdarr_library_schedule_enabled_hours",
+		"tdarr_library_scan_in_progress", "tdarr_library_scan_files_found",
+	} {
+		if got := countByName(samples, name); got != 1 {
+			t.Errorf("%s series = %d, want 1", name, got)
+		}
+	}
+	if got := findOne(t, samples, "tdarr_up", nil).value; got != 1 {
+		t.Errorf("tdarr_up = %v, want 1", got)
+	}
+}
+
+// TestCollectLibrarySettings_LegacySource verifies the settings and scan
+// series do not depend on get-pies: Tdarr before 2.24.01, whose library stats
+// come from the statistics document, still reports them from the library list.
+func TestCollectLibrarySettings_LegacySource(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	api := newSuccessFakeAPI(cfg)
+	api.setResponse(fakeKey{path: cfg.TdarrStatusPath}, []byte(`{"status":"good","os":"linux","version":"2.17.01","uptime":45}`))
+	api.setResponse(fakeKey{path: cfg.TdarrStatsPath, disc: "StatisticsJSONDB"}, legacyStatsBody())
+	api.setResponse(fakeKey{path: cfg.TdarrStatsPath, disc: "LibrarySettingsJSONDB"}, []byte(librarySettingsBody))
+	c := newTdarrCollectorWithAPI(cfg, api)
+	samples := collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+
+	if got := api.callCount(fakeKey{path: cfg.TdarrPieStatsPath, disc: "lib1"}); got != 0 {
+		t.Fatalf("get-pies requests = %d, want 0 on a legacy server", got)
+	}
+	lib1 := map[string]string{"library_id": "lib1"}
+	findOne(t, samples, "tdarr_library_settings_info", lib1)
+	if got := findOne(t, samples, "tdarr_library_folder_watch_enabled", lib1).value; got != 1 {
+		t.Errorf("tdarr_library_folder_watch_enabled = %v, want 1", got)
+	}
+	if got := findOne(t, samples, "tdarr_library_scan_files_found", lib1).value; got != 120 {
+		t.Errorf("tdarr_library_scan_files_found = %v, want 120", got)
+	}
+	if got := findOne(t, samples, "tdarr_up", nil).value; got != 1 {
+		t.Errorf("tdarr_up = %v, want 1", got)
+	}
+}
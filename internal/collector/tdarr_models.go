@@ -1,5 +1,12 @@
 package collector
 
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
 type TdarrMetricRequest struct {
 	Data TdarrDataRequest `json:"data"`
 }
@@ -46,6 +53,9 @@ type TdarrMetric struct {
 	HealthCheckQueue   int `json:"table4Count"`
 	HealthCheckSuccess int `json:"table5Count"`
 	HealthCheckFailed  int `json:"table6Count"` // includes "cancelled"
+	// Pies is the per-library stats of Tdarr before 2.24.01, which has no get-pies
+	// endpoint: one positional array per library, decoded by legacyPieStats.
+	Pies []json.RawMessage `json:"pies"`
 }
 
 // TdarrServerStatus decodes GET /api/v2/status. Only the fields surfaced as
@@ -64,6 +74,138 @@ type TdarrLibraryInfo struct {
 	Name      string `json:"name"`
 }
 
+// TdarrLibrarySettings is one LibrarySettingsJSONDB document, the library
+// list, decoded as far as the library settings metrics need. The embedded
+// TdarrLibraryInfo is all the pie cache keeps of it.
+type TdarrLibrarySettings struct {
+	TdarrLibraryInfo
+	// The toggles of the library's Process Library tab and its folder
+	// watcher. A document saved by a Tdarr without a toggle leaves it nil, and
+	// its gauge is not emitted.
+	ProcessLibrary      *bool `json:"processLibrary"`
+	ProcessTranscodes   *bool `json:"processTranscodes"`
+	ProcessHealthChecks *bool `json:"processHealthChecks"`
+	FolderWatching      *bool `json:"folderWatching"`
+	// FolderWatchScanInterval is in seconds.
+	FolderWatchScanInterval looseFloat `json:"folderWatchScanInterval"`
+	Priority                looseFloat `json:"priority"`
+	Cache                   string     `json:"cache"`
+	// Output is only used while FolderToFolderConversion is on.
+	Output                   string `json:"output"`
+	FolderToFolderConversion bool   `json:"folderToFolderConversion"`
+	DecisionMaker            struct {
+		SettingsFlows bool `json:"settingsFlows"`
+	} `json:"decisionMaker"`
+	FlowId string `json:"flowId"`
+	// Schedule holds one slot per hour of the week, e.g. "Sun:00-01"; the
+	// library only processes files during the checked ones.
+	Schedule []TdarrScheduleSlot `json:"schedule"`
+	// The state of the library's scans, as the server's scanner keeps it on
+	// the document for the UI: ScanButtons is false while a scan runs, which
+	// disables the library's scan buttons, and ScanFound reads
+	// "Files found:<n>" for the running or last scan. Both are in the
+	// defaults Tdarr gives a new library (scanButtons: true,
+	// scanFound: "Files found:0"). Tdarr records no scan timestamps.
+	ScanButtons *bool     `json:"scanButtons"`
+	ScanFound   scanFound `json:"scanFound"`
+	// PluginIDs is the library's classic plugin stack, run in Priority order.
+	PluginIDs []TdarrLibraryPlugin `json:"pluginIDs"`
+}
+
+// TdarrLibraryPlugin is one plugin of a library's classic plugin stack.
+type TdarrLibraryPlugin struct {
+	Id       string     `json:"_id"`
+	Checked  bool       `json:"checked"`
+	Priority looseFloat `json:"priority"`
+}
+
+type TdarrScheduleSlot struct {
+	Id      string `json:"_id"`
+	Checked bool   `json:"checked"`
+}
+
+// looseFloat decodes a JSON number or a numeric string, as the settings of
+// older libraries store some of their inputs. Anything else leaves it unset
+// rather than failing the whole library list.
+type looseFloat struct {
+	value float64
+	set   bool
+}
+
+func (f *looseFloat) UnmarshalJSON(b []byte) error {
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil
+	}
+	switch v := v.(type) {
+	case float64:
+		f.value, f.set = v, true
+	case string:
+		if n, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+			f.value, f.set = n, true
+		}
+	}
+	return nil
+}
+
+// MarshalJSON writes f back as a number, or null when unset, for the debug log
+// of the response.
+func (f looseFloat) MarshalJSON() ([]byte, error) {
+	if !f.set {
+		return []byte("null"), nil
+	}
+	return json.Marshal(f.value)
+}
+
+// looseTimestamp decodes a Unix time in seconds or milliseconds, as a number
+// or numeric string, or an RFC 3339 string, into seconds. Like looseFloat,
+// anything else leaves it unset, as does 0.
+type looseTimestamp struct {
+	looseFloat
+}
+
+// msThreshold tells Unix milliseconds from seconds: as seconds, it is in the
+// year 5138.
+const msThreshold = 1e11
+
+func (t *looseTimestamp) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		if ts, err := time.Parse(time.RFC3339, strings.TrimSpace(s)); err == nil {
+			t.value, t.set = float64(ts.UnixMilli())/1e3, true
+			return nil
+		}
+	}
+	if err := t.looseFloat.UnmarshalJSON(b); err != nil {
+		return err
+	}
+	if t.value > msThreshold {
+		t.value /= 1e3
+	}
+	t.set = t.set && t.value > 0
+	return nil
+}
+
+// scanFound decodes the "Files found:<n>" progress text of a library's
+// scans into n. Like looseFloat, anything else leaves it unset.
+type scanFound struct {
+	looseFloat
+}
+
+func (f *scanFound) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return f.looseFloat.UnmarshalJSON(b)
+	}
+	if i := strings.LastIndexByte(s, ':'); i >= 0 {
+		s = s[i+1:]
+	}
+	if v, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+		f.value, f.set = v, true
+	}
+	return nil
+}
+
 type TdarrPieStats struct {
 	PieStats    TdarrPieStat `json:"pieStats"`
 	libraryName string
@@ -154,9 +296,12 @@ type TdarrNodeJobs struct {
 }
 
 type TdarrNodeWorkers struct {
-	Id                 string  `json:"_id"`
-	WorkerType         string  `json:"workerType"`
-	FlowWorker         bool    `json:"isFlowWorker"`
+	Id         string `json:"_id"`
+	WorkerType string `json:"workerType"`
+	FlowWorker bool   `json:"isFlowWorker"`
+	// FlowId is the flow a flow worker runs; LastPluginDetails.Id is then the
+	// id of its current step among the flow's FlowPlugins.
+	FlowId             string  `json:"flowId"`
 	Idle               bool    `json:"idle"`
 	File               string  `json:"file"`
 	OriginalfileSizeGb float64 `json:"originalfileSizeInGbytes"`
@@ -186,6 +331,42 @@ type TdarrNodeWorkers struct {
 	EstSizeGb        float64 `json:"estSize"`
 }
 
+// TdarrPluginsRequest searches the plugin catalogue of one source, Community
+// or Local; an empty String matches every plugin.
+type TdarrPluginsRequest struct {
+	Data TdarrPluginsRequestData `json:"data"`
+}
+
+type TdarrPluginsRequestData struct {
+	String     string `json:"string"`
+	PluginType string `json:"pluginType"`
+}
+
+// TdarrPlugin is one plugin of the catalogue, as its exported details name it.
+type TdarrPlugin struct {
+	Id      string `json:"id"`
+	Name    string `json:"Name"`
+	Version string `json:"Version"`
+}
+
+// TdarrFlow is one FlowsJSONDB document, decoded as far as the flows
+// collector needs; the step inputs and the edges between steps are left out.
+type TdarrFlow struct {
+	Id          string            `json:"_id"`
+	Name        string            `json:"name"`
+	FlowPlugins []TdarrFlowPlugin `json:"flowPlugins"`
+}
+
+// TdarrFlowPlugin is one step of a flow.
+type TdarrFlowPlugin struct {
+	// Id is the step's id, unique within the flow.
+	Id string `json:"id"`
+	// Name is the step's label in the flow editor, PluginName the plugin it
+	// runs, e.g. ffmpegCommandStart.
+	Name       string `json:"name"`
+	PluginName string `json:"pluginName"`
+}
+
 type tdarrCacheTotals struct {
 	totalFileCount        int
 	totalTranscodeCount   int
@@ -198,3 +379,87 @@ type tdarrCacheTotals struct {
 	healthCheckSuccess    int
 	healthCheckFailed     int
 }
+
+// TdarrFilesRequest requests a page of one of Tdarr's status tables
+// (/api/v2/client/status-tables), the views of FileJSONDB behind the tables
+// of the Tdarr UI home page.
+type TdarrFilesRequest struct {
+	Data TdarrFilesRequestData `json:"data"`
+}
+
+type TdarrFilesRequestData struct {
+	Start    int             `json:"start"`
+	PageSize int             `json:"pageSize"`
+	Filters  []any           `json:"filters"`
+	Sorts    []any           `json:"sorts"`
+	Opts     TdarrFilesTable `json:"opts"`
+}
+
+type TdarrFilesTable struct {
+	Table string `json:"table"`
+}
+
+// TdarrFilesPage is a page of a status table; TotalCount counts the whole table.
+type TdarrFilesPage struct {
+	Array      []TdarrFile `json:"array"`
+	TotalCount int         `json:"totalCount"`
+}
+
+// TdarrFile is a FileJSONDB document, decoded only as far as the files
+// collector needs. Id is the file path.
+type TdarrFile struct {
+	Id        string `json:"_id"`
+	LibraryId string `json:"DB"`
+	// FileSize is in MB (10^6 bytes), rounded by Tdarr; StatSync.Size, the
+	// size in bytes from the last scan, is preferred when present.
+	FileSize float64 `json:"file_size"`
+	StatSync struct {
+		Size int64 `json:"size"`
+	} `json:"statSync"`
+	// The video stream properties the library pies count files by; empty
+	// for a file without a video stream.
+	VideoCodecName  string `json:"video_codec_name"`
+	Container       string `json:"container"`
+	VideoResolution string `json:"video_resolution"`
+	// FfProbeData is the ffprobe output Tdarr stored for the file, read by
+	// the streams collector.
+	FfProbeData struct {
+		Streams []TdarrStream `json:"streams"`
+	} `json:"ffProbeData"`
+}
+
+// TdarrStream is one stream of a file's ffprobe output.
+type TdarrStream struct {
+	CodecType     string `json:"codec_type"` // video, audio, subtitle, ...
+	ColorTransfer string `json:"color_transfer"`
+	PixFmt        string `json:"pix_fmt"`
+	// BitsPerRawSample is a string in ffprobe's JSON output, e.g. "10".
+	BitsPerRawSample string `json:"bits_per_raw_sample"`
+	RFrameRate       string `json:"r_frame_rate"` // e.g. "24000/1001"
+	AvgFrameRate     string `json:"avg_frame_rate"`
+	Channels         int    `json:"channels"`
+	ChannelLayout    string `json:"channel_layout"`
+	Tags             struct {
+		Language string `json:"language"`
+	} `json:"tags"`
+	Disposition struct {
+		AttachedPic int `json:"attached_pic"`
+	} `json:"disposition"`
+}
+
+// sizeBytes is the file's size in bytes.
+func (f TdarrFile) sizeBytes() float64 {
+	if f.StatSync.Size > 0 {
+		return float64(f.StatSync.Size)
+	}
+	return f.FileSize * 1e6
+}
+
+// TdarrStagedFile is one StagedJSONDB document, a processed file waiting in
+// staging to be accepted or rejected. It carries the fields of the library
+// file, so its library and size read like a TdarrFile's.
+type TdarrStagedFile struct {
+	TdarrFile
+	// CreatedAt is when the file was staged.
+	CreatedAt looseTimestamp `json:"createdAt"`
+}
@@ -56,6 +56,10 @@ func parseWorkerType(api string) (workerType, computeType string) {
 }
 
 type TdarrNodeMetrics struct {
+	// nodes and workers are the collector.nodes and collector.workers toggles:
+	// which of the two groups below descs returns and emitNodeMetrics emits.
+	nodes   bool
+	workers bool
 	// identity / info
 	nodeInfo typedDesc
 	// resource stats
@@ -109,6 +113,8 @@ func NewTdarrNodeMetrics(runConfig config.Config) *TdarrNodeMetrics {
 	instance := prometheus.Labels{"tdarr_instance": runConfig.InstanceName}
 
 	return &TdarrNodeMetrics{
+		nodes:   runConfig.Collectors.Nodes,
+		workers: runConfig.Collectors.Workers,
 		nodeInfo: newGauge(
 			"node_info",
 			"Tdarr node identity information",
@@ -249,10 +255,22 @@ func NewTdarrNodeMetrics(runConfig config.Config) *TdarrNodeMetrics {
 	}
 }
 
-// descs returns the node metrics' descs in Describe order. The TdarrCollector's Describe
-// appends this to its own descs so it never reaches into TdarrNodeMetrics field-by-field —
-// the node metric set is owned and ordered here, in one place.
+// descs returns the descs of the enabled node and worker groups in Describe order. The
+// TdarrCollector's Describe appends this to its own descs so it never reaches into
+// TdarrNodeMetrics field-by-field — the node metric set is owned and ordered here, in one place.
 func (m *TdarrNodeMetrics) descs() []typedDesc {
+	var descs []typedDesc
+	if m.nodes {
+		descs = append(descs, m.nodeDescs()...)
+	}
+	if m.workers {
+		descs = append(descs, m.workerDescs()...)
+	}
+	return descs
+}
+
+// nodeDescs is the node-level group, emitted by emitNode.
+func (m *TdarrNodeMetrics) nodeDescs() []typedDesc {
 	return []typedDesc{
 		m.nodeInfo,
 		m.nodeUptime,
@@ -267,6 +285,12 @@ func (m *TdarrNodeMetrics) descs() []typedDesc {
 		m.nodeWorkerCount,
 		m.nodeWorkerLimit,
 		m.nodeQueueLength,
+	}
+}
+
+// workerDescs is the per-worker group, emitted by emitWorkers.
+func (m *TdarrNodeMetrics) workerDescs() []typedDesc {
+	return []typedDesc{
 		m.nodeWorkerInfo,
 		m.nodeWorkerStatus,
 		m.nodeWorkerPlugin,
@@ -0,0 +1,114 @@
+package collector
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// pluginSources are the plugin catalogues searched by the plugins collector,
+// as the pluginType of the search; the source label is the lowercased name.
+var pluginSources = []string{"Community", "Local"}
+
+// catalogPlugin is a catalogue plugin with the source it was found in.
+type catalogPlugin struct {
+	TdarrPlugin
+	source string
+}
+
+// pluginsSnapshot is the plugin catalogue and the classic plugin stack of
+// each library, as fetched at fetched.
+type pluginsSnapshot struct {
+	plugins []catalogPlugin
+	stacks  map[string][]TdarrLibraryPlugin
+	fetched time.Time
+}
+
+// pluginsCache holds the last fetch of the plugins collector. The catalogue
+// only changes on a plugin update and the stacks on a library edit, so they
+// are fetched again once plugins_refresh_interval has passed, not per scrape.
+type pluginsCache struct {
+	mu   sync.RWMutex
+	snap pluginsSnapshot
+}
+
+func (c *pluginsCache) Read() pluginsSnapshot {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.snap
+}
+
+func (c *pluginsCache) Write(snap pluginsSnapshot) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.snap = snap
+}
+
+// collectPlugins emits the plugin catalogue and the plugin stack of each
+// library, fetching them again when the cached ones are older than the
+// refresh interval. A failed fetch emits nothing and keeps the cache, so the
+// next scrape retries it.
+func (c *TdarrCollector) collectPlugins(ctx context.Context, ch chan<- prometheus.Metric) error {
+	snap := c.pluginsCache.Read()
+	if snap.fetched.IsZero() || c.now().Sub(snap.fetched) >= c.pluginsRefresh {
+		start := time.Now()
+		fresh, err := c.fetchPlugins(ctx)
+		c.emitPhase(ch, phasePlugins, start)
+		if err != nil {
+			return err
+		}
+		fresh.fetched = c.now()
+		c.pluginsCache.Write(fresh)
+		snap = fresh
+	}
+	for _, p := range snap.plugins {
+		ch <- c.pluginInfo.mustNewConstMetric(1, p.Id, p.Name, p.source, p.Version)
+	}
+	for libId, stack := range snap.stacks {
+		for i, p := range stack {
+			ch <- c.libraryPluginStack.mustNewConstMetric(1, libId, p.Id, strconv.Itoa(i+1), strconv.FormatBool(p.Checked))
+		}
+	}
+	return nil
+}
+
+// fetchPlugins fetches the library list, for the plugin stacks, and the
+// catalogue of each plugin source. A plugin listed twice in a source is kept
+// once. Each stack is sorted into run order, by priority.
+func (c *TdarrCollector) fetchPlugins(ctx context.Context) (pluginsSnapshot, error) {
+	libs := []TdarrLibrarySettings{}
+	if err := c.httpReqHelper(ctx, c.statsPath, getGeneralReqPayload("library"), &libs); err != nil {
+		return pluginsSnapshot{}, fmt.Errorf("get library details: %w", err)
+	}
+	snap := pluginsSnapshot{stacks: make(map[string][]TdarrLibraryPlugin, len(libs))}
+	for _, lib := range libs {
+		stack := slices.Clone(lib.PluginIDs)
+		slices.SortStableFunc(stack, func(a, b TdarrLibraryPlugin) int {
+			return cmp.Compare(a.Priority.value, b.Priority.value)
+		})
+		snap.stacks[lib.LibraryId] = stack
+	}
+	for _, source := range pluginSources {
+		found := []TdarrPlugin{}
+		payload := TdarrPluginsRequest{Data: TdarrPluginsRequestData{PluginType: source}}
+		if err := c.httpReqHelper(ctx, c.pluginsPath, payload, &found); err != nil {
+			return pluginsSnapshot{}, fmt.Errorf("get %s plugins: %w", strings.ToLower(source), err)
+		}
+		seen := make(map[string]bool, len(found))
+		for _, p := range found {
+			if p.Id == "" || seen[p.Id] {
+				continue
+			}
+			seen[p.Id] = true
+			snap.plugins = append(snap.plugins, catalogPlugin{TdarrPlugin: p, source: strings.ToLower(source)})
+		}
+	}
+	return snap, nil
+}
@@ -0,0 +1,116 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// pluginLibrariesBody is a library list with a classic plugin stack stored
+// out of run order, one plugin unchecked, and a library without one.
+const pluginLibrariesBody = `[
+  {
+    "_id": "lib1", "name": "Shows",
+    "pluginIDs": [
+      {"_id": "Tdarr_Plugin_lmg1_Reorder_Streams", "checked": false, "priority": 2, "source": "Community"},
+      {"_id": "Tdarr_Plugin_MC93_Migz1FFMPEG", "checked": true, "priority": 0, "source": "Community"},
+      {"_id": "Local_Plugin_Tag", "checked": true, "priority": 1, "source": "Local"}
+    ]
+  },
+  {"_id": "lib2", "name": "Music"}
+]`
+
+const communityPluginsBody = `[
+  {"id": "Tdarr_Plugin_MC93_Migz1FFMPEG", "Name": "Migz Transcode Using Nvidia GPU & FFMPEG", "Version": "3.11"},
+  {"id": "Tdarr_Plugin_lmg1_Reorder_Streams", "Name": "Re-order all streams V2", "Version": "1.00"},
+  {"id": "Tdarr_Plugin_MC93_Migz1FFMPEG", "Name": "Migz Transcode Using Nvidia GPU & FFMPEG", "Version": "3.11"}
+]`
+
+const localPluginsBody = `[{"id": "Local_Plugin_Tag", "Name": "Tag", "Version": "0.1"}]`
+
+func newPluginsFakeAPI(t *testing.T) (*fakeTdarrAPI, *TdarrCollector) {
+	t.Helper()
+	cfg := newTestConfig(t)
+	cfg.Collectors.Plugins = true
+	api := newSuccessFakeAPI(cfg)
+	api.setResponse(fakeKey{path: cfg.TdarrStatsPath, disc: "LibrarySettingsJSONDB"}, []byte(pluginLibrariesBody))
+	api.setResponse(fakeKey{path: cfg.TdarrPieStatsPath, disc: "lib2"}, validPieBody())
+	api.setResponse(fakeKey{path: cfg.TdarrPluginsPath, disc: "Community"}, []byte(communityPluginsBody))
+	api.setResponse(fakeKey{path: cfg.TdarrPluginsPath, disc: "Local"}, []byte(localPluginsBody))
+	return api, newTdarrCollectorWithAPI(cfg, api)
+}
+
+// TestCollectPlugins verifies the catalogue of both sources, each plugin
+// once, and the stacks in run order.
+func TestCollectPlugins(t *testing.T) {
+	t.Parallel()
+	_, c := newPluginsFakeAPI(t)
+	samples := collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+
+	findOne(t, samples, "tdarr_plugin_info", map[string]string{
+		"plugin_id": "Tdarr_Plugin_MC93_Migz1FFMPEG", "plugin_name": "Migz Transcode Using Nvidia GPU & FFMPEG", "source": "community", "version": "3.11",
+	})
+	findOne(t, samples, "tdarr_plugin_info", map[string]string{
+		"plugin_id": "Local_Plugin_Tag", "plugin_name": "Tag", "source": "local", "version": "0.1",
+	})
+	if got := countByName(samples, "tdarr_plugin_info"); got != 3 {
+		t.Errorf("plugin info series = %d, want 3", got)
+	}
+
+	for _, want := range []map[string]string{
+		{"plugin_id": "Tdarr_Plugin_MC93_Migz1FFMPEG", "position": "1", "enabled": "true"},
+		{"plugin_id": "Local_Plugin_Tag", "position": "2", "enabled": "true"},
+		{"plugin_id": "Tdarr_Plugin_lmg1_Reorder_Streams", "position": "3", "enabled": "false"},
+	} {
+		want["library_id"] = "lib1"
+		findOne(t, samples, "tdarr_library_plugin_stack", want)
+	}
+	if got := countByName(samples, "tdarr_library_plugin_stack"); got != 3 {
+		t.Errorf("library plugin stack series = %d, want 3", got)
+	}
+	if got := findOne(t, samples, "tdarr_scrape_collector_success", map[string]string{"collector": subsystemPlugins}).value; got != 1 {
+		t.Errorf("plugins collector success = %v, want 1", got)
+	}
+	findOne(t, samples, "tdarr_exporter_scrape_phase_duration_seconds", map[string]string{"phase": phasePlugins})
+}
+
+// TestCollectPlugins_Cache verifies the plugins are served from the cache
+// until the refresh interval has passed.
+func TestCollectPlugins_Cache(t *testing.T) {
+	t.Parallel()
+	api, c := newPluginsFakeAPI(t)
+	now := time.Date(2026, time.October, 12, 13, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	collect := func() []sample {
+		return collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+	}
+	collect()
+
+	// A failing catalogue is not asked for within the interval.
+	api.setError(fakeKey{path: c.pluginsPath, disc: "Community"}, statErr{"plugins failed"})
+	now = now.Add(59 * time.Minute)
+	samples := collect()
+	if got := countByName(samples, "tdarr_plugin_info"); got != 3 {
+		t.Errorf("cached plugin info series = %d, want 3", got)
+	}
+	for _, s := range samples {
+		if s.fqName == "tdarr_exporter_scrape_phase_duration_seconds" && s.labels["phase"] == phasePlugins {
+			t.Error("plugins phase timed on a cached scrape")
+		}
+	}
+
+	// Past it, the failed refetch fails the collector.
+	now = now.Add(time.Minute)
+	samples = collect()
+	if got := findOne(t, samples, "tdarr_scrape_collector_success", map[string]string{"collector": subsystemPlugins}).value; got != 0 {
+		t.Errorf("plugins collector success = %v, want 0", got)
+	}
+	if hasName(samples, "tdarr_plugin_info") || hasName(samples, "tdarr_library_plugin_stack") {
+		t.Error("plugin series emitted after a failed plugins request")
+	}
+	if got := findOne(t, samples, "tdarr_up", nil).value; got != 0 {
+		t.Errorf("tdarr_up = %v, want 0", got)
+	}
+}
@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// pollSnapshot is the result of one background poll: every metric the scrape
+// emitted, tdarr_up included, ready to be served as is.
+type pollSnapshot struct {
+	metrics []prometheus.Metric
+	// taken is when the poll finished; lastSuccess is when the most recent
+	// poll with tdarr_up 1 finished, zero if none has yet.
+	taken       time.Time
+	lastSuccess time.Time
+}
+
+// Run polls Tdarr every poll interval, starting right away, until ctx is
+// cancelled; Collect serves the latest snapshot meanwhile. It returns at once
+// when the collector scrapes live (poll_interval 0).
+func (c *TdarrCollector) Run(ctx context.Context) {
+	if c.pollInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+	for {
+		c.poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll scrapes Tdarr once and stores the result as the served snapshot. A
+// poll cut short by ctx (shutdown or reload) is dropped, so the previous
+// snapshot keeps being served rather than an aborted one.
+func (c *TdarrCollector) poll(ctx context.Context) {
+	ch := make(chan prometheus.Metric)
+	var up bool
+	go func() {
+		defer close(ch)
+		up = c.scrape(ctx, ch)
+	}()
+	var metrics []prometheus.Metric
+	for m := range ch {
+		metrics = append(metrics, m)
+	}
+	if ctx.Err() != nil {
+		return
+	}
+
+	snap := &pollSnapshot{metrics: metrics, taken: c.now()}
+	if up {
+		snap.lastSuccess = snap.taken
+	} else if prev := c.snapshot.Load(); prev != nil {
+		snap.lastSuccess = prev.lastSuccess
+	}
+	c.snapshot.Store(snap)
+	c.logger.Debug().Bool("up", up).Int("metrics", len(metrics)).Msg("Refreshed metrics snapshot")
+}
+
+// collectSnapshot serves the latest snapshot and its age. Before the first
+// poll has finished there is nothing to serve, which is reported as tdarr_up 0.
+func (c *TdarrCollector) collectSnapshot(ch chan<- prometheus.Metric) {
+	snap := c.snapshot.Load()
+	if snap == nil {
+		ch <- c.upMetric.mustNewConstMetric(0.0)
+		return
+	}
+	for _, m := range snap.metrics {
+		ch <- m
+	}
+	ch <- c.snapshotAge.mustNewConstMetric(c.now().Sub(snap.taken).Seconds())
+	if !snap.lastSuccess.IsZero() {
+		ch <- c.lastPollSuccess.mustNewConstMetric(float64(snap.lastSuccess.UnixNano()) / 1e9)
+	}
+}
@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// gaugeValue returns the value of the single series of the named family, and
+// whether the family was present.
+func gaugeValue(mfs []*dto.MetricFamily, name string) (float64, bool) {
+	for _, mf := range mfs {
+		if mf.GetName() == name {
+			return mf.GetMetric()[0].GetGauge().GetValue(), true
+		}
+	}
+	return 0, false
+}
+
+// TestPoll_ServesSnapshot verifies that with a poll interval Collect never
+// contacts Tdarr: it serves the latest poll, its age and the time of the last
+// successful poll.
+func TestPoll_ServesSnapshot(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	cfg.PollInterval = time.Minute
+	api := newSuccessFakeAPI(cfg)
+	c := newTdarrCollectorWithAPI(cfg, api)
+	now := time.Unix(1_700_000_000, 0)
+	c.now = func() time.Time { return now }
+	statsKey := fakeKey{path: cfg.TdarrStatsPath, disc: "StatisticsJSONDB"}
+
+	mfs := gatherMetricFamilies(t, c)
+	if up := upValueFromFamilies(mfs); up != 0 {
+		t.Errorf("tdarr_up before the first poll = %v, want 0", up)
+	}
+	if hasMetricFamily(mfs, "tdarr_exporter_snapshot_age_seconds") {
+		t.Error("snapshot age emitted before the first poll")
+	}
+	if got := api.callCount(statsKey); got != 0 {
+		t.Errorf("stats requests before the first poll = %d, want 0", got)
+	}
+
+	c.poll(context.Background())
+	now = now.Add(5 * time.Second)
+	for range 2 {
+		mfs = gatherMetricFamilies(t, c)
+	}
+	if got := api.callCount(statsKey); got != 1 {
+		t.Errorf("stats requests after one poll and two scrapes = %d, want 1", got)
+	}
+	if up := upValueFromFamilies(mfs); up != 1 {
+		t.Errorf("tdarr_up = %v, want 1", up)
+	}
+	if !hasMetricFamily(mfs, "tdarr_files") {
+		t.Error("tdarr_files missing from the snapshot")
+	}
+	if got, _ := gaugeValue(mfs, "tdarr_exporter_snapshot_age_seconds"); got != 5 {
+		t.Errorf("snapshot age = %v, want 5", got)
+	}
+	if got, _ := gaugeValue(mfs, "tdarr_exporter_last_poll_success_timestamp_seconds"); got != 1_700_000_000 {
+		t.Errorf("last poll success = %v, want 1700000000", got)
+	}
+
+	// A failed poll replaces the snapshot but keeps the last success time.
+	api.setError(statsKey, statErr{"stats fetch failed"})
+	c.poll(context.Background())
+	mfs = gatherMetricFamilies(t, c)
+	if up := upValueFromFamilies(mfs); up != 0 {
+		t.Errorf("tdarr_up after a failed poll = %v, want 0", up)
+	}
+	if got, _ := gaugeValue(mfs, "tdarr_exporter_snapshot_age_seconds"); got != 0 {
+		t.Errorf("snapshot age after a failed poll = %v, want 0", got)
+	}
+	if got, _ := gaugeValue(mfs, "tdarr_exporter_last_poll_success_timestamp_seconds"); got != 1_700_000_000 {
+		t.Errorf("last poll success after a failed poll = %v, want 1700000000", got)
+	}
+
+	// A poll cut short by shutdown keeps the previous snapshot.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	before := c.snapshot.Load()
+	c.poll(ctx)
+	if c.snapshot.Load() != before {
+		t.Error("cancelled poll replaced the snapshot")
+	}
+}
+
+// TestRun_StopsOnCancel verifies Run polls right away and returns once its
+// context is cancelled, and returns at once when polling is off.
+func TestRun_StopsOnCancel(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	cfg.PollInterval = time.Hour
+	c := newTdarrCollectorWithAPI(cfg, newSuccessFakeAPI(cfg))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for c.snapshot.Load() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("Run did not take a snapshot")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after its context was cancelled")
+	}
+
+	live := newTdarrCollectorWithAPI(newTestConfig(t), newSuccessFakeAPI(cfg))
+	live.Run(context.Background())
+	if live.snapshot.Load() != nil {
+		t.Error("Run without a poll interval took a snapshot")
+	}
+}
@@ -0,0 +1,148 @@
+package collector
+
+import (
+	"context"
+	"sync"
+
+	"github.com/homeylab/tdarr-exporter/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// probeKey identifies one cached /probe collector. The module is part of the key
+// because two modules probing the same url carry different credentials and must
+// not share a client.
+type probeKey struct {
+	target string
+	module string
+}
+
+// probeEntry is one cached /probe target: its collector (which owns the target's
+// own TdarrLibStatsCache) and a registry dedicated to that target's HTTP client
+// metrics, so a probe response never mixes in another target's series. The
+// collector is not registered there: the /probe handler collects it under the
+// request's context, so the probe honours Prometheus' scrape timeout.
+type probeEntry struct {
+	collector *TdarrCollector
+	registry  *prometheus.Registry
+	// lastUsed is a logical clock tick (not wall time) recording when the entry
+	// was last handed out; the smallest tick is evicted first.
+	lastUsed uint64
+}
+
+// TdarrProbePool builds and caches one TdarrCollector per (target, module) pair
+// for the /probe endpoint. Caching is what makes probing viable at all: each
+// collector keeps its own pie-stats cache, so repeated probes of a target reuse
+// it exactly like repeated /metrics scrapes do. The pool is bounded by
+// ProbeMaxTargets; beyond it the least recently probed target is evicted, so a
+// caller cycling through arbitrary target values cannot grow memory unbounded.
+type TdarrProbePool struct {
+	baseCtx context.Context
+	base    config.Config
+	// newCollector is the construction seam; production wires NewTdarrCollector,
+	// tests inject a constructor backed by fakeTdarrAPI.
+	newCollector func(ctx context.Context, runConfig config.Config, opts ...TdarrCollectorOption) (*TdarrCollector, error)
+
+	mu      sync.Mutex
+	entries map[probeKey]*probeEntry
+	clock   uint64
+	// generation counts Resets, so a collector built from a replaced config is
+	// not cached after the fact.
+	generation uint64
+}
+
+// NewTdarrProbePool returns a pool deriving each target's config from base via
+// config.ProbeConfig. ctx is the parent of every probe collector's requests, the
+// same shutdown-cancellable context main hands the primary collector.
+func NewTdarrProbePool(ctx context.Context, base config.Config) *TdarrProbePool {
+	return &TdarrProbePool{
+		baseCtx:      ctx,
+		base:         base,
+		newCollector: NewTdarrCollector,
+		entries:      make(map[probeKey]*probeEntry),
+	}
+}
+
+// Gatherer returns the client-metrics registry and the collector for target
+// probed with module, building and caching the collector on first use. A bad target url or unknown module is
+// returned as an error (wrapping config.ErrUnknownProbeModule for the latter)
+// before anything is cached.
+func (p *TdarrProbePool) Gatherer(target, module string) (prometheus.Gatherer, ScrapeCollector, error) {
+	if module == "" {
+		module = config.DefaultProbeModule
+	}
+	key := probeKey{target: target, module: module}
+
+	p.mu.Lock()
+	p.clock++
+	if entry, ok := p.entries[key]; ok {
+		entry.lastUsed = p.clock
+		p.mu.Unlock()
+		return entry.registry, entry.collector, nil
+	}
+	base, generation := p.base, p.generation
+	p.mu.Unlock()
+
+	// Construction reads the target's CA, client certificate and api key files,
+	// so it runs outside p.mu: a slow build must not hold up probes of every
+	// other target.
+	runConfig, err := base.ProbeConfig(target, module)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The target's HTTP client metrics go in its own registry, so they are
+	// served with its probes and evicted with it.
+	registry := prometheus.NewRegistry()
+	c, err := p.newCollector(p.baseCtx, runConfig, WithRegisterer(registry))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// A concurrent probe of the same target may have cached its collector while
+	// this one was being built; hand out that one so the target keeps a single
+	// pie cache.
+	if entry, ok := p.entries[key]; ok {
+		entry.lastUsed = p.clock
+		return entry.registry, entry.collector, nil
+	}
+	// A Reset during construction means runConfig came from the replaced config:
+	// serve this probe with it, like any probe already in flight, but leave the
+	// next probe to build from the new one.
+	if p.generation != generation {
+		return registry, c, nil
+	}
+	p.evictLocked(p.base.ProbeMaxTargets - 1)
+	p.entries[key] = &probeEntry{collector: c, registry: registry, lastUsed: p.clock}
+	c.logger.Info().Str("target", target).Str("module", module).Msg("Created collector for probe target")
+	return registry, c, nil
+}
+
+// Reset replaces the config targets are derived from and drops every cached
+// probe collector, so a config reload's module credentials and settings apply to
+// the next probe of each target. The dropped collectors' pie caches go with
+// them; a probe in flight keeps the registry it already holds.
+func (p *TdarrProbePool) Reset(base config.Config) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.base = base
+	p.generation++
+	clear(p.entries)
+}
+
+// evictLocked drops least recently used entries until at most keep remain.
+// Callers must hold p.mu.
+func (p *TdarrProbePool) evictLocked(keep int) {
+	for len(p.entries) > keep {
+		var oldestKey probeKey
+		var oldest *probeEntry
+		for k, e := range p.entries {
+			if oldest == nil || e.lastUsed < oldest.lastUsed {
+				oldestKey, oldest = k, e
+			}
+		}
+		oldest.collector.logger.Info().Str("target", oldestKey.target).Str("module", oldestKey.module).
+			Msg("Evicting least recently probed target")
+		delete(p.entries, oldestKey)
+	}
+}
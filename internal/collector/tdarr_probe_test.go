@@ -0,0 +1,216 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/homeylab/tdarr-exporter/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// newTestProbePool builds a pool whose collectors are backed by one shared
+// fakeTdarrAPI (the fake routes on path, not host, so every target answers with
+// the same fixtures) and records every config a collector was built from.
+func newTestProbePool(t *testing.T, maxTargets int) (*TdarrProbePool, *[]config.Config) {
+	t.Helper()
+	base := newTestConfig(t)
+	base.ProbeMaxTargets = maxTargets
+	base.ProbeModules = map[string]config.ProbeModule{
+		config.DefaultProbeModule: {VerifySsl: true},
+		"tdarr-4k":                {ApiKey: "module-key", VerifySsl: false},
+	}
+	api := newSuccessFakeAPI(base)
+	built := &[]config.Config{}
+	pool := NewTdarrProbePool(context.Background(), base)
+	pool.newCollector = func(ctx context.Context, runConfig config.Config, _ ...TdarrCollectorOption) (*TdarrCollector, error) {
+		*built = append(*built, runConfig)
+		return newTdarrCollectorWithAPI(runConfig, api), nil
+	}
+	return pool, built
+}
+
+// instanceLabel returns the tdarr_instance label of the first tdarr_up sample.
+func instanceLabel(mfs []*dto.MetricFamily) string {
+	for _, mf := range mfs {
+		if mf.GetName() != "tdarr_up" || len(mf.GetMetric()) == 0 {
+			continue
+		}
+		for _, lp := range mf.GetMetric()[0].GetLabel() {
+			if lp.GetName() == "tdarr_instance" {
+				return lp.GetValue()
+			}
+		}
+	}
+	return ""
+}
+
+// TestProbePool_PerTargetCollector verifies each target gets its own collector,
+// labeled with the target hostname and credentialed from the named module, and
+// that a repeat probe reuses the cached collector (and with it the target's own
+// pie-stats cache) instead of building a new one.
+func TestProbePool_PerTargetCollector(t *testing.T) {
+	t.Parallel()
+	pool, built := newTestProbePool(t, 4)
+
+	g1, c1, err := pool.Gatherer("http://tdarr-a.lan:8265", "tdarr-4k")
+	if err != nil {
+		t.Fatalf("Gatherer(a): %v", err)
+	}
+	if _, _, err := pool.Gatherer("tdarr-b.lan", ""); err != nil {
+		t.Fatalf("Gatherer(b): %v", err)
+	}
+	again, _, err := pool.Gatherer("http://tdarr-a.lan:8265", "tdarr-4k")
+	if err != nil {
+		t.Fatalf("Gatherer(a) again: %v", err)
+	}
+	if again != g1 {
+		t.Error("repeat probe of the same target/module built a new registry; want the cached one")
+	}
+	if len(*built) != 2 {
+		t.Fatalf("collectors built = %d, want 2", len(*built))
+	}
+
+	a, b := (*built)[0], (*built)[1]
+	if a.InstanceName != "tdarr-a.lan:8265" || a.ApiKey != "module-key" || a.VerifySsl {
+		t.Errorf("target a config = {instance %q, key %q, verify %v}, want {tdarr-a.lan:8265, module-key, false}", a.InstanceName, a.ApiKey, a.VerifySsl)
+	}
+	// The default module must never inherit the exporter's own api key.
+	if b.InstanceName != "tdarr-b.lan" || b.ApiKey != "" || !b.VerifySsl {
+		t.Errorf("target b config = {instance %q, key %q, verify %v}, want {tdarr-b.lan, \"\", true}", b.InstanceName, b.ApiKey, b.VerifySsl)
+	}
+
+	// The /probe handler gathers the collector next to the target's registry.
+	scrapeReg := prometheus.NewRegistry()
+	scrapeReg.MustRegister(c1.(*TdarrCollector))
+	mfs, err := prometheus.Gatherers{g1, scrapeReg}.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	if got := upValueFromFamilies(mfs); got != 1.0 {
+		t.Errorf("tdarr_up = %v, want 1", got)
+	}
+	if got := instanceLabel(mfs); got != "tdarr-a.lan:8265" {
+		t.Errorf("tdarr_instance = %q, want tdarr-a.lan:8265", got)
+	}
+}
+
+// TestProbePool_Errors verifies bad requests are rejected before anything is
+// cached: an unknown module wraps config.ErrUnknownProbeModule, and a host-less
+// target fails url validation.
+func TestProbePool_Errors(t *testing.T) {
+	t.Parallel()
+	pool, built := newTestProbePool(t, 4)
+
+	if _, _, err := pool.Gatherer("tdarr.lan", "missing"); !errors.Is(err, config.ErrUnknownProbeModule) {
+		t.Errorf("unknown module err = %v, want ErrUnknownProbeModule", err)
+	}
+	if _, _, err := pool.Gatherer("https://", ""); err == nil {
+		t.Error("host-less target: want error, got nil")
+	}
+	if len(*built) != 0 || len(pool.entries) != 0 {
+		t.Errorf("rejected probes built %d collectors / cached %d entries, want 0", len(*built), len(pool.entries))
+	}
+}
+
+// TestProbePool_EvictsLeastRecentlyUsed verifies the pool stays within
+// ProbeMaxTargets by evicting the target probed longest ago.
+func TestProbePool_EvictsLeastRecentlyUsed(t *testing.T) {
+	t.Parallel()
+	pool, _ := newTestProbePool(t, 2)
+
+	for _, target := range []string{"a.lan", "b.lan", "a.lan", "c.lan"} {
+		if _, _, err := pool.Gatherer(target, ""); err != nil {
+			t.Fatalf("Gatherer(%s): %v", target, err)
+		}
+	}
+	if len(pool.entries) != 2 {
+		t.Fatalf("cached entries = %d, want 2", len(pool.entries))
+	}
+	module := config.DefaultProbeModule
+	if _, ok := pool.entries[probeKey{target: "b.lan", module: module}]; ok {
+		t.Error("b.lan (least recently used) was not evicted")
+	}
+	for _, target := range []string{"a.lan", "c.lan"} {
+		if _, ok := pool.entries[probeKey{target: target, module: module}]; !ok {
+			t.Errorf("%s evicted, want it cached", target)
+		}
+	}
+}
+
+// TestProbePool_ResetDropsCachedTargets verifies Reset applies the new base
+// config to the next probe of an already-cached target by rebuilding its
+// collector.
+func TestProbePool_ResetDropsCachedTargets(t *testing.T) {
+	t.Parallel()
+	pool, built := newTestProbePool(t, 4)
+
+	if _, _, err := pool.Gatherer("tdarr-a.lan", "tdarr-4k"); err != nil {
+		t.Fatalf("Gatherer: %v", err)
+	}
+	next := pool.base
+	next.ProbeModules = map[string]config.ProbeModule{
+		config.DefaultProbeModule: {VerifySsl: true},
+		"tdarr-4k":                {ApiKey: "rotated-key", VerifySsl: true},
+	}
+	pool.Reset(next)
+	if _, _, err := pool.Gatherer("tdarr-a.lan", "tdarr-4k"); err != nil {
+		t.Fatalf("Gatherer after Reset: %v", err)
+	}
+
+	if len(*built) != 2 {
+		t.Fatalf("collectors built: want 2 (rebuilt after Reset), got %d", len(*built))
+	}
+	if got := (*built)[1].ApiKey; got != "rotated-key" {
+		t.Errorf("rebuilt collector ApiKey = %q, want rotated-key", got)
+	}
+}
+
+// TestProbePool_SlowBuildDoesNotBlockCachedTargets verifies a target whose
+// collector is still being built does not hold up probes of a target that is
+// already cached.
+func TestProbePool_SlowBuildDoesNotBlockCachedTargets(t *testing.T) {
+	t.Parallel()
+	pool, _ := newTestProbePool(t, 4)
+	if _, _, err := pool.Gatherer("fast.lan", ""); err != nil {
+		t.Fatalf("Gatherer(fast): %v", err)
+	}
+
+	building, release := make(chan struct{}), make(chan struct{})
+	build := pool.newCollector
+	pool.newCollector = func(ctx context.Context, runConfig config.Config, opts ...TdarrCollectorOption) (*TdarrCollector, error) {
+		close(building)
+		<-release
+		return build(ctx, runConfig, opts...)
+	}
+	slow := make(chan error, 1)
+	go func() {
+		_, _, err := pool.Gatherer("slow.lan", "")
+		slow <- err
+	}()
+	<-building
+
+	cached := make(chan error, 1)
+	go func() {
+		_, _, err := pool.Gatherer("fast.lan", "")
+		cached <- err
+	}()
+	select {
+	case err := <-cached:
+		if err != nil {
+			t.Errorf("Gatherer(fast) during slow build: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("probe of a cached target waited on another target's collector construction")
+	}
+
+	close(release)
+	if err := <-slow; err != nil {
+		t.Fatalf("Gatherer(slow): %v", err)
+	}
+	if len(pool.entries) != 2 {
+		t.Errorf("cached entries = %d, want 2", len(pool.entries))
+	}
+}
@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// stagedLibrary totals the staged files of one library.
+type stagedLibrary struct {
+	files int
+	bytes float64
+	// oldest is when the oldest file was staged, in seconds since the epoch;
+	// 0 when no file records it.
+	oldest float64
+}
+
+// collectStaged fetches the staged files and emits their count, size and
+// the age of the oldest one, per library. Every library of libs emits its
+// count and size, 0 with nothing staged, so alerts on them see an empty
+// staging section rather than no series. Without the library list only the
+// libraries with staged files are emitted; the failed list is the library
+// collector's to report.
+func (c *TdarrCollector) collectStaged(ctx context.Context, ch chan<- prometheus.Metric, libs *libraryList) error {
+	all := []TdarrStagedFile{}
+	start := time.Now()
+	err := c.httpReqHelper(ctx, c.statsPath, getGeneralReqPayload("staged"), &all)
+	c.emitPhase(ch, phaseStaged, start)
+	if err != nil {
+		return fmt.Errorf("get staged files: %w", err)
+	}
+	known, err := libs.get(ctx, c, ch)
+	if err != nil {
+		c.logger.Warn().Err(err).Msg("No library list; staged series left out for libraries with nothing staged")
+	}
+	staged := make(map[string]*stagedLibrary, len(known))
+	for _, lib := range known {
+		staged[lib.LibraryId] = &stagedLibrary{}
+	}
+	for _, f := range all {
+		lib, ok := staged[f.LibraryId]
+		if !ok {
+			lib = &stagedLibrary{}
+			staged[f.LibraryId] = lib
+		}
+		lib.files++
+		lib.bytes += f.sizeBytes()
+		if f.CreatedAt.set && (lib.oldest == 0 || f.CreatedAt.value < lib.oldest) {
+			lib.oldest = f.CreatedAt.value
+		}
+	}
+	now := float64(c.now().UnixMilli()) / 1000
+	for libId, lib := range staged {
+		ch <- c.stagedFiles.mustNewConstMetric(float64(lib.files), libId)
+		ch <- c.stagedBytes.mustNewConstMetric(lib.bytes, libId)
+		if lib.oldest > 0 {
+			// A clock behind Tdarr's reads as just staged, not a negative age.
+			ch <- c.stagedOldestAge.mustNewConstMetric(max(now-lib.oldest, 0), libId)
+		}
+	}
+	return nil
+}
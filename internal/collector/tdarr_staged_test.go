@@ -0,0 +1,135 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// stagedBody has two staged files of lib1, one sized only in MB and one
+// without a staging time, and a file of lib2 staged in the exporter's future.
+const stagedBody = `[
+  {"_id": "/media/shows/a.mkv", "DB": "lib1", "file_size": 1500, "createdAt": 1760000000000},
+  {"_id": "/media/shows/b.mkv", "DB": "lib1", "file_size": 2, "statSync": {"size": 2500000}},
+  {"_id": "/media/music/c.flac", "DB": "lib2", "file_size": 30, "createdAt": "2025-10-09T09:00:00Z"}
+]`
+
+// TestCollectStaged verifies the staged files are totalled per library, with
+// the age of the oldest file that records when it was staged.
+func TestCollectStaged(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	cfg.Collectors.Staged = true
+	api := newSuccessFakeAPI(cfg)
+	api.setResponse(fakeKey{path: cfg.TdarrStatsPath, disc: "StagedJSONDB"}, []byte(stagedBody))
+	c := newTdarrCollectorWithAPI(cfg, api)
+	c.now = func() time.Time { return time.Unix(1760000300, 0) }
+	samples := collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+
+	tests := []struct {
+		name  string
+		libId string
+		want  float64
+	}{
+		{"tdarr_library_staged_files", "lib1", 2},
+		{"tdarr_library_staged_bytes", "lib1", 1500e6 + 2500000},
+		{"tdarr_library_staged_oldest_age_seconds", "lib1", 300},
+		{"tdarr_library_staged_files", "lib2", 1},
+		{"tdarr_library_staged_bytes", "lib2", 30e6},
+		{"tdarr_library_staged_oldest_age_seconds", "lib2", 0},
+	}
+	for _, tt := range tests {
+		if got := findOne(t, samples, tt.name, map[string]string{"library_id": tt.libId}).value; got != tt.want {
+			t.Errorf("%s{library_id=%q} = %v, want %v", tt.name, tt.libId, got, tt.want)
+		}
+	}
+	if got := findOne(t, samples, "tdarr_scrape_collector_success", map[string]string{"collector": subsystemStaged}).value; got != 1 {
+		t.Errorf("staged collector success = %v, want 1", got)
+	}
+	findOne(t, samples, "tdarr_exporter_scrape_phase_duration_seconds", map[string]string{"phase": phaseStaged})
+}
+
+// TestCollectStaged_Fails verifies a failed staged request fails only the
+// staged collector.
+func TestCollectStaged_Fails(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	cfg.Collectors.Staged = true
+	api := newSuccessFakeAPI(cfg)
+	api.setError(fakeKey{path: cfg.TdarrStatsPath, disc: "StagedJSONDB"}, statErr{"staged failed"})
+	c := newTdarrCollectorWithAPI(cfg, api)
+	samples := collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+
+	want := map[string]float64{subsystemLibrary: 1, subsystemNodes: 1, subsystemStaged: 0}
+	for collector, ok := range want {
+		if got := findOne(t, samples, "tdarr_scrape_collector_success", map[string]string{"collector": collector}).value; got != ok {
+			t.Errorf("%s collector success = %v, want %v", collector, got, ok)
+		}
+	}
+	if got := findOne(t, samples, "tdarr_up", nil).value; got != 0 {
+		t.Errorf("tdarr_up = %v, want 0", got)
+	}
+	if hasName(samples, "tdarr_library_staged_files") {
+		t.Error("staged series emitted after a failed staged request")
+	}
+}
+
+// TestCollectStaged_Empty verifies every library of the library list reports
+// an empty staging section as 0, with or without the library collector, which
+// shares the one library list request of the scrape.
+func TestCollectStaged_Empty(t *testing.T) {
+	t.Parallel()
+	for _, library := range []bool{true, false} {
+		cfg := newTestConfig(t)
+		cfg.Collectors.Staged = true
+		cfg.Collectors.Library = library
+		api := newSuccessFakeAPI(cfg)
+		api.setResponse(fakeKey{path: cfg.TdarrStatsPath, disc: "StagedJSONDB"}, []byte(`[]`))
+		c := newTdarrCollectorWithAPI(cfg, api)
+		samples := collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+
+		lib1 := map[string]string{"library_id": "lib1"}
+		for _, name := range []string{"tdarr_library_staged_files", "tdarr_library_staged_bytes"} {
+			if got := findOne(t, samples, name, lib1).value; got != 0 {
+				t.Errorf("library collector %v: %s = %v, want 0", library, name, got)
+			}
+		}
+		if hasName(samples, "tdarr_library_staged_oldest_age_seconds") {
+			t.Errorf("library collector %v: oldest age emitted with nothing staged", library)
+		}
+		if got := api.callCount(fakeKey{path: cfg.TdarrStatsPath, disc: "LibrarySettingsJSONDB"}); got != 1 {
+			t.Errorf("library collector %v: library list requests = %d, want 1", library, got)
+		}
+	}
+}
+
+// TestCollectStaged_LibraryListFails verifies a failed library list only
+// costs the staged collector its zeros: the libraries in the staged response
+// are still emitted, and the failure is the library collector's.
+func TestCollectStaged_LibraryListFails(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	cfg.Collectors.Staged = true
+	api := newSuccessFakeAPI(cfg)
+	api.setResponse(fakeKey{path: cfg.TdarrStatsPath, disc: "StagedJSONDB"}, []byte(stagedBody))
+	api.setError(fakeKey{path: cfg.TdarrStatsPath, disc: "LibrarySettingsJSONDB"}, statErr{"library list failed"})
+	c := newTdarrCollectorWithAPI(cfg, api)
+	samples := collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+
+	want := map[string]float64{subsystemLibrary: 0, subsystemNodes: 1, subsystemStaged: 1}
+	for collector, ok := range want {
+		if got := findOne(t, samples, "tdarr_scrape_collector_success", map[string]string{"collector": collector}).value; got != ok {
+			t.Errorf("%s collector success = %v, want %v", collector, got, ok)
+		}
+	}
+	for libId, files := range map[string]float64{"lib1": 2, "lib2": 1} {
+		if got := findOne(t, samples, "tdarr_library_staged_files", map[string]string{"library_id": libId}).value; got != files {
+			t.Errorf("tdarr_library_staged_files{library_id=%q} = %v, want %v", libId, got, files)
+		}
+	}
+	if got := api.callCount(fakeKey{path: cfg.TdarrStatsPath, disc: "LibrarySettingsJSONDB"}); got != 1 {
+		t.Errorf("library list requests = %d, want 1", got)
+	}
+}
@@ -0,0 +1,207 @@
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// stateFileVersion is the format of the state file. Bump it whenever the
+// format or the meaning of a cached field changes: a file of any other version
+// is discarded at startup, so the first scrape refetches instead of serving
+// stats decoded into the wrong shape.
+const stateFileVersion = 1
+
+// PieCacheStore keeps the pie caches of the collectors in a state file (see
+// config.Config.StateFile), so a restart does not send a full per-library
+// sweep to every Tdarr instance. One store is shared by all the targets of the
+// exporter; each target's cache is kept under its instance name.
+//
+// A restored cache is only a starting point: the first scrape still compares
+// it against the live totals and library list (see refetchReason) and
+// refetches when anything moved while the exporter was down.
+type PieCacheStore struct {
+	path    string
+	mu      sync.Mutex
+	targets map[string]stateTarget
+}
+
+// stateFile is the JSON document of the state file.
+type stateFile struct {
+	Version int                    `json:"version"`
+	Targets map[string]stateTarget `json:"targets"`
+}
+
+// stateTarget is the pie cache of one target. Url is the Tdarr url the cache
+// was filled from: a target whose url changed since does not get it back.
+type stateTarget struct {
+	Url           string             `json:"url"`
+	Written       time.Time          `json:"written"`
+	SweepDuration time.Duration      `json:"sweep_duration"`
+	Totals        map[string]int     `json:"totals"`
+	Fingerprint   []TdarrLibraryInfo `json:"fingerprint"`
+	Pies          []statePie         `json:"pies"`
+}
+
+// statePie is a TdarrPieStats with its unexported library id and name, and
+// its statuses as already normalized, so a restored pie is emitted as is.
+type statePie struct {
+	LibraryId    string         `json:"library_id"`
+	LibraryName  string         `json:"library_name"`
+	PieStats     TdarrPieStat   `json:"pie_stats"`
+	Transcodes   map[string]int `json:"transcodes"`
+	HealthChecks map[string]int `json:"health_checks"`
+}
+
+// OpenPieCacheStore reads the state file at path. A missing file is not an
+// error, nor is an unreadable or outdated one: the store then starts empty and
+// the file is replaced on the first cache write. Only a failure to read an
+// existing file is returned.
+func OpenPieCacheStore(path string) (*PieCacheStore, error) {
+	s := &PieCacheStore{path: path, targets: make(map[string]stateTarget)}
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read state file: %w", err)
+	}
+	var file stateFile
+	if err := json.Unmarshal(raw, &file); err != nil {
+		log.Warn().Err(err).Str("path", path).Msg("Discarding unreadable state file")
+		return s, nil
+	}
+	if file.Version != stateFileVersion {
+		log.Warn().Int("version", file.Version).Int("want", stateFileVersion).Str("path", path).Msg("Discarding state file of another version")
+		return s, nil
+	}
+	if file.Targets != nil {
+		s.targets = file.Targets
+	}
+	return s, nil
+}
+
+// restore returns the cache saved for instance, if it was filled from url.
+func (s *PieCacheStore) restore(instance, url string) (libStatsSnapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	target, ok := s.targets[instance]
+	if !ok || target.Url != url {
+		return libStatsSnapshot{}, false
+	}
+	stats := make([]*TdarrPieStats, 0, len(target.Pies))
+	for _, pie := range target.Pies {
+		stats = append(stats, &TdarrPieStats{
+			PieStats:               pie.PieStats,
+			libraryId:              pie.LibraryId,
+			libraryName:            pie.LibraryName,
+			NormalizedTranscodes:   pie.Transcodes,
+			NormalizedHealthChecks: pie.HealthChecks,
+		})
+	}
+	return libStatsSnapshot{
+		totals:        totalsFromNames(target.Totals),
+		stats:         stats,
+		fingerprint:   target.Fingerprint,
+		sweepDuration: target.SweepDuration,
+		written:       target.Written,
+	}, true
+}
+
+// save records snap as the cache of instance, filled from url, and rewrites
+// the state file.
+func (s *PieCacheStore) save(instance, url string, snap libStatsSnapshot) error {
+	pies := make([]statePie, 0, len(snap.stats))
+	for _, pie := range snap.stats {
+		pies = append(pies, statePie{
+			LibraryId:    pie.libraryId,
+			LibraryName:  pie.libraryName,
+			PieStats:     pie.PieStats,
+			Transcodes:   pie.NormalizedTranscodes,
+			HealthChecks: pie.NormalizedHealthChecks,
+		})
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.targets[instance] = stateTarget{
+		Url:           url,
+		Written:       snap.written,
+		SweepDuration: snap.sweepDuration,
+		Totals:        snap.totals.byName(),
+		Fingerprint:   snap.fingerprint,
+		Pies:          pies,
+	}
+	raw, err := json.Marshal(stateFile{Version: stateFileVersion, Targets: s.targets})
+	if err != nil {
+		return fmt.Errorf("encode state file: %w", err)
+	}
+	return writeFileAtomic(s.path, raw)
+}
+
+// writeFileAtomic replaces path with data through a temporary file in the same
+// directory, so a crash mid-write leaves the previous file rather than a
+// truncated one.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("write state file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("write state file: %w", err)
+	}
+	return nil
+}
+
+// totalsFromNames is the inverse of tdarrCacheTotals.byName; a missing name
+// reads as 0.
+func totalsFromNames(names map[string]int) tdarrCacheTotals {
+	return tdarrCacheTotals{
+		totalFileCount:        names["total_file_count"],
+		totalTranscodeCount:   names["total_transcode_count"],
+		totalHealthCheckCount: names["total_health_check_count"],
+		holdQueue:             names["hold_queue"],
+		transcodeQueue:        names["transcode_queue"],
+		transcodeSuccess:      names["transcode_success"],
+		transcodeFailed:       names["transcode_failed"],
+		healthCheckQueue:      names["health_check_queue"],
+		healthCheckSuccess:    names["health_check_success"],
+		healthCheckFailed:     names["health_check_failed"],
+	}
+}
+
+// useStateStore makes store keep c's pie cache, filled from the Tdarr at url,
+// and restores the cache saved there, if any.
+func (c *TdarrCollector) useStateStore(store *PieCacheStore, url string) {
+	c.stateStore, c.stateUrl = store, url
+	if snap, ok := store.restore(c.instanceName, url); ok {
+		c.statsCache.Write(snap)
+		c.logger.Info().Int("libraries", len(snap.stats)).Time("written", snap.written).Msg("Restored library stats cache from state file")
+	}
+}
+
+// saveState saves snap, just written to c's pie cache, to the state store. A
+// failure is only logged: the cache in memory is unaffected, and the next
+// sweep tries again.
+func (c *TdarrCollector) saveState(snap libStatsSnapshot) {
+	if c.stateStore == nil {
+		return
+	}
+	if err := c.stateStore.save(c.instanceName, c.stateUrl, snap); err != nil {
+		c.logger.Warn().Err(err).Str("path", c.stateStore.path).Msg("Failed to save library stats cache to state file")
+	}
+}
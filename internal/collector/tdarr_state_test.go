@@ -0,0 +1,159 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func testStateSnapshot() libStatsSnapshot {
+	return libStatsSnapshot{
+		totals: tdarrCacheTotals{totalFileCount: 10, transcodeQueue: 2, healthCheckFailed: 1},
+		stats: []*TdarrPieStats{{
+			PieStats:               TdarrPieStat{TotalFiles: 10, SizeDiff: 1.5},
+			libraryId:              "lib1",
+			libraryName:            "Library One",
+			NormalizedTranscodes:   map[string]int{"success": 3},
+			NormalizedHealthChecks: map[string]int{"error": 1},
+		}},
+		fingerprint:   []TdarrLibraryInfo{{LibraryId: "lib1", Name: "Library One"}},
+		sweepDuration: 3 * time.Second,
+		written:       time.Unix(1_700_000_000, 0).UTC(),
+	}
+}
+
+// TestPieCacheStore_RoundTrip verifies a saved cache comes back from a store
+// opened on the same file, but only for the url it was filled from.
+func TestPieCacheStore_RoundTrip(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "state.json")
+	store, err := OpenPieCacheStore(path)
+	if err != nil {
+		t.Fatalf("open missing state file: %v", err)
+	}
+	if _, ok := store.restore("tdarr", "http://tdarr.test"); ok {
+		t.Fatal("restore from an empty store succeeded")
+	}
+	want := testStateSnapshot()
+	if err := store.save("tdarr", "http://tdarr.test", want); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if info, err := os.Stat(path); err != nil {
+		t.Fatalf("stat state file: %v", err)
+	} else if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("state file mode = %v, want 0600", perm)
+	}
+
+	reopened, err := OpenPieCacheStore(path)
+	if err != nil {
+		t.Fatalf("reopen state file: %v", err)
+	}
+	got, ok := reopened.restore("tdarr", "http://tdarr.test")
+	if !ok {
+		t.Fatal("restore after reopening found nothing")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("restored snapshot = %+v, want %+v", got, want)
+	}
+	if _, ok := reopened.restore("tdarr", "http://other.test"); ok {
+		t.Error("restore for another url succeeded")
+	}
+	if _, ok := reopened.restore("other", "http://tdarr.test"); ok {
+		t.Error("restore for another instance succeeded")
+	}
+}
+
+// TestOpenPieCacheStore_Discards verifies a state file of another version or
+// one that does not decode leaves the store empty rather than failing.
+func TestOpenPieCacheStore_Discards(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"other version", `{"version":999,"targets":{"tdarr":{"url":"http://tdarr.test","pies":[]}}}`},
+		{"corrupt", `{"version":1,"targets":`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), "state.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("write state file: %v", err)
+			}
+			store, err := OpenPieCacheStore(path)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			if _, ok := store.restore("tdarr", "http://tdarr.test"); ok {
+				t.Error("restore from a discarded state file succeeded")
+			}
+			if err := store.save("tdarr", "http://tdarr.test", testStateSnapshot()); err != nil {
+				t.Errorf("save over a discarded state file: %v", err)
+			}
+		})
+	}
+}
+
+// TestStateStore_RestoredCache verifies a collector started on a saved cache
+// serves it without a sweep while the live totals match, and refetches once
+// they moved during the restart.
+func TestStateStore_RestoredCache(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	path := filepath.Join(t.TempDir(), "state.json")
+	pieKey := fakeKey{path: cfg.TdarrPieStatsPath, disc: "lib1"}
+	scrape := func(c *TdarrCollector) []sample {
+		return collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+	}
+	start := func(api *fakeTdarrAPI) *TdarrCollector {
+		store, err := OpenPieCacheStore(path)
+		if err != nil {
+			t.Fatalf("open state file: %v", err)
+		}
+		c := newTdarrCollectorWithAPI(cfg, api)
+		c.useStateStore(store, cfg.UrlParsed.String())
+		return c
+	}
+
+	first := newSuccessFakeAPI(cfg)
+	scrape(start(first))
+	if got := first.callCount(pieKey); got != 1 {
+		t.Fatalf("pie requests of the first run = %d, want 1", got)
+	}
+
+	restarted := newSuccessFakeAPI(cfg)
+	c := start(restarted)
+	samples := scrape(c)
+	if got := restarted.callCount(pieKey); got != 0 {
+		t.Errorf("pie requests after a restart with unchanged totals = %d, want 0", got)
+	}
+	if got := findOne(t, samples, "tdarr_exporter_pie_cache_hits_total", nil).value; got != 1 {
+		t.Errorf("hits after a restart = %v, want 1", got)
+	}
+	findOne(t, samples, "tdarr_library_files", map[string]string{"library_id": "lib1"})
+
+	// Totals that moved while the exporter was down invalidate the restored cache.
+	store, err := OpenPieCacheStore(path)
+	if err != nil {
+		t.Fatalf("open state file: %v", err)
+	}
+	snap, _ := store.restore(cfg.InstanceName, cfg.UrlParsed.String())
+	snap.totals.totalFileCount++
+	if err := store.save(cfg.InstanceName, cfg.UrlParsed.String(), snap); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	moved := newSuccessFakeAPI(cfg)
+	samples = scrape(start(moved))
+	if got := moved.callCount(pieKey); got != 1 {
+		t.Errorf("pie requests after a restart with changed totals = %d, want 1", got)
+	}
+	if got := findOne(t, samples, "tdarr_exporter_pie_cache_misses_total", map[string]string{"reason": missTotalsChanged}).value; got != 1 {
+		t.Errorf("totals_changed misses after a restart = %v, want 1", got)
+	}
+}
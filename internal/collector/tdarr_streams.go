@@ -0,0 +1,309 @@
+package collector
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+// Stream properties counted by the streams collector. Each is also the
+// job_kind of tdarr_unknown_status_total for its values outside the known set.
+const (
+	streamDynamicRange     = "dynamic_range"
+	streamBitDepth         = "bit_depth"
+	streamFrameRate        = "frame_rate"
+	streamChannelLayout    = "channel_layout"
+	streamAudioLanguage    = "audio_language"
+	streamSubtitleLanguage = "subtitle_language"
+)
+
+// knownStreamValues is the finite set of cleaned label values of each stream
+// property, pre-emitted as 0. The languages have none: any ISO 639-2 code is
+// known (see isLanguageCode), and only the ones found are emitted.
+var knownStreamValues = map[string]map[string]struct{}{
+	streamDynamicRange: {"hdr": {}, "sdr": {}},
+	streamBitDepth:     {"8": {}, "10": {}, "12": {}},
+	streamFrameRate: {
+		"23.976": {}, "24": {}, "25": {}, "29.97": {}, "30": {},
+		"48": {}, "50": {}, "59.94": {}, "60": {}, "120": {}, "other": {},
+	},
+	streamChannelLayout: {
+		"mono": {}, "stereo": {}, "2.1": {}, "3.0": {}, "4.0": {}, "quad": {},
+		"5.0": {}, "5.1": {}, "6.1": {}, "7.1": {},
+	},
+	streamAudioLanguage:    nil,
+	streamSubtitleLanguage: nil,
+}
+
+// hdrTransfers are the ffprobe color_transfer values of HDR video: PQ (HDR10,
+// HDR10+, most Dolby Vision) and HLG.
+var hdrTransfers = map[string]struct{}{
+	"smpte2084":    {},
+	"arib-std-b67": {},
+}
+
+// sdrTransfers are the color_transfer values of SDR video, including none at
+// all, which is how most SDR files are tagged.
+var sdrTransfers = map[string]struct{}{
+	"":             {},
+	"unknown":      {},
+	"bt709":        {},
+	"bt470m":       {},
+	"bt470bg":      {},
+	"smpte170m":    {},
+	"smpte240m":    {},
+	"linear":       {},
+	"gamma22":      {},
+	"gamma28":      {},
+	"iec61966-2-1": {},
+	"iec61966-2-4": {},
+	"bt2020-10":    {},
+	"bt2020-12":    {},
+}
+
+// standardFrameRates are the frame_rate buckets, by the rate they stand for.
+var standardFrameRates = []struct {
+	label string
+	fps   float64
+}{
+	{"23.976", 24000.0 / 1001}, {"24", 24}, {"25", 25}, {"29.97", 30000.0 / 1001}, {"30", 30},
+	{"48", 48}, {"50", 50}, {"59.94", 60000.0 / 1001}, {"60", 60}, {"120", 120},
+}
+
+// pixFmtDepth finds the bit depth in a pix_fmt, e.g. yuv420p10le or p010le.
+var pixFmtDepth = regexp.MustCompile(`p(\d+)(le|be)?$`)
+
+// cleanDynamicRange converts a video stream's color_transfer into hdr or sdr.
+// Any other transfer is returned cleaned, as an unknown value.
+func cleanDynamicRange(transfer string) string {
+	t := strings.ToLower(strings.TrimSpace(transfer))
+	if _, ok := hdrTransfers[t]; ok {
+		return "hdr"
+	}
+	if _, ok := sdrTransfers[t]; ok {
+		return "sdr"
+	}
+	return t
+}
+
+// cleanBitDepth returns the bit depth of a video stream, from
+// bits_per_raw_sample or else its pix_fmt, where no depth means 8 bits.
+//
+// Examples:
+//
+//	pix_fmt "yuv420p10le" -> "10"
+//	pix_fmt "yuv420p"     -> "8"
+func cleanBitDepth(s TdarrStream) string {
+	if bits, err := strconv.Atoi(strings.TrimSpace(s.BitsPerRawSample)); err == nil && bits > 0 {
+		return strconv.Itoa(bits)
+	}
+	pixFmt := strings.ToLower(strings.TrimSpace(s.PixFmt))
+	if pixFmt == "" {
+		return ""
+	}
+	if m := pixFmtDepth.FindStringSubmatch(pixFmt); m != nil {
+		if bits, err := strconv.Atoi(m[1]); err == nil && bits > 0 {
+			return strconv.Itoa(bits)
+		}
+	}
+	return "8"
+}
+
+// cleanFrameRate returns the frame_rate bucket of a video stream: the standard
+// rate within 0.01 fps of its r_frame_rate (or avg_frame_rate), or other. A
+// rate that does not parse, e.g. "0/0", is returned cleaned, as an unknown
+// value.
+func cleanFrameRate(s TdarrStream) string {
+	raw := strings.ToLower(strings.TrimSpace(s.RFrameRate))
+	fps, ok := parseFrameRate(raw)
+	if !ok {
+		if fps, ok = parseFrameRate(s.AvgFrameRate); !ok {
+			return raw
+		}
+	}
+	for _, r := range standardFrameRates {
+		if math.Abs(fps-r.fps) < 0.01 {
+			return r.label
+		}
+	}
+	return "other"
+}
+
+// parseFrameRate parses an ffprobe rate, a fraction such as "24000/1001".
+func parseFrameRate(raw string) (float64, bool) {
+	num, den, isFraction := strings.Cut(strings.TrimSpace(raw), "/")
+	n, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, false
+	}
+	if isFraction {
+		d, err := strconv.ParseFloat(den, 64)
+		if err != nil || d == 0 {
+			return 0, false
+		}
+		n /= d
+	}
+	return n, n > 0 && !math.IsInf(n, 0)
+}
+
+// cleanChannelLayout returns the channel layout of an audio stream, without
+// ffmpeg's speaker placement suffix, or else one named after its channel
+// count.
+//
+// Examples:
+//
+//	"5.1(side)" -> "5.1"
+//	"" with 2 channels -> "stereo"
+func cleanChannelLayout(s TdarrStream) string {
+	layout := strings.ToLower(strings.TrimSpace(s.ChannelLayout))
+	if i := strings.IndexByte(layout, '('); i > 0 {
+		layout = layout[:i]
+	}
+	if layout != "" {
+		return layout
+	}
+	switch s.Channels {
+	case 0:
+		return ""
+	case 1:
+		return "mono"
+	case 2:
+		return "stereo"
+	case 6:
+		return "5.1"
+	case 8:
+		return "7.1"
+	}
+	return fmt.Sprintf("%d channels", s.Channels)
+}
+
+// cleanLanguage returns a stream's language tag, und (undetermined, as in ISO
+// 639-2) when it has none.
+func cleanLanguage(raw string) string {
+	if l := strings.ToLower(strings.TrimSpace(raw)); l != "" {
+		return l
+	}
+	return "und"
+}
+
+// isLanguageCode reports whether a cleaned language is shaped like an ISO
+// 639-2 code, as ffmpeg writes them; "english" or "en-us" are not.
+func isLanguageCode(l string) bool {
+	if len(l) != 3 {
+		return false
+	}
+	for _, r := range l {
+		if r < 'a' || r > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// streamCounts counts the stream properties of a library's files, by
+// property and cleaned value: the video ones once per file, from its first
+// video stream, the audio and subtitle ones once per stream.
+type streamCounts map[string]map[string]int
+
+func (sc streamCounts) add(property, value string) {
+	if value == "" {
+		return
+	}
+	values, ok := sc[property]
+	if !ok {
+		values = make(map[string]int)
+		sc[property] = values
+	}
+	values[value]++
+}
+
+// addFile counts the streams of file.
+func (sc streamCounts) addFile(file TdarrFile) {
+	video := false
+	for _, s := range file.FfProbeData.Streams {
+		switch strings.ToLower(s.CodecType) {
+		case "video":
+			// Cover art is a video stream too.
+			if video || s.Disposition.AttachedPic == 1 {
+				continue
+			}
+			video = true
+			sc.add(streamDynamicRange, cleanDynamicRange(s.ColorTransfer))
+			sc.add(streamBitDepth, cleanBitDepth(s))
+			sc.add(streamFrameRate, cleanFrameRate(s))
+		case "audio":
+			sc.add(streamChannelLayout, cleanChannelLayout(s))
+			sc.add(streamAudioLanguage, cleanLanguage(s.Tags.Language))
+		case "subtitle":
+			sc.add(streamSubtitleLanguage, cleanLanguage(s.Tags.Language))
+		}
+	}
+}
+
+// normalizeStreamCounts pads counts with the known values of each property
+// and reports the others, like normalizePieStatuses:
+//   - Known values: present with their count, or 0.
+//   - Unknown values: kept with their count (no data loss), warn-logged, and
+//     bumped in the unknownStatusTotal counter, once per library and walk.
+func normalizeStreamCounts(counts streamCounts, libraryId string, unknownCounter func(kind, status string)) {
+	for property, known := range knownStreamValues {
+		values, ok := counts[property]
+		if !ok {
+			values = make(map[string]int, len(known))
+			counts[property] = values
+		}
+		for k := range known {
+			if _, ok := values[k]; !ok {
+				values[k] = 0
+			}
+		}
+		for value := range values {
+			if _, isKnown := known[value]; isKnown || (known == nil && isLanguageCode(value)) {
+				continue
+			}
+			log.Warn().
+				Str("kind", property).
+				Str("value", value).
+				Str("libraryId", libraryId).
+				Msg("Unknown stream property value encountered; will emit metric but zero-pad not applied for future scrapes")
+			if unknownCounter != nil {
+				unknownCounter(property, value)
+			}
+		}
+	}
+}
+
+// streamDesc returns the desc of a stream property.
+func (c *TdarrCollector) streamDesc(property string) typedDesc {
+	switch property {
+	case streamDynamicRange:
+		return c.videoDynamicRanges
+	case streamBitDepth:
+		return c.videoBitDepths
+	case streamFrameRate:
+		return c.videoFrameRates
+	case streamChannelLayout:
+		return c.audioChannelLayouts
+	case streamAudioLanguage:
+		return c.audioLanguages
+	default:
+		return c.subtitleLanguages
+	}
+}
+
+// emitStreamMetrics emits the stream property counts of each library.
+func (c *TdarrCollector) emitStreamMetrics(ch chan<- prometheus.Metric, libraries map[string]*libraryFiles) {
+	for libId, lib := range libraries {
+		for property, values := range lib.streams {
+			desc := c.streamDesc(property)
+			for value, count := range values {
+				ch <- desc.mustNewConstMetric(float64(count), libId, value)
+			}
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCleanStreamProperties(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		clean func(TdarrStream) string
+		in    TdarrStream
+		want  string
+	}{
+		{"pq is hdr", func(s TdarrStream) string { return cleanDynamicRange(s.ColorTransfer) }, TdarrStream{ColorTransfer: "smpte2084"}, "hdr"},
+		{"hlg is hdr", func(s TdarrStream) string { return cleanDynamicRange(s.ColorTransfer) }, TdarrStream{ColorTransfer: "ARIB-STD-B67"}, "hdr"},
+		{"untagged is sdr", func(s TdarrStream) string { return cleanDynamicRange(s.ColorTransfer) }, TdarrStream{}, "sdr"},
+		{"unknown transfer kept", func(s TdarrStream) string { return cleanDynamicRange(s.ColorTransfer) }, TdarrStream{ColorTransfer: "Log3G10"}, "log3g10"},
+		{"bits per raw sample", cleanBitDepth, TdarrStream{BitsPerRawSample: "10", PixFmt: "yuv420p"}, "10"},
+		{"10-bit pix_fmt", cleanBitDepth, TdarrStream{PixFmt: "yuv420p10le"}, "10"},
+		{"p010 pix_fmt", cleanBitDepth, TdarrStream{PixFmt: "p010le"}, "10"},
+		{"8-bit pix_fmt", cleanBitDepth, TdarrStream{PixFmt: "yuv420p"}, "8"},
+		{"no pix_fmt", cleanBitDepth, TdarrStream{}, ""},
+		{"ntsc film", cleanFrameRate, TdarrStream{RFrameRate: "24000/1001"}, "23.976"},
+		{"pal", cleanFrameRate, TdarrStream{RFrameRate: "25/1"}, "25"},
+		{"avg when r is unset", cleanFrameRate, TdarrStream{RFrameRate: "0/0", AvgFrameRate: "60000/1001"}, "59.94"},
+		{"non-standard rate", cleanFrameRate, TdarrStream{RFrameRate: "15/1"}, "other"},
+		{"unparsable rate kept", cleanFrameRate, TdarrStream{RFrameRate: "0/0"}, "0/0"},
+		{"side suffix dropped", cleanChannelLayout, TdarrStream{ChannelLayout: "5.1(side)"}, "5.1"},
+		{"layout from channels", cleanChannelLayout, TdarrStream{Channels: 2}, "stereo"},
+		{"odd channel count", cleanChannelLayout, TdarrStream{Channels: 3}, "3 channels"},
+		{"language lowercased", func(s TdarrStream) string { return cleanLanguage(s.Tags.Language) }, testStream("audio", func(s *TdarrStream) { s.Tags.Language = " ENG " }), "eng"},
+		{"untagged language", func(s TdarrStream) string { return cleanLanguage(s.Tags.Language) }, TdarrStream{}, "und"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := tt.clean(tt.in); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func testStream(codecType string, set func(*TdarrStream)) TdarrStream {
+	s := TdarrStream{CodecType: codecType}
+	if set != nil {
+		set(&s)
+	}
+	return s
+}
+
+func withStreams(f TdarrFile, streams ...TdarrStream) TdarrFile {
+	f.FfProbeData.Streams = streams
+	return f
+}
+
+// TestCollectStreams verifies the streams collector counts the video
+// properties per file, skipping cover art, and the audio and subtitle ones per
+// stream, and reports unknown values once per walk.
+func TestCollectStreams(t *testing.T) {
+	t.Parallel()
+	cfg := newFilesTestConfig(t)
+	cfg.Collectors.Files = false
+	cfg.Collectors.Streams = true
+	api := newSuccessFakeAPI(cfg)
+	setFilesPages(cfg, api)
+	hdr := withStreams(testFile("hdr", "lib1", 20e9),
+		testStream("video", func(s *TdarrStream) {
+			s.ColorTransfer, s.PixFmt, s.RFrameRate = "smpte2084", "yuv420p10le", "24000/1001"
+		}),
+		testStream("video", func(s *TdarrStream) { s.Disposition.AttachedPic = 1 }),
+		testStream("audio", func(s *TdarrStream) { s.ChannelLayout, s.Tags.Language = "7.1", "eng" }),
+		testStream("audio", func(s *TdarrStream) { s.ChannelLayout, s.Tags.Language = "5.1(side)", "english" }),
+		testStream("subtitle", func(s *TdarrStream) { s.Tags.Language = "fre" }),
+	)
+	sdr := withStreams(testFile("sdr", "lib1", 2e9),
+		testStream("video", func(s *TdarrStream) { s.PixFmt, s.RFrameRate = "yuv420p", "25/1" }),
+		testStream("audio", func(s *TdarrStream) { s.Channels = 2 }),
+	)
+	api.setResponse(filesPageKey(cfg.TdarrFilesPath, "table3", 0), filesPageBody(2, hdr, sdr))
+	c := newTdarrCollectorWithAPI(cfg, api)
+	scrape := func() []sample {
+		return collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+	}
+	samples := scrape()
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   float64
+	}{
+		{"tdarr_library_video_dynamic_ranges", map[string]string{"dynamic_range": "hdr"}, 1},
+		{"tdarr_library_video_dynamic_ranges", map[string]string{"dynamic_range": "sdr"}, 1},
+		{"tdarr_library_video_bit_depths", map[string]string{"bit_depth": "10"}, 1},
+		{"tdarr_library_video_bit_depths", map[string]string{"bit_depth": "12"}, 0},
+		{"tdarr_library_video_frame_rates", map[string]string{"frame_rate": "23.976"}, 1},
+		{"tdarr_library_video_frame_rates", map[string]string{"frame_rate": "25"}, 1},
+		{"tdarr_library_audio_channel_layouts", map[string]string{"layout": "7.1"}, 1},
+		{"tdarr_library_audio_channel_layouts", map[string]string{"layout": "5.1"}, 1},
+		{"tdarr_library_audio_channel_layouts", map[string]string{"layout": "stereo"}, 1},
+		{"tdarr_library_audio_languages", map[string]string{"language": "eng"}, 1},
+		{"tdarr_library_audio_languages", map[string]string{"language": "english"}, 1},
+		{"tdarr_library_audio_languages", map[string]string{"language": "und"}, 1},
+		{"tdarr_library_subtitle_languages", map[string]string{"language": "fre"}, 1},
+		{"tdarr_unknown_status_total", map[string]string{"job_kind": streamAudioLanguage, "status": "english"}, 1},
+		{"tdarr_scrape_collector_success", map[string]string{"collector": subsystemStreams}, 1},
+	}
+	for _, tt := range tests {
+		labels := map[string]string{"library_id": "lib1"}
+		if tt.name == "tdarr_unknown_status_total" || tt.name == "tdarr_scrape_collector_success" {
+			labels = map[string]string{}
+		}
+		for k, v := range tt.labels {
+			labels[k] = v
+		}
+		if got := findOne(t, samples, tt.name, labels).value; got != tt.want {
+			t.Errorf("%s%v = %v, want %v", tt.name, tt.labels, got, tt.want)
+		}
+	}
+	// The fixture files of setFilesPages have no streams: lib2 only gets
+	// the zero-padded known values.
+	if got := findOne(t, samples, "tdarr_library_video_dynamic_ranges", map[string]string{"library_id": "lib2", "dynamic_range": "sdr"}).value; got != 0 {
+		t.Errorf("lib2 sdr files = %v, want 0", got)
+	}
+	if hasName(samples, "tdarr_library_size_bytes") || hasName(samples, "tdarr_library_file_size_bytes_count") {
+		t.Error("files collector series emitted with only the streams collector enabled")
+	}
+
+	// A cached walk does not count the unknown values again.
+	samples = scrape()
+	if got := findOne(t, samples, "tdarr_unknown_status_total", map[string]string{"job_kind": streamAudioLanguage, "status": "english"}).value; got != 1 {
+		t.Errorf("unknown audio language after a cached scrape = %v, want 1", got)
+	}
+}
+
+// TestCollectStreams_InvalidatesWalk verifies a walk made for the files
+// collector alone is redone once the streams collector is enabled.
+func TestCollectStreams_InvalidatesWalk(t *testing.T) {
+	t.Parallel()
+	cfg := newFilesTestConfig(t)
+	api := newSuccessFakeAPI(cfg)
+	setFilesPages(cfg, api)
+	c := newTdarrCollectorWithAPI(cfg, api)
+	collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+
+	cfg.Collectors.Streams = true
+	next := newTdarrCollectorWithAPI(cfg, api)
+	next.InheritState(c)
+	api.resetCalls()
+	samples := collectSamples(t, func(ch chan<- prometheus.Metric) { next.CollectContext(context.Background(), ch) })
+	if got := filesWalkCalls(cfg, api); got != 5 {
+		t.Errorf("status-table requests after enabling streams = %d, want 5", got)
+	}
+	findOne(t, samples, "tdarr_library_video_dynamic_ranges", map[string]string{"library_id": "lib1", "dynamic_range": "hdr"})
+}
@@ -3,17 +3,33 @@ package collector
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"io/fs"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
 	"regexp"
 	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/homeylab/tdarr-exporter/internal/client"
 	"github.com/homeylab/tdarr-exporter/internal/config"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
@@ -31,16 +47,22 @@ func newTestConfig(t *testing.T) config.Config {
 		t.Fatalf("parse url: %v", err)
 	}
 	return config.Config{
-		UrlParsed:          u,
-		InstanceName:       "test-instance",
-		ApiKey:             "test-key",
-		VerifySsl:          false,
-		HttpTimeoutSeconds: 5,
-		TdarrStatsPath:     "/api/v2/cruddb",
-		TdarrPieStatsPath:  "/api/v2/stats/get-pies",
-		TdarrNodePath:      "/api/v2/get-nodes",
-		TdarrStatusPath:    "/api/v2/status",
-		HttpMaxConcurrency: 1,
+		UrlParsed:              u,
+		InstanceName:           "test-instance",
+		ApiKey:                 "test-key",
+		VerifySsl:              false,
+		HttpTimeoutSeconds:     5,
+		TdarrStatsPath:         "/api/v2/cruddb",
+		TdarrPieStatsPath:      "/api/v2/stats/get-pies",
+		TdarrNodePath:          "/api/v2/get-nodes",
+		TdarrStatusPath:        "/api/v2/status",
+		TdarrFilesPath:         "/api/v2/client/status-tables",
+		TdarrPluginsPath:       "/api/v2/search-plugins",
+		FileSizeBuckets:        config.DefaultFileSizeBuckets,
+		FilesPageSize:          1000,
+		PluginsRefreshInterval: time.Hour,
+		HttpMaxConcurrency:     1,
+		Collectors:             config.DefaultCollectors(),
 	}
 }
 
@@ -605,9 +627,9 @@ func TestDescribe_EmitsAllDescs(t *testing.T) {
 		fqNames[descFqName(t, d)]++
 	}
 
-	// 28 collector descs + 26 node descs. Adding/removing a metric must update this number,
+	// 53 collector descs + 26 node descs. Adding/removing a metric must update this number,
 	// which is exactly the point: the count is the tripwire for a dropped Describe entry.
-	const wantCollectorDescs = 28
+	const wantCollectorDescs = 53
 	const wantNodeDescs = 26
 	wantTotal := wantCollectorDescs + wantNodeDescs
 	if len(descs) != wantTotal {
@@ -733,7 +755,7 @@ func libraryInfoHasSeries(mfs []*dto.MetricFamily, libID, libName string) bool {
 // TestCollect_LibraryFingerprint_TriggersRefetch verifies that a library-list
 // change invisible to the 10 numeric totals — a rename, or a new zero-file
 // library — still triggers a pie refetch. Before the library-list fingerprint
-// was added to the cache invalidation signal, shouldRefetch keyed only on the
+// was added to the cache invalidation signal, refetchReason keyed only on the
 // totals, so neither case would be observed until unrelated activity next
 // changed a total (see docs/metrics-internals.md).
 func TestCollect_LibraryFingerprint_TriggersRefetch(t *testing.T) {
@@ -849,6 +871,398 @@ func TestCollect_UnchangedLibraries_CacheHit_NoPieCalls(t *testing.T) {
 	}
 }
 
+// TestInheritState_KeepsCacheAndCounters verifies a collector replacing another
+// for the same instance (a config reload) serves the first scrape from the
+// inherited pie cache and continues the unknown-status counter rather than
+// restarting it from zero.
+func TestInheritState_KeepsCacheAndCounters(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	api := newSuccessFakeAPI(cfg)
+	prev := newTdarrCollectorWithAPI(cfg, api)
+	pieKey := fakeKey{path: cfg.TdarrPieStatsPath, disc: "lib1"}
+
+	gatherMetricFamilies(t, prev)
+	prev.bumpUnknownStatus("transcode", "Drifted")
+	prev.bumpUnknownStatus("transcode", "Drifted")
+	api.resetCalls()
+
+	next := newTdarrCollectorWithAPI(cfg, api)
+	next.InheritState(prev)
+	mfs := gatherMetricFamilies(t, next)
+
+	if got := api.callCount(pieKey); got != 0 {
+		t.Errorf("pie calls after inheriting the cache: want 0, got %d", got)
+	}
+	var unknown float64
+	for _, mf := range mfs {
+		if mf.GetName() == "tdarr_unknown_status_total" {
+			unknown = mf.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	if unknown != 2 {
+		t.Errorf("tdarr_unknown_status_total: want inherited 2, got %v", unknown)
+	}
+}
+
+// TestNewTdarrCollector_ApiKeyFile verifies a configured api key file must be
+// readable at construction, and that its read-failure counter is emitted (and
+// carried across InheritState) only when the file is configured.
+func TestNewTdarrCollector_ApiKeyFile(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	cfg.ApiKey = ""
+	cfg.ApiKeyFile = filepath.Join(t.TempDir(), "api-key")
+
+	if _, err := NewTdarrCollector(context.Background(), cfg); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("missing key file: error = %v, want fs.ErrNotExist", err)
+	}
+	if err := os.WriteFile(cfg.ApiKeyFile, []byte("file-key\n"), 0o600); err != nil {
+		t.Fatalf("write key file: %v", err)
+	}
+	if _, err := NewTdarrCollector(context.Background(), cfg); err != nil {
+		t.Fatalf("NewTdarrCollector: %v", err)
+	}
+
+	// Build the collectors around the fake API (as NewTdarrCollector would around
+	// a real client) and attach the key source directly.
+	api := newSuccessFakeAPI(cfg)
+	keyFile, err := client.NewFileAPIKey(cfg.ApiKeyFile)
+	if err != nil {
+		t.Fatalf("NewFileAPIKey: %v", err)
+	}
+	prev := newTdarrCollectorWithAPI(cfg, api)
+	prev.apiKeyFile = keyFile
+	prev.apiKeyFileFailuresCarried = 3
+	next := newTdarrCollectorWithAPI(cfg, api)
+	next.apiKeyFile = keyFile
+	next.InheritState(prev)
+
+	if got := testutil.ToFloat64(filteredCollector{next, "tdarr_api_key_file_read_failures_total"}); got != 3 {
+		t.Errorf("tdarr_api_key_file_read_failures_total = %v, want 3 carried over", got)
+	}
+	if hasMetricFamily(gatherMetricFamilies(t, newTdarrCollectorWithAPI(cfg, api)), "tdarr_api_key_file_read_failures_total") {
+		t.Errorf("failure counter emitted without an api key file")
+	}
+}
+
+// TestNewTdarrCollector_ClientCertificate verifies configured TLS files must
+// load at construction and that the client certificate's expiry is emitted
+// only when one is configured.
+func TestNewTdarrCollector_ClientCertificate(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	dir := t.TempDir()
+	cfg.TlsCaFile = filepath.Join(dir, "missing-ca.pem")
+	if _, err := NewTdarrCollector(context.Background(), cfg); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("missing ca file: error = %v, want fs.ErrNotExist", err)
+	}
+
+	notAfter := time.Unix(1_900_000_000, 0)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "exporter"}, NotAfter: notAfter}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create cert: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	cfg.TlsCaFile = ""
+	cfg.TlsCertFile = filepath.Join(dir, "client.pem")
+	cfg.TlsKeyFile = filepath.Join(dir, "client-key.pem")
+	if err := os.WriteFile(cfg.TlsCertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write cert: %v", err)
+	}
+	if err := os.WriteFile(cfg.TlsKeyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	built, err := NewTdarrCollector(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("NewTdarrCollector: %v", err)
+	}
+
+	// As in TestNewTdarrCollector_ApiKeyFile, scrape through the fake API with
+	// the TLS files the real constructor built.
+	api := newSuccessFakeAPI(cfg)
+	c := newTdarrCollectorWithAPI(cfg, api)
+	c.tlsFiles = built.tlsFiles
+	if got := testutil.ToFloat64(filteredCollector{c, "tdarr_client_certificate_expiry_timestamp_seconds"}); got != float64(notAfter.Unix()) {
+		t.Errorf("tdarr_client_certificate_expiry_timestamp_seconds = %v, want %d", got, notAfter.Unix())
+	}
+	if hasMetricFamily(gatherMetricFamilies(t, newTdarrCollectorWithAPI(cfg, api)), "tdarr_client_certificate_expiry_timestamp_seconds") {
+		t.Errorf("expiry emitted without a client certificate")
+	}
+}
+
+// TestNewTdarrCollector_CircuitBreaker verifies scrapes of an unreachable
+// Tdarr stop contacting it once the breaker opens, and that the breaker's
+// transitions survive a reload.
+func TestNewTdarrCollector_CircuitBreaker(t *testing.T) {
+	t.Parallel()
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	cfg := newTestConfig(t)
+	cfg.UrlParsed, _ = url.Parse(srv.URL)
+	cfg.CircuitBreakerThreshold = 1
+	cfg.CircuitBreakerCooldownSeconds = 3600
+	cfg.RequestPolicies = map[string]config.RequestPolicy{"status": {}}
+	c, err := NewTdarrCollector(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("NewTdarrCollector: %v", err)
+	}
+	c.logger = zerolog.Nop()
+
+	for scrape := 1; scrape <= 2; scrape++ {
+		if up := getUpValue(t, c); up != 0 {
+			t.Errorf("scrape %d: tdarr_up = %v, want 0", scrape, up)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("requests to Tdarr = %d, want 1 before the breaker opened", got)
+	}
+	expected := `# HELP tdarr_exporter_circuit_state 1 for the state the circuit breaker in front of Tdarr is in (closed, open or half_open), 0 for the others. While open, scrapes fail with tdarr_up=0 without contacting Tdarr. Only emitted when circuit_breaker_threshold is above 0.
+# TYPE tdarr_exporter_circuit_state gauge
+tdarr_exporter_circuit_state{state="closed",tdarr_instance="test-instance"} 0
+tdarr_exporter_circuit_state{state="half_open",tdarr_instance="test-instance"} 0
+tdarr_exporter_circuit_state{state="open",tdarr_instance="test-instance"} 1
+# HELP tdarr_exporter_circuit_transitions_total Count of circuit breaker transitions into each state. Only emitted when circuit_breaker_threshold is above 0.
+# TYPE tdarr_exporter_circuit_transitions_total counter
+tdarr_exporter_circuit_transitions_total{state="closed",tdarr_instance="test-instance"} 0
+tdarr_exporter_circuit_transitions_total{state="half_open",tdarr_instance="test-instance"} 0
+tdarr_exporter_circuit_transitions_total{state="open",tdarr_instance="test-instance"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(expected), "tdarr_exporter_circuit_state", "tdarr_exporter_circuit_transitions_total"); err != nil {
+		t.Errorf("metric output mismatch:\n%v", err)
+	}
+
+	next, err := NewTdarrCollector(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("NewTdarrCollector: %v", err)
+	}
+	next.InheritState(c)
+	if got := next.circuitTransitionsCarried[client.CircuitOpen]; got != 1 {
+		t.Errorf("carried open transitions = %v, want 1", got)
+	}
+
+	cfg.CircuitBreakerThreshold = 0
+	disabled, err := NewTdarrCollector(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("NewTdarrCollector: %v", err)
+	}
+	if disabled.breaker != nil {
+		t.Errorf("breaker built with circuit_breaker_threshold 0")
+	}
+}
+
+// TestNewTdarrCollector_Registerer verifies the HTTP client's metrics are
+// registered labeled with the target's instance, and that a collector rebuilt
+// for the same target, as on reload, reuses them.
+func TestNewTdarrCollector_Registerer(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	cfg := newTestConfig(t)
+	cfg.UrlParsed, _ = url.Parse(srv.URL)
+	cfg.RequestPolicies = map[string]config.RequestPolicy{"status": {}}
+	cfg.Collectors = config.Collectors{Server: true}
+	reg := prometheus.NewRegistry()
+	for range 2 {
+		c, err := NewTdarrCollector(context.Background(), cfg, WithRegisterer(reg))
+		if err != nil {
+			t.Fatalf("NewTdarrCollector: %v", err)
+		}
+		c.logger = zerolog.Nop()
+		getUpValue(t, c)
+	}
+	expected := `# HELP tdarr_exporter_http_requests_total HTTP requests sent to Tdarr, one per attempt, by response status code, or "error" for a transport error such as a timeout.
+# TYPE tdarr_exporter_http_requests_total counter
+tdarr_exporter_http_requests_total{code="503",endpoint="status",tdarr_instance="test-instance"} 2
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "tdarr_exporter_http_requests_total"); err != nil {
+		t.Errorf("metric output mismatch:\n%v", err)
+	}
+}
+
+// TestCollect_Collectors verifies each collector.* toggle: a disabled group
+// makes none of its requests and its metrics drop out of both Collect and
+// Describe.
+func TestCollect_Collectors(t *testing.T) {
+	t.Parallel()
+
+	nodeBody, _ := json.Marshal(map[string]TdarrNode{"n1": {
+		Id: "n1", Name: "Node1",
+		Workers: map[string]TdarrNodeWorkers{"w1": {Id: "w1", WorkerType: "transcodecpu"}},
+	}})
+	tests := []struct {
+		name       string
+		collectors config.Collectors
+		// wantCalls are the requests made by one scrape, by endpoint.
+		wantStatus, wantStats, wantLibs, wantPies, wantNodes int
+		wantFamilies                                         map[string]bool
+		wantDescs                                            int
+	}{
+		{
+			name:       "defaults",
+			collectors: config.DefaultCollectors(),
+			wantStatus: 1, wantStats: 1, wantLibs: 1, wantPies: 1, wantNodes: 1,
+			wantFamilies: map[string]bool{"tdarr_server_info": true, "tdarr_files": true, "tdarr_library_files": true, "tdarr_node_info": true, "tdarr_node_worker_info": true},
+			wantDescs:    79,
+		},
+		{
+			name:         "nodes and workers only",
+			collectors:   config.Collectors{Nodes: true, Workers: true},
+			wantNodes:    1,
+			wantFamilies: map[string]bool{"tdarr_server_info": false, "tdarr_files": false, "tdarr_library_files": false, "tdarr_node_info": true, "tdarr_node_worker_info": true},
+			wantDescs:    12 + 26,
+		},
+		{
+			name:         "workers only",
+			collectors:   config.Collectors{Workers: true},
+			wantNodes:    1,
+			wantFamilies: map[string]bool{"tdarr_node_info": false, "tdarr_node_worker_count": false, "tdarr_node_worker_info": true},
+			wantDescs:    12 + 13,
+		},
+		{
+			name:       "library without server or general",
+			collectors: config.Collectors{Library: true},
+			wantStats:  1, wantLibs: 1, wantPies: 1,
+			wantFamilies: map[string]bool{"tdarr_server_info": false, "tdarr_files": false, "tdarr_library_files": true, "tdarr_node_info": false},
+			wantDescs:    12 + 27,
+		},
+		{
+			name:         "general only",
+			collectors:   config.Collectors{General: true},
+			wantStats:    1,
+			wantFamilies: map[string]bool{"tdarr_files": true, "tdarr_library_files": false},
+			wantDescs:    12 + 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := newTestConfig(t)
+			cfg.Collectors = tt.collectors
+			api := newSuccessFakeAPI(cfg)
+			api.setResponse(fakeKey{path: cfg.TdarrNodePath}, nodeBody)
+			c := newTdarrCollectorWithAPI(cfg, api)
+
+			mfs := gatherMetricFamilies(t, c)
+			if up := upValueFromFamilies(mfs); up != 1 {
+				t.Errorf("tdarr_up = %v, want 1", up)
+			}
+			for key, want := range map[fakeKey]int{
+				{path: cfg.TdarrStatusPath}:                               tt.wantStatus,
+				{path: cfg.TdarrStatsPath, disc: "StatisticsJSONDB"}:      tt.wantStats,
+				{path: cfg.TdarrStatsPath, disc: "LibrarySettingsJSONDB"}: tt.wantLibs,
+				{path: cfg.TdarrPieStatsPath, disc: "lib1"}:               tt.wantPies,
+				{path: cfg.TdarrNodePath}:                                 tt.wantNodes,
+			} {
+				if got := api.callCount(key); got != want {
+					t.Errorf("requests to %s %s = %d, want %d", key.path, key.disc, got, want)
+				}
+			}
+			for family, want := range tt.wantFamilies {
+				if got := hasMetricFamily(mfs, family); got != want {
+					t.Errorf("%s present = %v, want %v", family, got, want)
+				}
+			}
+
+			ch := make(chan *prometheus.Desc, 256)
+			c.Describe(ch)
+			close(ch)
+			if len(ch) != tt.wantDescs {
+				t.Errorf("Describe emitted %d descs, want %d", len(ch), tt.wantDescs)
+			}
+		})
+	}
+}
+
+// TestProxyAuthenticators verifies the configured proxy credentials map to
+// client authenticators, headers first so an Authorization scheme wins.
+func TestProxyAuthenticators(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	if auth := proxyAuthenticators(cfg); len(auth) != 0 {
+		t.Errorf("no proxy credentials: got %d authenticators, want 0", len(auth))
+	}
+
+	cfg.HttpHeaders = map[string]string{"X-Tenant": "media"}
+	cfg.BasicAuth = config.BasicAuth{Username: "exporter", Password: "s3cret"}
+	auth := proxyAuthenticators(cfg)
+	if len(auth) != 2 {
+		t.Fatalf("headers and basic auth: got %d authenticators, want 2", len(auth))
+	}
+	if _, ok := auth[0].(client.StaticHeaders); !ok {
+		t.Errorf("first authenticator = %T, want client.StaticHeaders", auth[0])
+	}
+	if got, ok := auth[1].(client.BasicAuth); !ok || got.Username != "exporter" {
+		t.Errorf("second authenticator = %#v, want client.BasicAuth for exporter", auth[1])
+	}
+
+	cfg.HttpHeaders, cfg.BasicAuth = nil, config.BasicAuth{}
+	cfg.Oauth2 = config.Oauth2{TokenUrl: "https://auth.lan/token", ClientId: "exporter", ClientSecret: "s3cret"}
+	if auth := proxyAuthenticators(cfg); len(auth) != 1 {
+		t.Errorf("oauth2: got %d authenticators, want 1", len(auth))
+	} else if _, ok := auth[0].(*client.OAuth2ClientCredentials); !ok {
+		t.Errorf("oauth2 authenticator = %T, want *client.OAuth2ClientCredentials", auth[0])
+	}
+}
+
+// TestRequestPolicies verifies the per-endpoint policies are keyed by the
+// configured request paths.
+func TestRequestPolicies(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	cfg.TdarrStatusPath = "/proxy/status"
+	cfg.RequestPolicies = map[string]config.RequestPolicy{
+		"status":    {Timeout: 2 * time.Second},
+		"pie_stats": {Timeout: time.Minute, Retries: 4, Jitter: 0.2, RetryStatuses: []int{503}},
+	}
+	got := requestPolicies(cfg)
+	want := map[string]client.RequestPolicy{
+		"/proxy/status":          {Timeout: 2 * time.Second},
+		"/api/v2/stats/get-pies": {Timeout: time.Minute, Retries: 4, Jitter: 0.2, RetryStatuses: []int{503}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("requestPolicies = %+v, want %+v", got, want)
+	}
+}
+
+// filteredCollector passes through only the metric family named name, so
+// testutil.ToFloat64 can read one series from a multi-family collector.
+type filteredCollector struct {
+	c    *TdarrCollector
+	name string
+}
+
+func (f filteredCollector) Describe(ch chan<- *prometheus.Desc) { f.c.Describe(ch) }
+
+func (f filteredCollector) Collect(ch chan<- prometheus.Metric) {
+	all := make(chan prometheus.Metric)
+	go func() {
+		f.c.Collect(all)
+		close(all)
+	}()
+	for m := range all {
+		if strings.Contains(m.Desc().String(), `"`+f.name+`"`) {
+			ch <- m
+		}
+	}
+}
+
 // TestLibStatsCache_ReadWritePairing verifies Read always returns the exact
 // snapshot the last Write stored, sequentially. This is the baseline invariant
 // check for the single-lock snapshot Read/Write API replacing the four
@@ -919,3 +1333,236 @@ func TestLibStatsCache_ReadWritePairing_Concurrent(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+// TestCollectContext_Deadline verifies a scrape deadline too close for the
+// library stats sweep serves the cached stats without refetching, and that an
+// expired deadline fails the scrape; both report cause deadline_exceeded.
+func TestCollectContext_Deadline(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	api := newSuccessFakeAPI(cfg)
+	c := newTdarrCollectorWithAPI(cfg, api)
+	pieKey := fakeKey{path: cfg.TdarrPieStatsPath, disc: "lib1"}
+
+	// Warm the cache, then make it stale with a sweep too slow for the deadline.
+	if up := getUpValue(t, c); up != 1 {
+		t.Fatalf("warm-up tdarr_up = %v, want 1", up)
+	}
+	snap := c.statsCache.Read()
+	snap.totals.totalFileCount++
+	snap.sweepDuration = time.Hour
+	c.statsCache.Write(snap)
+	api.resetCalls()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	samples := collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(ctx, ch) })
+	if got := api.callCount(pieKey); got != 0 {
+		t.Errorf("pie requests with the deadline too close = %d, want 0", got)
+	}
+	if up := findOne(t, samples, "tdarr_up", nil).value; up != 0 {
+		t.Errorf("tdarr_up = %v, want 0", up)
+	}
+	findOne(t, samples, "tdarr_exporter_scrape_error", map[string]string{"cause": causeDeadlineExceeded})
+	if !hasName(samples, "tdarr_library_files") {
+		t.Error("cached library series missing from the skipped sweep")
+	}
+	if !hasName(samples, "tdarr_files") {
+		t.Error("general series missing after the skipped sweep")
+	}
+
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+	samples = collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(expired, ch) })
+	if up := findOne(t, samples, "tdarr_up", nil).value; up != 0 {
+		t.Errorf("tdarr_up past the deadline = %v, want 0", up)
+	}
+	findOne(t, samples, "tdarr_exporter_scrape_error", map[string]string{"cause": causeDeadlineExceeded})
+
+	// Without a deadline the stale cache is refetched as before.
+	samples = collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+	if up := findOne(t, samples, "tdarr_up", nil).value; up != 1 {
+		t.Errorf("tdarr_up without a deadline = %v, want 1", up)
+	}
+	if hasName(samples, "tdarr_exporter_scrape_error") {
+		t.Error("scrape error emitted for a successful scrape")
+	}
+	if got := api.callCount(pieKey); got != 1 {
+		t.Errorf("pie requests without a deadline = %d, want 1", got)
+	}
+}
+
+func TestScrapeErrorCause(t *testing.T) {
+	t.Parallel()
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	cancelled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want string
+	}{
+		{"skipped sweep", context.Background(), fmt.Errorf("skip: %w", ErrDeadline), causeDeadlineExceeded},
+		{"deadline passed", expired, fmt.Errorf("%w: %w", ErrUpstream, context.DeadlineExceeded), causeDeadlineExceeded},
+		{"partial", context.Background(), nil, causePartial},
+		{"parse", context.Background(), fmt.Errorf("%w: bad number", ErrParse), causeParse},
+		{"upstream", context.Background(), fmt.Errorf("%w: 502", ErrUpstream), causeUpstream},
+		{"cancelled", cancelled, fmt.Errorf("%w: %w", ErrUpstream, context.Canceled), causeUpstream},
+		{"unknown", context.Background(), errors.New("boom"), causeUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scrapeErrorCause(tt.ctx, tt.err); got != tt.want {
+				t.Errorf("scrapeErrorCause() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestCollect_PhasesAndPieCache verifies each scrape times the phases that
+// ran, and that pie cache lookups are counted by outcome with the cache age.
+func TestCollect_PhasesAndPieCache(t *testing.T) {
+	t.Parallel()
+	cfg := newTestConfig(t)
+	api := newSuccessFakeAPI(cfg)
+	c := newTdarrCollectorWithAPI(cfg, api)
+	scrape := func() []sample {
+		return collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+	}
+	phase := "tdarr_exporter_scrape_phase_duration_seconds"
+
+	samples := scrape()
+	for _, p := range []string{phaseStatus, phaseGeneral, phaseLibraries, phasePies, phaseNodes} {
+		findOne(t, samples, phase, map[string]string{"phase": p})
+	}
+	if got := findOne(t, samples, "tdarr_exporter_pie_cache_misses_total", map[string]string{"reason": missEmpty}).value; got != 1 {
+		t.Errorf("empty misses after the first scrape = %v, want 1", got)
+	}
+	findOne(t, samples, "tdarr_exporter_pie_cache_age_seconds", nil)
+
+	samples = scrape()
+	if got := findOne(t, samples, "tdarr_exporter_pie_cache_hits_total", nil).value; got != 1 {
+		t.Errorf("hits after an unchanged scrape = %v, want 1", got)
+	}
+	if got := countByName(samples, phase); got != 4 {
+		t.Errorf("phases timed on a cache hit = %d, want 4 (no pies)", got)
+	}
+
+	snap := c.statsCache.Read()
+	snap.totals.totalFileCount++
+	c.statsCache.Write(snap)
+	samples = scrape()
+	if got := findOne(t, samples, "tdarr_exporter_pie_cache_misses_total", map[string]string{"reason": missTotalsChanged}).value; got != 1 {
+		t.Errorf("totals_changed misses = %v, want 1", got)
+	}
+}
+
+// TestCollect_CollectorSuccess verifies each collector reports its own
+// outcome and still emits its series when another one fails, while tdarr_up
+// stays 0 unless every enabled collector succeeded.
+func TestCollect_CollectorSuccess(t *testing.T) {
+	t.Parallel()
+	badScore, _ := json.Marshal(TdarrMetric{TotalFileCount: 10, TdarrScore: "n/a", HealthCheckScore: "0"})
+	tests := []struct {
+		name      string
+		setup     func(cfg config.Config, api *fakeTdarrAPI)
+		wantOK    map[string]float64
+		wantCause string
+		// wantFamilies are emitted despite the failure.
+		wantFamilies []string
+	}{
+		{
+			name:         "all succeed",
+			setup:        func(config.Config, *fakeTdarrAPI) {},
+			wantOK:       map[string]float64{subsystemServer: 1, subsystemGeneral: 1, subsystemLibrary: 1, subsystemNodes: 1},
+			wantFamilies: []string{"tdarr_server_info", "tdarr_files", "tdarr_library_files"},
+		},
+		{
+			name: "nodes fail",
+			setup: func(cfg config.Config, api *fakeTdarrAPI) {
+				api.setError(fakeKey{path: cfg.TdarrNodePath}, statErr{"node fetch failed"})
+			},
+			wantOK:       map[string]float64{subsystemServer: 1, subsystemGeneral: 1, subsystemLibrary: 1, subsystemNodes: 0},
+			wantCause:    causeUpstream,
+			wantFamilies: []string{"tdarr_server_info", "tdarr_files", "tdarr_library_files"},
+		},
+		{
+			name: "server fails",
+			setup: func(cfg config.Config, api *fakeTdarrAPI) {
+				api.setError(fakeKey{path: cfg.TdarrStatusPath}, statErr{"status failed"})
+			},
+			wantOK:       map[string]float64{subsystemServer: 0, subsystemGeneral: 1, subsystemLibrary: 1, subsystemNodes: 1},
+			wantCause:    causeUpstream,
+			wantFamilies: []string{"tdarr_files", "tdarr_library_files"},
+		},
+		{
+			name: "general fails to parse",
+			setup: func(cfg config.Config, api *fakeTdarrAPI) {
+				api.setResponse(fakeKey{path: cfg.TdarrStatsPath, disc: "StatisticsJSONDB"}, badScore)
+			},
+			wantOK:       map[string]float64{subsystemServer: 1, subsystemGeneral: 0, subsystemLibrary: 1, subsystemNodes: 1},
+			wantCause:    causeParse,
+			wantFamilies: []string{"tdarr_server_info", "tdarr_library_files"},
+		},
+		{
+			name: "statistics document fails",
+			setup: func(cfg config.Config, api *fakeTdarrAPI) {
+				api.setError(fakeKey{path: cfg.TdarrStatsPath, disc: "StatisticsJSONDB"}, statErr{"stats fetch failed"})
+			},
+			wantOK:       map[string]float64{subsystemServer: 1, subsystemGeneral: 0, subsystemLibrary: 0, subsystemNodes: 1},
+			wantCause:    causeUpstream,
+			wantFamilies: []string{"tdarr_server_info"},
+		},
+		{
+			name: "library partial",
+			setup: func(cfg config.Config, api *fakeTdarrAPI) {
+				api.setError(fakeKey{path: cfg.TdarrPieStatsPath, disc: "lib1"}, statErr{"pie fetch failed"})
+			},
+			wantOK:       map[string]float64{subsystemServer: 1, subsystemGeneral: 1, subsystemLibrary: 0, subsystemNodes: 1},
+			wantCause:    causePartial,
+			wantFamilies: []string{"tdarr_server_info", "tdarr_files"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := newTestConfig(t)
+			api := newSuccessFakeAPI(cfg)
+			tt.setup(cfg, api)
+			c := newTdarrCollectorWithAPI(cfg, api)
+			samples := collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+
+			for collector, want := range tt.wantOK {
+				if got := findOne(t, samples, "tdarr_scrape_collector_success", map[string]string{"collector": collector}).value; got != want {
+					t.Errorf("collector %s success = %v, want %v", collector, got, want)
+				}
+				findOne(t, samples, "tdarr_scrape_collector_duration_seconds", map[string]string{"collector": collector})
+			}
+			for _, family := range tt.wantFamilies {
+				if !hasName(samples, family) {
+					t.Errorf("%s missing", family)
+				}
+			}
+			wantUp := 1.0
+			if tt.wantCause != "" {
+				wantUp = 0
+				findOne(t, samples, "tdarr_exporter_scrape_error", map[string]string{"cause": tt.wantCause})
+			}
+			if got := findOne(t, samples, "tdarr_up", nil).value; got != wantUp {
+				t.Errorf("tdarr_up = %v, want %v", got, wantUp)
+			}
+		})
+	}
+
+	// Only the enabled collectors report.
+	cfg := newTestConfig(t)
+	cfg.Collectors = config.Collectors{Workers: true}
+	c := newTdarrCollectorWithAPI(cfg, newSuccessFakeAPI(cfg))
+	samples := collectSamples(t, func(ch chan<- prometheus.Metric) { c.CollectContext(context.Background(), ch) })
+	if got := countByName(samples, "tdarr_scrape_collector_success"); got != 1 {
+		t.Errorf("collectors reported with workers only = %d, want 1 (nodes)", got)
+	}
+	findOne(t, samples, "tdarr_scrape_collector_success", map[string]string{"collector": subsystemNodes})
+}
@@ -1,14 +1,19 @@
 package config
 
 import (
+	"crypto/tls"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"math"
 	"net/url"
 	"os"
 	"path"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/rs/zerolog"
@@ -23,24 +28,103 @@ var errFlagParse = errors.New("invalid command line arguments")
 const (
 	envTdarrUrl           = "TDARR_URL"
 	envTdarrApiKey        = "TDARR_API_KEY"
+	envTdarrApiKeyFile    = "TDARR_API_KEY_FILE"
 	envSslVerify          = "VERIFY_SSL"
 	envPrometheusPort     = "PROMETHEUS_PORT"
 	envPrometheusPath     = "PROMETHEUS_PATH"
 	envLogLevel           = "LOG_LEVEL"
 	envHttpMaxConcurrency = "HTTP_MAX_CONCURRENCY"
 	envHttpTimeoutSeconds = "HTTP_TIMEOUT_SECONDS"
+	envCircuitThreshold   = "CIRCUIT_BREAKER_THRESHOLD"
+	envCircuitCooldown    = "CIRCUIT_BREAKER_COOLDOWN_SECONDS"
+	envPollInterval       = "POLL_INTERVAL"
+	envScrapeTimeout      = "SCRAPE_TIMEOUT_OFFSET"
+	envStateFile          = "STATE_FILE"
+	envFileSizeBuckets    = "FILE_SIZE_BUCKETS"
+	envFilesPageSize      = "FILES_PAGE_SIZE"
+	envCodecResolution    = "CODEC_RESOLUTION_MAX_SERIES"
+	envPluginsRefresh     = "PLUGINS_REFRESH_INTERVAL"
 	envListenAddress      = "LISTEN_ADDRESS"
 	envInstanceName       = "INSTANCE_NAME"
+	envProbeEnabled       = "PROBE_ENABLED"
+	envProbeMaxTargets    = "PROBE_MAX_TARGETS"
+	envProbeModules       = "PROBE_MODULES"
+	envConfigFile         = "CONFIG_FILE"
+	envReloadEnabled      = "RELOAD_ENABLED"
+	envDebugCacheEnabled  = "DEBUG_CACHE_ENABLED"
+	envTlsCaFile          = "TLS_CA_FILE"
+	envTlsCertFile        = "TLS_CERT_FILE"
+	envTlsKeyFile         = "TLS_KEY_FILE"
+	envTlsServerName      = "TLS_SERVER_NAME"
+	envTlsMinVersion      = "TLS_MIN_VERSION"
+	envBasicAuthUsername  = "BASIC_AUTH_USERNAME"
+	envBasicAuthPassword  = "BASIC_AUTH_PASSWORD"
+	envBearerToken        = "BEARER_TOKEN"
+	envOauth2TokenUrl     = "OAUTH2_TOKEN_URL"
+	envOauth2ClientId     = "OAUTH2_CLIENT_ID"
+	envOauth2ClientSecret = "OAUTH2_CLIENT_SECRET"
+	envOauth2Scopes       = "OAUTH2_SCOPES"
+	envHttpHeaders        = "HTTP_HEADERS"
+	envCollectorServer    = "COLLECTOR_SERVER"
+	envCollectorGeneral   = "COLLECTOR_GENERAL"
+	envCollectorLibrary   = "COLLECTOR_LIBRARY"
+	envCollectorNodes     = "COLLECTOR_NODES"
+	envCollectorWorkers   = "COLLECTOR_WORKERS"
+	envCollectorFiles     = "COLLECTOR_FILES"
+	envCollectorStreams   = "COLLECTOR_STREAMS"
+	envCollectorFlows     = "COLLECTOR_FLOWS"
+	envCollectorPlugins   = "COLLECTOR_PLUGINS"
+	envCollectorStaged    = "COLLECTOR_STAGED"
+	// envProbeModulePrefix prefixes the per-module credential variables, e.g.
+	// PROBE_MODULE_TDARR_4K_API_KEY for a module named "tdarr-4k" (see
+	// probeModuleEnvKey).
+	envProbeModulePrefix = "PROBE_MODULE_"
 )
 
+// DefaultProbeModule is the module used by /probe when the request names none.
+// It carries no credentials on purpose: /probe targets are caller-supplied, so
+// the exporter's own api key must never be sent to them implicitly.
+const DefaultProbeModule = "default"
+
+// ErrUnknownProbeModule is returned by ProbeConfig for a module name that was
+// not configured, so the /probe handler can answer 400 instead of 500.
+var ErrUnknownProbeModule = errors.New("unknown probe module")
+
+// ProbeModule holds the per-module settings applied to /probe targets, in the
+// spirit of blackbox_exporter modules: the credentials live in the exporter
+// config and the scrape only names the module.
+type ProbeModule struct {
+	ApiKey    string
+	VerifySsl bool
+}
+
 type Config struct {
-	Version            bool
-	LogLevel           string
-	url                string
-	UrlParsed          *url.URL
-	InstanceName       string
-	ApiKey             string
-	VerifySsl          bool
+	Version      bool
+	LogLevel     string
+	url          string
+	UrlParsed    *url.URL
+	InstanceName string
+	ApiKey       string
+	// ApiKeyFile is read for the api key instead of ApiKey, and re-read at
+	// runtime so a rotated key is picked up (see client.FileAPIKey).
+	ApiKeyFile string
+	VerifySsl  bool
+	// TlsCaFile, TlsCertFile and TlsKeyFile are PEM files for a private CA and
+	// an mTLS client certificate; they are reloaded when they change on disk
+	// (see client.TLSFiles). TlsServerName overrides the verified server name
+	// and TlsMinVersion is one of tlsVersions' keys, empty for the Go default.
+	TlsCaFile     string
+	TlsCertFile   string
+	TlsKeyFile    string
+	TlsServerName string
+	TlsMinVersion string
+	// BasicAuth, BearerToken and Oauth2 authenticate to a proxy in front of
+	// Tdarr through the Authorization header, so at most one is set. They and
+	// HttpHeaders are sent alongside the api key.
+	BasicAuth          BasicAuth
+	BearerToken        string
+	Oauth2             Oauth2
+	HttpHeaders        map[string]string
 	PrometheusPort     string
 	PrometheusPath     string
 	HttpTimeoutSeconds int
@@ -48,8 +132,277 @@ type Config struct {
 	TdarrPieStatsPath  string
 	TdarrNodePath      string
 	TdarrStatusPath    string
+	// TdarrFilesPath is the paged file table the files collector walks.
+	TdarrFilesPath string
+	// TdarrPluginsPath is the plugin search the plugins collector reads the
+	// plugin catalogue from.
+	TdarrPluginsPath string
+	// RequestPolicies maps a tdarr_paths key (stats, pie_stats, nodes, status, files, plugins)
+	// to the timeout and retries for that endpoint. Config file only; an
+	// endpoint without one keeps http_timeout_seconds and the default retries.
+	RequestPolicies    map[string]RequestPolicy
 	HttpMaxConcurrency int
-	ListenAddress      string
+	// CircuitBreakerThreshold is the number of consecutive failed requests that
+	// open the circuit breaker, 0 to disable it. CircuitBreakerCooldownSeconds
+	// is how long it stays open before a probe request is let through.
+	CircuitBreakerThreshold       int
+	CircuitBreakerCooldownSeconds int
+	// PollInterval, when above 0, scrapes Tdarr in the background at this
+	// interval and serves /metrics from the latest result instead of scraping
+	// on every request. /probe always scrapes live.
+	PollInterval time.Duration
+	// ScrapeTimeoutOffset is subtracted from the scrape timeout Prometheus
+	// sends to get the deadline of a /metrics scrape.
+	ScrapeTimeoutOffset time.Duration
+	// StateFile, when set, is where the per-library stats cache of each target
+	// is saved, so a restart does not refetch every library.
+	StateFile     string
+	ListenAddress string
+	// Collectors selects the metric groups scraped from Tdarr.
+	Collectors Collectors
+	// FileSizeBuckets are the upper bounds in bytes of the
+	// tdarr_library_file_size_bytes histogram, strictly increasing.
+	FileSizeBuckets []float64
+	// FilesPageSize is how many files the files collector requests at a time.
+	FilesPageSize int
+	// CodecResolutionMaxSeries, when above 0, enables the joint codec by
+	// resolution bytes of the files collector, at most this many series per
+	// library.
+	CodecResolutionMaxSeries int
+	// PluginsRefreshInterval is how long the plugins collector serves the
+	// plugin catalogue and library plugin stacks it last fetched.
+	PluginsRefreshInterval time.Duration
+	// LabelPolicies rewrite label values that can leak file and library names.
+	// Config file only.
+	LabelPolicies LabelPolicies
+	// ProbeEnabled registers the multi-target /probe route.
+	ProbeEnabled bool
+	// ProbeMaxTargets bounds how many per-target collectors /probe keeps cached.
+	ProbeMaxTargets int
+	// ProbeModules maps a module name to its settings. Always contains
+	// DefaultProbeModule.
+	ProbeModules map[string]ProbeModule
+	// ReloadEnabled registers the POST /-/reload route. SIGHUP reloads
+	// regardless.
+	ReloadEnabled bool
+	// DebugCacheEnabled registers the GET /debug/cache route. It is off by
+	// default: the view lists every library's name, whatever the library_name
+	// label policy.
+	DebugCacheEnabled bool
+	// ConfigFile is the -config.file path the config was loaded from, if any.
+	ConfigFile string
+	// EnvFile is the -env_file path overlaid on the process environment, if any.
+	EnvFile string
+	// Targets are additional Tdarr instances scraped alongside the primary url.
+	// They can only be declared in the config file.
+	Targets []Target
+}
+
+// RequestPolicy is the timeout and retry behavior for one Tdarr endpoint, see
+// client.RequestPolicy. Timeout 0 keeps http_timeout_seconds and nil
+// RetryStatuses retries 429 and every 5xx.
+type RequestPolicy struct {
+	Timeout       time.Duration
+	Retries       int
+	Backoff       time.Duration
+	MaxBackoff    time.Duration
+	Jitter        float64
+	RetryStatuses []int
+}
+
+// Collectors toggles the groups of metrics the exporter scrapes. A disabled
+// group makes none of its requests to Tdarr and drops its metrics from
+// Describe.
+type Collectors struct {
+	// Server is /api/v2/status and the tdarr_server_* series. Without it the
+	// Tdarr version is unknown, so the library stats source is probed.
+	Server bool
+	// General is the statistics document totals, e.g. tdarr_files.
+	General bool
+	// Library is the library list and the per-library tdarr_library_* series.
+	Library bool
+	// Nodes is the per-node tdarr_node_* series, including worker counts,
+	// limits and queue lengths by worker type.
+	Nodes bool
+	// Workers is the per-worker tdarr_node_worker_* series.
+	Workers bool
+	// Files walks every file Tdarr knows for the per-library size
+	// distribution. Off by default, as the walk grows with the file count.
+	Files bool
+	// Streams counts the stream properties of every file, e.g. HDR and audio
+	// languages, from the same walk as Files. Off by default.
+	Streams bool
+	// Flows is the flow inventory, tdarr_flow_*, and the flow and step of
+	// each flow worker when Workers is enabled too. Off by default, as Tdarr
+	// releases before flows fail it.
+	Flows bool
+	// Plugins is the plugin catalogue, tdarr_plugin_info, and the classic
+	// plugin stack of each library, refreshed every PluginsRefreshInterval.
+	// Off by default.
+	Plugins bool
+	// Staged is the files waiting in staging for a manual accept or reject,
+	// by library. Off by default, as the staged documents are fetched whole.
+	Staged bool
+}
+
+// DefaultCollectors returns the Collectors enabled by default: every group
+// but the opt-in files, streams, flows, plugins and staged collectors.
+func DefaultCollectors() Collectors {
+	return Collectors{Server: true, General: true, Library: true, Nodes: true, Workers: true}
+}
+
+// AllCollectors returns Collectors with every group enabled.
+func AllCollectors() Collectors {
+	all := DefaultCollectors()
+	all.Files = true
+	all.Streams = true
+	all.Flows = true
+	all.Plugins = true
+	all.Staged = true
+	return all
+}
+
+// DefaultFileSizeBuckets are the default FileSizeBuckets, from 100MB (a short
+// episode) to 50GB (a remux).
+var DefaultFileSizeBuckets = []float64{100e6, 250e6, 500e6, 1e9, 2e9, 4e9, 8e9, 16e9, 32e9, 50e9}
+
+// collectorToggle ties a Collectors field to its collector.<name> setting.
+type collectorToggle struct {
+	name    string
+	env     string
+	help    string
+	enabled *bool
+}
+
+func (c *Collectors) toggles() []collectorToggle {
+	return []collectorToggle{
+		{"server", envCollectorServer, "server status and version (/api/v2/status)", &c.Server},
+		{"general", envCollectorGeneral, "instance-wide statistics totals", &c.General},
+		{"library", envCollectorLibrary, "per-library statistics, the expensive part of a scrape", &c.Library},
+		{"nodes", envCollectorNodes, "per-node resources, worker counts, limits and queues", &c.Nodes},
+		{"workers", envCollectorWorkers, "per-worker progress and status", &c.Workers},
+		{"files", envCollectorFiles, "per-library file size histograms, walking every file in tdarr (off by default)", &c.Files},
+		{"streams", envCollectorStreams, "per-library HDR, bit depth, frame rate, channel layout and language counts, walking every file in tdarr (off by default)", &c.Streams},
+		{"flows", envCollectorFlows, "flow inventory and the flow step of each flow worker (off by default)", &c.Flows},
+		{"plugins", envCollectorPlugins, "plugin catalogue and per-library plugin stacks, refreshed every plugins_refresh_interval (off by default)", &c.Plugins},
+		{"staged", envCollectorStaged, "per-library count, size and age of the files awaiting approval in staging (off by default)", &c.Staged},
+	}
+}
+
+// LabelMode is how a LabelPolicy rewrites a label value.
+type LabelMode string
+
+const (
+	// LabelKeep leaves the value as Tdarr reports it, the default.
+	LabelKeep LabelMode = "keep"
+	// LabelBasename keeps the last element of a path, with / or \ separators.
+	LabelBasename LabelMode = "basename"
+	// LabelStripPrefix removes Prefix from the start of the value.
+	LabelStripPrefix LabelMode = "strip_prefix"
+	// LabelRegex applies the first of Rules whose Match matches the value.
+	LabelRegex LabelMode = "regex"
+	// LabelHash replaces the value with a truncated HMAC-SHA256 keyed by
+	// HashKey, stable across restarts and exporters sharing the key.
+	LabelHash LabelMode = "hash"
+	// LabelDrop replaces the value with "".
+	LabelDrop LabelMode = "drop"
+)
+
+// LabelPolicy is the rewrite applied to one label. The zero value keeps the
+// value unchanged.
+type LabelPolicy struct {
+	Mode    LabelMode
+	Prefix  string
+	Rules   []LabelRewrite
+	HashKey string
+}
+
+// LabelRewrite is one regex rule: a value Match matches is replaced by
+// Match.ReplaceAllString(value, Replace), so Replace can use $1 and ${name}.
+type LabelRewrite struct {
+	Match   *regexp.Regexp
+	Replace string
+}
+
+// LabelPolicies are the label policies, by label.
+type LabelPolicies struct {
+	// WorkerFile is the worker_file label of tdarr_node_worker_info.
+	WorkerFile LabelPolicy
+	// LibraryName is the library_name label of tdarr_library_info.
+	LibraryName LabelPolicy
+	// LibraryFolder is the cache_folder and output_folder labels of
+	// tdarr_library_settings_info.
+	LibraryFolder LabelPolicy
+}
+
+// defaultRequestPolicy fills the fields a request_policies entry leaves out.
+var defaultRequestPolicy = RequestPolicy{Retries: 2, Backoff: time.Second, MaxBackoff: 30 * time.Second}
+
+// BasicAuth is an HTTP basic auth pair; the zero value means unset.
+type BasicAuth struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+func (b BasicAuth) isSet() bool { return b != BasicAuth{} }
+
+// Oauth2 is an OAuth2 client-credentials grant (see
+// client.OAuth2ClientCredentials); the zero value means unset.
+type Oauth2 struct {
+	TokenUrl     string   `yaml:"token_url"`
+	ClientId     string   `yaml:"client_id"`
+	ClientSecret string   `yaml:"client_secret"`
+	Scopes       []string `yaml:"scopes"`
+}
+
+func (o Oauth2) isSet() bool {
+	return o.TokenUrl != "" || o.ClientId != "" || o.ClientSecret != "" || len(o.Scopes) > 0
+}
+
+// Target is one additional Tdarr instance from the config file's targets list.
+// Nil override fields inherit the top-level value after env and flags are
+// applied, so e.g. -http_timeout_seconds still reaches targets that do not pin
+// their own timeout.
+type Target struct {
+	url string
+	// line is the config file line of the list item, for error messages.
+	line          int
+	UrlParsed     *url.URL
+	InstanceName  string
+	ApiKey        *string
+	ApiKeyFile    *string
+	VerifySsl     *bool
+	TlsCaFile     *string
+	TlsCertFile   *string
+	TlsKeyFile    *string
+	TlsServerName *string
+	// Setting any of BasicAuth, BearerToken or Oauth2 replaces the inherited
+	// Authorization scheme; a non-nil HttpHeaders replaces the inherited map.
+	BasicAuth          *BasicAuth
+	BearerToken        *string
+	Oauth2             *Oauth2
+	HttpHeaders        map[string]string
+	HttpTimeoutSeconds *int
+	HttpMaxConcurrency *int
+}
+
+// tlsVersions maps the accepted tls_min_version values to crypto/tls versions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSMinVersionValue returns TlsMinVersion as a crypto/tls version constant, or
+// 0 (the Go default) when it is unset. parseConfig has already validated it.
+func (c Config) TLSMinVersionValue() uint16 {
+	return tlsVersions[c.TlsMinVersion]
+}
+
+// HasTLSOptions reports whether any TLS setting beyond VerifySsl is configured.
+func (c Config) HasTLSOptions() bool {
+	return c.TlsCaFile != "" || c.TlsCertFile != "" || c.TlsKeyFile != "" || c.TlsServerName != "" || c.TlsMinVersion != ""
 }
 
 // parseLogLevel maps a string log level to a zerolog.Level. It returns an error
@@ -89,21 +442,39 @@ func getDefaults() Config {
 		TdarrNodePath:      "/api/v2/get-nodes",
 		TdarrPieStatsPath:  "/api/v2/stats/get-pies",
 		TdarrStatusPath:    "/api/v2/status",
+		TdarrFilesPath:     "/api/v2/client/status-tables",
+		TdarrPluginsPath:   "/api/v2/search-plugins",
 		HttpMaxConcurrency: 3,
 		ListenAddress:      "0.0.0.0",
+		ProbeMaxTargets:    16,
+		// 5 failed requests is one failed scrape's worth at most: status, stats
+		// and a few libraries.
+		CircuitBreakerThreshold:       5,
+		CircuitBreakerCooldownSeconds: 30,
+		Collectors:                    DefaultCollectors(),
+		FileSizeBuckets:               DefaultFileSizeBuckets,
+		FilesPageSize:                 1000,
+		// The catalogue only changes when plugins are updated or installed.
+		PluginsRefreshInterval: time.Hour,
+		// Leaves time to encode and send the response, like blackbox_exporter.
+		ScrapeTimeoutOffset: 500 * time.Millisecond,
 	}
 }
 
-// applyEnvDefaults overlays environment variables on top of getDefaults using
-// the injected getenv. precedence: defaults -> env (flags are layered later).
-func applyEnvDefaults(getenv func(string) string) (Config, error) {
-	defaults := getDefaults()
+// applyEnvDefaults overlays environment variables on top of base (getDefaults,
+// or the config file layered on it) using the injected getenv.
+// precedence: defaults -> file -> env (flags are layered later).
+func applyEnvDefaults(base Config, getenv func(string) string) (Config, error) {
+	defaults := base
 	if tdarrUrlEnv := getenv(envTdarrUrl); tdarrUrlEnv != "" {
 		defaults.url = tdarrUrlEnv
 	}
 	if tdarrApiKeyEnv := getenv(envTdarrApiKey); tdarrApiKeyEnv != "" {
 		defaults.ApiKey = tdarrApiKeyEnv
 	}
+	if v := getenv(envTdarrApiKeyFile); v != "" {
+		defaults.ApiKeyFile = v
+	}
 	if sslVerifyEnv := getenv(envSslVerify); sslVerifyEnv != "" {
 		boolValue, err := strconv.ParseBool(sslVerifyEnv)
 		if err != nil {
@@ -111,6 +482,57 @@ func applyEnvDefaults(getenv func(string) string) (Config, error) {
 		}
 		defaults.VerifySsl = boolValue
 	}
+	for _, e := range []struct {
+		key string
+		dst *string
+	}{
+		{envTlsCaFile, &defaults.TlsCaFile},
+		{envTlsCertFile, &defaults.TlsCertFile},
+		{envTlsKeyFile, &defaults.TlsKeyFile},
+		{envTlsServerName, &defaults.TlsServerName},
+		{envTlsMinVersion, &defaults.TlsMinVersion},
+		{envBasicAuthUsername, &defaults.BasicAuth.Username},
+		{envBasicAuthPassword, &defaults.BasicAuth.Password},
+		{envBearerToken, &defaults.BearerToken},
+		{envOauth2TokenUrl, &defaults.Oauth2.TokenUrl},
+		{envOauth2ClientId, &defaults.Oauth2.ClientId},
+		{envOauth2ClientSecret, &defaults.Oauth2.ClientSecret},
+	} {
+		if v := getenv(e.key); v != "" {
+			*e.dst = v
+		}
+	}
+	if v := getenv(envFileSizeBuckets); v != "" {
+		buckets, err := parseBuckets(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid value for file_size_buckets: %w", err)
+		}
+		defaults.FileSizeBuckets = buckets
+	}
+	if v := getenv(envFilesPageSize); v != "" {
+		intValue, err := strconv.Atoi(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid value for files_page_size, please provide a valid integer: %w", err)
+		}
+		defaults.FilesPageSize = intValue
+	}
+	if v := getenv(envCodecResolution); v != "" {
+		intValue, err := strconv.Atoi(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid value for codec_resolution_max_series, please provide a valid integer: %w", err)
+		}
+		defaults.CodecResolutionMaxSeries = intValue
+	}
+	if v := getenv(envOauth2Scopes); v != "" {
+		defaults.Oauth2.Scopes = splitList(v)
+	}
+	if v := getenv(envHttpHeaders); v != "" {
+		headers, err := parseHeaderList(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid value for %s: %w", envHttpHeaders, err)
+		}
+		defaults.HttpHeaders = headers
+	}
 	if prometheusPortEnv := getenv(envPrometheusPort); prometheusPortEnv != "" {
 		defaults.PrometheusPort = prometheusPortEnv
 	}
@@ -134,15 +556,247 @@ func applyEnvDefaults(getenv func(string) string) (Config, error) {
 		}
 		defaults.HttpTimeoutSeconds = intValue
 	}
+	if v := getenv(envCircuitThreshold); v != "" {
+		intValue, err := strconv.Atoi(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid value for circuit_breaker_threshold, please provide a valid integer: %w", err)
+		}
+		defaults.CircuitBreakerThreshold = intValue
+	}
+	if v := getenv(envCircuitCooldown); v != "" {
+		intValue, err := strconv.Atoi(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid value for circuit_breaker_cooldown_seconds, please provide a valid integer: %w", err)
+		}
+		defaults.CircuitBreakerCooldownSeconds = intValue
+	}
+	if v := getenv(envPollInterval); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid value for poll_interval, please provide a duration such as 30s: %w", err)
+		}
+		defaults.PollInterval = d
+	}
+	if v := getenv(envPluginsRefresh); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid value for plugins_refresh_interval, please provide a duration such as 1h: %w", err)
+		}
+		defaults.PluginsRefreshInterval = d
+	}
+	if v := getenv(envScrapeTimeout); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid value for scrape_timeout_offset, please provide a duration such as 500ms: %w", err)
+		}
+		defaults.ScrapeTimeoutOffset = d
+	}
+	if v := getenv(envStateFile); v != "" {
+		defaults.StateFile = v
+	}
 	if v := getenv(envListenAddress); v != "" {
 		defaults.ListenAddress = v
 	}
 	if v := getenv(envInstanceName); v != "" {
 		defaults.InstanceName = v
 	}
+	if v := getenv(envProbeEnabled); v != "" {
+		boolValue, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid value for probe_enabled, please provide one of true or false: %w", err)
+		}
+		defaults.ProbeEnabled = boolValue
+	}
+	if v := getenv(envProbeMaxTargets); v != "" {
+		intValue, err := strconv.Atoi(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid value for probe_max_targets, please provide a valid integer: %w", err)
+		}
+		defaults.ProbeMaxTargets = intValue
+	}
+	if v := getenv(envReloadEnabled); v != "" {
+		boolValue, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid value for reload_enabled, please provide one of true or false: %w", err)
+		}
+		defaults.ReloadEnabled = boolValue
+	}
+	if v := getenv(envDebugCacheEnabled); v != "" {
+		boolValue, err := strconv.ParseBool(v)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid value for debug_cache_enabled, please provide one of true or false: %w", err)
+		}
+		defaults.DebugCacheEnabled = boolValue
+	}
+	for _, t := range defaults.Collectors.toggles() {
+		if v := getenv(t.env); v != "" {
+			boolValue, err := strconv.ParseBool(v)
+			if err != nil {
+				return Config{}, fmt.Errorf("invalid value for collector.%s, please provide one of true or false: %w", t.name, err)
+			}
+			*t.enabled = boolValue
+		}
+	}
+	modules, err := probeModulesFromEnv(defaults.ProbeModules, getenv)
+	if err != nil {
+		return Config{}, err
+	}
+	defaults.ProbeModules = modules
 	return defaults, nil
 }
 
+// probeModuleEnvKey builds the env var name for one setting of a probe module:
+// the module name is upper-cased and every character outside [A-Z0-9] becomes
+// '_' so names like "tdarr-4k" map to PROBE_MODULE_TDARR_4K_<SETTING>.
+func probeModuleEnvKey(module, setting string) string {
+	name := strings.Map(func(r rune) rune {
+		r = unicode.ToUpper(r)
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, module)
+	return envProbeModulePrefix + name + "_" + setting
+}
+
+// probeModulesFromEnv reads the comma-separated PROBE_MODULES list and each
+// module's PROBE_MODULE_<NAME>_API_KEY / _VERIFY_SSL settings on top of base
+// (the modules from the config file, if any). Modules are listed explicitly
+// (rather than discovered by scanning the environment) so the injected getenv
+// stays the only environment seam. The credential-less DefaultProbeModule is
+// always present and cannot be redefined; an env module may replace a module
+// of the same name from the file, like any other env-over-file setting.
+func probeModulesFromEnv(base map[string]ProbeModule, getenv func(string) string) (map[string]ProbeModule, error) {
+	modules := make(map[string]ProbeModule, len(base)+1)
+	for name, module := range base {
+		modules[name] = module
+	}
+	modules[DefaultProbeModule] = ProbeModule{VerifySsl: true}
+	fromEnv := make(map[string]bool)
+	for name := range strings.SplitSeq(getenv(envProbeModules), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if name == DefaultProbeModule || fromEnv[name] {
+			return nil, fmt.Errorf("probe module %q is defined more than once or shadows a built-in module", name)
+		}
+		fromEnv[name] = true
+		module := ProbeModule{ApiKey: getenv(probeModuleEnvKey(name, "API_KEY")), VerifySsl: true}
+		if v := getenv(probeModuleEnvKey(name, "VERIFY_SSL")); v != "" {
+			boolValue, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for %s, please provide one of true or false: %w", probeModuleEnvKey(name, "VERIFY_SSL"), err)
+			}
+			module.VerifySsl = boolValue
+		}
+		modules[name] = module
+	}
+	return modules, nil
+}
+
+// splitList splits a comma-separated list, trimming spaces and dropping empty
+// items.
+func splitList(s string) []string {
+	var items []string
+	for item := range strings.SplitSeq(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseHeaderList parses comma-separated Name=Value pairs. Values cannot
+// contain commas here; the config file's http_headers map has no such limit.
+func parseHeaderList(s string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, item := range splitList(s) {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("header %q is not in Name=Value form", item)
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// parseBuckets parses comma-separated histogram bucket bounds; validateBuckets
+// checks them once every source is layered.
+func parseBuckets(s string) ([]float64, error) {
+	var buckets []float64
+	for _, item := range splitList(s) {
+		bound, err := strconv.ParseFloat(item, 64)
+		if err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, bound)
+	}
+	return buckets, nil
+}
+
+// validateBuckets requires at least one bucket bound, all positive, finite
+// and strictly increasing, as prometheus.NewConstHistogram expects.
+func validateBuckets(buckets []float64) error {
+	if len(buckets) == 0 {
+		return fmt.Errorf("at least one bucket is needed")
+	}
+	for i, bound := range buckets {
+		if !(bound > 0) || math.IsInf(bound, 1) {
+			return fmt.Errorf("bucket bounds must be positive and finite, got %g", bound)
+		}
+		if i > 0 && bound <= buckets[i-1] {
+			return fmt.Errorf("bucket bounds must be strictly increasing, got %g after %g", bound, buckets[i-1])
+		}
+	}
+	return nil
+}
+
+// validHeaderName reports whether name is an RFC 9110 token, the only form
+// net/http sends as a header field name.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("!#$%&'*+-.^_`|~", r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateAuth checks one set of proxy credentials, top-level or a target's.
+// Error messages use the flag names; the config file keys nest the same words.
+func validateAuth(basic BasicAuth, bearerToken string, oauth2 Oauth2, headers map[string]string) error {
+	schemes := 0
+	for _, set := range []bool{basic.isSet(), bearerToken != "", oauth2.isSet()} {
+		if set {
+			schemes++
+		}
+	}
+	if schemes > 1 {
+		return fmt.Errorf("basic_auth, bearer_token and oauth2 all set the Authorization header and are mutually exclusive, set only one")
+	}
+	if basic.isSet() && basic.Username == "" {
+		return fmt.Errorf("basic_auth_password requires basic_auth_username")
+	}
+	if oauth2.isSet() {
+		if oauth2.TokenUrl == "" || oauth2.ClientId == "" || oauth2.ClientSecret == "" {
+			return fmt.Errorf("oauth2 needs oauth2_token_url, oauth2_client_id and oauth2_client_secret")
+		}
+		if u, err := url.Parse(oauth2.TokenUrl); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("oauth2_token_url must be an absolute http or https url, got %q", oauth2.TokenUrl)
+		}
+	}
+	for name := range headers {
+		if !validHeaderName(name) {
+			return fmt.Errorf("http_headers: %q is not a valid header name", name)
+		}
+	}
+	return nil
+}
+
 // parseUrl validates and parses the provided url. A missing scheme defaults to
 // https. It returns an error instead of exiting on parse failure.
 func parseUrl(urlString string) (*url.URL, error) {
@@ -167,26 +821,87 @@ func parseUrl(urlString string) (*url.URL, error) {
 }
 
 // parseConfig is the pure, testable core of configuration loading. It applies
-// precedence defaults -> env -> flags using the injected flag set, args, and
-// getenv, and returns validation errors instead of exiting. It does NOT mutate
-// any global state (including the zerolog level).
+// precedence defaults -> config file -> env -> flags using the injected flag
+// set, args, and getenv, and returns validation errors instead of exiting. It
+// does NOT mutate any global state (including the zerolog level).
 func parseConfig(fs *flag.FlagSet, args []string, getenv func(string) string) (Config, error) {
-	defaults, err := applyEnvDefaults(getenv)
+	// The env file is overlaid on getenv before anything reads the environment,
+	// so it can also carry CONFIG_FILE.
+	envFile, _ := lookupFlagArg(args, "env_file")
+	if envFile != "" {
+		fileEnv, err := readEnvFile(envFile)
+		if err != nil {
+			return Config{}, err
+		}
+		getenv = overlayEnv(fileEnv, getenv)
+	}
+	// The config file has to be loaded before the flags are defined because its
+	// values become the flag defaults (so -h shows the effective values). The
+	// -config.file flag is still defined below so it appears in the usage and
+	// parses normally; lookupFlagArg only peeks at it ahead of time.
+	configFile := getenv(envConfigFile)
+	if v, ok := lookupFlagArg(args, "config.file"); ok {
+		configFile = v
+	}
+	base := getDefaults()
+	if configFile != "" {
+		var err error
+		if base, err = loadConfigFile(configFile, base); err != nil {
+			return Config{}, err
+		}
+	}
+	defaults, err := applyEnvDefaults(base, getenv)
 	if err != nil {
 		return Config{}, err
 	}
 
-	url := fs.String("url", defaults.url, "valid url for tdarr instance, ex: https://tdarr.somedomain.com")
+	tdarrUrl := fs.String("url", defaults.url, "valid url for tdarr instance, ex: https://tdarr.somedomain.com")
 	apiKeyAuth := fs.String("api_key", defaults.ApiKey, "api token for tdarr instance if authentication is enabled")
+	apiKeyFile := fs.String("api_key_file", defaults.ApiKeyFile, "file to read the api token from instead of api_key; re-read at runtime so a rotated key is picked up without a restart")
 	sslVerify := fs.Bool("verify_ssl", defaults.VerifySsl, "verify ssl certificates from tdarr")
+	tlsCaFile := fs.String("tls_ca_file", defaults.TlsCaFile, "PEM CA bundle used instead of the system roots to verify tdarr; reloaded when it changes")
+	tlsCertFile := fs.String("tls_cert_file", defaults.TlsCertFile, "PEM client certificate for mTLS to tdarr (requires tls_key_file); reloaded when it changes")
+	tlsKeyFile := fs.String("tls_key_file", defaults.TlsKeyFile, "PEM private key for tls_cert_file")
+	tlsServerName := fs.String("tls_server_name", defaults.TlsServerName, "server name sent as SNI and verified against tdarr's certificate, if different from the url host")
+	tlsMinVersion := fs.String("tls_min_version", defaults.TlsMinVersion, "minimum TLS version for tdarr connections: 1.0, 1.1, 1.2 or 1.3; unset uses the Go default, TLS 1.2")
+	basicAuthUsername := fs.String("basic_auth_username", defaults.BasicAuth.Username, "username for http basic auth to a proxy in front of tdarr")
+	basicAuthPassword := fs.String("basic_auth_password", defaults.BasicAuth.Password, "password for basic_auth_username")
+	bearerToken := fs.String("bearer_token", defaults.BearerToken, "static bearer token for a proxy in front of tdarr")
+	oauth2TokenUrl := fs.String("oauth2_token_url", defaults.Oauth2.TokenUrl, "token endpoint for an oauth2 client-credentials proxy in front of tdarr")
+	oauth2ClientId := fs.String("oauth2_client_id", defaults.Oauth2.ClientId, "oauth2 client id")
+	oauth2ClientSecret := fs.String("oauth2_client_secret", defaults.Oauth2.ClientSecret, "oauth2 client secret")
+	// Lists are only parsed when given on the command line, so a value from
+	// the config file (which may contain commas) is never round-tripped
+	// through a flag string.
+	oauth2Scopes := fs.String("oauth2_scopes", "", "comma-separated oauth2 scopes to request")
+	httpHeaders := fs.String("http_headers", "", "comma-separated Name=Value headers sent with every request to tdarr")
+	fileSizeBuckets := fs.String("file_size_buckets", "", "comma-separated upper bounds in bytes of the tdarr_library_file_size_bytes histogram buckets, e.g. 1e9,4e9,16e9")
+	filesPageSize := fs.Int("files_page_size", defaults.FilesPageSize, "number of files the files collector requests from tdarr at a time")
+	pluginsRefresh := fs.Duration("plugins_refresh_interval", defaults.PluginsRefreshInterval, "how long the plugins collector serves the plugin catalogue and library plugin stacks before fetching them again, e.g. 1h")
+	codecResolution := fs.Int("codec_resolution_max_series", defaults.CodecResolutionMaxSeries, "when above 0, the files collector also reports bytes by video codec and resolution together, at most this many series per library; 0 disables it")
 	promPort := fs.String("prometheus_port", defaults.PrometheusPort, "port for prometheus exporter")
 	promPath := fs.String("prometheus_path", defaults.PrometheusPath, "path to use for prometheus exporter")
 	logLevel := fs.String("log_level", defaults.LogLevel, "log level to use, see link for possible values: https://pkg.go.dev/github.com/rs/zerolog#Level")
 	httpMaxConcurrency := fs.Int("http_max_concurrency", defaults.HttpMaxConcurrency, "maximum number of concurrent http requests to make when requesting per Library stats")
 	httpTimeoutSeconds := fs.Int("http_timeout_seconds", defaults.HttpTimeoutSeconds, "total time budget in seconds for an http request to the tdarr instance, including transport-level retries and backoff (a low value can silently truncate retries)")
+	circuitThreshold := fs.Int("circuit_breaker_threshold", defaults.CircuitBreakerThreshold, "consecutive failed requests to tdarr after which scrapes fail fast without contacting it; 0 disables the circuit breaker")
+	circuitCooldown := fs.Int("circuit_breaker_cooldown_seconds", defaults.CircuitBreakerCooldownSeconds, "seconds the circuit breaker stays open before a single probe request is let through")
+	pollInterval := fs.Duration("poll_interval", defaults.PollInterval, "scrape tdarr in the background at this interval (e.g. 30s) and serve /metrics from the latest result; 0 scrapes on every request")
+	scrapeTimeoutOffset := fs.Duration("scrape_timeout_offset", defaults.ScrapeTimeoutOffset, "subtracted from the scrape timeout prometheus sends to get the deadline for scraping tdarr, leaving time to send the response")
+	stateFile := fs.String("state_file", defaults.StateFile, "path of a file the per-library stats cache is saved to and restored from at startup, so a restart does not refetch every library; empty disables it")
 	versionFlag := fs.Bool("version", false, "print version information and exit")
 	listenAddress := fs.String("listen_address", defaults.ListenAddress, "network interface address for the exporter's http server to listen on, ex: 127.0.0.1 or ::")
 	instanceName := fs.String("instance_name", defaults.InstanceName, "set to customize the tdarr_instance label (defaults to the url hostname); helpful when running multiple exporters and/or multiple tdarr instances on one host")
+	probeEnabled := fs.Bool("probe_enabled", defaults.ProbeEnabled, "serve the multi-target /probe?target=<url>&module=<name> endpoint")
+	probeMaxTargets := fs.Int("probe_max_targets", defaults.ProbeMaxTargets, "maximum number of /probe targets to keep a cached collector for; the least recently probed target is evicted beyond this")
+	fs.String("config.file", configFile, "path to a YAML config file; precedence is defaults -> config file -> env -> flags")
+	fs.String("env_file", envFile, "path to a KEY=VALUE env file overlaid on the process environment (file values win); re-read on reload")
+	reloadEnabled := fs.Bool("reload_enabled", defaults.ReloadEnabled, "serve POST /-/reload to reload the configuration, like sending SIGHUP")
+	debugCacheEnabled := fs.Bool("debug_cache_enabled", defaults.DebugCacheEnabled, "serve GET /debug/cache with the library stats cache of each target, library names included")
+	collectors := defaults.Collectors
+	for _, t := range collectors.toggles() {
+		fs.BoolVar(t.enabled, "collector."+t.name, *t.enabled, "scrape "+t.help)
+	}
 
 	if err := fs.Parse(args); err != nil {
 		if errors.Is(err, flag.ErrHelp) {
@@ -202,8 +917,51 @@ func parseConfig(fs *flag.FlagSet, args []string, getenv func(string) string) (C
 		return Config{Version: true}, nil
 	}
 
-	if *url == "" {
-		return Config{}, fmt.Errorf("a valid url needs to be provided")
+	if *tdarrUrl == "" && len(defaults.Targets) == 0 {
+		return Config{}, fmt.Errorf("a valid url (or at least one config file target) needs to be provided")
+	}
+	// Both being set usually means a leftover TDARR_API_KEY next to a newly
+	// mounted secret; refusing is safer than silently picking one.
+	if *apiKeyAuth != "" && *apiKeyFile != "" {
+		return Config{}, fmt.Errorf("api_key and api_key_file are mutually exclusive, set only one")
+	}
+	if (*tlsCertFile == "") != (*tlsKeyFile == "") {
+		return Config{}, fmt.Errorf("tls_cert_file and tls_key_file must be set together")
+	}
+	basicAuth := BasicAuth{Username: *basicAuthUsername, Password: *basicAuthPassword}
+	oauth2 := Oauth2{TokenUrl: *oauth2TokenUrl, ClientId: *oauth2ClientId, ClientSecret: *oauth2ClientSecret, Scopes: defaults.Oauth2.Scopes}
+	if *oauth2Scopes != "" {
+		oauth2.Scopes = splitList(*oauth2Scopes)
+	}
+	headers := defaults.HttpHeaders
+	if *httpHeaders != "" {
+		if headers, err = parseHeaderList(*httpHeaders); err != nil {
+			return Config{}, fmt.Errorf("invalid value for http_headers: %w", err)
+		}
+	}
+	buckets := defaults.FileSizeBuckets
+	if *fileSizeBuckets != "" {
+		if buckets, err = parseBuckets(*fileSizeBuckets); err != nil {
+			return Config{}, fmt.Errorf("invalid value for file_size_buckets: %w", err)
+		}
+	}
+	if err := validateBuckets(buckets); err != nil {
+		return Config{}, fmt.Errorf("invalid value for file_size_buckets: %w", err)
+	}
+	if *filesPageSize <= 0 {
+		return Config{}, fmt.Errorf("files_page_size must be at least 1")
+	}
+	if *codecResolution < 0 {
+		return Config{}, fmt.Errorf("codec_resolution_max_series must not be negative")
+	}
+	if *pluginsRefresh < time.Minute {
+		return Config{}, fmt.Errorf("plugins_refresh_interval must be at least 1m, got %s", *pluginsRefresh)
+	}
+	if err := validateAuth(basicAuth, *bearerToken, oauth2, headers); err != nil {
+		return Config{}, err
+	}
+	if _, ok := tlsVersions[*tlsMinVersion]; !ok && *tlsMinVersion != "" {
+		return Config{}, fmt.Errorf("tls_min_version must be one of 1.0, 1.1, 1.2 or 1.3, got %q", *tlsMinVersion)
 	}
 	if *httpMaxConcurrency <= 0 {
 		return Config{}, fmt.Errorf("http_max_concurrency must be at least 1 (single connection)")
@@ -211,6 +969,24 @@ func parseConfig(fs *flag.FlagSet, args []string, getenv func(string) string) (C
 	if *httpTimeoutSeconds <= 0 {
 		return Config{}, fmt.Errorf("http_timeout_seconds must be at least 1")
 	}
+	if *circuitThreshold < 0 {
+		return Config{}, fmt.Errorf("circuit_breaker_threshold must be 0 (disabled) or more")
+	}
+	if *circuitCooldown <= 0 {
+		return Config{}, fmt.Errorf("circuit_breaker_cooldown_seconds must be at least 1")
+	}
+	if *pollInterval != 0 && *pollInterval < time.Second {
+		return Config{}, fmt.Errorf("poll_interval must be 0 (disabled) or at least 1s, got %s", *pollInterval)
+	}
+	if *scrapeTimeoutOffset < 0 {
+		return Config{}, fmt.Errorf("scrape_timeout_offset must be 0 or more, got %s", *scrapeTimeoutOffset)
+	}
+	if collectors == (Collectors{}) {
+		return Config{}, fmt.Errorf("every collector is disabled, enable at least one collector.* group")
+	}
+	if *probeMaxTargets <= 0 {
+		return Config{}, fmt.Errorf("probe_max_targets must be at least 1")
+	}
 	// ParseUint with bitSize 16 rejects out-of-range and signed ports for free;
 	// port 0 is a valid uint16 but means "pick a random port", so reject it
 	// explicitly. Fails fast here rather than later at ListenAndServe.
@@ -219,7 +995,7 @@ func parseConfig(fs *flag.FlagSet, args []string, getenv func(string) string) (C
 	}
 	// PrometheusPath is spliced into an http.ServeMux pattern ("GET "+path) at
 	// registration (internal/server/server.go, which also hardcodes "/{$}" for
-	// the index and "/healthz"). ServeMux panics at registration on several
+	// the index, "/healthz", "/probe", "/-/reload" and "/debug/cache"). ServeMux panics at registration on several
 	// malformed patterns, so validate here to fail cleanly at startup instead
 	// of crashing the ServeHttp goroutine. Keep the reserved list below in sync
 	// with those hardcoded routes.
@@ -243,9 +1019,12 @@ func parseConfig(fs *flag.FlagSet, args []string, getenv func(string) string) (C
 	if *promPath != path.Clean(*promPath) {
 		return Config{}, fmt.Errorf("prometheus_path %q must be a clean path (no '.', '..', '//', or trailing slash)", *promPath)
 	}
-	// Each built-in route (index at "/", "/healthz") already claims its path; a
-	// PrometheusPath equal to one collides and panics ServeMux at registration.
-	if *promPath == "/" || *promPath == "/healthz" {
+	// Each built-in route (index at "/", "/healthz", "/probe", "/-/reload",
+	// "/debug/cache") already claims its path; a PrometheusPath equal to one
+	// collides and panics ServeMux at registration. The optional routes are
+	// reserved even when disabled so enabling one later cannot turn a working
+	// config into a startup panic.
+	if *promPath == "/" || *promPath == "/healthz" || *promPath == "/probe" || *promPath == "/-/reload" || *promPath == "/debug/cache" {
 		return Config{}, fmt.Errorf("prometheus_path %q conflicts with a reserved exporter route", *promPath)
 	}
 
@@ -254,36 +1033,198 @@ func parseConfig(fs *flag.FlagSet, args []string, getenv func(string) string) (C
 		return Config{}, err
 	}
 
-	urlParsed, err := parseUrl(*url)
-	if err != nil {
-		return Config{}, err
-	}
-
+	// The primary url is optional when the config file lists targets; the
+	// exporter then scrapes only those.
+	var urlParsed *url.URL
 	name := *instanceName
-	if name == "" {
-		name = urlParsed.Hostname()
+	if *tdarrUrl != "" {
+		if urlParsed, err = parseUrl(*tdarrUrl); err != nil {
+			return Config{}, err
+		}
+		if name == "" {
+			name = urlParsed.Hostname()
+		}
+		for _, t := range defaults.Targets {
+			if t.InstanceName == name {
+				return Config{}, fmt.Errorf("config file %s: line %d: target instance_name %q duplicates the primary instance name; set instance_name to tell them apart", configFile, t.line, name)
+			}
+		}
 	}
 
 	return Config{
-		url:                *url,
+		url:                *tdarrUrl,
 		UrlParsed:          urlParsed,
 		InstanceName:       name,
 		ApiKey:             *apiKeyAuth,
+		ApiKeyFile:         *apiKeyFile,
 		VerifySsl:          *sslVerify,
+		TlsCaFile:          *tlsCaFile,
+		TlsCertFile:        *tlsCertFile,
+		TlsKeyFile:         *tlsKeyFile,
+		TlsServerName:      *tlsServerName,
+		TlsMinVersion:      *tlsMinVersion,
+		BasicAuth:          basicAuth,
+		BearerToken:        *bearerToken,
+		Oauth2:             oauth2,
+		HttpHeaders:        headers,
 		PrometheusPort:     *promPort,
 		PrometheusPath:     *promPath,
 		LogLevel:           *logLevel,
 		HttpTimeoutSeconds: *httpTimeoutSeconds,
-		// path fields are intentionally not overridable.
-		TdarrStatsPath:     defaults.TdarrStatsPath,
-		TdarrNodePath:      defaults.TdarrNodePath,
-		TdarrPieStatsPath:  defaults.TdarrPieStatsPath,
-		TdarrStatusPath:    defaults.TdarrStatusPath,
-		HttpMaxConcurrency: *httpMaxConcurrency,
-		ListenAddress:      *listenAddress,
+		// path fields and their policies are only overridable from the config file.
+		TdarrStatsPath:                defaults.TdarrStatsPath,
+		TdarrNodePath:                 defaults.TdarrNodePath,
+		TdarrPieStatsPath:             defaults.TdarrPieStatsPath,
+		TdarrStatusPath:               defaults.TdarrStatusPath,
+		TdarrFilesPath:                defaults.TdarrFilesPath,
+		TdarrPluginsPath:              defaults.TdarrPluginsPath,
+		RequestPolicies:               defaults.RequestPolicies,
+		LabelPolicies:                 defaults.LabelPolicies,
+		HttpMaxConcurrency:            *httpMaxConcurrency,
+		CircuitBreakerThreshold:       *circuitThreshold,
+		CircuitBreakerCooldownSeconds: *circuitCooldown,
+		PollInterval:                  *pollInterval,
+		ScrapeTimeoutOffset:           *scrapeTimeoutOffset,
+		StateFile:                     *stateFile,
+		ListenAddress:                 *listenAddress,
+		Collectors:                    collectors,
+		FileSizeBuckets:               buckets,
+		FilesPageSize:                 *filesPageSize,
+		CodecResolutionMaxSeries:      *codecResolution,
+		PluginsRefreshInterval:        *pluginsRefresh,
+		ProbeEnabled:                  *probeEnabled,
+		ProbeMaxTargets:               *probeMaxTargets,
+		ProbeModules:                  defaults.ProbeModules,
+		ReloadEnabled:                 *reloadEnabled,
+		DebugCacheEnabled:             *debugCacheEnabled,
+		ConfigFile:                    configFile,
+		EnvFile:                       envFile,
+		Targets:                       defaults.Targets,
 	}, nil
 }
 
+// TargetConfigs returns one Config per Tdarr instance to scrape: the primary
+// url (when set) followed by each config file target with its overrides
+// applied. Every returned Config has UrlParsed and InstanceName set and no
+// Targets of its own.
+func (c Config) TargetConfigs() []Config {
+	configs := make([]Config, 0, len(c.Targets)+1)
+	primary := c
+	primary.Targets = nil
+	if c.UrlParsed != nil {
+		configs = append(configs, primary)
+	}
+	for _, t := range c.Targets {
+		configs = append(configs, primary.withTarget(t))
+	}
+	return configs
+}
+
+// withTarget applies a config file target on top of c.
+func (c Config) withTarget(t Target) Config {
+	c.url = t.url
+	c.UrlParsed = t.UrlParsed
+	c.InstanceName = t.InstanceName
+	// A target's own credential replaces the inherited one of either kind.
+	if t.ApiKey != nil {
+		c.ApiKey, c.ApiKeyFile = *t.ApiKey, ""
+	}
+	if t.ApiKeyFile != nil {
+		c.ApiKey, c.ApiKeyFile = "", *t.ApiKeyFile
+	}
+	setIfPresent(&c.VerifySsl, t.VerifySsl)
+	setIfPresent(&c.TlsCaFile, t.TlsCaFile)
+	// Like the api key, a target's client certificate replaces the inherited
+	// pair as a whole; parseFileTargets requires both halves.
+	if t.TlsCertFile != nil {
+		c.TlsCertFile, c.TlsKeyFile = *t.TlsCertFile, *t.TlsKeyFile
+	}
+	setIfPresent(&c.TlsServerName, t.TlsServerName)
+	if t.BasicAuth != nil || t.BearerToken != nil || t.Oauth2 != nil {
+		c.BasicAuth, c.BearerToken, c.Oauth2 = BasicAuth{}, "", Oauth2{}
+		setIfPresent(&c.BasicAuth, t.BasicAuth)
+		setIfPresent(&c.BearerToken, t.BearerToken)
+		setIfPresent(&c.Oauth2, t.Oauth2)
+	}
+	if t.HttpHeaders != nil {
+		c.HttpHeaders = t.HttpHeaders
+	}
+	setIfPresent(&c.HttpTimeoutSeconds, t.HttpTimeoutSeconds)
+	setIfPresent(&c.HttpMaxConcurrency, t.HttpMaxConcurrency)
+	return c
+}
+
+// ProbeConfig derives the Config for a single /probe target from the exporter
+// config: the target url replaces UrlParsed, the target hostname becomes the
+// tdarr_instance label, and credentials come only from the named module
+// (DefaultProbeModule when module is empty). The client certificate counts as a
+// credential, as are the proxy credentials (basic auth, bearer token, oauth2
+// and extra headers), and the server name override is specific to the
+// exporter's own instance, so none of them is sent. Everything else (timeouts, concurrency, api
+// paths, the CA bundle and minimum TLS version) is inherited unchanged.
+//
+// A target matching a config file target by instance_name or by its url as
+// written resolves to that target's Config, overrides and credentials
+// included, without needing a module: those credentials were configured for
+// exactly that instance, so sending them is not an implicit leak.
+//
+// PollInterval is always 0: a probe is scraped live when it is requested.
+func (c Config) ProbeConfig(target, module string) (Config, error) {
+	if module == "" {
+		module = DefaultProbeModule
+	}
+	settings, ok := c.ProbeModules[module]
+	if !ok {
+		return Config{}, fmt.Errorf("%w: %q", ErrUnknownProbeModule, module)
+	}
+	if module == DefaultProbeModule {
+		for _, t := range c.Targets {
+			if target == t.InstanceName || target == t.url {
+				probe := c.withTarget(t)
+				probe.Targets = nil
+				probe.PollInterval = 0
+				return probe, nil
+			}
+		}
+	}
+	urlParsed, err := parseUrl(target)
+	if err != nil {
+		return Config{}, err
+	}
+	probe := c
+	probe.url = target
+	probe.UrlParsed = urlParsed
+	// Host rather than Hostname: two Tdarr servers probed on one host differ
+	// only by port, and must not share a tdarr_instance label.
+	probe.InstanceName = urlParsed.Host
+	probe.ApiKey = settings.ApiKey
+	probe.ApiKeyFile = ""
+	probe.TlsCertFile, probe.TlsKeyFile = "", ""
+	probe.TlsServerName = ""
+	probe.BasicAuth, probe.BearerToken, probe.Oauth2, probe.HttpHeaders = BasicAuth{}, "", Oauth2{}, nil
+	probe.VerifySsl = settings.VerifySsl
+	probe.PollInterval = 0
+	return probe, nil
+}
+
+// ApplyLogLevel sets the global zerolog level from LogLevel. parseConfig has
+// already validated it, so an unknown level cannot reach here from a parsed
+// Config.
+func (c Config) ApplyLogLevel() {
+	level, _ := parseLogLevel(c.LogLevel)
+	zerolog.SetGlobalLevel(level)
+}
+
+// Load parses the configuration from args and getenv without any of
+// NewConfig's side effects: errors (including -h and flag syntax errors) are
+// returned rather than exiting, and nothing is printed. It is what a runtime
+// reload uses, since a bad edit must leave the running exporter untouched.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	fs := flag.NewFlagSet("tdarr-exporter", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return parseConfig(fs, args, getenv)
+}
+
 // NewConfig is the production entrypoint (composition root). It wires os.Args
 // and os.Getenv into the testable parseConfig core, then applies the global
 // side effects (log level mutation, fatal on error, startup logging) that must
@@ -313,10 +1254,10 @@ func NewConfig() Config {
 		return cfg
 	}
 
-	// parseConfig already validated the log level; apply it globally here.
-	level, _ := parseLogLevel(cfg.LogLevel)
-	zerolog.SetGlobalLevel(level)
+	cfg.ApplyLogLevel()
 
-	log.Info().Str("url", cfg.UrlParsed.String()).Msg("Using provided full url for tdarr instance")
+	for _, target := range cfg.TargetConfigs() {
+		log.Info().Str("url", target.UrlParsed.String()).Str("instance", target.InstanceName).Msg("Using provided full url for tdarr instance")
+	}
 	return cfg
 }
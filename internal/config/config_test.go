@@ -1,10 +1,15 @@
 package config
 
 import (
+	"crypto/tls"
 	"errors"
 	"flag"
 	"io"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/rs/zerolog"
 )
@@ -49,6 +54,12 @@ func TestParseConfigDefaults(t *testing.T) {
 	if cfg.HttpMaxConcurrency != 3 {
 		t.Errorf("HttpMaxConcurrency = %d, want 3", cfg.HttpMaxConcurrency)
 	}
+	if cfg.Collectors != DefaultCollectors() || cfg.Collectors.Files || cfg.Collectors.Streams || cfg.Collectors.Flows || cfg.Collectors.Plugins || cfg.Collectors.Staged {
+		t.Errorf("Collectors = %+v, want all but files, streams, flows, plugins and staged enabled", cfg.Collectors)
+	}
+	if cfg.CircuitBreakerThreshold != 5 || cfg.CircuitBreakerCooldownSeconds != 30 {
+		t.Errorf("circuit breaker = %d/%ds, want 5/30s", cfg.CircuitBreakerThreshold, cfg.CircuitBreakerCooldownSeconds)
+	}
 	if cfg.ApiKey != "" {
 		t.Errorf("ApiKey = %q, want empty", cfg.ApiKey)
 	}
@@ -75,6 +86,8 @@ func TestParseConfigEnvOverrides(t *testing.T) {
 		envLogLevel:           "debug",
 		envHttpMaxConcurrency: "7",
 		envHttpTimeoutSeconds: "42",
+		envCircuitThreshold:   "0",
+		envCircuitCooldown:    "60",
 	}
 	cfg, err := parseConfig(newFS(), nil, envFunc(env))
 	if err != nil {
@@ -105,6 +118,9 @@ func TestParseConfigEnvOverrides(t *testing.T) {
 	if cfg.HttpTimeoutSeconds != 42 {
 		t.Errorf("HttpTimeoutSeconds = %d, want 42", cfg.HttpTimeoutSeconds)
 	}
+	if cfg.CircuitBreakerThreshold != 0 || cfg.CircuitBreakerCooldownSeconds != 60 {
+		t.Errorf("circuit breaker = %d/%ds, want 0/60s", cfg.CircuitBreakerThreshold, cfg.CircuitBreakerCooldownSeconds)
+	}
 }
 
 func TestParseConfigFlagsOverrideEnv(t *testing.T) {
@@ -119,6 +135,7 @@ func TestParseConfigFlagsOverrideEnv(t *testing.T) {
 		envLogLevel:           "warn",
 		envHttpMaxConcurrency: "2",
 		envHttpTimeoutSeconds: "20",
+		envCircuitThreshold:   "3",
 	}
 	args := []string{
 		"-url", "https://flag.example.com",
@@ -129,6 +146,7 @@ func TestParseConfigFlagsOverrideEnv(t *testing.T) {
 		"-log_level", "error",
 		"-http_max_concurrency", "9",
 		"-http_timeout_seconds", "99",
+		"-circuit_breaker_threshold", "8",
 	}
 	cfg, err := parseConfig(newFS(), args, envFunc(env))
 	if err != nil {
@@ -159,6 +177,9 @@ func TestParseConfigFlagsOverrideEnv(t *testing.T) {
 	if cfg.HttpTimeoutSeconds != 99 {
 		t.Errorf("HttpTimeoutSeconds = %d, want 99", cfg.HttpTimeoutSeconds)
 	}
+	if cfg.CircuitBreakerThreshold != 8 {
+		t.Errorf("CircuitBreakerThreshold = %d, want 8", cfg.CircuitBreakerThreshold)
+	}
 }
 
 func TestParseConfigErrors(t *testing.T) {
@@ -181,6 +202,18 @@ func TestParseConfigErrors(t *testing.T) {
 			name: "invalid http_timeout_seconds",
 			env:  map[string]string{envTdarrUrl: "https://x.com", envHttpTimeoutSeconds: "notanint"},
 		},
+		{
+			name: "invalid circuit_breaker_threshold",
+			env:  map[string]string{envTdarrUrl: "https://x.com", envCircuitThreshold: "notanint"},
+		},
+		{
+			name: "circuit_breaker_threshold < 0",
+			env:  map[string]string{envTdarrUrl: "https://x.com", envCircuitThreshold: "-1"},
+		},
+		{
+			name: "circuit_breaker_cooldown_seconds <= 0",
+			env:  map[string]string{envTdarrUrl: "https://x.com", envCircuitCooldown: "0"},
+		},
 		{
 			name: "empty url",
 			env:  map[string]string{},
@@ -382,6 +415,8 @@ func TestPrometheusPathValidation(t *testing.T) {
 		{"no leading slash", "metrics", true},
 		{"root conflicts with index route", "/", true},
 		{"healthz conflicts with reserved route", "/healthz", true},
+		{"reload conflicts with reserved route", "/-/reload", true},
+		{"debug cache conflicts with reserved route", "/debug/cache", true},
 		{"malformed wildcard open brace", "/metrics/{", true},
 		{"wildcard segment", "/{id}", true},
 		{"anchor pattern", "/{$}", true},
@@ -465,3 +500,645 @@ func TestInstanceNameOverride(t *testing.T) {
 		})
 	}
 }
+
+func TestProbeModulesFromEnv(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{
+		envTdarrUrl:                     "http://tdarr.test",
+		envTdarrApiKey:                  "primary-key",
+		envProbeModules:                 "tdarr-4k, anime",
+		"PROBE_MODULE_TDARR_4K_API_KEY": "key-4k",
+		"PROBE_MODULE_ANIME_API_KEY":    "key-anime",
+		"PROBE_MODULE_ANIME_VERIFY_SSL": "false",
+		"PROBE_MODULE_UNLISTED_API_KEY": "never-read",
+	}
+	cfg, err := parseConfig(newFS(), nil, envFunc(env))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+	want := map[string]ProbeModule{
+		DefaultProbeModule: {VerifySsl: true},
+		"tdarr-4k":         {ApiKey: "key-4k", VerifySsl: true},
+		"anime":            {ApiKey: "key-anime", VerifySsl: false},
+	}
+	if len(cfg.ProbeModules) != len(want) {
+		t.Fatalf("ProbeModules = %+v, want %+v", cfg.ProbeModules, want)
+	}
+	for name, module := range want {
+		if got := cfg.ProbeModules[name]; got != module {
+			t.Errorf("module %q = %+v, want %+v", name, got, module)
+		}
+	}
+}
+
+func TestProbeConfigErrors(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		env  map[string]string
+		args []string
+	}{
+		{"redefined default module", map[string]string{envProbeModules: DefaultProbeModule}, nil},
+		{"duplicate module", map[string]string{envProbeModules: "a,a"}, nil},
+		{"invalid module verify_ssl", map[string]string{envProbeModules: "a", "PROBE_MODULE_A_VERIFY_SSL": "maybe"}, nil},
+		{"invalid probe_enabled", map[string]string{envProbeEnabled: "maybe"}, nil},
+		{"probe_max_targets <= 0", nil, []string{"-probe_max_targets", "0"}},
+		{"prometheus_path conflicts with /probe", nil, []string{"-prometheus_path", "/probe"}},
+		{"invalid reload_enabled", map[string]string{envReloadEnabled: "sometimes"}, nil},
+		{"invalid debug_cache_enabled", map[string]string{envDebugCacheEnabled: "sometimes"}, nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			env := map[string]string{envTdarrUrl: "http://tdarr.test"}
+			for k, v := range tc.env {
+				env[k] = v
+			}
+			if _, err := parseConfig(newFS(), tc.args, envFunc(env)); err == nil {
+				t.Fatal("expected error, got nil")
+			}
+		})
+	}
+}
+
+// TestProbeConfig verifies a probe target inherits the exporter settings but
+// takes its url, instance label and credentials from the target and module only.
+func TestProbeConfig(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{
+		envTdarrUrl:                   "http://tdarr.test",
+		envTdarrApiKey:                "primary-key",
+		envHttpTimeoutSeconds:         "30",
+		envProbeModules:               "remote",
+		"PROBE_MODULE_REMOTE_API_KEY": "remote-key",
+	}
+	base, err := parseConfig(newFS(), nil, envFunc(env))
+	if err != nil {
+		t.Fatalf("parseConfig: %v", err)
+	}
+
+	probe, err := base.ProbeConfig("tdarr-remote.lan:8266", "remote")
+	if err != nil {
+		t.Fatalf("ProbeConfig: %v", err)
+	}
+	if got := probe.UrlParsed.String(); got != "https://tdarr-remote.lan:8266" {
+		t.Errorf("UrlParsed = %q, want https://tdarr-remote.lan:8266", got)
+	}
+	if probe.InstanceName != "tdarr-remote.lan:8266" {
+		t.Errorf("InstanceName = %q, want tdarr-remote.lan:8266 (port kept)", probe.InstanceName)
+	}
+	if probe.ApiKey != "remote-key" {
+		t.Errorf("ApiKey = %q, want remote-key", probe.ApiKey)
+	}
+	if probe.HttpTimeoutSeconds != 30 {
+		t.Errorf("HttpTimeoutSeconds = %d, want inherited 30", probe.HttpTimeoutSeconds)
+	}
+	if base.UrlParsed.String() != "http://tdarr.test" || base.ApiKey != "primary-key" {
+		t.Error("ProbeConfig mutated the base config")
+	}
+
+	defaultProbe, err := base.ProbeConfig("tdarr-remote.lan", "")
+	if err != nil {
+		t.Fatalf("ProbeConfig default module: %v", err)
+	}
+	if defaultProbe.ApiKey != "" {
+		t.Errorf("default module ApiKey = %q, want empty (primary key must not leak to probe targets)", defaultProbe.ApiKey)
+	}
+	if _, err := base.ProbeConfig("tdarr-remote.lan", "nope"); !errors.Is(err, ErrUnknownProbeModule) {
+		t.Errorf("unknown module err = %v, want ErrUnknownProbeModule", err)
+	}
+}
+
+// TestApiKeyFile verifies api_key_file is read from env and flags, is mutually
+// exclusive with api_key, and that a target's credential of either kind
+// replaces the inherited one.
+func TestApiKeyFile(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{envTdarrUrl: "https://tdarr.example.com", envTdarrApiKeyFile: "/run/secrets/tdarr"}
+	cfg, err := parseConfig(newFS(), nil, envFunc(env))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ApiKeyFile != "/run/secrets/tdarr" || cfg.ApiKey != "" {
+		t.Errorf("ApiKeyFile/ApiKey = %q/%q, want the env file path and no key", cfg.ApiKeyFile, cfg.ApiKey)
+	}
+
+	if _, err := parseConfig(newFS(), []string{"-api_key", "inline"}, envFunc(env)); err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Errorf("api_key with api_key_file: error = %v, want mutually exclusive", err)
+	}
+
+	path := writeConfigFile(t, `
+api_key_file: /run/secrets/shared
+targets:
+  - url: https://inline.lan
+    api_key: inline-key
+  - url: https://filed.lan
+    api_key_file: /run/secrets/filed
+  - url: https://inherits.lan
+`)
+	cfg, err = parseConfig(newFS(), []string{"-config.file", path}, envFunc(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	targets := cfg.TargetConfigs()
+	want := []struct{ key, file string }{
+		{"inline-key", ""},
+		{"", "/run/secrets/filed"},
+		{"", "/run/secrets/shared"},
+	}
+	for i, w := range want {
+		if targets[i].ApiKey != w.key || targets[i].ApiKeyFile != w.file {
+			t.Errorf("target %s: ApiKey/ApiKeyFile = %q/%q, want %q/%q", targets[i].InstanceName, targets[i].ApiKey, targets[i].ApiKeyFile, w.key, w.file)
+		}
+	}
+
+	// A default-module probe never inherits the exporter's key file either.
+	probe, err := cfg.ProbeConfig("https://elsewhere.lan", "")
+	if err != nil {
+		t.Fatalf("ProbeConfig: %v", err)
+	}
+	if probe.ApiKeyFile != "" {
+		t.Errorf("probe ApiKeyFile = %q, want empty", probe.ApiKeyFile)
+	}
+
+	bad := writeConfigFile(t, "targets:\n  - url: https://x.lan\n    api_key: a\n    api_key_file: b\n")
+	if _, err := parseConfig(newFS(), []string{"-config.file", bad}, envFunc(nil)); err == nil || !strings.Contains(err.Error(), "line 2: targets[0]: api_key and api_key_file are mutually exclusive") {
+		t.Errorf("target with both: error = %v, want a line 2 mutual-exclusion error", err)
+	}
+}
+
+// TestTLSOptions covers the tls_* settings: env/flag precedence, validation,
+// per-target overrides and what a /probe target inherits.
+func TestTLSOptions(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{
+		envTdarrUrl:      "https://tdarr.example.com",
+		envTlsCaFile:     "/etc/tdarr/ca.pem",
+		envTlsCertFile:   "/etc/tdarr/client.pem",
+		envTlsKeyFile:    "/etc/tdarr/client-key.pem",
+		envTlsServerName: "tdarr.internal",
+		envTlsMinVersion: "1.2",
+	}
+	cfg, err := parseConfig(newFS(), []string{"-tls_min_version", "1.3"}, envFunc(env))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.TlsCaFile != "/etc/tdarr/ca.pem" || cfg.TlsCertFile != "/etc/tdarr/client.pem" || cfg.TlsKeyFile != "/etc/tdarr/client-key.pem" || cfg.TlsServerName != "tdarr.internal" {
+		t.Errorf("tls files/server name = %q %q %q %q, want the env values", cfg.TlsCaFile, cfg.TlsCertFile, cfg.TlsKeyFile, cfg.TlsServerName)
+	}
+	if cfg.TLSMinVersionValue() != tls.VersionTLS13 || !cfg.HasTLSOptions() {
+		t.Errorf("TLSMinVersionValue = %#x, HasTLSOptions = %v; want the flag's TLS 1.3 and true", cfg.TLSMinVersionValue(), cfg.HasTLSOptions())
+	}
+	plain, err := parseConfig(newFS(), nil, envFunc(map[string]string{envTdarrUrl: "https://tdarr.example.com"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if plain.HasTLSOptions() || plain.TLSMinVersionValue() != 0 {
+		t.Errorf("defaults: HasTLSOptions = %v, TLSMinVersionValue = %#x; want false and 0", plain.HasTLSOptions(), plain.TLSMinVersionValue())
+	}
+
+	for _, tc := range []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"cert without key", []string{"-tls_cert_file", "c.pem"}, "must be set together"},
+		{"key without cert", []string{"-tls_key_file", "k.pem"}, "must be set together"},
+		{"unknown version", []string{"-tls_min_version", "1.4"}, "tls_min_version must be one of"},
+	} {
+		args := append([]string{"-url", "https://tdarr.example.com"}, tc.args...)
+		if _, err := parseConfig(newFS(), args, envFunc(nil)); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error = %v, want %q", tc.name, err, tc.want)
+		}
+	}
+
+	path := writeConfigFile(t, `
+url: https://primary.lan
+tls_ca_file: /etc/tdarr/ca.pem
+tls_cert_file: /etc/tdarr/client.pem
+tls_key_file: /etc/tdarr/client-key.pem
+targets:
+  - url: https://10.0.0.5
+    tls_server_name: tdarr-4k.internal
+    tls_cert_file: /etc/tdarr/4k.pem
+    tls_key_file: /etc/tdarr/4k-key.pem
+  - url: https://inherits.lan
+`)
+	cfg, err = parseConfig(newFS(), []string{"-config.file", path}, envFunc(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	targets := cfg.TargetConfigs()
+	if got := targets[1]; got.TlsServerName != "tdarr-4k.internal" || got.TlsCertFile != "/etc/tdarr/4k.pem" || got.TlsKeyFile != "/etc/tdarr/4k-key.pem" || got.TlsCaFile != "/etc/tdarr/ca.pem" {
+		t.Errorf("overriding target: tls = %q %q %q %q, want its own server name and pair with the inherited CA", got.TlsServerName, got.TlsCertFile, got.TlsKeyFile, got.TlsCaFile)
+	}
+	if got := targets[2]; got.TlsServerName != "" || got.TlsCertFile != "/etc/tdarr/client.pem" {
+		t.Errorf("inheriting target: server name/cert = %q/%q, want none/the top-level pair", got.TlsServerName, got.TlsCertFile)
+	}
+
+	// The client certificate is a credential: a default-module probe of an
+	// unrelated target keeps the CA but presents no certificate.
+	probe, err := cfg.ProbeConfig("https://elsewhere.lan", "")
+	if err != nil {
+		t.Fatalf("ProbeConfig: %v", err)
+	}
+	if probe.TlsCertFile != "" || probe.TlsKeyFile != "" || probe.TlsCaFile != "/etc/tdarr/ca.pem" {
+		t.Errorf("probe tls cert/key/ca = %q/%q/%q, want no pair and the CA", probe.TlsCertFile, probe.TlsKeyFile, probe.TlsCaFile)
+	}
+
+	bad := writeConfigFile(t, "targets:\n  - url: https://x.lan\n    tls_cert_file: c.pem\n")
+	if _, err := parseConfig(newFS(), []string{"-config.file", bad}, envFunc(nil)); err == nil || !strings.Contains(err.Error(), "line 2: targets[0]: tls_cert_file and tls_key_file must be set together") {
+		t.Errorf("target with half a pair: error = %v, want a line 2 pairing error", err)
+	}
+}
+
+// TestProxyAuth covers the proxy credentials: env/flag parsing, validation,
+// per-target replacement and that /probe targets never receive them.
+func TestProxyAuth(t *testing.T) {
+	t.Parallel()
+
+	env := map[string]string{
+		envTdarrUrl:           "https://tdarr.example.com",
+		envOauth2TokenUrl:     "https://auth.example.com/api/oidc/token",
+		envOauth2ClientId:     "exporter",
+		envOauth2ClientSecret: "s3cret",
+		envOauth2Scopes:       "tdarr, metrics",
+		envHttpHeaders:        "X-Forwarded-User=exporter, X-Env=1",
+	}
+	cfg, err := parseConfig(newFS(), []string{"-http_headers", "X-Flag=2"}, envFunc(env))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantOauth2 := Oauth2{TokenUrl: "https://auth.example.com/api/oidc/token", ClientId: "exporter", ClientSecret: "s3cret", Scopes: []string{"tdarr", "metrics"}}
+	if !reflect.DeepEqual(cfg.Oauth2, wantOauth2) {
+		t.Errorf("Oauth2 = %+v, want %+v", cfg.Oauth2, wantOauth2)
+	}
+	if !reflect.DeepEqual(cfg.HttpHeaders, map[string]string{"X-Flag": "2"}) {
+		t.Errorf("HttpHeaders = %v, want the flag's map replacing the env's", cfg.HttpHeaders)
+	}
+
+	for _, tc := range []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"two schemes", []string{"-bearer_token", "t", "-basic_auth_username", "u"}, "mutually exclusive"},
+		{"password without username", []string{"-basic_auth_password", "p"}, "requires basic_auth_username"},
+		{"incomplete oauth2", []string{"-oauth2_token_url", "https://auth.lan/token"}, "oauth2 needs"},
+		{"relative token url", []string{"-oauth2_token_url", "/token", "-oauth2_client_id", "a", "-oauth2_client_secret", "b"}, "absolute http or https url"},
+		{"malformed header", []string{"-http_headers", "X-No-Value"}, "Name=Value"},
+		{"invalid header name", []string{"-http_headers", "Bad Name=1"}, "not a valid header name"},
+	} {
+		args := append([]string{"-url", "https://tdarr.example.com"}, tc.args...)
+		if _, err := parseConfig(newFS(), args, envFunc(nil)); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error = %v, want %q", tc.name, err, tc.want)
+		}
+	}
+
+	path := writeConfigFile(t, `
+url: https://primary.lan
+bearer_token: shared-token
+http_headers:
+  X-Tenant: "media, home"
+targets:
+  - url: https://basic.lan
+    basic_auth:
+      username: exporter
+      password: s3cret
+  - url: https://oauth.lan
+    oauth2:
+      token_url: https://auth.lan/token
+      client_id: exporter
+      client_secret: s3cret
+      scopes: [tdarr]
+    http_headers: {}
+  - url: https://inherits.lan
+`)
+	cfg, err = parseConfig(newFS(), []string{"-config.file", path}, envFunc(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	targets := cfg.TargetConfigs()
+	if got := targets[1]; got.BearerToken != "" || got.BasicAuth != (BasicAuth{Username: "exporter", Password: "s3cret"}) || got.HttpHeaders["X-Tenant"] != "media, home" {
+		t.Errorf("basic target: bearer %q, basic %+v, headers %v; want only its basic auth plus the inherited headers", got.BearerToken, got.BasicAuth, got.HttpHeaders)
+	}
+	if got := targets[2]; got.BearerToken != "" || got.Oauth2.ClientId != "exporter" || len(got.HttpHeaders) != 0 {
+		t.Errorf("oauth2 target: bearer %q, oauth2 %+v, headers %v; want only its oauth2 and no headers", got.BearerToken, got.Oauth2, got.HttpHeaders)
+	}
+	if got := targets[3]; got.BearerToken != "shared-token" {
+		t.Errorf("inheriting target: bearer %q, want shared-token", got.BearerToken)
+	}
+
+	probe, err := cfg.ProbeConfig("https://elsewhere.lan", "")
+	if err != nil {
+		t.Fatalf("ProbeConfig: %v", err)
+	}
+	if probe.BearerToken != "" || probe.HttpHeaders != nil {
+		t.Errorf("probe bearer/headers = %q/%v, want none", probe.BearerToken, probe.HttpHeaders)
+	}
+
+	bad := writeConfigFile(t, "targets:\n  - url: https://x.lan\n    bearer_token: t\n    basic_auth:\n      username: u\n")
+	if _, err := parseConfig(newFS(), []string{"-config.file", bad}, envFunc(nil)); err == nil || !strings.Contains(err.Error(), "line 2: targets[0]: basic_auth, bearer_token and oauth2") {
+		t.Errorf("target with two schemes: error = %v, want a line 2 exclusivity error", err)
+	}
+}
+
+// TestCollectors verifies the collector.* toggles layer like every other
+// setting (file, then env, then flags) and that disabling all is an error.
+func TestCollectors(t *testing.T) {
+	t.Parallel()
+
+	path := writeConfigFile(t, `
+url: https://tdarr.example.com
+collector.library: false
+collector.general: false
+collector.streams: true
+collector.staged: true
+`)
+	env := map[string]string{envCollectorGeneral: "true", envCollectorServer: "false", envCollectorFlows: "true"}
+	cfg, err := parseConfig(newFS(), []string{"-config.file", path, "-collector.workers=false"}, envFunc(env))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Collectors{General: true, Nodes: true, Streams: true, Flows: true, Staged: true}
+	if cfg.Collectors != want {
+		t.Errorf("Collectors = %+v, want %+v", cfg.Collectors, want)
+	}
+
+	if _, err := parseConfig(newFS(), nil, envFunc(map[string]string{envTdarrUrl: "https://x.com", envCollectorNodes: "maybe"})); err == nil {
+		t.Errorf("invalid COLLECTOR_NODES: want an error")
+	}
+	args := []string{"-collector.server=false", "-collector.general=false", "-collector.library=false", "-collector.nodes=false", "-collector.workers=false"}
+	if _, err := parseConfig(newFS(), args, envFunc(map[string]string{envTdarrUrl: "https://x.com"})); err == nil {
+		t.Errorf("every collector disabled: want an error")
+	}
+}
+
+func TestPollInterval(t *testing.T) {
+	t.Parallel()
+
+	path := writeConfigFile(t, "url: https://tdarr.example.com\npoll_interval: 1m\n")
+	cfg, err := parseConfig(newFS(), []string{"-config.file", path}, envFunc(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.PollInterval != time.Minute {
+		t.Errorf("file PollInterval = %v, want 1m", cfg.PollInterval)
+	}
+	env := map[string]string{envTdarrUrl: "https://tdarr.example.com", envPollInterval: "45s"}
+	if cfg, err = parseConfig(newFS(), nil, envFunc(env)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.PollInterval != 45*time.Second {
+		t.Errorf("env PollInterval = %v, want 45s", cfg.PollInterval)
+	}
+	if cfg, err = parseConfig(newFS(), []string{"-poll_interval", "0"}, envFunc(env)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.PollInterval != 0 {
+		t.Errorf("flag PollInterval = %v, want 0 to beat the environment", cfg.PollInterval)
+	}
+
+	// A probe is always scraped live, whatever the exporter's own interval.
+	if cfg, err = parseConfig(newFS(), nil, envFunc(env)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	probe, err := cfg.ProbeConfig("https://other.example.com", "")
+	if err != nil {
+		t.Fatalf("ProbeConfig: %v", err)
+	}
+	if probe.PollInterval != 0 {
+		t.Errorf("probe PollInterval = %v, want 0", probe.PollInterval)
+	}
+
+	for _, value := range []string{"soon", "500ms", "-1s"} {
+		env := map[string]string{envTdarrUrl: "https://tdarr.example.com", envPollInterval: value}
+		if _, err := parseConfig(newFS(), nil, envFunc(env)); err == nil {
+			t.Errorf("POLL_INTERVAL=%s: want an error", value)
+		}
+	}
+}
+
+func TestScrapeTimeoutOffset(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := parseConfig(newFS(), nil, envFunc(map[string]string{envTdarrUrl: "https://tdarr.example.com"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ScrapeTimeoutOffset != 500*time.Millisecond {
+		t.Errorf("default ScrapeTimeoutOffset = %v, want 500ms", cfg.ScrapeTimeoutOffset)
+	}
+	path := writeConfigFile(t, "url: https://tdarr.example.com\nscrape_timeout_offset: 2s\n")
+	if cfg, err = parseConfig(newFS(), []string{"-config.file", path}, envFunc(nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ScrapeTimeoutOffset != 2*time.Second {
+		t.Errorf("file ScrapeTimeoutOffset = %v, want 2s", cfg.ScrapeTimeoutOffset)
+	}
+	env := map[string]string{envTdarrUrl: "https://tdarr.example.com", envScrapeTimeout: "1s"}
+	if cfg, err = parseConfig(newFS(), nil, envFunc(env)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ScrapeTimeoutOffset != time.Second {
+		t.Errorf("env ScrapeTimeoutOffset = %v, want 1s", cfg.ScrapeTimeoutOffset)
+	}
+	if cfg, err = parseConfig(newFS(), []string{"-scrape_timeout_offset", "0"}, envFunc(env)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ScrapeTimeoutOffset != 0 {
+		t.Errorf("flag ScrapeTimeoutOffset = %v, want 0 to beat the environment", cfg.ScrapeTimeoutOffset)
+	}
+
+	for _, value := range []string{"soon", "-1s"} {
+		env := map[string]string{envTdarrUrl: "https://tdarr.example.com", envScrapeTimeout: value}
+		if _, err := parseConfig(newFS(), nil, envFunc(env)); err == nil {
+			t.Errorf("SCRAPE_TIMEOUT_OFFSET=%s: want an error", value)
+		}
+	}
+}
+
+func TestStateFile(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := parseConfig(newFS(), nil, envFunc(map[string]string{envTdarrUrl: "https://tdarr.example.com"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.StateFile != "" {
+		t.Errorf("default StateFile = %q, want empty", cfg.StateFile)
+	}
+	path := writeConfigFile(t, "url: https://tdarr.example.com\nstate_file: /var/lib/tdarr-exporter/file.json\n")
+	if cfg, err = parseConfig(newFS(), []string{"-config.file", path}, envFunc(nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.StateFile != "/var/lib/tdarr-exporter/file.json" {
+		t.Errorf("file StateFile = %q, want /var/lib/tdarr-exporter/file.json", cfg.StateFile)
+	}
+	env := map[string]string{envTdarrUrl: "https://tdarr.example.com", envStateFile: "/tmp/env.json"}
+	if cfg, err = parseConfig(newFS(), []string{"-config.file", path}, envFunc(env)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.StateFile != "/tmp/env.json" {
+		t.Errorf("env StateFile = %q, want /tmp/env.json to beat the file", cfg.StateFile)
+	}
+	if cfg, err = parseConfig(newFS(), []string{"-state_file", "/tmp/flag.json"}, envFunc(env)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.StateFile != "/tmp/flag.json" {
+		t.Errorf("flag StateFile = %q, want /tmp/flag.json to beat the environment", cfg.StateFile)
+	}
+}
+
+// TestDebugCacheEnabled verifies /debug/cache is off unless turned on, and
+// layers like every other setting.
+func TestDebugCacheEnabled(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := parseConfig(newFS(), nil, envFunc(map[string]string{envTdarrUrl: "https://tdarr.example.com"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.DebugCacheEnabled {
+		t.Error("default DebugCacheEnabled = true, want false")
+	}
+	path := writeConfigFile(t, "url: https://tdarr.example.com\ndebug_cache_enabled: true\n")
+	if cfg, err = parseConfig(newFS(), []string{"-config.file", path}, envFunc(nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.DebugCacheEnabled {
+		t.Error("file DebugCacheEnabled = false, want true")
+	}
+	env := map[string]string{envTdarrUrl: "https://tdarr.example.com", envDebugCacheEnabled: "true"}
+	if cfg, err = parseConfig(newFS(), []string{"-debug_cache_enabled=false"}, envFunc(env)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.DebugCacheEnabled {
+		t.Error("flag DebugCacheEnabled = true, want false to beat the environment")
+	}
+}
+
+// TestFilesCollector verifies the opt-in files collector and its settings
+// layer like every other setting, and that bad buckets are rejected.
+func TestFilesCollector(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := parseConfig(newFS(), nil, envFunc(map[string]string{envTdarrUrl: "https://tdarr.example.com"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(cfg.FileSizeBuckets, DefaultFileSizeBuckets) || cfg.FilesPageSize != 1000 || cfg.TdarrFilesPath != "/api/v2/client/status-tables" {
+		t.Errorf("defaults: buckets %v, page size %d, path %q", cfg.FileSizeBuckets, cfg.FilesPageSize, cfg.TdarrFilesPath)
+	}
+	if cfg.CodecResolutionMaxSeries != 0 {
+		t.Errorf("defaults: codec_resolution_max_series %d, want 0", cfg.CodecResolutionMaxSeries)
+	}
+
+	path := writeConfigFile(t, `
+url: https://tdarr.example.com
+collector.files: true
+file_size_buckets: [1e9, 4e9]
+files_page_size: 200
+codec_resolution_max_series: 20
+tdarr_paths:
+  files: /proxy/status-tables
+request_policies:
+  files:
+    timeout: 1m
+`)
+	if cfg, err = parseConfig(newFS(), []string{"-config.file", path}, envFunc(nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.Collectors.Files || !slices.Equal(cfg.FileSizeBuckets, []float64{1e9, 4e9}) || cfg.FilesPageSize != 200 || cfg.TdarrFilesPath != "/proxy/status-tables" {
+		t.Errorf("file: files %v, buckets %v, page size %d, path %q", cfg.Collectors.Files, cfg.FileSizeBuckets, cfg.FilesPageSize, cfg.TdarrFilesPath)
+	}
+	if cfg.CodecResolutionMaxSeries != 20 {
+		t.Errorf("file: codec_resolution_max_series %d, want 20", cfg.CodecResolutionMaxSeries)
+	}
+	if got := cfg.RequestPolicies["files"].Timeout; got != time.Minute {
+		t.Errorf("request_policies.files timeout = %v, want 1m", got)
+	}
+
+	env := map[string]string{envFileSizeBuckets: "2e9, 8e9", envFilesPageSize: "50", envCodecResolution: "30"}
+	if cfg, err = parseConfig(newFS(), []string{"-config.file", path}, envFunc(env)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(cfg.FileSizeBuckets, []float64{2e9, 8e9}) || cfg.FilesPageSize != 50 || cfg.CodecResolutionMaxSeries != 30 {
+		t.Errorf("env: buckets %v, page size %d, codec_resolution_max_series %d", cfg.FileSizeBuckets, cfg.FilesPageSize, cfg.CodecResolutionMaxSeries)
+	}
+	if cfg, err = parseConfig(newFS(), []string{"-config.file", path, "-file_size_buckets", "3e9", "-files_page_size", "10", "-codec_resolution_max_series", "0"}, envFunc(env)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !slices.Equal(cfg.FileSizeBuckets, []float64{3e9}) || cfg.FilesPageSize != 10 || cfg.CodecResolutionMaxSeries != 0 {
+		t.Errorf("flags: buckets %v, page size %d, codec_resolution_max_series %d", cfg.FileSizeBuckets, cfg.FilesPageSize, cfg.CodecResolutionMaxSeries)
+	}
+
+	for _, args := range [][]string{
+		{"-file_size_buckets", "1e9,big"},
+		{"-file_size_buckets", "4e9,1e9"},
+		{"-file_size_buckets", "0,1e9"},
+		{"-file_size_buckets", "1e9,+Inf"},
+		{"-files_page_size", "0"},
+		{"-codec_resolution_max_series", "-1"},
+	} {
+		if _, err := parseConfig(newFS(), args, envFunc(map[string]string{envTdarrUrl: "https://tdarr.example.com"})); err == nil {
+			t.Errorf("%v: want an error", args)
+		}
+	}
+}
+
+// TestPluginsSettings verifies the plugins collector's refresh interval and
+// path layer like the files collector's settings.
+func TestPluginsSettings(t *testing.T) {
+	t.Parallel()
+	cfg, err := parseConfig(newFS(), nil, envFunc(map[string]string{envTdarrUrl: "https://tdarr.example.com"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Collectors.Plugins || cfg.PluginsRefreshInterval != time.Hour || cfg.TdarrPluginsPath != "/api/v2/search-plugins" {
+		t.Errorf("defaults: plugins %v, refresh %s, path %q", cfg.Collectors.Plugins, cfg.PluginsRefreshInterval, cfg.TdarrPluginsPath)
+	}
+
+	path := writeConfigFile(t, `
+url: https://tdarr.example.com
+collector.plugins: true
+plugins_refresh_interval: 6h
+tdarr_paths:
+  plugins: /proxy/search-plugins
+request_policies:
+  plugins:
+    timeout: 30s
+`)
+	if cfg, err = parseConfig(newFS(), []string{"-config.file", path}, envFunc(nil)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !cfg.Collectors.Plugins || cfg.PluginsRefreshInterval != 6*time.Hour || cfg.TdarrPluginsPath != "/proxy/search-plugins" {
+		t.Errorf("file: plugins %v, refresh %s, path %q", cfg.Collectors.Plugins, cfg.PluginsRefreshInterval, cfg.TdarrPluginsPath)
+	}
+	if got := cfg.RequestPolicies["plugins"].Timeout; got != 30*time.Second {
+		t.Errorf("request_policies.plugins timeout = %v, want 30s", got)
+	}
+
+	env := map[string]string{envPluginsRefresh: "2h"}
+	if cfg, err = parseConfig(newFS(), []string{"-config.file", path}, envFunc(env)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.PluginsRefreshInterval != 2*time.Hour {
+		t.Errorf("env: refresh %s, want 2h", cfg.PluginsRefreshInterval)
+	}
+	if cfg, err = parseConfig(newFS(), []string{"-config.file", path, "-plugins_refresh_interval", "30m"}, envFunc(env)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.PluginsRefreshInterval != 30*time.Minute {
+		t.Errorf("flags: refresh %s, want 30m", cfg.PluginsRefreshInterval)
+	}
+
+	if _, err := parseConfig(newFS(), []string{"-plugins_refresh_interval", "10s"}, envFunc(map[string]string{envTdarrUrl: "https://tdarr.example.com"})); err == nil {
+		t.Errorf("plugins_refresh_interval below 1m: want an error")
+	}
+}
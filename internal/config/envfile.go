@@ -0,0 +1,56 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// readEnvFile parses a docker-style env file: one KEY=VALUE per line, blank
+// lines and '#' comments skipped, an optional leading "export " tolerated, and
+// one layer of matching single or double quotes stripped from the value. There
+// is no interpolation or escape handling; values are taken literally.
+func readEnvFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read env file: %w", err)
+	}
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimPrefix(text, "export ")
+		key, value, ok := strings.Cut(text, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("env file %s: line %d: expected KEY=VALUE", path, line)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		env[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("env file %s: %w", path, err)
+	}
+	return env, nil
+}
+
+// overlayEnv returns a getenv that answers from file first and falls back to
+// getenv. The file wins over the process environment because it is the only
+// part of the environment that can change without a restart: a reload that
+// re-read it but let a stale process variable shadow it would be a no-op.
+func overlayEnv(file map[string]string, getenv func(string) string) func(string) string {
+	return func(key string) string {
+		if v, ok := file[key]; ok {
+			return v
+		}
+		return getenv(key)
+	}
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeEnvFile(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tdarr-exporter.env")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatalf("write env file: %v", err)
+	}
+	return path
+}
+
+func TestReadEnvFile(t *testing.T) {
+	t.Parallel()
+
+	path := writeEnvFile(t, `
+# comment
+TDARR_URL=https://tdarr.example.com
+export LOG_LEVEL = debug
+TDARR_API_KEY="quoted=value"
+INSTANCE_NAME='single'
+EMPTY=
+`)
+	env, err := readEnvFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"TDARR_URL":     "https://tdarr.example.com",
+		"LOG_LEVEL":     "debug",
+		"TDARR_API_KEY": "quoted=value",
+		"INSTANCE_NAME": "single",
+		"EMPTY":         "",
+	}
+	if len(env) != len(want) {
+		t.Errorf("got %d entries %v, want %d", len(env), env, len(want))
+	}
+	for k, v := range want {
+		if env[k] != v {
+			t.Errorf("%s = %q, want %q", k, env[k], v)
+		}
+	}
+}
+
+func TestReadEnvFileErrors(t *testing.T) {
+	t.Parallel()
+
+	path := writeEnvFile(t, "TDARR_URL=https://tdarr.example.com\nnot an assignment\n")
+	if _, err := readEnvFile(path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("error = %v, want a line 2 error", err)
+	}
+	if _, err := readEnvFile(filepath.Join(t.TempDir(), "missing.env")); err == nil {
+		t.Errorf("expected an error for a missing env file")
+	}
+}
+
+// TestParseConfigEnvFile verifies the env file overrides the process
+// environment, can point at the config file, and is itself overridden by flags.
+func TestParseConfigEnvFile(t *testing.T) {
+	t.Parallel()
+
+	configPath := writeConfigFile(t, "http_timeout_seconds: 42\n")
+	envPath := writeEnvFile(t, "TDARR_URL=https://from-env-file.example.com\nLOG_LEVEL=warn\nCONFIG_FILE="+configPath+"\n")
+	env := map[string]string{envTdarrUrl: "https://from-process.example.com", envLogLevel: "debug"}
+
+	cfg, err := parseConfig(newFS(), []string{"-env_file", envPath, "-log_level", "error"}, envFunc(env))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.url != "https://from-env-file.example.com" {
+		t.Errorf("url = %q, want the env file to win over the process environment", cfg.url)
+	}
+	if cfg.LogLevel != "error" {
+		t.Errorf("LogLevel = %q, want the flag to win over the env file", cfg.LogLevel)
+	}
+	if cfg.HttpTimeoutSeconds != 42 || cfg.ConfigFile != configPath {
+		t.Errorf("HttpTimeoutSeconds/ConfigFile = %d/%q, want the config file named by the env file", cfg.HttpTimeoutSeconds, cfg.ConfigFile)
+	}
+	if cfg.EnvFile != envPath {
+		t.Errorf("EnvFile = %q, want %q", cfg.EnvFile, envPath)
+	}
+}
+
+// TestLoad verifies Load reports -h and flag errors instead of exiting and
+// re-reads the env file on every call, which is what a reload relies on.
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	envPath := writeEnvFile(t, "TDARR_URL=https://first.example.com\n")
+	args := []string{"-env_file", envPath}
+	cfg, err := Load(args, envFunc(nil))
+	if err != nil || cfg.InstanceName != "first.example.com" {
+		t.Fatalf("Load = %q, %v; want first.example.com", cfg.InstanceName, err)
+	}
+	if err := os.WriteFile(envPath, []byte("TDARR_URL=https://second.example.com\nRELOAD_ENABLED=true\n"), 0o600); err != nil {
+		t.Fatalf("rewrite env file: %v", err)
+	}
+	cfg, err = Load(args, envFunc(nil))
+	if err != nil || cfg.InstanceName != "second.example.com" || !cfg.ReloadEnabled {
+		t.Fatalf("Load after edit = %q/%v, %v; want second.example.com with reload enabled", cfg.InstanceName, cfg.ReloadEnabled, err)
+	}
+	if _, err := Load([]string{"-no_such_flag"}, envFunc(nil)); err == nil {
+		t.Errorf("expected an error for an unknown flag")
+	}
+}
@@ -0,0 +1,508 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// fileConfig mirrors Config for the optional YAML config file (-config.file).
+// Pointer fields distinguish "key absent" from a zero value, so only keys that
+// are present override the defaults; env vars and flags then layer on top.
+type fileConfig struct {
+	Url                *string                      `yaml:"url"`
+	ApiKey             *string                      `yaml:"api_key"`
+	ApiKeyFile         *string                      `yaml:"api_key_file"`
+	VerifySsl          *bool                        `yaml:"verify_ssl"`
+	TlsCaFile          *string                      `yaml:"tls_ca_file"`
+	TlsCertFile        *string                      `yaml:"tls_cert_file"`
+	TlsKeyFile         *string                      `yaml:"tls_key_file"`
+	TlsServerName      *string                      `yaml:"tls_server_name"`
+	TlsMinVersion      *string                      `yaml:"tls_min_version"`
+	BasicAuth          *BasicAuth                   `yaml:"basic_auth"`
+	BearerToken        *string                      `yaml:"bearer_token"`
+	Oauth2             *Oauth2                      `yaml:"oauth2"`
+	HttpHeaders        map[string]string            `yaml:"http_headers"`
+	PrometheusPort     *string                      `yaml:"prometheus_port"`
+	PrometheusPath     *string                      `yaml:"prometheus_path"`
+	LogLevel           *string                      `yaml:"log_level"`
+	HttpMaxConcurrency *int                         `yaml:"http_max_concurrency"`
+	HttpTimeoutSeconds *int                         `yaml:"http_timeout_seconds"`
+	CircuitThreshold   *int                         `yaml:"circuit_breaker_threshold"`
+	CircuitCooldown    *int                         `yaml:"circuit_breaker_cooldown_seconds"`
+	PollInterval       *time.Duration               `yaml:"poll_interval"`
+	ScrapeTimeout      *time.Duration               `yaml:"scrape_timeout_offset"`
+	StateFile          *string                      `yaml:"state_file"`
+	ListenAddress      *string                      `yaml:"listen_address"`
+	InstanceName       *string                      `yaml:"instance_name"`
+	ProbeEnabled       *bool                        `yaml:"probe_enabled"`
+	ProbeMaxTargets    *int                         `yaml:"probe_max_targets"`
+	ProbeModules       map[string]fileProbeModule   `yaml:"probe_modules"`
+	ReloadEnabled      *bool                        `yaml:"reload_enabled"`
+	DebugCacheEnabled  *bool                        `yaml:"debug_cache_enabled"`
+	CollectorServer    *bool                        `yaml:"collector.server"`
+	CollectorGeneral   *bool                        `yaml:"collector.general"`
+	CollectorLibrary   *bool                        `yaml:"collector.library"`
+	CollectorNodes     *bool                        `yaml:"collector.nodes"`
+	CollectorWorkers   *bool                        `yaml:"collector.workers"`
+	CollectorFiles     *bool                        `yaml:"collector.files"`
+	CollectorStreams   *bool                        `yaml:"collector.streams"`
+	CollectorFlows     *bool                        `yaml:"collector.flows"`
+	CollectorPlugins   *bool                        `yaml:"collector.plugins"`
+	CollectorStaged    *bool                        `yaml:"collector.staged"`
+	FileSizeBuckets    []float64                    `yaml:"file_size_buckets"`
+	FilesPageSize      *int                         `yaml:"files_page_size"`
+	CodecResolution    *int                         `yaml:"codec_resolution_max_series"`
+	PluginsRefresh     *time.Duration               `yaml:"plugins_refresh_interval"`
+	TdarrPaths         fileTdarrPaths               `yaml:"tdarr_paths"`
+	RequestPolicies    map[string]fileRequestPolicy `yaml:"request_policies"`
+	LabelPolicies      map[string]fileLabelPolicy   `yaml:"label_policies"`
+	Targets            []fileTarget                 `yaml:"targets"`
+}
+
+type fileProbeModule struct {
+	ApiKey    string `yaml:"api_key"`
+	VerifySsl *bool  `yaml:"verify_ssl"`
+}
+
+// fileTdarrPaths are the Tdarr API paths. They are not exposed as env vars or
+// flags (a wrong path is never what a user wants on the command line), but the
+// config file covers every Config field, so a reverse proxy that remaps the
+// API can still be accommodated there.
+type fileTdarrPaths struct {
+	Stats    *string `yaml:"stats"`
+	PieStats *string `yaml:"pie_stats"`
+	Nodes    *string `yaml:"nodes"`
+	Status   *string `yaml:"status"`
+	Files    *string `yaml:"files"`
+	Plugins  *string `yaml:"plugins"`
+}
+
+// fileRequestPolicy is a request_policies entry, keyed like tdarr_paths. Like
+// the paths it is file-only; unset fields take defaultRequestPolicy's values.
+type fileRequestPolicy struct {
+	Timeout       *time.Duration `yaml:"timeout"`
+	Retries       *int           `yaml:"retries"`
+	Backoff       *time.Duration `yaml:"backoff"`
+	MaxBackoff    *time.Duration `yaml:"max_backoff"`
+	Jitter        *float64       `yaml:"jitter"`
+	RetryStatuses []int          `yaml:"retry_statuses"`
+}
+
+// fileLabelPolicy is a label_policies entry, keyed by label name. File-only,
+// since regex rules do not fit a flag.
+type fileLabelPolicy struct {
+	Mode    string             `yaml:"mode"`
+	Prefix  string             `yaml:"prefix"`
+	Rules   []fileLabelRewrite `yaml:"rules"`
+	HashKey string             `yaml:"hash_key"`
+}
+
+type fileLabelRewrite struct {
+	Match   string `yaml:"match"`
+	Replace string `yaml:"replace"`
+}
+
+type fileTarget struct {
+	Url                string            `yaml:"url"`
+	InstanceName       string            `yaml:"instance_name"`
+	ApiKey             *string           `yaml:"api_key"`
+	ApiKeyFile         *string           `yaml:"api_key_file"`
+	VerifySsl          *bool             `yaml:"verify_ssl"`
+	TlsCaFile          *string           `yaml:"tls_ca_file"`
+	TlsCertFile        *string           `yaml:"tls_cert_file"`
+	TlsKeyFile         *string           `yaml:"tls_key_file"`
+	TlsServerName      *string           `yaml:"tls_server_name"`
+	BasicAuth          *BasicAuth        `yaml:"basic_auth"`
+	BearerToken        *string           `yaml:"bearer_token"`
+	Oauth2             *Oauth2           `yaml:"oauth2"`
+	HttpHeaders        map[string]string `yaml:"http_headers"`
+	HttpTimeoutSeconds *int              `yaml:"http_timeout_seconds"`
+	HttpMaxConcurrency *int              `yaml:"http_max_concurrency"`
+}
+
+// lookupFlagArg finds the value of flag name in args without a FlagSet, using
+// the stdlib flag syntax (-name, --name, =value or the next argument) and
+// stopping at "--" or the first non-flag argument like flag.Parse does. It
+// exists because the config file path must be known before the real FlagSet is
+// defined: the file's values become the flag defaults shown by -h.
+func lookupFlagArg(args []string, name string) (string, bool) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || len(arg) < 2 || arg[0] != '-' {
+			return "", false
+		}
+		arg = strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if arg == name && i+1 < len(args) {
+			return args[i+1], true
+		}
+		if value, ok := strings.CutPrefix(arg, name+"="); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// loadConfigFile reads the YAML file at path and overlays it on base.
+// Decoding is strict: an unknown key, a type mismatch or malformed YAML fails
+// with the yaml library's "line N: ..." message, and semantic target errors are
+// reported with the line of the offending list item.
+func loadConfigFile(path string, base Config) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("read config file: %w", err)
+	}
+	var fc fileConfig
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&fc); err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, fmt.Errorf("config file %s: %w", path, err)
+	}
+	// Decode a second time into a node tree purely to recover line numbers for
+	// the semantic checks below; the strict decode above already validated shape.
+	var root yaml.Node
+	_ = yaml.Unmarshal(data, &root)
+	lines := fileLines(&root)
+
+	cfg := base
+	setIfPresent(&cfg.url, fc.Url)
+	setIfPresent(&cfg.ApiKey, fc.ApiKey)
+	setIfPresent(&cfg.ApiKeyFile, fc.ApiKeyFile)
+	setIfPresent(&cfg.VerifySsl, fc.VerifySsl)
+	setIfPresent(&cfg.TlsCaFile, fc.TlsCaFile)
+	setIfPresent(&cfg.TlsCertFile, fc.TlsCertFile)
+	setIfPresent(&cfg.TlsKeyFile, fc.TlsKeyFile)
+	setIfPresent(&cfg.TlsServerName, fc.TlsServerName)
+	setIfPresent(&cfg.TlsMinVersion, fc.TlsMinVersion)
+	// Validated by parseConfig once env and flags are layered on top.
+	setIfPresent(&cfg.BasicAuth, fc.BasicAuth)
+	setIfPresent(&cfg.BearerToken, fc.BearerToken)
+	setIfPresent(&cfg.Oauth2, fc.Oauth2)
+	if fc.HttpHeaders != nil {
+		cfg.HttpHeaders = fc.HttpHeaders
+	}
+	setIfPresent(&cfg.PrometheusPort, fc.PrometheusPort)
+	setIfPresent(&cfg.PrometheusPath, fc.PrometheusPath)
+	setIfPresent(&cfg.LogLevel, fc.LogLevel)
+	setIfPresent(&cfg.HttpMaxConcurrency, fc.HttpMaxConcurrency)
+	setIfPresent(&cfg.HttpTimeoutSeconds, fc.HttpTimeoutSeconds)
+	setIfPresent(&cfg.CircuitBreakerThreshold, fc.CircuitThreshold)
+	setIfPresent(&cfg.CircuitBreakerCooldownSeconds, fc.CircuitCooldown)
+	setIfPresent(&cfg.PollInterval, fc.PollInterval)
+	setIfPresent(&cfg.ScrapeTimeoutOffset, fc.ScrapeTimeout)
+	setIfPresent(&cfg.StateFile, fc.StateFile)
+	setIfPresent(&cfg.ListenAddress, fc.ListenAddress)
+	setIfPresent(&cfg.InstanceName, fc.InstanceName)
+	setIfPresent(&cfg.ProbeEnabled, fc.ProbeEnabled)
+	setIfPresent(&cfg.ProbeMaxTargets, fc.ProbeMaxTargets)
+	setIfPresent(&cfg.ReloadEnabled, fc.ReloadEnabled)
+	setIfPresent(&cfg.DebugCacheEnabled, fc.DebugCacheEnabled)
+	setIfPresent(&cfg.Collectors.Server, fc.CollectorServer)
+	setIfPresent(&cfg.Collectors.General, fc.CollectorGeneral)
+	setIfPresent(&cfg.Collectors.Library, fc.CollectorLibrary)
+	setIfPresent(&cfg.Collectors.Nodes, fc.CollectorNodes)
+	setIfPresent(&cfg.Collectors.Workers, fc.CollectorWorkers)
+	setIfPresent(&cfg.Collectors.Files, fc.CollectorFiles)
+	setIfPresent(&cfg.Collectors.Streams, fc.CollectorStreams)
+	setIfPresent(&cfg.Collectors.Flows, fc.CollectorFlows)
+	setIfPresent(&cfg.Collectors.Plugins, fc.CollectorPlugins)
+	setIfPresent(&cfg.Collectors.Staged, fc.CollectorStaged)
+	if fc.FileSizeBuckets != nil {
+		cfg.FileSizeBuckets = fc.FileSizeBuckets
+	}
+	setIfPresent(&cfg.FilesPageSize, fc.FilesPageSize)
+	setIfPresent(&cfg.CodecResolutionMaxSeries, fc.CodecResolution)
+	setIfPresent(&cfg.PluginsRefreshInterval, fc.PluginsRefresh)
+
+	for _, p := range []struct {
+		key   string
+		value *string
+		dst   *string
+	}{
+		{"stats", fc.TdarrPaths.Stats, &cfg.TdarrStatsPath},
+		{"pie_stats", fc.TdarrPaths.PieStats, &cfg.TdarrPieStatsPath},
+		{"nodes", fc.TdarrPaths.Nodes, &cfg.TdarrNodePath},
+		{"status", fc.TdarrPaths.Status, &cfg.TdarrStatusPath},
+		{"files", fc.TdarrPaths.Files, &cfg.TdarrFilesPath},
+		{"plugins", fc.TdarrPaths.Plugins, &cfg.TdarrPluginsPath},
+	} {
+		if p.value == nil {
+			continue
+		}
+		if !strings.HasPrefix(*p.value, "/") {
+			return Config{}, fmt.Errorf("config file %s: line %d: tdarr_paths.%s must start with '/', got %q", path, lines.paths[p.key], p.key, *p.value)
+		}
+		*p.dst = *p.value
+	}
+
+	if len(fc.RequestPolicies) > 0 {
+		policies, err := parseRequestPolicies(fc.RequestPolicies, lines.policies)
+		if err != nil {
+			return Config{}, fmt.Errorf("config file %s: %w", path, err)
+		}
+		cfg.RequestPolicies = policies
+	}
+
+	if len(fc.LabelPolicies) > 0 {
+		policies, err := parseLabelPolicies(fc.LabelPolicies, lines.labels)
+		if err != nil {
+			return Config{}, fmt.Errorf("config file %s: %w", path, err)
+		}
+		cfg.LabelPolicies = policies
+	}
+
+	if len(fc.ProbeModules) > 0 {
+		modules := make(map[string]ProbeModule, len(base.ProbeModules)+len(fc.ProbeModules))
+		for name, module := range base.ProbeModules {
+			modules[name] = module
+		}
+		for name, module := range fc.ProbeModules {
+			if name == DefaultProbeModule {
+				return Config{}, fmt.Errorf("config file %s: line %d: probe module %q is built in and cannot be redefined", path, lines.modules[name], name)
+			}
+			verifySsl := true
+			setIfPresent(&verifySsl, module.VerifySsl)
+			modules[name] = ProbeModule{ApiKey: module.ApiKey, VerifySsl: verifySsl}
+		}
+		cfg.ProbeModules = modules
+	}
+
+	targets, err := parseFileTargets(fc.Targets, lines.targets)
+	if err != nil {
+		return Config{}, fmt.Errorf("config file %s: %w", path, err)
+	}
+	cfg.Targets = targets
+	return cfg, nil
+}
+
+// maxPolicyRetries bounds request_policies retries; each retry costs a
+// scrape at least one backoff.
+const maxPolicyRetries = 10
+
+// parseRequestPolicies validates the request_policies entries and fills in the
+// defaults. lines holds the line of each endpoint key.
+func parseRequestPolicies(filePolicies map[string]fileRequestPolicy, lines map[string]int) (map[string]RequestPolicy, error) {
+	policies := make(map[string]RequestPolicy, len(filePolicies))
+	for endpoint, fp := range filePolicies {
+		fail := func(format string, args ...any) error {
+			return fmt.Errorf("line %d: request_policies.%s: %s", lines[endpoint], endpoint, fmt.Sprintf(format, args...))
+		}
+		switch endpoint {
+		case "stats", "pie_stats", "nodes", "status", "files", "plugins":
+		default:
+			return nil, fail("unknown endpoint, want one of stats, pie_stats, nodes, status, files, plugins")
+		}
+		p := defaultRequestPolicy
+		setIfPresent(&p.Timeout, fp.Timeout)
+		setIfPresent(&p.Retries, fp.Retries)
+		setIfPresent(&p.Backoff, fp.Backoff)
+		setIfPresent(&p.MaxBackoff, fp.MaxBackoff)
+		setIfPresent(&p.Jitter, fp.Jitter)
+		p.RetryStatuses = fp.RetryStatuses
+		switch {
+		case fp.Timeout != nil && p.Timeout <= 0:
+			return nil, fail("timeout must be positive, got %s", p.Timeout)
+		case p.Retries < 0 || p.Retries > maxPolicyRetries:
+			return nil, fail("retries must be between 0 and %d, got %d", maxPolicyRetries, p.Retries)
+		case p.Backoff < 0 || p.MaxBackoff < 0:
+			return nil, fail("backoff and max_backoff must not be negative")
+		case p.Jitter < 0 || p.Jitter > 1:
+			return nil, fail("jitter must be between 0 and 1, got %g", p.Jitter)
+		}
+		for _, code := range p.RetryStatuses {
+			if code < 400 || code > 599 {
+				return nil, fail("retry_statuses must be 4xx or 5xx codes, got %d", code)
+			}
+		}
+		policies[endpoint] = p
+	}
+	return policies, nil
+}
+
+// parseLabelPolicies validates the label_policies entries and compiles their
+// rules. lines holds the line of each label key.
+func parseLabelPolicies(filePolicies map[string]fileLabelPolicy, lines map[string]int) (LabelPolicies, error) {
+	var policies LabelPolicies
+	for label, fp := range filePolicies {
+		fail := func(format string, args ...any) error {
+			return fmt.Errorf("line %d: label_policies.%s: %s", lines[label], label, fmt.Sprintf(format, args...))
+		}
+		var dst *LabelPolicy
+		switch label {
+		case "worker_file":
+			dst = &policies.WorkerFile
+		case "library_name":
+			dst = &policies.LibraryName
+		case "library_folder":
+			dst = &policies.LibraryFolder
+		default:
+			return LabelPolicies{}, fail("unknown label, want worker_file, library_name or library_folder")
+		}
+		p := LabelPolicy{Mode: LabelMode(fp.Mode), Prefix: fp.Prefix, HashKey: fp.HashKey}
+		switch p.Mode {
+		case LabelKeep, LabelBasename, LabelDrop:
+		case LabelStripPrefix:
+			if p.Prefix == "" {
+				return LabelPolicies{}, fail("mode strip_prefix needs a prefix")
+			}
+		case LabelRegex:
+			if len(fp.Rules) == 0 {
+				return LabelPolicies{}, fail("mode regex needs at least one rule")
+			}
+			for i, rule := range fp.Rules {
+				if rule.Match == "" {
+					return LabelPolicies{}, fail("rules[%d]: match is required", i)
+				}
+				re, err := regexp.Compile(rule.Match)
+				if err != nil {
+					return LabelPolicies{}, fail("rules[%d]: %v", i, err)
+				}
+				p.Rules = append(p.Rules, LabelRewrite{Match: re, Replace: rule.Replace})
+			}
+		case LabelHash:
+			if p.HashKey == "" {
+				return LabelPolicies{}, fail("mode hash needs a hash_key")
+			}
+		default:
+			return LabelPolicies{}, fail("unknown mode %q, want one of keep, basename, strip_prefix, regex, hash, drop", fp.Mode)
+		}
+		*dst = p
+	}
+	return policies, nil
+}
+
+// parseFileTargets validates the targets list and resolves each entry's url and
+// default instance name. lines holds the line of each list item, index-aligned.
+func parseFileTargets(fileTargets []fileTarget, lines []int) ([]Target, error) {
+	targets := make([]Target, 0, len(fileTargets))
+	seen := make(map[string]int, len(fileTargets))
+	for i, ft := range fileTargets {
+		line := 0
+		if i < len(lines) {
+			line = lines[i]
+		}
+		if ft.Url == "" {
+			return nil, fmt.Errorf("line %d: targets[%d]: url is required", line, i)
+		}
+		urlParsed, err := parseUrl(ft.Url)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: targets[%d]: %w", line, i, err)
+		}
+		if ft.ApiKey != nil && ft.ApiKeyFile != nil {
+			return nil, fmt.Errorf("line %d: targets[%d]: api_key and api_key_file are mutually exclusive, set only one", line, i)
+		}
+		if (ft.TlsCertFile == nil) != (ft.TlsKeyFile == nil) {
+			return nil, fmt.Errorf("line %d: targets[%d]: tls_cert_file and tls_key_file must be set together", line, i)
+		}
+		var (
+			basic  BasicAuth
+			bearer string
+			oauth2 Oauth2
+		)
+		setIfPresent(&basic, ft.BasicAuth)
+		setIfPresent(&bearer, ft.BearerToken)
+		setIfPresent(&oauth2, ft.Oauth2)
+		if err := validateAuth(basic, bearer, oauth2, ft.HttpHeaders); err != nil {
+			return nil, fmt.Errorf("line %d: targets[%d]: %w", line, i, err)
+		}
+		if ft.HttpTimeoutSeconds != nil && *ft.HttpTimeoutSeconds <= 0 {
+			return nil, fmt.Errorf("line %d: targets[%d]: http_timeout_seconds must be at least 1", line, i)
+		}
+		if ft.HttpMaxConcurrency != nil && *ft.HttpMaxConcurrency <= 0 {
+			return nil, fmt.Errorf("line %d: targets[%d]: http_max_concurrency must be at least 1 (single connection)", line, i)
+		}
+		name := ft.InstanceName
+		if name == "" {
+			name = urlParsed.Hostname()
+		}
+		if prev, dup := seen[name]; dup {
+			return nil, fmt.Errorf("line %d: targets[%d]: instance_name %q is already used by the target on line %d; set instance_name to tell them apart", line, i, name, prev)
+		}
+		seen[name] = line
+		targets = append(targets, Target{
+			url:                ft.Url,
+			line:               line,
+			UrlParsed:          urlParsed,
+			InstanceName:       name,
+			ApiKey:             ft.ApiKey,
+			ApiKeyFile:         ft.ApiKeyFile,
+			VerifySsl:          ft.VerifySsl,
+			TlsCaFile:          ft.TlsCaFile,
+			TlsCertFile:        ft.TlsCertFile,
+			TlsKeyFile:         ft.TlsKeyFile,
+			TlsServerName:      ft.TlsServerName,
+			BasicAuth:          ft.BasicAuth,
+			BearerToken:        ft.BearerToken,
+			Oauth2:             ft.Oauth2,
+			HttpHeaders:        ft.HttpHeaders,
+			HttpTimeoutSeconds: ft.HttpTimeoutSeconds,
+			HttpMaxConcurrency: ft.HttpMaxConcurrency,
+		})
+	}
+	return targets, nil
+}
+
+// fileLineIndex holds the source lines the semantic validation reports.
+type fileLineIndex struct {
+	targets  []int
+	modules  map[string]int
+	paths    map[string]int
+	policies map[string]int
+	labels   map[string]int
+}
+
+// fileLines walks the document node tree and records the line of each targets
+// item, probe module key, tdarr_paths key, request_policies key and
+// label_policies key. Missing sections yield empty
+// entries (reported as line 0, which cannot happen for a key that was decoded).
+func fileLines(root *yaml.Node) fileLineIndex {
+	idx := fileLineIndex{modules: map[string]int{}, paths: map[string]int{}, policies: map[string]int{}, labels: map[string]int{}}
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return idx
+	}
+	doc := root.Content[0]
+	if doc.Kind != yaml.MappingNode {
+		return idx
+	}
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		key, value := doc.Content[i], doc.Content[i+1]
+		switch key.Value {
+		case "targets":
+			for _, item := range value.Content {
+				idx.targets = append(idx.targets, item.Line)
+			}
+		case "probe_modules":
+			recordKeyLines(value, idx.modules)
+		case "tdarr_paths":
+			recordKeyLines(value, idx.paths)
+		case "request_policies":
+			recordKeyLines(value, idx.policies)
+		case "label_policies":
+			recordKeyLines(value, idx.labels)
+		}
+	}
+	return idx
+}
+
+func recordKeyLines(mapping *yaml.Node, into map[string]int) {
+	if mapping.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		into[mapping.Content[i].Value] = mapping.Content[i].Line
+	}
+}
+
+// setIfPresent overwrites *dst with *value when the file set the key.
+func setIfPresent[T any](dst *T, value *T) {
+	if value != nil {
+		*dst = *value
+	}
+}
@@ -0,0 +1,485 @@
+package config
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfigFile writes body to a temp YAML file and returns its path.
+func writeConfigFile(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatalf("write config file: %v", err)
+	}
+	return path
+}
+
+func TestLookupFlagArg(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		args      []string
+		wantValue string
+		wantOk    bool
+	}{
+		{"absent", []string{"-url", "x"}, "", false},
+		{"single dash next arg", []string{"-config.file", "a.yaml"}, "a.yaml", true},
+		{"double dash equals", []string{"--config.file=b.yaml"}, "b.yaml", true},
+		{"after other flags", []string{"-url=x", "-config.file", "c.yaml"}, "c.yaml", true},
+		{"stops at terminator", []string{"--", "-config.file", "d.yaml"}, "", false},
+		{"stops at non-flag", []string{"positional", "-config.file", "e.yaml"}, "", false},
+		{"missing value", []string{"-config.file"}, "", false},
+		{"prefix is not a match", []string{"-config.filex=f.yaml"}, "", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, ok := lookupFlagArg(tc.args, "config.file")
+			if got != tc.wantValue || ok != tc.wantOk {
+				t.Errorf("lookupFlagArg(%q) = (%q, %v), want (%q, %v)", tc.args, got, ok, tc.wantValue, tc.wantOk)
+			}
+		})
+	}
+}
+
+// TestConfigFilePrecedence verifies defaults -> file -> env -> flags: the file
+// overrides defaults, env overrides the file, flags override both.
+func TestConfigFilePrecedence(t *testing.T) {
+	t.Parallel()
+
+	path := writeConfigFile(t, `
+url: https://file.example.com
+api_key: file-key
+prometheus_port: 9100
+log_level: debug
+http_timeout_seconds: 30
+tdarr_paths:
+  stats: /proxy/api/v2/cruddb
+`)
+	env := map[string]string{
+		envConfigFile:     path,
+		envTdarrApiKey:    "env-key",
+		envPrometheusPort: "9200",
+	}
+	cfg, err := parseConfig(newFS(), []string{"-prometheus_port", "9300"}, envFunc(env))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.url != "https://file.example.com" {
+		t.Errorf("url = %q, want file value", cfg.url)
+	}
+	if cfg.LogLevel != "debug" || cfg.HttpTimeoutSeconds != 30 {
+		t.Errorf("LogLevel/HttpTimeoutSeconds = %q/%d, want file values debug/30", cfg.LogLevel, cfg.HttpTimeoutSeconds)
+	}
+	if cfg.ApiKey != "env-key" {
+		t.Errorf("ApiKey = %q, want env to override file", cfg.ApiKey)
+	}
+	if cfg.PrometheusPort != "9300" {
+		t.Errorf("PrometheusPort = %q, want flag to override env and file", cfg.PrometheusPort)
+	}
+	if cfg.TdarrStatsPath != "/proxy/api/v2/cruddb" {
+		t.Errorf("TdarrStatsPath = %q, want file override", cfg.TdarrStatsPath)
+	}
+	if cfg.TdarrNodePath != "/api/v2/get-nodes" {
+		t.Errorf("TdarrNodePath = %q, want default when the file does not set it", cfg.TdarrNodePath)
+	}
+	if cfg.ConfigFile != path {
+		t.Errorf("ConfigFile = %q, want %q", cfg.ConfigFile, path)
+	}
+}
+
+// TestConfigFileFlagBeatsEnvPath checks -config.file takes precedence over
+// CONFIG_FILE and that file values become the flag defaults shown by -h.
+func TestConfigFileFlagBeatsEnvPath(t *testing.T) {
+	t.Parallel()
+
+	envPath := writeConfigFile(t, "url: https://env-file.example.com\n")
+	flagPath := writeConfigFile(t, "url: https://flag-file.example.com\nprometheus_path: /tdarr\n")
+	fset := newFS()
+	cfg, err := parseConfig(fset, []string{"--config.file=" + flagPath}, envFunc(map[string]string{envConfigFile: envPath}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.url != "https://flag-file.example.com" {
+		t.Errorf("url = %q, want the -config.file value", cfg.url)
+	}
+	if got := fset.Lookup("prometheus_path").DefValue; got != "/tdarr" {
+		t.Errorf("prometheus_path default = %q, want the file value /tdarr", got)
+	}
+}
+
+func TestConfigFileEmpty(t *testing.T) {
+	t.Parallel()
+
+	path := writeConfigFile(t, "# nothing configured here\n")
+	cfg, err := parseConfig(newFS(), []string{"-config.file", path, "-url", "https://tdarr.example.com"}, envFunc(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.PrometheusPath != "/metrics" {
+		t.Errorf("PrometheusPath = %q, want default", cfg.PrometheusPath)
+	}
+}
+
+func TestConfigFileErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{
+			name:    "unknown key",
+			body:    "url: https://tdarr.example.com\nverify_tls: false\n",
+			wantErr: "line 2: field verify_tls not found",
+		},
+		{
+			name:    "unknown target key",
+			body:    "targets:\n  - url: https://a.example.com\n    token: x\n",
+			wantErr: "line 3: field token not found",
+		},
+		{
+			name:    "type mismatch",
+			body:    "url: https://tdarr.example.com\nhttp_timeout_seconds: soon\n",
+			wantErr: "line 2: cannot unmarshal",
+		},
+		{
+			name:    "malformed yaml",
+			body:    "url: [unterminated\n",
+			wantErr: "line",
+		},
+		{
+			name:    "target without url",
+			body:    "targets:\n  - url: https://a.example.com\n  - instance_name: b\n",
+			wantErr: "line 3: targets[1]: url is required",
+		},
+		{
+			name:    "target bad timeout",
+			body:    "targets:\n  - url: https://a.example.com\n    http_timeout_seconds: 0\n",
+			wantErr: "line 2: targets[0]: http_timeout_seconds must be at least 1",
+		},
+		{
+			name:    "duplicate target instance names",
+			body:    "targets:\n  - url: https://a.example.com\n  - url: http://a.example.com:8266\n",
+			wantErr: `line 3: targets[1]: instance_name "a.example.com" is already used by the target on line 2`,
+		},
+		{
+			name:    "relative tdarr path",
+			body:    "url: https://tdarr.example.com\ntdarr_paths:\n  status: api/v2/status\n",
+			wantErr: "line 3: tdarr_paths.status must start with '/'",
+		},
+		{
+			name:    "unknown request policy endpoint",
+			body:    "url: https://tdarr.example.com\nrequest_policies:\n  get_pies:\n    retries: 1\n",
+			wantErr: "line 3: request_policies.get_pies: unknown endpoint",
+		},
+		{
+			name:    "request policy jitter out of range",
+			body:    "url: https://tdarr.example.com\nrequest_policies:\n  status:\n    jitter: 1.5\n",
+			wantErr: "line 3: request_policies.status: jitter must be between 0 and 1",
+		},
+		{
+			name:    "request policy bare number timeout",
+			body:    "url: https://tdarr.example.com\nrequest_policies:\n  status:\n    timeout: 5\n",
+			wantErr: "line 4: cannot unmarshal",
+		},
+		{
+			name:    "request policy retry status",
+			body:    "url: https://tdarr.example.com\nrequest_policies:\n  nodes:\n    retry_statuses: [200]\n",
+			wantErr: "line 3: request_policies.nodes: retry_statuses must be 4xx or 5xx codes, got 200",
+		},
+		{
+			name:    "unknown label policy label",
+			body:    "url: https://tdarr.example.com\nlabel_policies:\n  node_name:\n    mode: drop\n",
+			wantErr: "line 3: label_policies.node_name: unknown label",
+		},
+		{
+			name:    "unknown label policy mode",
+			body:    "url: https://tdarr.example.com\nlabel_policies:\n  worker_file:\n    mode: truncate\n",
+			wantErr: `line 3: label_policies.worker_file: unknown mode "truncate"`,
+		},
+		{
+			name:    "strip_prefix without prefix",
+			body:    "url: https://tdarr.example.com\nlabel_policies:\n  worker_file:\n    mode: strip_prefix\n",
+			wantErr: "line 3: label_policies.worker_file: mode strip_prefix needs a prefix",
+		},
+		{
+			name:    "label policy bad regex",
+			body:    "url: https://tdarr.example.com\nlabel_policies:\n  library_name:\n    mode: regex\n    rules:\n      - match: '(unclosed'\n",
+			wantErr: "line 3: label_policies.library_name: rules[0]: error parsing regexp",
+		},
+		{
+			name:    "hash without key",
+			body:    "url: https://tdarr.example.com\nlabel_policies:\n  worker_file:\n    mode: hash\n",
+			wantErr: "line 3: label_policies.worker_file: mode hash needs a hash_key",
+		},
+		{
+			name:    "redefined default probe module",
+			body:    "url: https://tdarr.example.com\nprobe_modules:\n  default:\n    api_key: x\n",
+			wantErr: `line 3: probe module "default" is built in`,
+		},
+		{
+			name:    "file value still validated",
+			body:    "url: https://tdarr.example.com\nprometheus_path: metrics\n",
+			wantErr: "prometheus_path must start with '/'",
+		},
+		{
+			name:    "target duplicates primary",
+			body:    "url: https://a.example.com\ntargets:\n  - url: http://a.example.com:8266\n",
+			wantErr: `line 3: target instance_name "a.example.com" duplicates the primary instance name`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			path := writeConfigFile(t, tc.body)
+			fset := newFS()
+			fset.SetOutput(io.Discard)
+			_, err := parseConfig(fset, []string{"-config.file", path}, envFunc(nil))
+			if err == nil {
+				t.Fatalf("expected error containing %q, got nil", tc.wantErr)
+			}
+			if !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", err, tc.wantErr)
+			}
+			// File errors are semantic config errors (exit 1), not flag syntax errors.
+			if errors.Is(err, errFlagParse) {
+				t.Errorf("config file error must not be tagged errFlagParse: %v", err)
+			}
+		})
+	}
+}
+
+func TestConfigFileMissing(t *testing.T) {
+	t.Parallel()
+
+	missing := filepath.Join(t.TempDir(), "absent.yaml")
+	_, err := parseConfig(newFS(), []string{"-config.file", missing}, envFunc(nil))
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("error = %v, want fs.ErrNotExist", err)
+	}
+}
+
+func TestConfigFileTargets(t *testing.T) {
+	t.Parallel()
+
+	path := writeConfigFile(t, `
+api_key: shared-key
+http_max_concurrency: 2
+targets:
+  - url: https://tdarr-a.lan
+  - url: http://tdarr-b.lan:8266
+    instance_name: b
+    api_key: b-key
+    verify_ssl: false
+    http_timeout_seconds: 60
+    http_max_concurrency: 5
+`)
+	// No primary url: targets alone are enough. The timeout flag reaches
+	// targets that do not override it.
+	cfg, err := parseConfig(newFS(), []string{"-config.file", path, "-http_timeout_seconds", "20"}, envFunc(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.UrlParsed != nil {
+		t.Errorf("UrlParsed = %v, want nil without a primary url", cfg.UrlParsed)
+	}
+
+	targets := cfg.TargetConfigs()
+	if len(targets) != 2 {
+		t.Fatalf("TargetConfigs: got %d configs, want 2", len(targets))
+	}
+	a, b := targets[0], targets[1]
+	if a.InstanceName != "tdarr-a.lan" || a.UrlParsed.String() != "https://tdarr-a.lan" {
+		t.Errorf("target a = %q/%q, want hostname default and its url", a.InstanceName, a.UrlParsed)
+	}
+	if a.ApiKey != "shared-key" || !a.VerifySsl || a.HttpTimeoutSeconds != 20 || a.HttpMaxConcurrency != 2 {
+		t.Errorf("target a = %+v, want inherited top-level values", a)
+	}
+	if b.InstanceName != "b" || b.ApiKey != "b-key" || b.VerifySsl || b.HttpTimeoutSeconds != 60 || b.HttpMaxConcurrency != 5 {
+		t.Errorf("target b = %+v, want its overrides", b)
+	}
+	for _, tc := range targets {
+		if tc.Targets != nil {
+			t.Errorf("TargetConfigs entry %q still carries Targets", tc.InstanceName)
+		}
+	}
+}
+
+func TestTargetConfigsPrimaryFirst(t *testing.T) {
+	t.Parallel()
+
+	path := writeConfigFile(t, "targets:\n  - url: https://second.lan\n")
+	cfg, err := parseConfig(newFS(), []string{"-config.file", path, "-url", "https://first.lan"}, envFunc(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	targets := cfg.TargetConfigs()
+	if len(targets) != 2 || targets[0].InstanceName != "first.lan" || targets[1].InstanceName != "second.lan" {
+		t.Errorf("TargetConfigs order = %v, want [first.lan second.lan]", targets)
+	}
+}
+
+// TestConfigFileProbeModules checks file modules load and env modules layer on
+// top of them, replacing a same-named module.
+func TestConfigFileProbeModules(t *testing.T) {
+	t.Parallel()
+
+	path := writeConfigFile(t, `
+url: https://tdarr.example.com
+probe_modules:
+  homelab:
+    api_key: file-homelab
+  lab:
+    api_key: file-lab
+    verify_ssl: false
+`)
+	env := map[string]string{
+		envProbeModules:                        "lab",
+		probeModuleEnvKey("lab", "API_KEY"):    "env-lab",
+		probeModuleEnvKey("lab", "VERIFY_SSL"): "true",
+	}
+	cfg, err := parseConfig(newFS(), []string{"-config.file", path}, envFunc(env))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := cfg.ProbeModules["homelab"]; got != (ProbeModule{ApiKey: "file-homelab", VerifySsl: true}) {
+		t.Errorf("homelab module = %+v, want file values with verify_ssl defaulting to true", got)
+	}
+	if got := cfg.ProbeModules["lab"]; got != (ProbeModule{ApiKey: "env-lab", VerifySsl: true}) {
+		t.Errorf("lab module = %+v, want env to override the file", got)
+	}
+	if _, ok := cfg.ProbeModules[DefaultProbeModule]; !ok {
+		t.Errorf("default module missing")
+	}
+}
+
+// TestConfigFileRequestPolicies verifies request_policies entries fill unset
+// fields from the defaults and are kept per endpoint.
+func TestConfigFileRequestPolicies(t *testing.T) {
+	t.Parallel()
+
+	path := writeConfigFile(t, `
+url: https://tdarr.example.com
+request_policies:
+  status:
+    timeout: 2s
+    retries: 0
+  pie_stats:
+    timeout: 1m
+    retries: 4
+    backoff: 500ms
+    max_backoff: 8s
+    jitter: 0.2
+    retry_statuses: [429, 503]
+`)
+	cfg, err := parseConfig(newFS(), []string{"-config.file", path}, envFunc(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]RequestPolicy{
+		"status": {Timeout: 2 * time.Second, Retries: 0, Backoff: time.Second, MaxBackoff: 30 * time.Second},
+		"pie_stats": {
+			Timeout: time.Minute, Retries: 4, Backoff: 500 * time.Millisecond, MaxBackoff: 8 * time.Second,
+			Jitter: 0.2, RetryStatuses: []int{429, 503},
+		},
+	}
+	if !reflect.DeepEqual(cfg.RequestPolicies, want) {
+		t.Errorf("RequestPolicies = %+v, want %+v", cfg.RequestPolicies, want)
+	}
+}
+
+func TestConfigFileLabelPolicies(t *testing.T) {
+	t.Parallel()
+
+	path := writeConfigFile(t, `
+url: https://tdarr.example.com
+label_policies:
+  worker_file:
+    mode: regex
+    rules:
+      - match: '^/media/(movies|tv)/.*$'
+        replace: '$1'
+  library_name:
+    mode: hash
+    hash_key: secret
+  library_folder:
+    mode: strip_prefix
+    prefix: /mnt/
+`)
+	cfg, err := parseConfig(newFS(), []string{"-config.file", path}, envFunc(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	worker := cfg.LabelPolicies.WorkerFile
+	if worker.Mode != LabelRegex || len(worker.Rules) != 1 {
+		t.Fatalf("WorkerFile = %+v, want one regex rule", worker)
+	}
+	if got := worker.Rules[0].Match.ReplaceAllString("/media/tv/show.mkv", worker.Rules[0].Replace); got != "tv" {
+		t.Errorf("rule rewrote /media/tv/show.mkv to %q, want tv", got)
+	}
+	if want := (LabelPolicy{Mode: LabelHash, HashKey: "secret"}); !reflect.DeepEqual(cfg.LabelPolicies.LibraryName, want) {
+		t.Errorf("LibraryName = %+v, want %+v", cfg.LabelPolicies.LibraryName, want)
+	}
+	if want := (LabelPolicy{Mode: LabelStripPrefix, Prefix: "/mnt/"}); !reflect.DeepEqual(cfg.LabelPolicies.LibraryFolder, want) {
+		t.Errorf("LibraryFolder = %+v, want %+v", cfg.LabelPolicies.LibraryFolder, want)
+	}
+
+	// Without the section all labels are emitted as Tdarr reports them.
+	cfg, err = parseConfig(newFS(), nil, envFunc(map[string]string{envTdarrUrl: "https://tdarr.example.com"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(cfg.LabelPolicies, LabelPolicies{}) {
+		t.Errorf("default LabelPolicies = %+v, want zero (keep)", cfg.LabelPolicies)
+	}
+}
+
+// TestProbeConfigMatchesTarget checks /probe resolves a configured target by
+// instance name or url to that target's credentials, while the same url probed
+// with an explicit module keeps module semantics.
+func TestProbeConfigMatchesTarget(t *testing.T) {
+	t.Parallel()
+
+	path := writeConfigFile(t, `
+url: https://primary.lan
+probe_modules:
+  other:
+    api_key: other-key
+targets:
+  - url: tdarr-b.lan:8266
+    instance_name: b
+    api_key: b-key
+`)
+	cfg, err := parseConfig(newFS(), []string{"-config.file", path}, envFunc(nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, target := range []string{"b", "tdarr-b.lan:8266"} {
+		probe, err := cfg.ProbeConfig(target, "")
+		if err != nil {
+			t.Fatalf("ProbeConfig(%q): %v", target, err)
+		}
+		if probe.InstanceName != "b" || probe.ApiKey != "b-key" {
+			t.Errorf("ProbeConfig(%q) = %q/%q, want the configured target b/b-key", target, probe.InstanceName, probe.ApiKey)
+		}
+	}
+	probe, err := cfg.ProbeConfig("tdarr-b.lan:8266", "other")
+	if err != nil {
+		t.Fatalf("ProbeConfig with module: %v", err)
+	}
+	if probe.ApiKey != "other-key" || probe.InstanceName != "tdarr-b.lan:8266" {
+		t.Errorf("ProbeConfig with module = %q/%q, want module credentials and host:port", probe.InstanceName, probe.ApiKey)
+	}
+}
@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// DebugCacheHandler serves GET /debug/cache: view's report of the collectors'
+// caches, JSON-encoded, to explain why a scrape did or did not refetch.
+func DebugCacheHandler(view func() any) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(view())
+	})
+}
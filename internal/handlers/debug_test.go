@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestDebugCacheHandler verifies the view is served as JSON.
+func TestDebugCacheHandler(t *testing.T) {
+	t.Parallel()
+
+	h := DebugCacheHandler(func() any {
+		return []map[string]any{{"instance": "tdarr.lan", "empty": true}}
+	})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/cache", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var got []struct {
+		Instance string `json:"instance"`
+		Empty    bool   `json:"empty"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if len(got) != 1 || got[0].Instance != "tdarr.lan" || !got[0].Empty {
+		t.Errorf("body = %+v, want the one empty tdarr.lan cache", got)
+	}
+}
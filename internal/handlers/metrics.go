@@ -1,20 +1,81 @@
 package handlers
 
 import (
+	"context"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/homeylab/tdarr-exporter/internal/collector"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
 )
 
+// scrapeTimeoutHeader is sent by Prometheus with every scrape: the seconds it
+// waits for the response before giving up.
+const scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+
+// scrapeFunc adapts a collector.ScrapeCollector bound to one request's context to
+// prometheus.Collector. It is unchecked (Describe sends nothing): it lives in a
+// registry built per request, never next to the collectors it could clash with.
+type scrapeFunc struct {
+	ctx    context.Context
+	scrape collector.ScrapeCollector
+}
+
+func (s scrapeFunc) Describe(chan<- *prometheus.Desc) {}
+
+func (s scrapeFunc) Collect(ch chan<- prometheus.Metric) {
+	s.scrape.CollectContext(s.ctx, ch)
+}
+
 // MetricsHandler returns the promhttp handler for the registry. The handler's
 // own instrumentation counters are labeled with tdarr_instance to match the
 // const label on the collector metrics. HandlerFor keeps the raw reg (it
 // gathers the real metrics); only the handler-internal counters are wrapped.
 // Setting opts.Registry routes promhttp_metric_handler_errors_total through
 // instReg too (it is registered only when opts.Registry != nil).
-func MetricsHandler(reg *prometheus.Registry, opts promhttp.HandlerOpts, tdarrInstance string) http.Handler {
+//
+// scrape, when not nil, is gathered along with reg under the request's
+// context, with a deadline of the scrape timeout Prometheus sends minus
+// timeoutOffset, so the exporter still has time to answer before Prometheus
+// gives up. A timeout no larger than the offset is used as is.
+func MetricsHandler(reg *prometheus.Registry, opts promhttp.HandlerOpts, tdarrInstance string, scrape collector.ScrapeCollector, timeoutOffset time.Duration) http.Handler {
 	instReg := prometheus.WrapRegistererWith(prometheus.Labels{"tdarr_instance": tdarrInstance}, reg)
 	opts.Registry = instReg
-	return promhttp.InstrumentMetricHandler(instReg, promhttp.HandlerFor(reg, opts))
+	if scrape == nil {
+		return promhttp.InstrumentMetricHandler(instReg, promhttp.HandlerFor(reg, opts))
+	}
+	return promhttp.InstrumentMetricHandler(instReg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if timeout, ok := scrapeTimeout(r, timeoutOffset); ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		scrapeReg := prometheus.NewRegistry()
+		scrapeReg.MustRegister(scrapeFunc{ctx: ctx, scrape: scrape})
+		promhttp.HandlerFor(prometheus.Gatherers{reg, scrapeReg}, opts).ServeHTTP(w, r)
+	}))
+}
+
+// scrapeTimeout returns the time budget for r from its scrape timeout header,
+// less offset, and false when r carries no usable header (e.g. curl).
+func scrapeTimeout(r *http.Request, offset time.Duration) (time.Duration, bool) {
+	raw := r.Header.Get(scrapeTimeoutHeader)
+	if raw == "" {
+		return 0, false
+	}
+	seconds, err := strconv.ParseFloat(raw, 64)
+	if err != nil || !(seconds > 0) || math.IsInf(seconds, 1) {
+		log.Debug().Str("value", raw).Msg("Ignoring invalid " + scrapeTimeoutHeader + " header")
+		return 0, false
+	}
+	timeout := time.Duration(seconds * float64(time.Second))
+	if timeout > offset {
+		timeout -= offset
+	}
+	return timeout, true
 }
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// fakeScrape records the time left before the deadline of the context it was
+// collected under, and emits one gauge.
+type fakeScrape struct {
+	desc        *prometheus.Desc
+	left        time.Duration
+	hasDeadline bool
+}
+
+func (f *fakeScrape) CollectContext(ctx context.Context, ch chan<- prometheus.Metric) {
+	var deadline time.Time
+	deadline, f.hasDeadline = ctx.Deadline()
+	f.left = time.Until(deadline)
+	ch <- prometheus.MustNewConstMetric(f.desc, prometheus.GaugeValue, 1)
+}
+
+// TestMetricsHandler_ScrapeTimeout verifies the scrape collector runs under
+// the scrape timeout Prometheus sends less the offset, and without a deadline
+// when the header is absent or unusable.
+func TestMetricsHandler_ScrapeTimeout(t *testing.T) {
+	t.Parallel()
+	const offset = 500 * time.Millisecond
+
+	tests := []struct {
+		name         string
+		header       string
+		wantDeadline bool
+		wantLeft     time.Duration
+	}{
+		{name: "no header", header: ""},
+		{name: "timeout less offset", header: "10", wantDeadline: true, wantLeft: 9500 * time.Millisecond},
+		{name: "fractional timeout", header: "2.5", wantDeadline: true, wantLeft: 2 * time.Second},
+		{name: "timeout within the offset is used as is", header: "0.3", wantDeadline: true, wantLeft: 300 * time.Millisecond},
+		{name: "invalid header ignored", header: "soon"},
+		{name: "zero ignored", header: "0"},
+		{name: "negative ignored", header: "-5"},
+		{name: "infinite ignored", header: "+Inf"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			scrape := &fakeScrape{desc: prometheus.NewDesc("scrape_test_gauge", "test", nil, nil)}
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tc.header != "" {
+				req.Header.Set(scrapeTimeoutHeader, tc.header)
+			}
+
+			MetricsHandler(prometheus.NewRegistry(), promhttp.HandlerOpts{}, "tdarr.lan", scrape, offset).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+			if !strings.Contains(rec.Body.String(), "scrape_test_gauge 1") {
+				t.Errorf("body missing the scrape collector's gauge:\n%s", rec.Body.String())
+			}
+			if scrape.hasDeadline != tc.wantDeadline {
+				t.Fatalf("deadline set = %v, want %v", scrape.hasDeadline, tc.wantDeadline)
+			}
+			// Allow for the time the request took to reach the collector.
+			if tc.wantDeadline && (scrape.left > tc.wantLeft || scrape.left < tc.wantLeft-time.Second) {
+				t.Errorf("time left = %v, want about %v", scrape.left, tc.wantLeft)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/homeylab/tdarr-exporter/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+)
+
+// ProbeGatherers resolves a /probe target and module to the gatherer holding
+// that target's own metrics (its HTTP client series) and the collector that
+// scrapes it. collector.TdarrProbePool is the production implementation; an
+// error means the request itself was bad (unparseable target, unknown module)
+// and is answered with 400.
+type ProbeGatherers interface {
+	Gatherer(target, module string) (prometheus.Gatherer, collector.ScrapeCollector, error)
+}
+
+// ProbeHandler serves blackbox-style multi-target scrapes:
+// GET /probe?target=<url>&module=<name>. The target's collector is looked up
+// (or built) per request and gathered through promhttp with the same opts as
+// the primary metrics endpoint. Like MetricsHandler, the collector runs under
+// the request's context with the scrape timeout less timeoutOffset as its
+// deadline.
+func ProbeHandler(probes ProbeGatherers, opts promhttp.HandlerOpts, timeoutOffset time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		target := query.Get("target")
+		if target == "" {
+			http.Error(w, "target parameter is missing", http.StatusBadRequest)
+			return
+		}
+		gatherer, scrape, err := probes.Gatherer(target, query.Get("module"))
+		if err != nil {
+			log.Warn().Err(err).Str("target", target).Msg("Rejected probe request")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx := r.Context()
+		if timeout, ok := scrapeTimeout(r, timeoutOffset); ok {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		scrapeReg := prometheus.NewRegistry()
+		scrapeReg.MustRegister(scrapeFunc{ctx: ctx, scrape: scrape})
+		promhttp.HandlerFor(prometheus.Gatherers{gatherer, scrapeReg}, opts).ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/homeylab/tdarr-exporter/internal/collector"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// fakeProbes records the (target, module) it was asked for and returns a fixed
+// gatherer and scrape collector, or error.
+type fakeProbes struct {
+	gatherer prometheus.Gatherer
+	scrape   collector.ScrapeCollector
+	err      error
+	target   string
+	module   string
+}
+
+func (f *fakeProbes) Gatherer(target, module string) (prometheus.Gatherer, collector.ScrapeCollector, error) {
+	f.target, f.module = target, module
+	return f.gatherer, f.scrape, f.err
+}
+
+// TestProbeHandler verifies the /probe request contract: a missing target or a
+// gatherer lookup error is a 400, and a valid request serves the target's
+// gatherer with the target and module passed through verbatim.
+func TestProbeHandler(t *testing.T) {
+	t.Parallel()
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "probe_test_gauge", Help: "test"}))
+	scrape := &fakeScrape{desc: prometheus.NewDesc("scrape_test_gauge", "test", nil, nil)}
+
+	tests := []struct {
+		name         string
+		query        string
+		probes       *fakeProbes
+		wantStatus   int
+		wantContains string
+		wantTarget   string
+		wantModule   string
+	}{
+		{
+			name:         "missing target is 400",
+			query:        "",
+			probes:       &fakeProbes{gatherer: reg},
+			wantStatus:   http.StatusBadRequest,
+			wantContains: "target parameter is missing",
+		},
+		{
+			name:         "lookup error is 400",
+			query:        "?target=tdarr.lan&module=nope",
+			probes:       &fakeProbes{err: errors.New("unknown probe module: \"nope\"")},
+			wantStatus:   http.StatusBadRequest,
+			wantContains: "unknown probe module",
+			wantTarget:   "tdarr.lan",
+			wantModule:   "nope",
+		},
+		{
+			name:         "valid request serves the target gatherer",
+			query:        "?target=http://tdarr.lan:8265&module=tdarr-4k",
+			probes:       &fakeProbes{gatherer: reg, scrape: scrape},
+			wantStatus:   http.StatusOK,
+			wantContains: "probe_test_gauge 0",
+			wantTarget:   "http://tdarr.lan:8265",
+			wantModule:   "tdarr-4k",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/probe"+tc.query, nil)
+
+			ProbeHandler(tc.probes, promhttp.HandlerOpts{}, 0).ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.wantStatus)
+			}
+			if !strings.Contains(rec.Body.String(), tc.wantContains) {
+				t.Errorf("body = %q, want it to contain %q", rec.Body.String(), tc.wantContains)
+			}
+			if tc.probes.target != tc.wantTarget || tc.probes.module != tc.wantModule {
+				t.Errorf("lookup = (%q, %q), want (%q, %q)", tc.probes.target, tc.probes.module, tc.wantTarget, tc.wantModule)
+			}
+		})
+	}
+}
+
+// TestProbeHandler_ScrapeTimeout verifies the target's collector is gathered
+// under the scrape timeout Prometheus sends less the offset, alongside the
+// target's own registry, and without a deadline when the header is absent.
+func TestProbeHandler_ScrapeTimeout(t *testing.T) {
+	t.Parallel()
+	const offset = 500 * time.Millisecond
+
+	tests := []struct {
+		name         string
+		header       string
+		wantDeadline bool
+		wantLeft     time.Duration
+	}{
+		{name: "no header", header: ""},
+		{name: "timeout less offset", header: "10", wantDeadline: true, wantLeft: 9500 * time.Millisecond},
+		{name: "invalid header ignored", header: "soon"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			reg := prometheus.NewRegistry()
+			reg.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "probe_test_gauge", Help: "test"}))
+			scrape := &fakeScrape{desc: prometheus.NewDesc("scrape_test_gauge", "test", nil, nil)}
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/probe?target=tdarr.lan", nil)
+			if tc.header != "" {
+				req.Header.Set(scrapeTimeoutHeader, tc.header)
+			}
+
+			ProbeHandler(&fakeProbes{gatherer: reg, scrape: scrape}, promhttp.HandlerOpts{}, offset).ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want 200", rec.Code)
+			}
+			for _, want := range []string{"probe_test_gauge 0", "scrape_test_gauge 1"} {
+				if !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("body missing %q:\n%s", want, rec.Body.String())
+				}
+			}
+			if scrape.hasDeadline != tc.wantDeadline {
+				t.Fatalf("deadline set = %v, want %v", scrape.hasDeadline, tc.wantDeadline)
+			}
+			if tc.wantDeadline && (scrape.left > tc.wantLeft || scrape.left < tc.wantLeft-time.Second) {
+				t.Errorf("time left = %v, want about %v", scrape.left, tc.wantLeft)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ReloadHandler serves POST /-/reload (the Prometheus convention) by running
+// reload, the same function SIGHUP triggers. A failed reload leaves the
+// previous configuration running and is answered with 500 and the validation
+// error, so an operator pushing a bad edit sees why it was rejected.
+func ReloadHandler(reload func() error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := reload(); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(InternalHealth{Status: "error", Error: err.Error()})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(InternalHealth{Status: "ok"})
+	})
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestReloadHandler verifies a successful reload answers 200/"ok" and a failed
+// one answers 500 with the error in the body.
+func TestReloadHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		want       InternalHealth
+	}{
+		{name: "success", wantStatus: http.StatusOK, want: InternalHealth{Status: "ok"}},
+		{
+			name:       "failure",
+			err:        errors.New("prometheus_port must be an integer"),
+			wantStatus: http.StatusInternalServerError,
+			want:       InternalHealth{Status: "error", Error: "prometheus_port must be an integer"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			calls := 0
+			h := ReloadHandler(func() error { calls++; return tc.err })
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/-/reload", nil))
+
+			if calls != 1 {
+				t.Errorf("reload called %d times, want 1", calls)
+			}
+			if rec.Code != tc.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tc.wantStatus)
+			}
+			var got InternalHealth
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("body = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/homeylab/tdarr-exporter/internal/collector"
 	"github.com/homeylab/tdarr-exporter/internal/handlers"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -21,19 +22,39 @@ type HttpServerConfig struct {
 	PrometheusPort  string
 	PrometheusPath  string
 	GracefulTimeout time.Duration
+	// Scrape is gathered on PrometheusPath with the deadline of each scrape,
+	// its timeout less ScrapeTimeoutOffset; nil serves the registry alone.
+	Scrape              collector.ScrapeCollector
+	ScrapeTimeoutOffset time.Duration
+	// Probes serves the multi-target /probe route; nil leaves it unregistered.
+	Probes handlers.ProbeGatherers
+	// Reload serves POST /-/reload; nil leaves it unregistered.
+	Reload func() error
+	// DebugCache serves GET /debug/cache; nil leaves it unregistered.
+	DebugCache func() any
 }
 
 // newMux builds the exporter's HTTP handler: the metrics/index/healthz routes,
-// the catch-all 404, wrapped in the Recovery + RequestLogger middleware. Shared
+// the optional /probe, /-/reload and /debug/cache routes, the catch-all 404, wrapped in the Recovery + RequestLogger middleware. Shared
 // by ServeHttp and the server tests so the real routing/middleware stack is what
 // gets exercised.
 func newMux(runConfig HttpServerConfig, registry *prometheus.Registry) http.Handler {
 	mux := http.NewServeMux()
-	mux.Handle("GET "+runConfig.PrometheusPath, handlers.MetricsHandler(registry, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError}, runConfig.TdarrInstance))
+	opts := promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError}
+	mux.Handle("GET "+runConfig.PrometheusPath, handlers.MetricsHandler(registry, opts, runConfig.TdarrInstance, runConfig.Scrape, runConfig.ScrapeTimeoutOffset))
 	// These hardcoded routes are the "reserved" set that config.go rejects for
 	// prometheus_path; keep the two in sync.
 	mux.Handle("GET /{$}", handlers.IndexHandler(runConfig.PrometheusPath))
 	mux.Handle("GET /healthz", handlers.HealthzHandler())
+	if runConfig.Probes != nil {
+		mux.Handle("GET /probe", handlers.ProbeHandler(runConfig.Probes, opts, runConfig.ScrapeTimeoutOffset))
+	}
+	if runConfig.Reload != nil {
+		mux.Handle("POST /-/reload", handlers.ReloadHandler(runConfig.Reload))
+	}
+	if runConfig.DebugCache != nil {
+		mux.Handle("GET /debug/cache", handlers.DebugCacheHandler(runConfig.DebugCache))
+	}
 	// Fallback for everything else (gin's old NoRoute behavior).
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		log.Warn().
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/homeylab/tdarr-exporter/internal/collector"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -535,3 +537,107 @@ func TestMetricsHandler_ContinueOnError_Serves200OnGatherError(t *testing.T) {
 		t.Fatalf("status = %d, want 200 (ContinueOnError must not 500 on a Gather error)", rec.Code)
 	}
 }
+
+// stubProbes is a handlers.ProbeGatherers that serves one registry for every target.
+type stubProbes struct{ reg *prometheus.Registry }
+
+func (s stubProbes) Gatherer(target, module string) (prometheus.Gatherer, collector.ScrapeCollector, error) {
+	return s.reg, stubScrape{}, nil
+}
+
+// stubScrape is a collector.ScrapeCollector that emits nothing.
+type stubScrape struct{}
+
+func (stubScrape) CollectContext(context.Context, chan<- prometheus.Metric) {}
+
+// TestProbeRouteRegisteredOnlyWhenEnabled verifies /probe is served when a probe
+// pool is configured and otherwise falls through to the catch-all 404.
+func TestProbeRouteRegisteredOnlyWhenEnabled(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		probes     bool
+		wantStatus int
+	}{
+		{"enabled serves probe", true, http.StatusOK},
+		{"disabled falls through to 404", false, http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			cfg := HttpServerConfig{TdarrInstance: "probe", PrometheusPath: "/metrics"}
+			if tc.probes {
+				cfg.Probes = stubProbes{reg: prometheus.NewRegistry()}
+			}
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/probe?target=tdarr.lan", nil)
+
+			newMux(cfg, prometheus.NewRegistry()).ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.wantStatus)
+			}
+		})
+	}
+}
+
+// TestReloadRouteRegisteredOnlyWhenEnabled verifies POST /-/reload runs the
+// configured reload func and is absent without one. A GET never reloads; like
+// any other unmatched method+path it lands on the catch-all 404.
+func TestReloadRouteRegisteredOnlyWhenEnabled(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		reload     bool
+		method     string
+		wantStatus int
+		wantCalls  int
+	}{
+		{"enabled reloads on POST", true, http.MethodPost, http.StatusOK, 1},
+		{"enabled ignores GET", true, http.MethodGet, http.StatusNotFound, 0},
+		{"disabled falls through to 404", false, http.MethodPost, http.StatusNotFound, 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			calls := 0
+			cfg := HttpServerConfig{TdarrInstance: "reload", PrometheusPath: "/metrics"}
+			if tc.reload {
+				cfg.Reload = func() error { calls++; return nil }
+			}
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, "/-/reload", nil)
+
+			newMux(cfg, prometheus.NewRegistry()).ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.wantStatus)
+			}
+			if calls != tc.wantCalls {
+				t.Errorf("reload calls = %d, want %d", calls, tc.wantCalls)
+			}
+		})
+	}
+}
+
+// TestDebugCacheRouteRegisteredOnlyWhenSet verifies GET /debug/cache serves
+// the configured view and is absent without one.
+func TestDebugCacheRouteRegisteredOnlyWhenSet(t *testing.T) {
+	t.Parallel()
+
+	for _, set := range []bool{true, false} {
+		cfg := HttpServerConfig{TdarrInstance: "debug", PrometheusPath: "/metrics"}
+		wantStatus := http.StatusNotFound
+		if set {
+			cfg.DebugCache = func() any { return []string{} }
+			wantStatus = http.StatusOK
+		}
+		rec := httptest.NewRecorder()
+		newMux(cfg, prometheus.NewRegistry()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/cache", nil))
+		if rec.Code != wantStatus {
+			t.Errorf("set=%v: status = %d, want %d", set, rec.Code, wantStatus)
+		}
+	}
+}